@@ -0,0 +1,84 @@
+// inilint checks a Data/INI tree for problems that make replay output come
+// out blank or wrong: duplicate definitions, buildable objects without a cost
+// or an object type, references to undefined names and missing Zulu colors.
+//
+//	go run ./cmd/inilint -dir ./inizh/Data/INI
+//	go run ./cmd/inilint ./inizh/Data/INI
+//
+// It exits 1 when any error is found (or any finding at all with -strict),
+// so it can gate a mod data release.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bill-rich/cncstats/pkg/iniparse"
+)
+
+func main() {
+	dir := flag.String("dir", os.Getenv("CNC_INI"), "Path to CNC INI data directory (defaults to $CNC_INI)")
+	asJSON := flag.Bool("json", false, "Print findings as a JSON array")
+	strict := flag.Bool("strict", false, "Exit non-zero on warnings too")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: inilint [flags] [dir]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dirSet := false
+	flag.Visit(func(f *flag.Flag) { dirSet = dirSet || f.Name == "dir" })
+	switch {
+	case flag.NArg() > 1:
+		fmt.Fprintf(os.Stderr, "Expected at most one directory, got %d\n", flag.NArg())
+		flag.Usage()
+		os.Exit(2)
+	case flag.NArg() == 1 && dirSet:
+		fmt.Fprintln(os.Stderr, "Pass the directory with -dir or as an argument, not both")
+		flag.Usage()
+		os.Exit(2)
+	case flag.NArg() == 1:
+		*dir = flag.Arg(0)
+	}
+	if *dir == "" {
+		*dir = "./inizh/Data/INI"
+	}
+
+	findings, err := iniparse.Lint(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to lint %s: %v\n", *dir, err)
+		os.Exit(2)
+	}
+
+	errors, warnings := 0, 0
+	for _, f := range findings {
+		if f.Severity == iniparse.SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+
+	if *asJSON {
+		if findings == nil {
+			findings = []iniparse.Finding{}
+		}
+		out, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to marshal findings: %v\n", err)
+			os.Exit(2)
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		fmt.Fprintf(os.Stderr, "%d error(s), %d warning(s)\n", errors, warnings)
+	}
+
+	if errors > 0 || (*strict && warnings > 0) {
+		os.Exit(1)
+	}
+}
@@ -25,7 +25,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Stores one asset that makes up a map, keyed by X-Map-CRC. Supported X-Map-File values: \"map\", \"preview\", \"ini\", \"str\", \"solo\", \"assets\", \"readme\". Identical CRCs overwrite silently.",
+                "description": "Stores one asset that makes up a map, keyed by X-Map-CRC. Supported X-Map-File values: \"map\", \"preview\", \"ini\", \"str\", \"solo\", \"assets\", \"readme\". A \"map\" upload is rejected with 422 unless its bytes hash to X-Map-CRC under the game's map CRC, and a \"preview\" unless its header is a true-color TGA of at most 2048 pixels a side; other sidecars are stored as sent. Assets are replaced atomically; identical CRCs overwrite silently, except that a verified \"map\" is only replaced by the same bytes: different bytes that hash to the same CRC are refused with 409. Large assets can be sent in chunks with X-Upload-Offset and X-Upload-Total: each chunk must start where the previous one ended (409 returns the offset to resume from), and the asset is only stored once the last chunk arrives. When a manifest was posted to /map_manifest, sizes that disagree with it are rejected with 422.",
                 "consumes": [
                     "application/octet-stream"
                 ],
@@ -62,6 +62,18 @@ const docTemplate = `{
                         "description": "Game seed for telemetry correlation",
                         "name": "X-Game-Seed",
                         "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Chunked upload: byte offset of this chunk",
+                        "name": "X-Upload-Offset",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Chunked upload: full size of the asset",
+                        "name": "X-Upload-Total",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -84,6 +96,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -93,7 +123,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/get_logs": {
+        "/admin/identity_audit": {
             "get": {
                 "security": [
                     {
@@ -103,34 +133,30 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns a zip archive of every log file stored for the given match seed, with entries named \"\u003cplayer\u003e/\u003cfilename\u003e\". 404 if no logs are stored for that seed.",
+                "description": "Returns every change to player identities, oldest first: links the evidence made and undid when an address or client turned out shared (actor \"auto\", with the kind of evidence as reason) and admin merges and splits (actor is the calling operator). With player, only the events naming one of that player's nicknames. Needs an operator key from CNC_ADMIN_KEYS.",
                 "produces": [
-                    "application/zip"
+                    "application/json"
                 ],
                 "tags": [
-                    "logs"
+                    "players"
                 ],
-                "summary": "Download all match logs as a zip",
+                "summary": "Identity audit trail",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Game seed identifying the match",
-                        "name": "seed",
-                        "in": "query",
-                        "required": true
+                        "description": "Player nickname or ID",
+                        "name": "player",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Zip archive (application/zip)",
-                        "schema": {
-                            "type": "file"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/main.ErrorResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/identity.Event"
+                            }
                         }
                     },
                     "401": {
@@ -154,30 +180,43 @@ const docTemplate = `{
                 }
             }
         },
-        "/get_map": {
-            "get": {
-                "description": "Returns a zip archive whose entries are the .map file and (if available) the .tga preview, named after the original map basename. Suitable for direct extraction into a Generals Maps/ subdirectory.",
+        "/admin/merge_players": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Record that two players, each named by a nickname or ID, are one person, whatever the evidence says. Splits between their nicknames are dropped. The merged player keeps the older ID. Returns the merged player. The decision is written to the audit trail with the calling operator. Needs an operator key from CNC_ADMIN_KEYS.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "application/zip"
+                    "application/json"
                 ],
                 "tags": [
-                    "maps"
+                    "players"
                 ],
-                "summary": "Download the map and preview as a zip",
+                "summary": "Merge two players",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Map CRC (decimal)",
-                        "name": "crc",
-                        "in": "query",
-                        "required": true
+                        "description": "Players to merge",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.MergePlayersRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Zip archive (application/zip)",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/identity.Player"
                         }
                     },
                     "400": {
@@ -186,12 +225,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -201,53 +252,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/get_map_file": {
-            "get": {
-                "description": "Returns raw bytes for one stored asset kind. 404 if either the CRC isn't known or that kind wasn't uploaded.",
-                "produces": [
-                    "application/octet-stream"
-                ],
-                "tags": [
-                    "maps"
-                ],
-                "summary": "Download a single map asset",
-                "parameters": [
+        "/admin/reload": {
+            "post": {
+                "security": [
                     {
-                        "type": "string",
-                        "description": "Map CRC (decimal)",
-                        "name": "crc",
-                        "in": "query",
-                        "required": true
+                        "BearerAuth": []
                     },
                     {
-                        "type": "string",
-                        "description": "Asset kind: \\",
-                        "name": "kind",
-                        "in": "query",
-                        "required": true
+                        "ApiKeyAuth": []
                     }
                 ],
+                "description": "Re-parses the configured INI data directory (-objdata / CNC_INI) and, if the result validates, swaps it in without a restart. In-flight parses finish on the previous data set. On failure the previous data set stays in service and 422 is returned. Sending SIGHUP to the process does the same reload. Under -no-stores nothing is loaded and 409 is returned. Needs an operator key from CNC_ADMIN_KEYS.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reload INI data",
                 "responses": {
                     "200": {
-                        "description": "Raw asset bytes (application/octet-stream)",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/main.StatusResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
@@ -255,43 +298,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/list_map_assets": {
+        "/admin/reprocess": {
             "get": {
-                "description": "Returns JSON: {\"crc\": \"...\", \"name\": \"...\", \"kinds\": [\"map\",\"preview\",\"ini\",...]}. Empty kinds array if the CRC is unknown.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the running or last finished reprocessing job's report: its state (running, done, failed or canceled), how many of the stored replays it has parsed, how many failed and why, and each match whose record changed (added when the match database had no record of it) with what changed: win method, or a slot's result, side, faction or spending. fields counts the changes by field. 404 before the first job. Needs an operator key from CNC_ADMIN_KEYS.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "maps"
-                ],
-                "summary": "List stored asset kinds for a CRC",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Map CRC (decimal)",
-                        "name": "crc",
-                        "in": "query",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Reprocessing job status",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/reprocess.Report"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     }
                 }
-            }
-        },
-        "/logs": {
+            },
             "post": {
                 "security": [
                     {
@@ -301,52 +346,19 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Store one or more client log files for a match, keyed by game seed and grouped by player. Send a multipart/form-data body with one or more file parts (any field names); files are expected to be gzip-compressed by the client. Call once per player. The total request body is capped at 64 MiB.",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "Starts a background job that parses every stored replay again with the current parser and INI data set, merging the stats stored for each match, and updates the match database, as /reparse does for one match. Poll GET /admin/reprocess for its progress and report, and DELETE it to stop the job. Only one job runs at a time; 409 while one is running. Each match's replay is the one its record was built from, or the first uploaded for matches the database has no record of. The same job runs from the command line as \"cncstats reprocess\". Needs an operator key from CNC_ADMIN_KEYS.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "logs"
-                ],
-                "summary": "Upload match log files for a player",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Game seed identifying the match",
-                        "name": "X-Game-Seed",
-                        "in": "header",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Player identifier the logs belong to",
-                        "name": "X-Player",
-                        "in": "header",
-                        "required": true
-                    },
-                    {
-                        "type": "file",
-                        "description": "One or more log files (gzip-compressed)",
-                        "name": "files",
-                        "in": "formData",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Parse every stored replay again",
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/main.ErrorResponse"
+                            "$ref": "#/definitions/reprocess.Report"
                         }
                     },
                     "401": {
@@ -355,49 +367,46 @@ const docTemplate = `{
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
-                    "413": {
-                        "description": "Request Entity Too Large",
-                        "schema": {
-                            "$ref": "#/definitions/main.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     }
                 }
-            }
-        },
-        "/map_exists": {
-            "get": {
-                "description": "Returns plain-text \"true\" if a .map file is already stored under MAPS_DIR for the given crc, \"false\" otherwise. Used by the Generals client right after a game ends to decide whether to upload its played map.",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stops the running reprocessing job after the replay it is parsing. The job's state becomes canceled; GET /admin/reprocess reports how far it got. 409 when no job is running. Needs an operator key from CNC_ADMIN_KEYS.",
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "maps"
-                ],
-                "summary": "Check whether a map exists on the server",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Map CRC (decimal, as emitted by MapMetaData::m_CRC)",
-                        "name": "crc",
-                        "in": "query",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Cancel the reprocessing job",
                 "responses": {
-                    "200": {
-                        "description": "\\\"true\\\" or \\\"false\\",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/reprocess.Report"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
@@ -405,7 +414,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/replay": {
+        "/admin/split_player": {
             "post": {
                 "security": [
                     {
@@ -415,31 +424,33 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Upload a .rep replay file and receive parsed replay data in v2 format. Stats fields are populated when a matching stats file exists.",
+                "description": "Record that a nickname belongs to a different person from every other nickname currently linked to it, whatever the evidence says. Merges naming the nickname are dropped. Returns the nickname's player after the split. The decision is written to the audit trail with the calling operator. Needs an operator key from CNC_ADMIN_KEYS.",
                 "consumes": [
-                    "multipart/form-data"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "replay"
+                    "players"
                 ],
-                "summary": "Parse a replay file",
+                "summary": "Split a nickname off its player",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "Replay file to parse",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
+                        "description": "Nickname to split off",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.SplitPlayerRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/zhreplay.EnhancedReplayV2"
+                            "$ref": "#/definitions/identity.Player"
                         }
                     },
                     "400": {
@@ -454,17 +465,87 @@ const docTemplate = `{
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
-                    }
-                }
-            }
-        },
-        "/stats": {
-            "post": {
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/balance": {
+            "get": {
+                "description": "Aggregates recorded matches into faction-versus-faction win rates for 1v1 games and for team games (each faction pairing across opposing teams, once per match), win rates per map by start position and by side, and game length statistics with a histogram of 5-minute buckets, overall and per map. Win rates come with a 95% Wilson score interval (low, high) and mean lengths with a 95% confidence interval. Games with AI players, games whose winner was guessed from the last command and games without two teams that finished differently are left out and counted in skipped. Random start positions aren't recorded in replays, so they are left out of the start position rates.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Faction and map balance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game version from the replay header, e.g. \\",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date played, YYYY-MM-DD or RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last date played (inclusive), YYYY-MM-DD, or an RFC 3339 time (exclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map name without its directory, case-insensitive",
+                        "name": "map",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/balance.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/get_logs": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
@@ -473,31 +554,40 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Receive gzip-compressed JSON stats from a Generals game and store them keyed by seed.",
-                "consumes": [
-                    "application/octet-stream"
-                ],
+                "description": "Returns a zip archive of every log file stored for the given match, with entries named \"\u003cplayer\u003e/\u003cfilename\u003e\". 404 if no logs are stored for that match. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.",
                 "produces": [
-                    "application/json"
+                    "application/zip"
                 ],
                 "tags": [
-                    "stats"
+                    "logs"
                 ],
-                "summary": "Upload game stats",
+                "summary": "Download all match logs as a zip",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Game seed identifier",
-                        "name": "X-Game-Seed",
-                        "in": "header",
+                        "description": "Game seed identifying the match",
+                        "name": "seed",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "mapCrc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time (Unix seconds)",
+                        "name": "start",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Zip archive (application/zip)",
                         "schema": {
-                            "$ref": "#/definitions/main.StatsUploadResponse"
+                            "type": "file"
                         }
                     },
                     "400": {
@@ -512,6 +602,119 @@ const docTemplate = `{
                             "$ref": "#/definitions/main.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchConflictResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/get_map": {
+            "get": {
+                "description": "Returns a zip archive whose entries are the .map file and (if available) the .tga preview, named after the original map basename. Suitable for direct extraction into a Generals Maps/ subdirectory.",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Download the map and preview as a zip",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "crc",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Zip archive (application/zip)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/get_map_file": {
+            "get": {
+                "description": "Returns raw bytes for one stored asset kind. 404 if either the CRC isn't known or that kind wasn't uploaded.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Download a single map asset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "crc",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Asset kind: \\",
+                        "name": "kind",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Raw asset bytes (application/octet-stream)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -520,302 +723,3463 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "body.ArgMetadata": {
+        },
+        "/get_replay": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Return a replay file stored for a match, as it was uploaded: the one with the given hash (see /replay_uploads), or else the first uploaded. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Download a stored replay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifier",
+                        "name": "seed",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "mapCrc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time (Unix seconds)",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Content hash of the replay",
+                        "name": "hash",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Replay file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchConflictResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a parse job's status: queued (with how many jobs are ahead of it), running, done (with result, where to fetch the parsed replay) or failed (with error). Jobs are known only to the server that took them and are forgotten an hour after they finish.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Parse job status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Status"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/result": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the parsed replay of a done parse job, as POST /replay would have. 409 with the job's status until it is done, or if it failed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Parse job result",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/zhreplay.EnhancedReplayV2"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Status"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/list_map_assets": {
+            "get": {
+                "description": "Returns JSON: {\"crc\": \"...\", \"name\": \"...\", \"kinds\": [\"map\",\"preview\",\"ini\",...], \"verification\": {...}}. Empty kinds array if the CRC is unknown; verification is null for maps stored before server-side CRC checks.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "List stored asset kinds for a CRC",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "crc",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/logs": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Store one or more client log files for a match, keyed by match (the game seed plus, when sent, the map CRC and start time) and grouped by player. Send a multipart/form-data body with one or more file parts (any field names); files are expected to be gzip-compressed by the client. Call once per player. The total request body is capped at 64 MiB.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "logs"
+                ],
+                "summary": "Upload match log files for a player",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifying the match",
+                        "name": "X-Game-Seed",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "X-Map-CRC",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time in Unix seconds",
+                        "name": "X-Game-Start",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Player identifier the logs belong to",
+                        "name": "X-Player",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "One or more log files (gzip-compressed)",
+                        "name": "files",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/map_exists": {
+            "get": {
+                "description": "Returns plain-text \"true\" if a .map file is already stored under MAPS_DIR for the given crc (and, when a manifest was posted, every asset it lists), \"false\" otherwise. Used by the Generals client right after a game ends to decide whether to upload its played map.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Check whether a map exists on the server",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal, as emitted by MapMetaData::m_CRC)",
+                        "name": "crc",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "\\\"true\\\" or \\\"false\\",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/map_info": {
+            "get": {
+                "description": "Returns the map's catalog entry plus its layout from the decoded .map: playable size in world units, start positions, and counts of supply docks, oil derricks and other tech buildings.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Describe a stored map",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "crc",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/mapfile.MapInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/map_manifest": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Records the asset kinds and sizes the client will upload for X-Map-CRC. Until every listed asset is stored at its declared size, /map_exists reports false and the map isn't served, so peers never download a partial set. Must include \"map\". Replaces any earlier manifest, unless the map is already stored and its CRC verified: then the response is 409 with the stored set's status, and the manifest is ignored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Declare a map's assets before uploading them",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "X-Map-CRC",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Expected assets",
+                        "name": "manifest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.MapManifestRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/mapfile.UploadStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/mapfile.UploadStatus"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/map_preview": {
+            "get": {
+                "description": "Decodes the stored preview.tga (uncompressed or RLE, 24/32-bit) and returns it as PNG. With width, the image is scaled to that width keeping its aspect ratio. 404 if no preview was uploaded for the CRC.",
+                "produces": [
+                    "image/png"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Map preview as PNG",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "crc",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Output width in pixels (1-1024); omit for the original size",
+                        "name": "width",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "PNG image (image/png)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/map_upload_status": {
+            "get": {
+                "description": "Returns the manifest (if any), the size of every stored asset, the bytes received for chunked uploads in progress (the offset to resume from), the manifest kinds still missing, and whether the set is complete.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Map upload progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "crc",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/mapfile.UploadStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/maps": {
+            "get": {
+                "description": "Returns the maps the server can distribute, sorted by title: CRC, original name, .map size, player count (start positions), upload time, stored asset kinds and CRC verification. q matches title, name or CRC (every word must match, case-insensitive); players keeps only maps with that many start positions.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "List stored maps",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search words",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Exact number of start positions",
+                        "name": "players",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.MapListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/match_info": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Return what the server recorded when it parsed a match's replay: seed, map, date (from the header's SYSTEMTIME), game version, duration, win method, every player slot with faction, team, color and result, and the keys the match's stats, logs and replay are stored under. 404 until a replay of the match has been parsed. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "matches"
+                ],
+                "summary": "Recorded match details",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifier",
+                        "name": "seed",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "mapCrc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time (Unix seconds)",
+                        "name": "start",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/matchdb.Match"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchConflictResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/matches": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the recorded matches every given filter finds, newest first by default. player finds every nickname linked to the player (see /player_identity); faction, result and used then apply to that player's seat, or to any seat without player. Observers' seats never match. map takes a map CRC or part of the map's name. teamSize is the size of the largest team: 1 for 1v1 and free-for-all games, 2 for 2v2. result is win or loss (both need player), or unknown for matches without two teams that finished differently. used is a unit, building, upgrade or power template name, e.g. AmericaJetRaptor; templates are recorded when a replay is parsed, so matches parsed before have none until their replay is parsed again.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "matches"
+                ],
+                "summary": "Search matches",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player nickname or ID",
+                        "name": "player",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Faction name, e.g. \\",
+                        "name": "faction",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC, or part of the map name",
+                        "name": "map",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Game version from the replay header, e.g. \\",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest date played, YYYY-MM-DD or RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last date played (inclusive), YYYY-MM-DD, or an RFC 3339 time (exclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Shortest duration, seconds",
+                        "name": "minDuration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Longest duration, seconds",
+                        "name": "maxDuration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Players on the largest team",
+                        "name": "teamSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "win, loss or unknown",
+                        "name": "result",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether stats were uploaded",
+                        "name": "hasStats",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether logs were uploaded",
+                        "name": "hasLogs",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unit, building, upgrade or power template name",
+                        "name": "used",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "time (default), duration or map",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "desc (default) or asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchSearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/minimap": {
+            "get": {
+                "description": "Renders the stored .map's terrain as a top-down PNG and draws the match's stats events on it: units and structures built (dots), kills (heatmap, or crosses with heatmap=false) and the map's start positions, in player colors. The map is found by crc, or by the stats file's map name when crc is omitted.",
+                "produces": [
+                    "image/png"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Minimap with stats overlays",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifying the stats file",
+                        "name": "seed",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal) of the match, when several matches share the seed",
+                        "name": "mapCrc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time (Unix seconds), when several matches share the seed",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal); defaults to the stored map named in the stats file",
+                        "name": "crc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "First frame to draw events from (30 frames per second)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Last frame to draw events from; omit for the end of the game",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image width in pixels (default 512, max 2048)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw kills as a heatmap (default true)",
+                        "name": "heatmap",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "PNG image (image/png)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchConflictResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Like GET /minimap, but takes the replay file: structures are drawn where BuildObject placed them (squares), start positions are colored by who started there, and the stats overlays are added when a stats file exists for the replay's seed. The map is found by the replay's map CRC unless crc is given.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "image/png"
+                ],
+                "tags": [
+                    "maps"
+                ],
+                "summary": "Minimap with replay overlays",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Replay file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal); defaults to the replay header's map CRC",
+                        "name": "crc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "First frame to draw events from (30 frames per second)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Last frame to draw events from; omit for the end of the game",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image width in pixels (default 512, max 2048)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Draw kills as a heatmap (default true)",
+                        "name": "heatmap",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "PNG image (image/png)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/player_identity": {
+            "get": {
+                "description": "Returns the player a nickname or player ID belongs to: a stable ID, the nickname they were last seen under and every nickname linked to them, oldest first. Nicknames are linked when seen in enough matches with the same header IP address or uploading logs as X-Player through the same API client, unless they played in one match, or under a different stats DisplayName in one match slot. An address or client seen with many nicknames links none of them. Admins can merge and split players.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Player identity",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player nickname or ID",
+                        "name": "player",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/identity.Player"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/player_matches": {
+            "get": {
+                "description": "Returns the recorded matches a player played under any nickname linked to them, newest first: map, the nickname, side and faction they played, team, result (win, loss, or unknown when no team finished differently), win method, duration, APM, opening, teammates and opponents.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Player match history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player nickname or ID",
+                        "name": "player",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 20, max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PlayerMatchesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/player_profile": {
+            "get": {
+                "description": "Returns a player's record across every recorded match played under any nickname linked to them (see /player_identity): games, wins, losses and win rate overall and by faction, map, teammate and opponent; average game length and APM; their most played build openings (first units, buildings and upgrades ordered) and how they fared; and the APM of their latest matches. A game counts as a win or loss only when the teams finished differently. Built from what the server recorded when each replay was parsed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Player profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player nickname or ID",
+                        "name": "player",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/profile.Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/player_rating": {
+            "get": {
+                "description": "Returns a player's overall Glicko-2 rating and their rating with each faction they played, best first, computed as for /ratings. Games under every nickname linked to the player (see /player_identity) count.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ratings"
+                ],
+                "summary": "Player rating",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player nickname or ID",
+                        "name": "player",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PlayerRatingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ratings": {
+            "get": {
+                "description": "Returns Glicko-2 ratings computed from every recorded match, best first. Players are rated by identity, across every nickname linked to them (see /player_identity); name is the one they last played under. Matches are rated oldest first; each player is rated against every opposing team that finished differently, and deviation grows by one rating period per day without games, up to now. Games with AI players, games whose winner was guessed from the last command (winMethod \"lastCommand\") and games without two teams with different results are left out and counted in skipped. With faction, ranks players' ratings with that faction instead of their overall ratings.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ratings"
+                ],
+                "summary": "Rating leaderboard",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Faction name, e.g. \\",
+                        "name": "faction",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only players with at least this many rated games",
+                        "name": "minGames",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.RatingListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/reparse": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Parse a replay stored for a match with the current parser and INI data, merging the stats stored now, and update the match's record, as if it had just been uploaded to /replay. Use it after a parser fix or a data set reload. Picks the replay with the given hash (see /replay_uploads), or else the first uploaded. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Parse a stored replay again",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifier",
+                        "name": "seed",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "mapCrc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time (Unix seconds)",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Content hash of the replay",
+                        "name": "hash",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language for displayNames (default english)",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated stats sections to include, as for /replay (default all)",
+                        "name": "sections",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/zhreplay.EnhancedReplayV2"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchConflictResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/replay": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upload a .rep replay file and receive parsed replay data in v2 format. Stats fields are populated when a matching stats file exists: the one stored for the header's seed, map CRC and start time, so another game that happened to share the seed isn't picked up. The replay file is kept under its match, for /get_replay and /reparse; identical uploads are kept once. With async=true the replay is queued instead: the response is 202 with the job's status, and its Location header names /jobs/{id}, which reports progress and, once done, where to fetch the result. A full queue answers 503 with Retry-After. Without async the replay waits for a free parse worker, so at most PARSE_WORKERS replays are parsed at once either way. Uploads over 16 MiB are refused with 413.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Parse a replay file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Replay file to parse",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language for displayNames, e.g. english, german (default english; falls back to english per name)",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated stats sections to include: players, buildEvents, killEvents, captureEvents, energyEvents, rankEvents, skillPointsEvents, sciencePointsEvents, radarEvents, deathEvents, battlePlanEvents, timeSeries (default all). players and deathEvents are always included for winner detection.",
+                        "name": "sections",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Queue the replay and return a job instead of waiting for the result",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/zhreplay.EnhancedReplayV2"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Status"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/replay_batch": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upload a zip or tar.gz of .rep replay files, optionally with the .json.gz stats files the exporter wrote beside them, e.g. a tournament's replays collected from every player. Stats files are stored first, under the match their game section names; stats from older exporters without a map CRC and start time take them from the replay of the same name in the archive. Replays are then parsed concurrently and recorded as if uploaded one by one to /replay, merging their stats. The response reports on every file; one file failing doesn't stop the others. Other files are listed as ignored. Replays wait for the same parse workers as other uploads, and one batch takes at most half of them. The archive may be at most 64 MiB and hold at most 2000 files, 16 MiB each and 64 MiB in total.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "Upload an archive of replays",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Zip or tar.gz of replays and stats files",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.BatchUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/replay_uploads": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Return every distinct replay file stored for a match, in arrival order, with its content hash, size, uploaded file name, uploading client and upload time. Each player records their own replay of a match, so there can be several; identical uploads are kept once. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "replay"
+                ],
+                "summary": "List stored replays for a match",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifier",
+                        "name": "seed",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "mapCrc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time (Unix seconds)",
+                        "name": "start",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/replayfile.Upload"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchConflictResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Receive gzip-compressed JSON stats from a Generals game, keyed by match: the seed plus, when known, the map CRC and start time from the payload's game info (or the X-Map-CRC and X-Game-Start headers), so unrelated games that share a seed are stored apart. Start times within 10 minutes of each other are the same match, allowing for the players' clocks to differ. Every player's exporter may upload the same match; each upload is kept as sent and the stored stats are rebuilt as their merge: events are unioned with duplicates removed, each player's longest time series is kept, and game info comes from the upload that ran the most frames. Set force to make this upload replace the earlier ones in the merge (they are still kept). Uploads are validated on arrival: corrupt gzip or JSON is rejected with 400, and payloads with an unsupported version, missing game info, out-of-range or duplicate player indices, events out of frame order or naming unknown players, or time series that don't fit the snapshot interval are rejected with 422 listing each problem. Version 1 payloads are migrated to the current version.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Upload game stats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifier",
+                        "name": "X-Game-Seed",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal), if the payload's game info lacks mapCRC",
+                        "name": "X-Map-CRC",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time in Unix seconds, if the payload's game info lacks startTime",
+                        "name": "X-Game-Start",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Merge only this upload, superseding earlier ones (can also be set via the X-Force header)",
+                        "name": "force",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.StatsUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.StatsValidationResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats_uploads": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Return every stats upload recorded for a match, in arrival order, with the uploading client, size, frame count and whether a later forced upload superseded it. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "List stats uploads for a match",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Game seed identifier",
+                        "name": "seed",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Map CRC (decimal)",
+                        "name": "mapCrc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Game start time (Unix seconds)",
+                        "name": "start",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/statsfile.Contributor"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/main.MatchConflictResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/main.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/status": {
+            "get": {
+                "description": "Returns the INI data set currently in service (directory, content version, load time, store sizes) and the outcome of the most recent reload attempt.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Server status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.StatusResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "balance.Bucket": {
+            "type": "object",
+            "properties": {
+                "fromMinutes": {
+                    "type": "integer"
+                },
+                "games": {
+                    "type": "integer"
+                },
+                "toMinutes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "balance.Lengths": {
+            "type": "object",
+            "properties": {
+                "games": {
+                    "type": "integer"
+                },
+                "high": {
+                    "type": "number"
+                },
+                "histogram": {
+                    "description": "Histogram has LengthBucket-wide buckets from 0 to the longest game.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/balance.Bucket"
+                    }
+                },
+                "low": {
+                    "type": "number"
+                },
+                "mean": {
+                    "type": "number"
+                },
+                "median": {
+                    "type": "number"
+                },
+                "p25": {
+                    "type": "number"
+                },
+                "p75": {
+                    "type": "number"
+                }
+            }
+        },
+        "balance.MapStats": {
+            "type": "object",
+            "properties": {
+                "games": {
+                    "type": "integer"
+                },
+                "length": {
+                    "$ref": "#/definitions/balance.Lengths"
+                },
+                "map": {
+                    "type": "string"
+                },
+                "positions": {
+                    "description": "Positions leaves out random starts, whose position isn't recorded.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/balance.Position"
+                    }
+                },
+                "sides": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/balance.Side"
+                    }
+                }
+            }
+        },
+        "balance.Matchup": {
+            "type": "object",
+            "properties": {
+                "faction": {
+                    "type": "string"
+                },
+                "games": {
+                    "type": "integer"
+                },
+                "high": {
+                    "type": "number"
+                },
+                "low": {
+                    "type": "number"
+                },
+                "opponent": {
+                    "type": "string"
+                },
+                "winRate": {
+                    "type": "number"
+                },
+                "wins": {
+                    "type": "integer"
+                }
+            }
+        },
+        "balance.Position": {
+            "type": "object",
+            "properties": {
+                "games": {
+                    "type": "integer"
+                },
+                "high": {
+                    "type": "number"
+                },
+                "low": {
+                    "type": "number"
+                },
+                "position": {
+                    "type": "integer"
+                },
+                "winRate": {
+                    "type": "number"
+                },
+                "wins": {
+                    "type": "integer"
+                }
+            }
+        },
+        "balance.Report": {
+            "type": "object",
+            "properties": {
+                "length": {
+                    "$ref": "#/definitions/balance.Lengths"
+                },
+                "maps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/balance.MapStats"
+                    }
+                },
+                "matches": {
+                    "description": "Matches is how many matches were counted; Skipped how many the\nfilter picked but were left out for an untrustworthy result.",
+                    "type": "integer"
+                },
+                "oneVsOne": {
+                    "description": "OneVsOne lists 1v1 matchups. Team lists, for games with more than\none player on a team, the factions of players on opposing teams,\neach pairing once per match so every game counted is an independent\ntrial: a 2v2 adds up to four. A pairing found on both the winning\nand the losing side of one match isn't counted for it. Both list\neach matchup from both sides and leave out mirrors.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/balance.Matchup"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "team": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/balance.Matchup"
+                    }
+                }
+            }
+        },
+        "balance.Side": {
+            "type": "object",
+            "properties": {
+                "games": {
+                    "type": "integer"
+                },
+                "high": {
+                    "type": "number"
+                },
+                "low": {
+                    "type": "number"
+                },
+                "side": {
+                    "type": "string"
+                },
+                "winRate": {
+                    "type": "number"
+                },
+                "wins": {
+                    "type": "integer"
+                }
+            }
+        },
+        "body.ArgMetadata": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "integer"
+                }
+            }
+        },
+        "body.BodyChunk": {
+            "type": "object",
+            "properties": {
+                "argMetadata": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/body.ArgMetadata"
+                    }
+                },
+                "arguments": {
+                    "type": "array",
+                    "items": {}
+                },
+                "details": {},
+                "numberOfArguments": {
+                    "type": "integer"
+                },
+                "orderCode": {
+                    "type": "integer"
+                },
+                "orderName": {
+                    "type": "string"
+                },
+                "playerID": {
+                    "description": "Starts at 2 for humans",
+                    "type": "integer"
+                },
+                "playerName": {
+                    "type": "string"
+                },
+                "timeCode": {
+                    "type": "integer"
+                }
+            }
+        },
+        "datastore.Status": {
+            "type": "object",
+            "properties": {
+                "colors": {
+                    "type": "integer"
+                },
+                "dir": {
+                    "type": "string"
+                },
+                "languages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "lastReloadAt": {
+                    "type": "string"
+                },
+                "lastReloadError": {
+                    "type": "string"
+                },
+                "loaded": {
+                    "type": "boolean"
+                },
+                "loadedAt": {
+                    "type": "string"
+                },
+                "objects": {
+                    "type": "integer"
+                },
+                "powers": {
+                    "type": "integer"
+                },
+                "upgrades": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "header.GeneralsHeader": {
+            "type": "object",
+            "properties": {
+                "buildDate": {
+                    "description": "UTF-16 null-terminated (versionTimeString)",
+                    "type": "string"
+                },
+                "day": {
+                    "type": "integer"
+                },
+                "desync": {
+                    "description": "1 byte Bool",
+                    "type": "boolean"
+                },
+                "difficulty": {
+                    "description": "int32 (GameDifficulty enum)",
+                    "type": "integer"
+                },
+                "dow": {
+                    "type": "integer"
+                },
+                "exeCRC": {
+                    "description": "uint32",
+                    "type": "integer"
+                },
+                "frameCount": {
+                    "description": "uint32",
+                    "type": "integer"
+                },
+                "gameType": {
+                    "description": "6 bytes: \"GENREP\"",
+                    "type": "string"
+                },
+                "hour": {
+                    "type": "integer"
+                },
+                "iniCRC": {
+                    "description": "uint32",
+                    "type": "integer"
+                },
+                "localPlayerIndex": {
+                    "description": "ASCII null-terminated int",
+                    "type": "integer"
+                },
+                "maxFPS": {
+                    "description": "int32",
+                    "type": "integer"
+                },
+                "metadata": {
+                    "description": "ASCII null-terminated (gameOptions)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/header.Metadata"
+                        }
+                    ]
+                },
+                "millisecond": {
+                    "type": "integer"
+                },
+                "minute": {
+                    "type": "integer"
+                },
+                "month": {
+                    "type": "integer"
+                },
+                "originalGameMode": {
+                    "description": "int32",
+                    "type": "integer"
+                },
+                "playerDiscons": {
+                    "description": "8 × 1 byte Bool (MAX_SLOTS)",
+                    "type": "array",
+                    "items": {
+                        "type": "boolean"
+                    }
+                },
+                "quitEarly": {
+                    "description": "1 byte Bool",
+                    "type": "boolean"
+                },
+                "rankPoints": {
+                    "description": "int32",
+                    "type": "integer"
+                },
+                "replayName": {
+                    "description": "UTF-16 null-terminated",
+                    "type": "string"
+                },
+                "second": {
+                    "type": "integer"
+                },
+                "timeStampBegin": {
+                    "description": "int32 (replay_time_t)",
+                    "type": "integer"
+                },
+                "timeStampEnd": {
+                    "description": "int32 (replay_time_t)",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "UTF-16 null-terminated",
+                    "type": "string"
+                },
+                "versionNumber": {
+                    "description": "uint32",
+                    "type": "integer"
+                },
+                "year": {
+                    "description": "SYSTEMTIME fields (8 × uint16)",
+                    "type": "integer"
+                }
+            }
+        },
+        "header.Metadata": {
+            "type": "object",
+            "properties": {
+                "crcInterval": {
+                    "description": "C",
+                    "type": "string"
+                },
+                "mapCRC": {
+                    "description": "MC (hex)",
+                    "type": "string"
+                },
+                "mapContentsMask": {
+                    "description": "M prefix (2 hex digits)",
+                    "type": "string"
+                },
+                "mapPath": {
+                    "description": "M remainder (map directory path)",
+                    "type": "string"
+                },
+                "mapSize": {
+                    "description": "MS",
+                    "type": "string"
+                },
+                "oldFactionsOnly": {
+                    "description": "O (ZH only: Y/N)",
+                    "type": "string"
+                },
+                "players": {
+                    "description": "S",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/header.Player"
+                    }
+                },
+                "seed": {
+                    "description": "SD",
+                    "type": "string"
+                },
+                "startingCash": {
+                    "description": "SC (ZH only)",
+                    "type": "string"
+                },
+                "superweaponRestriction": {
+                    "description": "SR (ZH only)",
+                    "type": "string"
+                },
+                "useStats": {
+                    "description": "US (ZH only: 1=yes, 0=no)",
+                    "type": "string"
+                }
+            }
+        },
+        "header.Player": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "description": "Color index (or name if ColorStore provided)",
+                    "type": "string"
+                },
+                "flags": {
+                    "description": "Human: \u003caccepted\u003e\u003chasMap\u003e (e.g. \"TT\"); AI: difficulty (E/M/H)",
+                    "type": "string"
+                },
+                "ip": {
+                    "description": "Human only (hex)",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Human only",
+                    "type": "string"
+                },
+                "natBehavior": {
+                    "description": "Human only: firewall behavior type",
+                    "type": "string"
+                },
+                "playerTemplate": {
+                    "description": "Index into player template store",
+                    "type": "string"
+                },
+                "port": {
+                    "description": "Human only",
+                    "type": "string"
+                },
+                "startingPosition": {
+                    "description": "Start position (-1 = random)",
+                    "type": "string"
+                },
+                "team": {
+                    "description": "Team number (-1 = none)",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "H=human, C=computer",
+                    "type": "string"
+                }
+            }
+        },
+        "identity.Alias": {
+            "type": "object",
+            "properties": {
+                "firstSeen": {
+                    "type": "string"
+                },
+                "lastSeen": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "seq": {
+                    "description": "Seq numbers aliases in the order they were first seen. A player's ID\ncomes from its oldest alias.",
+                    "type": "integer"
+                }
+            }
+        },
+        "identity.Event": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is \"link\" or \"unlink\" for the evidence, \"merge\" or \"split\"\nfor an admin decision.",
+                    "type": "string"
+                },
+                "actor": {
+                    "description": "Actor is the API client of an admin decision, \"auto\" for a link the\nevidence made.",
+                    "type": "string"
+                },
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "identity.Player": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "description": "Aliases are ordered oldest first.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/identity.Alias"
+                    }
+                },
+                "id": {
+                    "description": "ID is \"p\" and the Seq of the player's oldest alias. It stays the\nsame as new aliases are linked, but a merge keeps only the older\nplayer's ID.",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name is the alias the player was most recently seen under.",
+                    "type": "string"
+                }
+            }
+        },
+        "jobs.Status": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is why a failed job failed.",
+                    "type": "string"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "position": {
+                    "description": "Position is how many jobs are ahead of a queued job, 0 once it runs.",
+                    "type": "integer"
+                },
+                "result": {
+                    "description": "Result is where a done job's result can be fetched.",
+                    "type": "string"
+                },
+                "started": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "submitted": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.BatchFileResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error is why the file wasn't stored.",
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "replay"
+                },
+                "match": {
+                    "description": "Match is the identity the file was stored under; see pkg/matchid.",
+                    "type": "string",
+                    "example": "12345-3862104857-1718000000"
+                },
+                "name": {
+                    "description": "Name is the file's path in the archive.",
+                    "type": "string",
+                    "example": "Replays/Final.rep"
+                }
+            }
+        },
+        "main.BatchUploadResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.BatchFileResult"
+                    }
+                },
+                "ignored": {
+                    "description": "Ignored lists the archive's files that are neither replays nor stats.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "replays": {
+                    "description": "Replays and Stats count the files stored, Failed those that weren't.",
+                    "type": "integer",
+                    "example": 12
+                },
+                "stats": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "main.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "string",
+                    "example": "underlying error message"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Something went wrong"
+                }
+            }
+        },
+        "main.MapListResponse": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "maps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/mapfile.CatalogEntry"
+                    }
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.MapManifestRequest": {
+            "type": "object",
+            "properties": {
+                "assets": {
+                    "description": "Assets maps asset kind (\"map\", \"preview\", ...) to size in bytes.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.MatchConflictResponse": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/matchid.ID"
+                    }
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Several matches were played with this seed; pass mapCrc or start to choose one"
+                }
+            }
+        },
+        "main.MatchSearchResponse": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "matches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/matchdb.Match"
+                    }
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.MergePlayersRequest": {
+            "type": "object",
+            "required": [
+                "into",
+                "player"
+            ],
+            "properties": {
+                "into": {
+                    "type": "string"
+                },
+                "player": {
+                    "description": "Player and Into are nicknames or player IDs.",
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PlayerMatchesResponse": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "matches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/profile.Appearance"
+                    }
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.PlayerRatingResponse": {
+            "type": "object",
+            "properties": {
+                "faction": {
+                    "description": "Faction is empty on a player's overall entry.",
+                    "type": "string"
+                },
+                "factions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rating.Entry"
+                    }
+                },
+                "games": {
+                    "type": "integer"
+                },
+                "lastPlayed": {
+                    "type": "string"
+                },
+                "losses": {
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Name is the name the player last played under.",
+                    "type": "string"
+                },
+                "player": {
+                    "description": "Player is the player's identity ID, or their name when ratings are\ncomputed without identities.",
+                    "type": "string"
+                },
+                "rating": {
+                    "$ref": "#/definitions/rating.Rating"
+                },
+                "wins": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.RatingListResponse": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "rated": {
+                    "type": "integer"
+                },
+                "ratings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rating.Entry"
+                    }
+                },
+                "skipped": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.SplitPlayerRequest": {
+            "type": "object",
+            "required": [
+                "player"
+            ],
+            "properties": {
+                "player": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.StatsUploadResponse": {
+            "type": "object",
+            "properties": {
+                "contributors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/statsfile.Contributor"
+                    }
+                },
+                "frameCount": {
+                    "description": "FrameCount is the merged stats' frame count.",
+                    "type": "integer",
+                    "example": 54000
+                },
+                "match": {
+                    "description": "Match is the identity the stats are stored under; see pkg/matchid.",
+                    "type": "string",
+                    "example": "12345-3862104857-1718000000"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Stats stored successfully"
+                },
+                "seed": {
+                    "type": "string",
+                    "example": "12345"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 8192
+                },
+                "uploads": {
+                    "description": "Uploads is how many uploads the match has had, this one included.",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "main.StatsValidationResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "Stats upload failed validation"
+                },
+                "validation": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/statsfile.ValidationError"
+                    }
+                }
+            }
+        },
+        "main.StatusResponse": {
+            "type": "object",
+            "properties": {
+                "dataSet": {
+                    "$ref": "#/definitions/datastore.Status"
+                }
+            }
+        },
+        "mapfile.CatalogEntry": {
+            "type": "object",
+            "properties": {
+                "complete": {
+                    "description": "Complete is false while assets listed in the upload manifest are\nstill missing; see Exists.",
+                    "type": "boolean"
+                },
+                "crc": {
+                    "type": "string"
+                },
+                "kinds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "description": "Name is the original X-Map-Name; Title its BaseName (\"Tournament\nDesert\"), which is what players know the map by.",
+                    "type": "string"
+                },
+                "players": {
+                    "description": "Players is the number of start positions, or 0 if the .map couldn't\nbe decoded.",
+                    "type": "integer"
+                },
+                "size": {
+                    "description": "Size is the .map file size in bytes.",
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "uploadedAt": {
+                    "description": "UploadedAt is when the .map was last written.",
+                    "type": "string"
+                },
+                "verified": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "mapfile.Manifest": {
+            "type": "object",
+            "properties": {
+                "assets": {
+                    "description": "Assets maps asset kind to size in bytes. It must include KindMap.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "mapfile.MapInfo": {
+            "type": "object",
+            "properties": {
+                "complete": {
+                    "description": "Complete is false while assets listed in the upload manifest are\nstill missing; see Exists.",
+                    "type": "boolean"
+                },
+                "crc": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "number"
+                },
+                "kinds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "description": "Name is the original X-Map-Name; Title its BaseName (\"Tournament\nDesert\"), which is what players know the map by.",
+                    "type": "string"
+                },
+                "oilDerricks": {
+                    "type": "integer"
+                },
+                "players": {
+                    "description": "Players is the number of start positions, or 0 if the .map couldn't\nbe decoded.",
+                    "type": "integer"
+                },
+                "size": {
+                    "description": "Size is the .map file size in bytes.",
+                    "type": "integer"
+                },
+                "startPositions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/mapparse.StartPosition"
+                    }
+                },
+                "supplyDocks": {
+                    "type": "integer"
+                },
+                "techBuildings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "uploadedAt": {
+                    "description": "UploadedAt is when the .map was last written.",
+                    "type": "string"
+                },
+                "verified": {
+                    "type": "boolean"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "width": {
+                    "description": "Width and Height are the playable area in world units.",
+                    "type": "number"
+                }
+            }
+        },
+        "mapfile.UploadStatus": {
+            "type": "object",
+            "properties": {
+                "complete": {
+                    "type": "boolean"
+                },
+                "crc": {
+                    "type": "string"
+                },
+                "manifest": {
+                    "$ref": "#/definitions/mapfile.Manifest"
+                },
+                "missing": {
+                    "description": "Missing lists manifest kinds not yet stored at their declared size.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "partial": {
+                    "description": "Partial maps kinds with a chunked upload in progress to the bytes\nreceived so far, which is where the next chunk must start.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "stored": {
+                    "description": "Stored maps each stored kind to its size.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                }
+            }
+        },
+        "mapparse.StartPosition": {
+            "type": "object",
+            "properties": {
+                "player": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                }
+            }
+        },
+        "matchdb.Match": {
             "type": "object",
             "properties": {
-                "count": {
+                "durationSeconds": {
                     "type": "integer"
                 },
-                "type": {
+                "frameCount": {
+                    "type": "integer"
+                },
+                "id": {
+                    "description": "ID is the match's identity (see pkg/matchid) and its key here.",
+                    "type": "string"
+                },
+                "indexedAt": {
+                    "type": "string"
+                },
+                "logs": {
+                    "type": "string"
+                },
+                "mapCrc": {
+                    "type": "string"
+                },
+                "mapPath": {
+                    "type": "string"
+                },
+                "playedAt": {
+                    "description": "PlayedAt is the recording player's local clock from the header's\nSYSTEMTIME. It has no zone, so it is stored as if it were UTC.",
+                    "type": "string"
+                },
+                "players": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/matchdb.Player"
+                    }
+                },
+                "replay": {
+                    "type": "string"
+                },
+                "replayHash": {
+                    "description": "ReplayHash is the content hash (see pkg/replayfile) of the stored\nreplay the record was built from.",
+                    "type": "string"
+                },
+                "seed": {
+                    "type": "string"
+                },
+                "startTime": {
+                    "type": "integer"
+                },
+                "stats": {
+                    "description": "Stats, Logs and Replay are the keys the match's files are stored\nunder in their repositories, empty when none are stored.",
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                },
+                "versionNumber": {
                     "type": "integer"
+                },
+                "winMethod": {
+                    "type": "string"
                 }
             }
         },
-        "body.BodyChunk": {
+        "matchdb.Player": {
             "type": "object",
             "properties": {
-                "argMetadata": {
+                "actions": {
+                    "description": "Actions counts the player's commands, leaving out selections and\nother orders that change nothing in the game; APM is Actions per\nminute of the match.",
+                    "type": "integer"
+                },
+                "apm": {
+                    "type": "number"
+                },
+                "buildings": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/body.ArgMetadata"
+                        "type": "string"
                     }
                 },
-                "arguments": {
+                "color": {
+                    "type": "string"
+                },
+                "faction": {
+                    "type": "string"
+                },
+                "human": {
+                    "type": "boolean"
+                },
+                "ip": {
+                    "description": "IP is the hex address the header records for humans.",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "observer": {
+                    "type": "boolean"
+                },
+                "opening": {
+                    "description": "Opening is the player's first OpeningLength units, buildings and\nupgrades ordered, in order.",
                     "type": "array",
-                    "items": {}
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "details": {},
-                "numberOfArguments": {
+                "powers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "side": {
+                    "type": "string"
+                },
+                "slot": {
+                    "description": "Slot is the player's position in the replay header, from 0.",
                     "type": "integer"
                 },
-                "orderCode": {
+                "spent": {
+                    "description": "Spent is what the player's units, buildings and upgrades cost, as\nthe parser priced them from the INI data.",
                     "type": "integer"
                 },
-                "orderName": {
-                    "type": "string"
+                "startPosition": {
+                    "description": "StartPosition is the map start the player chose in the lobby, from\n1 like the map's Player_N_Start waypoints; 0 for a random start,\nsince the header doesn't record where those landed.",
+                    "type": "integer"
                 },
-                "playerID": {
-                    "description": "Starts at 2 for humans",
+                "team": {
                     "type": "integer"
                 },
-                "playerName": {
+                "units": {
+                    "description": "Units, Buildings, Upgrades and Powers are the templates of what the\nplayer built, researched and fired, sorted; see Used.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "upgrades": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "win": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "matchid.ID": {
+            "type": "object",
+            "properties": {
+                "mapCrc": {
+                    "description": "MapCRC is the map CRC in decimal, as pkg/mapfile keys maps.",
                     "type": "string"
                 },
-                "timeCode": {
+                "seed": {
+                    "type": "string"
+                },
+                "start": {
+                    "description": "Start is when the game started, in Unix seconds.",
                     "type": "integer"
                 }
             }
         },
-        "header.GeneralsHeader": {
+        "object.ObjectSummary": {
             "type": "object",
             "properties": {
-                "buildDate": {
-                    "description": "UTF-16 null-terminated (versionTimeString)",
-                    "type": "string"
+                "count": {
+                    "type": "integer"
                 },
-                "day": {
+                "totalSpent": {
                     "type": "integer"
+                }
+            }
+        },
+        "profile.APMPoint": {
+            "type": "object",
+            "properties": {
+                "apm": {
+                    "type": "number"
                 },
-                "desync": {
-                    "description": "1 byte Bool",
-                    "type": "boolean"
+                "match": {
+                    "type": "string"
                 },
-                "difficulty": {
-                    "description": "int32 (GameDifficulty enum)",
-                    "type": "integer"
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "profile.Appearance": {
+            "type": "object",
+            "properties": {
+                "apm": {
+                    "type": "number"
                 },
-                "dow": {
+                "durationSeconds": {
                     "type": "integer"
                 },
-                "exeCRC": {
-                    "description": "uint32",
-                    "type": "integer"
+                "faction": {
+                    "type": "string"
                 },
-                "frameCount": {
-                    "description": "uint32",
-                    "type": "integer"
+                "map": {
+                    "type": "string"
                 },
-                "gameType": {
-                    "description": "6 bytes: \"GENREP\"",
+                "match": {
                     "type": "string"
                 },
-                "hour": {
-                    "type": "integer"
+                "name": {
+                    "type": "string"
                 },
-                "iniCRC": {
-                    "description": "uint32",
-                    "type": "integer"
+                "opening": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "localPlayerIndex": {
-                    "description": "ASCII null-terminated int",
+                "opponents": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "result": {
+                    "type": "string"
+                },
+                "side": {
+                    "type": "string"
+                },
+                "team": {
                     "type": "integer"
                 },
-                "maxFPS": {
-                    "description": "int32",
+                "teammates": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "time": {
+                    "type": "string"
+                },
+                "winMethod": {
+                    "type": "string"
+                }
+            }
+        },
+        "profile.Opening": {
+            "type": "object",
+            "properties": {
+                "builds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "games": {
                     "type": "integer"
                 },
-                "metadata": {
-                    "description": "ASCII null-terminated (gameOptions)",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/header.Metadata"
-                        }
-                    ]
+                "key": {
+                    "description": "Key is the faction, the map, or the other player's ID.",
+                    "type": "string"
                 },
-                "millisecond": {
+                "losses": {
                     "type": "integer"
                 },
-                "minute": {
+                "name": {
+                    "description": "Name is the other player's latest name, for teammates and opponents.",
+                    "type": "string"
+                },
+                "winRate": {
+                    "description": "WinRate is Wins over the games with a result, 0 without any.",
+                    "type": "number"
+                },
+                "wins": {
                     "type": "integer"
+                }
+            }
+        },
+        "profile.Profile": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "month": {
+                "apmTrend": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/profile.APMPoint"
+                    }
+                },
+                "averageApm": {
+                    "type": "number"
+                },
+                "averageSeconds": {
+                    "type": "number"
+                },
+                "factions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/profile.Record"
+                    }
+                },
+                "firstPlayed": {
+                    "type": "string"
+                },
+                "games": {
                     "type": "integer"
                 },
-                "originalGameMode": {
-                    "description": "int32",
+                "key": {
+                    "description": "Key is the faction, the map, or the other player's ID.",
+                    "type": "string"
+                },
+                "lastPlayed": {
+                    "type": "string"
+                },
+                "losses": {
                     "type": "integer"
                 },
-                "playerDiscons": {
-                    "description": "8 × 1 byte Bool (MAX_SLOTS)",
+                "maps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/profile.Record"
+                    }
+                },
+                "name": {
+                    "description": "Name is the other player's latest name, for teammates and opponents.",
+                    "type": "string"
+                },
+                "openings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/profile.Opening"
+                    }
+                },
+                "opponents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/profile.Record"
+                    }
+                },
+                "player": {
+                    "type": "string"
+                },
+                "teammates": {
                     "type": "array",
                     "items": {
-                        "type": "boolean"
+                        "$ref": "#/definitions/profile.Record"
                     }
                 },
-                "quitEarly": {
-                    "description": "1 byte Bool",
-                    "type": "boolean"
+                "winRate": {
+                    "description": "WinRate is Wins over the games with a result, 0 without any.",
+                    "type": "number"
                 },
-                "rankPoints": {
-                    "description": "int32",
+                "wins": {
                     "type": "integer"
-                },
-                "replayName": {
-                    "description": "UTF-16 null-terminated",
-                    "type": "string"
-                },
-                "second": {
+                }
+            }
+        },
+        "profile.Record": {
+            "type": "object",
+            "properties": {
+                "games": {
                     "type": "integer"
                 },
-                "timeStampBegin": {
-                    "description": "int32 (replay_time_t)",
-                    "type": "integer"
+                "key": {
+                    "description": "Key is the faction, the map, or the other player's ID.",
+                    "type": "string"
                 },
-                "timeStampEnd": {
-                    "description": "int32 (replay_time_t)",
+                "losses": {
                     "type": "integer"
                 },
-                "version": {
-                    "description": "UTF-16 null-terminated",
+                "name": {
+                    "description": "Name is the other player's latest name, for teammates and opponents.",
                     "type": "string"
                 },
-                "versionNumber": {
-                    "description": "uint32",
-                    "type": "integer"
+                "winRate": {
+                    "description": "WinRate is Wins over the games with a result, 0 without any.",
+                    "type": "number"
                 },
-                "year": {
-                    "description": "SYSTEMTIME fields (8 × uint16)",
+                "wins": {
                     "type": "integer"
                 }
             }
         },
-        "header.Metadata": {
+        "rating.Entry": {
             "type": "object",
             "properties": {
-                "crcInterval": {
-                    "description": "C",
+                "faction": {
+                    "description": "Faction is empty on a player's overall entry.",
                     "type": "string"
                 },
-                "mapCRC": {
-                    "description": "MC (hex)",
-                    "type": "string"
+                "games": {
+                    "type": "integer"
                 },
-                "mapContentsMask": {
-                    "description": "M prefix (2 hex digits)",
+                "lastPlayed": {
                     "type": "string"
                 },
-                "mapPath": {
-                    "description": "M remainder (map directory path)",
-                    "type": "string"
+                "losses": {
+                    "type": "integer"
                 },
-                "mapSize": {
-                    "description": "MS",
+                "name": {
+                    "description": "Name is the name the player last played under.",
                     "type": "string"
                 },
-                "oldFactionsOnly": {
-                    "description": "O (ZH only: Y/N)",
+                "player": {
+                    "description": "Player is the player's identity ID, or their name when ratings are\ncomputed without identities.",
                     "type": "string"
                 },
-                "players": {
-                    "description": "S",
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/header.Player"
-                    }
-                },
-                "seed": {
-                    "description": "SD",
-                    "type": "string"
+                "rating": {
+                    "$ref": "#/definitions/rating.Rating"
                 },
-                "startingCash": {
-                    "description": "SC (ZH only)",
-                    "type": "string"
+                "wins": {
+                    "type": "integer"
+                }
+            }
+        },
+        "rating.Rating": {
+            "type": "object",
+            "properties": {
+                "deviation": {
+                    "type": "number"
                 },
-                "superweaponRestriction": {
-                    "description": "SR (ZH only)",
-                    "type": "string"
+                "rating": {
+                    "type": "number"
                 },
-                "useStats": {
-                    "description": "US (ZH only: 1=yes, 0=no)",
-                    "type": "string"
+                "volatility": {
+                    "type": "number"
                 }
             }
         },
-        "header.Player": {
+        "replayfile.Upload": {
             "type": "object",
             "properties": {
-                "color": {
-                    "description": "Color index (or name if ColorStore provided)",
-                    "type": "string"
-                },
-                "flags": {
-                    "description": "Human: \u003caccepted\u003e\u003chasMap\u003e (e.g. \"TT\"); AI: difficulty (E/M/H)",
+                "client": {
+                    "description": "Client is the API key name of the uploader (\"anonymous\" without one).",
                     "type": "string"
                 },
-                "ip": {
-                    "description": "Human only (hex)",
+                "hash": {
+                    "description": "Hash is the SHA-256 of the replay; repeated identical uploads share\none stored copy.",
                     "type": "string"
                 },
                 "name": {
-                    "description": "Human only",
+                    "description": "Name is the uploaded file's base name.",
                     "type": "string"
                 },
-                "natBehavior": {
-                    "description": "Human only: firewall behavior type",
-                    "type": "string"
+                "size": {
+                    "type": "integer"
                 },
-                "playerTemplate": {
-                    "description": "Index into player template store",
+                "uploadedAt": {
                     "type": "string"
+                }
+            }
+        },
+        "reprocess.Change": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "description": "Added is set when the match database had no record of the match.",
+                    "type": "boolean"
                 },
-                "port": {
-                    "description": "Human only",
+                "differences": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reprocess.Difference"
+                    }
+                },
+                "match": {
+                    "type": "string"
+                }
+            }
+        },
+        "reprocess.Difference": {
+            "type": "object",
+            "properties": {
+                "field": {
                     "type": "string"
                 },
-                "startingPosition": {
-                    "description": "Start position (-1 = random)",
+                "new": {
                     "type": "string"
                 },
-                "team": {
-                    "description": "Team number (-1 = none)",
+                "old": {
                     "type": "string"
                 },
-                "type": {
-                    "description": "H=human, C=computer",
+                "player": {
                     "type": "string"
+                },
+                "slot": {
+                    "type": "integer"
                 }
             }
         },
-        "main.ErrorResponse": {
+        "reprocess.Failure": {
             "type": "object",
             "properties": {
-                "details": {
-                    "type": "string",
-                    "example": "underlying error message"
-                },
                 "error": {
-                    "type": "string",
-                    "example": "Something went wrong"
+                    "type": "string"
+                },
+                "match": {
+                    "type": "string"
                 }
             }
         },
-        "main.StatsUploadResponse": {
+        "reprocess.Report": {
             "type": "object",
             "properties": {
-                "message": {
-                    "type": "string",
-                    "example": "Stats stored successfully"
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reprocess.Change"
+                    }
                 },
-                "seed": {
-                    "type": "string",
-                    "example": "12345"
+                "done": {
+                    "type": "integer"
                 },
-                "size": {
-                    "type": "integer",
-                    "example": 8192
-                }
-            }
-        },
-        "object.ObjectSummary": {
-            "type": "object",
-            "properties": {
-                "count": {
+                "error": {
+                    "description": "Error is why a failed job stopped.",
+                    "type": "string"
+                },
+                "failures": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reprocess.Failure"
+                    }
+                },
+                "fields": {
+                    "description": "Fields counts the differences found by field.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "started": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total is how many matches have stored replays; Done how many of\nthem have been parsed again, failures included.",
                     "type": "integer"
                 },
-                "totalSpent": {
+                "unchanged": {
                     "type": "integer"
                 }
             }
@@ -899,6 +4263,32 @@ const docTemplate = `{
                 }
             }
         },
+        "statsfile.Contributor": {
+            "type": "object",
+            "properties": {
+                "client": {
+                    "description": "Client is the API key name of the uploader (\"anonymous\" without one).",
+                    "type": "string"
+                },
+                "frameCount": {
+                    "type": "integer"
+                },
+                "hash": {
+                    "description": "Hash is the SHA-256 of the raw upload; repeated identical uploads\nshare one stored copy.",
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "superseded": {
+                    "description": "Superseded uploads are kept but left out of the merge, because a\nlater upload was forced to replace them.",
+                    "type": "boolean"
+                },
+                "uploadedAt": {
+                    "type": "string"
+                }
+            }
+        },
         "statsfile.DeathEvent": {
             "type": "object",
             "properties": {
@@ -998,7 +4388,7 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "incomeBySource": {
-                    "description": "IncomeBySource holds one cumulative-income series per source, keyed by\nthe same source names as Player.IncomeBySource. Present for stats JSON\nversion \u003e= 2; nil for older uploads.",
+                    "description": "IncomeBySource holds one cumulative-income series per source, keyed by\nthe same source names as Player.IncomeBySource. Introduced in stats\nJSON version 2; older uploads are migrated with all income under\n\"other\".",
                     "type": "object",
                     "additionalProperties": {
                         "type": "array",
@@ -1030,6 +4420,17 @@ const docTemplate = `{
                 }
             }
         },
+        "statsfile.ValidationError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
         "zhreplay.EnhancedReplayV2": {
             "type": "object",
             "properties": {
@@ -1039,12 +4440,23 @@ const docTemplate = `{
                         "$ref": "#/definitions/body.BodyChunk"
                     }
                 },
+                "displayNames": {
+                    "description": "DisplayNames maps template names used anywhere in the response (e.g.\n\"Tank_ChinaVehicleDozer\") to the localized name shown in game.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "gameInfo": {
                     "$ref": "#/definitions/zhreplay.GameInfoV2"
                 },
                 "header": {
                     "$ref": "#/definitions/header.GeneralsHeader"
                 },
+                "language": {
+                    "description": "Language is the language DisplayNames were resolved in; empty when\nthe replay was not localized.",
+                    "type": "string"
+                },
                 "offset": {
                     "type": "integer"
                 },
@@ -1298,6 +4710,13 @@ const docTemplate = `{
                 "playerType": {
                     "type": "string"
                 },
+                "powerTimelines": {
+                    "description": "PowerTimelines covers superweapons and general's powers; see\nBuildPowerTimelines.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/zhreplay.PowerTimeline"
+                    }
+                },
                 "powersUsed": {
                     "type": "object",
                     "additionalProperties": {
@@ -1330,6 +4749,72 @@ const docTemplate = `{
                 }
             }
         },
+        "zhreplay.PowerFiring": {
+            "type": "object",
+            "properties": {
+                "delayFrames": {
+                    "type": "integer"
+                },
+                "frame": {
+                    "type": "integer"
+                },
+                "readyFrame": {
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Source numbers the timer that fired: each superweapon building has\nits own, general's powers share one.",
+                    "type": "integer"
+                }
+            }
+        },
+        "zhreplay.PowerTimeline": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "efficiency": {
+                    "description": "Efficiency is reload time over reload-plus-delay across measured\nfirings: 1.0 means every shot went off the moment it was ready.",
+                    "type": "number"
+                },
+                "firings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/zhreplay.PowerFiring"
+                    }
+                },
+                "grantedBy": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "meanDelaySeconds": {
+                    "type": "number"
+                },
+                "measuredFirings": {
+                    "description": "Efficiency metrics, over firings with a known ReadyFrame.",
+                    "type": "integer"
+                },
+                "missedFirings": {
+                    "description": "MissedFirings is how many more shots the sources had time for\nbetween their last ready frame and the end of the game. A building\ndestroyed while charged still counts, since we can't see that here.",
+                    "type": "integer"
+                },
+                "power": {
+                    "type": "string"
+                },
+                "promptFirings": {
+                    "description": "PromptFirings counts shots fired within five seconds of being ready.",
+                    "type": "integer"
+                },
+                "reloadFrames": {
+                    "type": "integer"
+                },
+                "requiredScience": {
+                    "type": "string"
+                }
+            }
+        },
         "zhreplay.TeamFactors": {
             "type": "object",
             "properties": {
@@ -1405,7 +4890,7 @@ const docTemplate = `{
             "in": "header"
         },
         "BearerAuth": {
-            "description": "API key supplied as \"Bearer \u003ckey\u003e\". Required on write endpoints when CNC_AUTH_REQUIRED is true.",
+            "description": "API key supplied as \"Bearer \u003ckey\u003e\". Required on write endpoints when CNC_AUTH_REQUIRED is true; /admin endpoints always need an operator key from CNC_ADMIN_KEYS.",
             "type": "apiKey",
             "name": "Authorization",
             "in": "header"
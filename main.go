@@ -3,26 +3,51 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"image/png"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	_ "github.com/bill-rich/cncstats/docs"
-	"github.com/bill-rich/cncstats/pkg/bitparse"
+	"github.com/bill-rich/cncstats/pkg/balance"
+	"github.com/bill-rich/cncstats/pkg/batch"
 	"github.com/bill-rich/cncstats/pkg/coordinator"
-	"github.com/bill-rich/cncstats/pkg/iniparse"
+	"github.com/bill-rich/cncstats/pkg/datastore"
+	"github.com/bill-rich/cncstats/pkg/gametext"
+	"github.com/bill-rich/cncstats/pkg/identity"
+	"github.com/bill-rich/cncstats/pkg/jobs"
 	"github.com/bill-rich/cncstats/pkg/logfile"
 	"github.com/bill-rich/cncstats/pkg/mapfile"
+	"github.com/bill-rich/cncstats/pkg/mapparse"
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+	"github.com/bill-rich/cncstats/pkg/matchid"
+	"github.com/bill-rich/cncstats/pkg/minimap"
+	"github.com/bill-rich/cncstats/pkg/profile"
+	"github.com/bill-rich/cncstats/pkg/rating"
+	"github.com/bill-rich/cncstats/pkg/replayfile"
+	"github.com/bill-rich/cncstats/pkg/reprocess"
+	"github.com/bill-rich/cncstats/pkg/search"
 	"github.com/bill-rich/cncstats/pkg/statsfile"
+	"github.com/bill-rich/cncstats/pkg/storage"
+	"github.com/bill-rich/cncstats/pkg/tga"
 	"github.com/bill-rich/cncstats/pkg/zhreplay"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/header"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -34,7 +59,28 @@ import (
 type StatsUploadResponse struct {
 	Message string `json:"message" example:"Stats stored successfully"`
 	Seed    string `json:"seed" example:"12345"`
-	Size    int    `json:"size" example:"8192"`
+	// Match is the identity the stats are stored under; see pkg/matchid.
+	Match string `json:"match" example:"12345-3862104857-1718000000"`
+	Size  int    `json:"size" example:"8192"`
+	// Uploads is how many uploads the match has had, this one included.
+	Uploads int `json:"uploads" example:"2"`
+	// FrameCount is the merged stats' frame count.
+	FrameCount   uint                    `json:"frameCount" example:"54000"`
+	Contributors []statsfile.Contributor `json:"contributors"`
+}
+
+// MatchConflictResponse is returned when a seed alone names several
+// stored matches.
+type MatchConflictResponse struct {
+	Error      string       `json:"error" example:"Several matches were played with this seed; pass mapCrc or start to choose one"`
+	Candidates []matchid.ID `json:"candidates"`
+}
+
+// StatsValidationResponse is returned when a stats upload decodes but
+// fails validation.
+type StatsValidationResponse struct {
+	Error      string                      `json:"error" example:"Stats upload failed validation"`
+	Validation []statsfile.ValidationError `json:"validation"`
 }
 
 // ErrorResponse represents an error response.
@@ -51,7 +97,7 @@ type ErrorResponse struct {
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description API key supplied as "Bearer <key>". Required on write endpoints when CNC_AUTH_REQUIRED is true.
+// @description API key supplied as "Bearer <key>". Required on write endpoints when CNC_AUTH_REQUIRED is true; /admin endpoints always need an operator key from CNC_ADMIN_KEYS.
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name X-API-Key
@@ -94,42 +140,65 @@ func main() {
 
 	log.Info("CNC Stats application starting...")
 
+	repos, err := openRepositories()
+	if err != nil {
+		log.WithError(err).Fatal("could not open storage")
+	}
+
 	// Handle local mode
 	if *local || len(os.Getenv("LOCAL")) > 0 {
-		var objectStore *iniparse.ObjectStore
-		var powerStore *iniparse.PowerStore
-		var upgradeStore *iniparse.UpgradeStore
-		var err error
-
 		// Initialize stores for local mode unless no-stores flag is set
-		var colorStore *iniparse.ColorStore
-		if !*noStores {
-			objectStore, powerStore, upgradeStore, colorStore, err = initializeStores(objDataPath)
-			if err != nil {
-				log.WithError(err).Fatal("could not initialize stores")
-			}
+		bundle, err := localBundle(objDataPath, *noStores)
+		if err != nil {
+			log.WithError(err).Fatal("could not initialize stores")
 		}
 
-		handleLocalMode(*replayFile, objectStore, powerStore, upgradeStore, colorStore)
+		handleLocalMode(*replayFile, bundle, repos.maps)
 		return
 	}
 
-	// Initialize stores for server mode unless no-stores flag is set
-	var objectStore *iniparse.ObjectStore
-	var powerStore *iniparse.PowerStore
-	var upgradeStore *iniparse.UpgradeStore
-	var colorStore *iniparse.ColorStore
-
+	// Initialize stores for server mode unless no-stores flag is set. The
+	// holder lets the data set be reloaded later (SIGHUP or /admin/reload)
+	// without restarting the server. Under -no-stores the holder has no
+	// directory, so neither of them loads one.
+	iniDir := objDataPath
+	if *noStores {
+		iniDir = ""
+	}
+	stores := datastore.NewHolder(iniDir)
 	if !*noStores {
 		log.Info("Initializing INI stores...")
-		var err error
-		objectStore, powerStore, upgradeStore, colorStore, err = initializeStores(objDataPath)
+		bundle, err := stores.Reload()
 		if err != nil {
 			log.WithError(err).Fatal("could not initialize stores")
 		}
-		log.Info("INI stores initialized successfully")
+		log.WithField("version", bundle.Version).Info("INI stores initialized successfully")
+		reloadStoresOnSIGHUP(stores)
 	} else {
 		log.Info("Running without INI stores")
+		signal.Ignore(syscall.SIGHUP)
+	}
+
+	// The match database is a local bbolt file (MATCHDB_PATH, default
+	// ./matches.db), not shared storage: it indexes what this server parsed.
+	dbPath := os.Getenv("MATCHDB_PATH")
+	if dbPath == "" {
+		dbPath = "./matches.db"
+	}
+	repos.db, err = matchdb.Open(dbPath)
+	if err != nil {
+		log.WithError(err).Fatal("could not open match database")
+	}
+	defer repos.db.Close()
+	repos.ratings = rating.NewCache(repos.db, repos.ids)
+	repos.profiles = profile.NewCache(repos.db, repos.ids)
+	repos.balance = balance.NewCache(repos.db)
+	repos.searches = search.NewCache(repos.db, repos.ids)
+
+	// "cncstats reprocess" parses every stored replay again and exits.
+	if flag.Arg(0) == "reprocess" {
+		runReprocess(stores, repos)
+		return
 	}
 
 	// Start the online coordinator (TCP signaling + UDP STUN/hole punch for
@@ -150,7 +219,86 @@ func main() {
 
 	// Start web server
 	log.Info("Starting web server...")
-	startWebServer(objectStore, powerStore, upgradeStore, colorStore, coordSrv)
+	startWebServer(stores, repos, coordSrv)
+}
+
+// repositories holds the persistent stores the handlers read and write.
+// They share one storage backend type, chosen by STORAGE_BACKEND, so
+// several stateless replicas can serve the same data from object storage.
+type repositories struct {
+	matches *matchid.Index
+	ids     *identity.Store
+	stats   *statsfile.Repository
+	logs    *logfile.Repository
+	maps    *mapfile.Repository
+	replays *replayfile.Repository
+	// results holds parse job results, one JSON object per job.
+	results storage.Backend
+
+	// db records every parsed match. It is a local file that only one
+	// process can open, so it is opened in server mode only and is nil in
+	// local mode.
+	db *matchdb.DB
+	// ratings caches the ratings computed from db until it changes.
+	ratings *rating.Cache
+	// profiles caches player histories read from db until it changes.
+	profiles *profile.Cache
+	// balance caches the balance reports computed from db until it
+	// changes.
+	balance *balance.Cache
+	// searches caches match search results from db until it changes.
+	searches *search.Cache
+}
+
+// openRepositories opens each repository's backend. On the filesystem
+// backend MATCHES_DIR, IDENTITIES_DIR, STATS_DIR, LOGS_DIR, MAPS_DIR,
+// REPLAYS_DIR and RESULTS_DIR choose the directories (default ./matches,
+// ./identities, ./stats, ./logs, ./maps, ./replays and ./results).
+func openRepositories() (*repositories, error) {
+	open := func(name, envDir string) (storage.Backend, error) {
+		dir := "./" + name
+		if d := os.Getenv(envDir); d != "" {
+			dir = d
+		}
+		return storage.Open(name, dir)
+	}
+	matches, err := open("matches", "MATCHES_DIR")
+	if err != nil {
+		return nil, err
+	}
+	ids, err := open("identities", "IDENTITIES_DIR")
+	if err != nil {
+		return nil, err
+	}
+	stats, err := open("stats", "STATS_DIR")
+	if err != nil {
+		return nil, err
+	}
+	logs, err := open("logs", "LOGS_DIR")
+	if err != nil {
+		return nil, err
+	}
+	maps, err := open("maps", "MAPS_DIR")
+	if err != nil {
+		return nil, err
+	}
+	replays, err := open("replays", "REPLAYS_DIR")
+	if err != nil {
+		return nil, err
+	}
+	results, err := open("results", "RESULTS_DIR")
+	if err != nil {
+		return nil, err
+	}
+	return &repositories{
+		matches: matchid.NewIndex(matches),
+		ids:     identity.NewStore(ids),
+		stats:   statsfile.NewRepository(stats),
+		logs:    logfile.NewRepository(logs),
+		maps:    mapfile.NewRepository(maps),
+		replays: replayfile.NewRepository(replays),
+		results: results,
+	}, nil
 }
 
 // Helper functions
@@ -160,6 +308,9 @@ func showHelp() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  cncstats [flags]")
+	fmt.Println("  cncstats [flags] reprocess")
+	fmt.Println("        Parse every stored replay again with the current INI data, update")
+	fmt.Println("        the match database and print what changed as JSON")
 	fmt.Println()
 	fmt.Println("Flags:")
 	fmt.Println("  -objdata string")
@@ -200,31 +351,35 @@ func getObjDataPath(cliObjData string) string {
 	return "/var/Data/INI"
 }
 
-func initializeStores(objDataPath string) (*iniparse.ObjectStore, *iniparse.PowerStore, *iniparse.UpgradeStore, *iniparse.ColorStore, error) {
-	objectStore, err := iniparse.NewObjectStore(objDataPath)
-	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("could not load object store: %w", err)
-	}
-
-	powerStore, err := iniparse.NewPowerStore(objDataPath)
-	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("could not load power store: %w", err)
-	}
-
-	upgradeStore, err := iniparse.NewUpgradeStore(objDataPath)
-	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("could not load upgrade store: %w", err)
-	}
+// reloadStoresOnSIGHUP reloads the INI stores whenever the process receives
+// SIGHUP, the conventional "re-read your config" signal. A failed reload is
+// logged and the previous data set stays in service.
+func reloadStoresOnSIGHUP(stores *datastore.Holder) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Info("SIGHUP received, reloading INI stores...")
+			bundle, err := stores.Reload()
+			if err != nil {
+				log.WithError(err).Error("INI store reload failed; keeping previous data set")
+				continue
+			}
+			log.WithField("version", bundle.Version).Info("INI stores reloaded")
+		}
+	}()
+}
 
-	colorStore, err := iniparse.NewColorStore(objDataPath)
-	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("could not load color store: %w", err)
+// localBundle loads the INI stores for local mode from dir, or returns an
+// empty bundle under -no-stores, as the server runs with.
+func localBundle(dir string, noStores bool) (*datastore.Bundle, error) {
+	if noStores {
+		return &datastore.Bundle{}, nil
 	}
-
-	return objectStore, powerStore, upgradeStore, colorStore, nil
+	return datastore.Load(dir)
 }
 
-func handleLocalMode(replayFile string, objectStore *iniparse.ObjectStore, powerStore *iniparse.PowerStore, upgradeStore *iniparse.UpgradeStore, colorStore *iniparse.ColorStore) {
+func handleLocalMode(replayFile string, bundle *datastore.Bundle, mapRepo *mapfile.Repository) {
 	// Use command line argument or fall back to first non-flag argument
 	if replayFile == "" && flag.NArg() > 0 {
 		replayFile = flag.Arg(0)
@@ -240,17 +395,7 @@ func handleLocalMode(replayFile string, objectStore *iniparse.ObjectStore, power
 	}
 	defer file.Close()
 
-	bp := &bitparse.BitParser{
-		Source:       file,
-		ObjectStore:  objectStore,
-		PowerStore:   powerStore,
-		UpgradeStore: upgradeStore,
-		ColorStore:   colorStore,
-	}
-
-	replay := zhreplay.NewReplay(bp)
-	v2 := zhreplay.ConvertToBasicEnhancedReplayV2(replay)
-	um, err := json.Marshal(v2)
+	um, err := localReplay(file, bundle, mapRepo)
 	if err != nil {
 		log.WithError(err).Fatal("could not marshal replay data")
 	}
@@ -258,12 +403,21 @@ func handleLocalMode(replayFile string, objectStore *iniparse.ObjectStore, power
 	fmt.Printf("%+v\n", string(um))
 }
 
+// localReplay parses a replay for local mode and returns it as JSON.
+func localReplay(src io.Reader, bundle *datastore.Bundle, mapRepo *mapfile.Repository) ([]byte, error) {
+	replay := zhreplay.NewReplay(bundle.BitParser(src))
+	v2 := zhreplay.ConvertToBasicEnhancedReplayV2(replay)
+	v2.BuildPowerTimelines(bundle.Powers)
+	localizeReplay(v2, bundle, mapRepo, "english", replay.Header.Metadata.MapCRC)
+	return json.Marshal(v2)
+}
+
 // apiKeyStore maps a valid API key to the name of the client it belongs to.
 // Names are used for logging only; keys are the secret.
 type apiKeyStore map[string]string
 
-// loadAPIKeys parses CNC_API_KEYS, a comma-separated list of "name:key" pairs
-// (e.g. "zulu:abc123,radarvan:def456,dev:ghi789"). Add, replace, or remove
+// loadAPIKeys parses CNC_API_KEYS (or CNC_ADMIN_KEYS), a comma-separated
+// list of "name:key" pairs (e.g. "zulu:abc123,radarvan:def456,dev:ghi789"). Add, replace, or remove
 // clients by editing the env var; no code change is needed. Malformed entries
 // are logged and skipped.
 func loadAPIKeys(raw string) apiKeyStore {
@@ -277,7 +431,7 @@ func loadAPIKeys(raw string) apiKeyStore {
 		name = strings.TrimSpace(name)
 		key = strings.TrimSpace(key)
 		if !ok || name == "" || key == "" {
-			log.WithField("entry", pair).Warn("ignoring malformed CNC_API_KEYS entry; expected name:key")
+			log.WithField("entry", pair).Warn("ignoring malformed API key entry; expected name:key")
 			continue
 		}
 		store[key] = name
@@ -376,6 +530,72 @@ func isForced(c *gin.Context) bool {
 	return false
 }
 
+// uploadMatchID builds the identity an upload is for: the seed from
+// X-Game-Seed, with the map CRC and start time from game when the
+// exporter included them and from the X-Map-CRC and X-Game-Start (Unix
+// seconds) headers otherwise. Either may be missing.
+func uploadMatchID(c *gin.Context, seed string, game *statsfile.GameInfo) (matchid.ID, error) {
+	crc := c.GetHeader("X-Map-CRC")
+	var start int64
+	if v := c.GetHeader("X-Game-Start"); v != "" {
+		var err error
+		if start, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return matchid.ID{}, fmt.Errorf("bad X-Game-Start header %q", v)
+		}
+	}
+	if game != nil {
+		if game.MapCRC != "" {
+			crc = game.MapCRC
+		}
+		if game.StartTime != 0 {
+			start = game.StartTime
+		}
+	}
+	return matchid.New(seed, crc, start)
+}
+
+// queryMatchID reads a match identity from the seed, mapCrc and start
+// query parameters. Only seed is required.
+func queryMatchID(c *gin.Context) (matchid.ID, error) {
+	var start int64
+	if v := c.Query("start"); v != "" {
+		var err error
+		if start, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return matchid.ID{}, fmt.Errorf("bad start query parameter %q", v)
+		}
+	}
+	return matchid.New(c.Query("seed"), c.Query("mapCrc"), start)
+}
+
+// lookupMatch returns the key a match's files are stored under, answering
+// the request itself when it can't: 409 with the candidates when a seed
+// alone names several matches, 500 if the index can't be read. exists
+// reports whether a key holds files, for repositories where uploads
+// without an identity are kept under the bare seed; it is nil for those
+// always stored by identity.
+func lookupMatch(c *gin.Context, matches *matchid.Index, id matchid.ID, exists func(string) bool) (string, bool) {
+	found, err := matches.Lookup(id)
+	var ambiguous *matchid.AmbiguousError
+	switch {
+	case errors.As(err, &ambiguous):
+		c.AbortWithStatusJSON(http.StatusConflict, MatchConflictResponse{
+			Error:      "Several matches were played with this seed; pass mapCrc or start to choose one",
+			Candidates: ambiguous.Candidates,
+		})
+		return "", false
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read match index",
+			"details": err.Error(),
+		})
+		return "", false
+	}
+	if exists != nil {
+		return matchid.StoredKey(found, exists), true
+	}
+	return found.String(), true
+}
+
 // extractAPIKey pulls the API key from the Authorization or X-API-Key header.
 func extractAPIKey(c *gin.Context) string {
 	if h := c.GetHeader("Authorization"); h != "" {
@@ -387,7 +607,7 @@ func extractAPIKey(c *gin.Context) string {
 	return c.GetHeader("X-API-Key")
 }
 
-func startWebServer(objectStore *iniparse.ObjectStore, powerStore *iniparse.PowerStore, upgradeStore *iniparse.UpgradeStore, colorStore *iniparse.ColorStore, coordSrv *coordinator.Server) {
+func startWebServer(stores *datastore.Holder, repos *repositories, coordSrv *coordinator.Server) {
 	router := gin.Default()
 
 	// Transparently gzip JSON responses (notably the large /replay payload:
@@ -396,7 +616,7 @@ func startWebServer(objectStore *iniparse.ObjectStore, powerStore *iniparse.Powe
 	// endpoints (map files, zips) are excluded to avoid wasting CPU
 	// recompressing them.
 	router.Use(gzip.Gzip(gzip.DefaultCompression,
-		gzip.WithExcludedPaths([]string{"/get_map_file", "/get_map", "/get_logs", "/zip"})))
+		gzip.WithExcludedPaths([]string{"/get_map_file", "/get_map", "/get_logs", "/get_replay", "/zip"})))
 
 	// Write endpoints are grouped behind a shared API key. Read endpoints
 	// (map downloads, docs) stay open because game peers need them mid-lobby
@@ -419,27 +639,162 @@ func startWebServer(objectStore *iniparse.ObjectStore, powerStore *iniparse.Powe
 		}
 	}
 
+	// Admin endpoints act on the whole server (the INI data set, identity
+	// links, reprocessing), so they take operator keys from CNC_ADMIN_KEYS
+	// rather than the per-client keys, and stay closed when
+	// CNC_AUTH_REQUIRED=false.
+	admin := router.Group("/admin")
+	adminKeys := loadAPIKeys(os.Getenv("CNC_ADMIN_KEYS"))
+	if len(adminKeys) == 0 {
+		log.Warn("no valid keys in CNC_ADMIN_KEYS; /admin endpoints are disabled")
+	} else {
+		log.WithField("operators", adminKeys.names()).Info("operator key auth enabled for /admin endpoints")
+	}
+	admin.Use(apiKeyAuth(adminKeys))
+
+	// Parse jobs - /replay?async=true queues the replay for a fixed pool of
+	// workers. Results are kept for an hour; they hold the parsed replay,
+	// addresses included, so polling is authenticated too.
+	parseWorkers := envInt("PARSE_WORKERS", runtime.NumCPU())
+	parses := jobs.NewQueue(parseWorkers, envInt("PARSE_QUEUE", 64), parseResultRetention,
+		func(s jobs.Status) {
+			if err := repos.results.Delete(parseResultKey(s.ID)); err != nil {
+				log.WithError(err).WithField("job", s.ID).Warn("Failed to delete parse result")
+			}
+		})
+	sweepParseResults(repos.results)
+	writes.GET("/jobs/:id", func(c *gin.Context) {
+		jobStatusHandler(c, parses)
+	})
+	writes.GET("/jobs/:id/result", func(c *gin.Context) {
+		jobResultHandler(c, parses, repos.results)
+	})
+
 	// Replay endpoint
 	writes.POST("/replay", func(c *gin.Context) {
-		saveFileHandler(c, objectStore, powerStore, upgradeStore, colorStore)
+		saveFileHandler(c, stores, repos, parses)
+	})
+	// Batch uploads - an archive of replays and stats, parsed in the
+	// parse queue's worker slots, shared with every other upload.
+	writes.POST("/replay_batch", func(c *gin.Context) {
+		batchUploadHandler(c, stores, repos, parses)
+	})
+	// Stored replays - authenticated: replay headers carry the players'
+	// addresses.
+	writes.GET("/replay_uploads", func(c *gin.Context) {
+		replayUploadsHandler(c, repos.matches, repos.replays)
+	})
+	writes.GET("/get_replay", func(c *gin.Context) {
+		getReplayHandler(c, repos.matches, repos.replays)
+	})
+	writes.POST("/reparse", func(c *gin.Context) {
+		reparseHandler(c, stores.Current(), repos)
 	})
 
-	// Stats upload endpoint - receives gzip-compressed JSON stats from Generals
+	// Stats endpoints - each player's game uploads gzip-compressed JSON stats,
+	// merged per match. The upload list names clients, so it's authenticated.
 	writes.POST("/stats", func(c *gin.Context) {
-		uploadStatsHandler(c)
+		uploadStatsHandler(c, repos.matches, repos.stats, repos.db, repos.ids)
+	})
+	writes.GET("/stats_uploads", func(c *gin.Context) {
+		statsContributorsHandler(c, repos.matches, repos.stats)
 	})
 
-	// Logs endpoints - clients post their per-match log files, retrieved as a zip by seed.
+	// Logs endpoints - clients post their per-match log files, retrieved as a zip by match.
 	// Both are authenticated: logs may contain sensitive client detail and aren't needed mid-lobby.
-	writes.POST("/logs", uploadLogsHandler)
-	writes.GET("/get_logs", getLogsHandler)
+	writes.POST("/logs", func(c *gin.Context) {
+		uploadLogsHandler(c, repos.matches, repos.logs, repos.db, repos.ids)
+	})
+	writes.GET("/get_logs", func(c *gin.Context) {
+		getLogsHandler(c, repos.matches, repos.logs)
+	})
+
+	// Match database - what the server recorded about each parsed replay.
+	// Authenticated: records include the players' addresses.
+	writes.GET("/match_info", func(c *gin.Context) {
+		matchInfoHandler(c, repos.matches, repos.db)
+	})
+	writes.GET("/matches", func(c *gin.Context) {
+		searchMatchesHandler(c, repos.searches)
+	})
+
+	// Ratings - Glicko-2 ratings computed from the match database. Open:
+	// they only show names and results.
+	router.GET("/ratings", func(c *gin.Context) {
+		ratingsHandler(c, repos.ratings)
+	})
+	router.GET("/player_rating", func(c *gin.Context) {
+		playerRatingHandler(c, repos.ratings)
+	})
+
+	// Player identities - the nicknames linked into each player. Changing
+	// and auditing the links is an admin action.
+	router.GET("/player_identity", func(c *gin.Context) {
+		playerIdentityHandler(c, repos.ids)
+	})
+	admin.POST("/merge_players", func(c *gin.Context) {
+		mergePlayersHandler(c, repos.ids)
+	})
+	admin.POST("/split_player", func(c *gin.Context) {
+		splitPlayerHandler(c, repos.ids)
+	})
+	admin.GET("/identity_audit", func(c *gin.Context) {
+		identityAuditHandler(c, repos.ids)
+	})
+
+	// Balance statistics - aggregated from the match database.
+	router.GET("/balance", func(c *gin.Context) {
+		balanceHandler(c, repos.balance)
+	})
+
+	// Player profiles - built from the match database.
+	router.GET("/player_profile", func(c *gin.Context) {
+		playerProfileHandler(c, repos.profiles)
+	})
+	router.GET("/player_matches", func(c *gin.Context) {
+		playerMatchesHandler(c, repos.profiles)
+	})
+
+	// Data set reload - swaps in freshly parsed INI stores without dropping
+	// coordinator sessions.
+	admin.POST("/reload", func(c *gin.Context) {
+		reloadStoresHandler(c, stores)
+	})
+
+	// Reprocessing - parses every stored replay again in the background,
+	// typically after a reload. One job runs at a time.
+	var reprocessing reprocess.Runner
+	admin.POST("/reprocess", func(c *gin.Context) {
+		startReprocessHandler(c, &reprocessing, stores, repos)
+	})
+	admin.GET("/reprocess", func(c *gin.Context) {
+		reprocessStatusHandler(c, &reprocessing)
+	})
+	admin.DELETE("/reprocess", func(c *gin.Context) {
+		cancelReprocessHandler(c, &reprocessing)
+	})
 
 	// Map endpoints
-	router.GET("/map_exists", mapExistsHandler)
-	writes.POST("/add_map", addMapHandler)
-	router.GET("/get_map", getMapHandler)
-	router.GET("/get_map_file", getMapFileHandler)
-	router.GET("/list_map_assets", listMapAssetsHandler)
+	// Most map handlers only need the map repository.
+	maps := func(h func(*gin.Context, *mapfile.Repository)) gin.HandlerFunc {
+		return func(c *gin.Context) { h(c, repos.maps) }
+	}
+	router.GET("/map_exists", maps(mapExistsHandler))
+	writes.POST("/add_map", maps(addMapHandler))
+	writes.POST("/map_manifest", maps(mapManifestHandler))
+	router.GET("/map_upload_status", maps(mapUploadStatusHandler))
+	router.GET("/get_map", maps(getMapHandler))
+	router.GET("/get_map_file", maps(getMapFileHandler))
+	router.GET("/map_preview", maps(mapPreviewHandler))
+	router.GET("/minimap", func(c *gin.Context) {
+		statsMinimapHandler(c, stores.Current(), repos.matches, repos.stats, repos.maps)
+	})
+	writes.POST("/minimap", func(c *gin.Context) {
+		replayMinimapHandler(c, stores.Current(), repos.matches, repos.stats, repos.maps)
+	})
+	router.GET("/list_map_assets", maps(listMapAssetsHandler))
+	router.GET("/maps", maps(listMapsHandler))
+	router.GET("/map_info", maps(mapInfoHandler))
 
 	// Coordinator status (read-only session/game counts; open like the other
 	// read endpoints -- it exposes nothing beyond what the in-game browser
@@ -450,6 +805,11 @@ func startWebServer(objectStore *iniparse.ObjectStore, powerStore *iniparse.Powe
 		})
 	}
 
+	// Server status, including which INI data set is in service.
+	router.GET("/status", func(c *gin.Context) {
+		statusHandler(c, stores)
+	})
+
 	// Swagger UI (serves Swagger 2.0 interactive docs)
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -472,23 +832,66 @@ func startWebServer(objectStore *iniparse.ObjectStore, powerStore *iniparse.Powe
 	}
 }
 
+// maxReplayUploadBytes caps a /replay request body, as a batch upload
+// caps each replay in it.
+const maxReplayUploadBytes = batch.MaxEntryBytes
+
 // saveFileHandler parses an uploaded replay file.
 // @Summary Parse a replay file
-// @Description Upload a .rep replay file and receive parsed replay data in v2 format. Stats fields are populated when a matching stats file exists.
+// @Description Upload a .rep replay file and receive parsed replay data in v2 format. Stats fields are populated when a matching stats file exists: the one stored for the header's seed, map CRC and start time, so another game that happened to share the seed isn't picked up. The replay file is kept under its match, for /get_replay and /reparse; identical uploads are kept once. With async=true the replay is queued instead: the response is 202 with the job's status, and its Location header names /jobs/{id}, which reports progress and, once done, where to fetch the result. A full queue answers 503 with Retry-After. Without async the replay waits for a free parse worker, so at most PARSE_WORKERS replays are parsed at once either way. Uploads over 16 MiB are refused with 413.
 // @Tags replay
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "Replay file to parse"
+// @Param lang query string false "Language for displayNames, e.g. english, german (default english; falls back to english per name)"
+// @Param sections query string false "Comma-separated stats sections to include: players, buildEvents, killEvents, captureEvents, energyEvents, rankEvents, skillPointsEvents, sciencePointsEvents, radarEvents, deathEvents, battlePlanEvents, timeSeries (default all). players and deathEvents are always included for winner detection."
+// @Param async query bool false "Queue the replay and return a job instead of waiting for the result"
 // @Success 200 {object} zhreplay.EnhancedReplayV2
+// @Success 202 {object} jobs.Status
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Security BearerAuth
 // @Security ApiKeyAuth
 // @Router /replay [post]
-func saveFileHandler(c *gin.Context, objectStore *iniparse.ObjectStore, powerStore *iniparse.PowerStore, upgradeStore *iniparse.UpgradeStore, colorStore *iniparse.ColorStore) {
+func saveFileHandler(c *gin.Context, stores *datastore.Holder, repos *repositories, parses *jobs.Queue) {
+	async := false
+	if v := c.Query("async"); v != "" {
+		var err error
+		if async, err = strconv.ParseBool(v); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid async query parameter",
+				"details": fmt.Sprintf("got %q", v),
+			})
+			return
+		}
+	}
+	sections, err := statsfile.ParseSections(c.Query("sections"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid sections query parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+	// Winner detection reads who died; the rest is only reported.
+	sections |= statsfile.SectionPlayers | statsfile.SectionDeathEvents
+
+	// The replay is kept once parsed and, with async, until its job runs;
+	// cap it before any of it is read.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReplayUploadBytes)
 	file, err := c.FormFile("file")
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   "Replay upload too large",
+				"details": fmt.Sprintf("request body exceeds %d bytes", maxReplayUploadBytes),
+			})
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"message": "No file is received",
 		})
@@ -503,16 +906,190 @@ func saveFileHandler(c *gin.Context, objectStore *iniparse.ObjectStore, powerSto
 		return
 	}
 	defer fileIn.Close()
+	// The replay is kept once parsed, so read it whole.
+	data, err := io.ReadAll(fileIn)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Could not read uploaded file",
+		})
+		return
+	}
+
+	upload := replayUpload{name: file.Filename, client: clientName(c), data: data}
+	language := c.DefaultQuery("lang", "english")
+	if !async {
+		// Parsed in one of the workers' slots, so plain uploads get the
+		// same backpressure as queued ones.
+		var v2Replay *zhreplay.EnhancedReplayV2
+		parses.Do(func() {
+			v2Replay, _ = parseReplay(stores.Current(), repos, upload, sections, language)
+		})
+		c.JSON(http.StatusOK, v2Replay)
+		return
+	}
+
+	status, err := parses.Submit(func(id string) (string, error) {
+		v2Replay, _ := parseReplay(stores.Current(), repos, upload, sections, language)
+		out, err := json.Marshal(v2Replay)
+		if err != nil {
+			return "", err
+		}
+		if err := storage.PutBytes(repos.results, parseResultKey(id), out); err != nil {
+			return "", fmt.Errorf("store result: %w", err)
+		}
+		return "/jobs/" + id + "/result", nil
+	})
+	switch {
+	case errors.Is(err, jobs.ErrFull):
+		c.Header("Retry-After", "10")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Too many replays waiting to be parsed; try again shortly",
+		})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to queue replay",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.Header("Location", "/jobs/"+status.ID)
+	c.JSON(http.StatusAccepted, status)
+}
+
+// parseResultRetention is how long a parse job's result is kept after it
+// finishes.
+const parseResultRetention = time.Hour
+
+// parseResultKey is where a parse job's result is stored.
+func parseResultKey(id string) string {
+	return id + ".json"
+}
+
+// sweepParseResults deletes parse results older than parseResultRetention,
+// now and then every retention period. Results are otherwise deleted when
+// their job is forgotten, which never happens for jobs a previous run of
+// the server took.
+func sweepParseResults(results storage.Backend) {
+	sweep := func() {
+		objects, err := results.List("")
+		if err != nil {
+			log.WithError(err).Warn("Failed to list parse results")
+			return
+		}
+		cutoff := time.Now().Add(-parseResultRetention)
+		for _, o := range objects {
+			if !o.ModTime.Before(cutoff) {
+				continue
+			}
+			if err := results.Delete(o.Key); err != nil {
+				log.WithError(err).WithField("key", o.Key).Warn("Failed to delete parse result")
+			}
+		}
+	}
+	sweep()
+	go func() {
+		for range time.Tick(parseResultRetention) {
+			sweep()
+		}
+	}()
+}
+
+// envInt reads a positive integer setting from the environment, falling
+// back to def when it is unset or invalid.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		log.WithField("value", v).Warnf("could not parse %s; defaulting to %d", name, def)
+		return def
+	}
+	return n
+}
+
+// jobStatusHandler reports on a parse job.
+// @Summary Parse job status
+// @Description Returns a parse job's status: queued (with how many jobs are ahead of it), running, done (with result, where to fetch the parsed replay) or failed (with error). Jobs are known only to the server that took them and are forgotten an hour after they finish.
+// @Tags replay
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Status
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /jobs/{id} [get]
+func jobStatusHandler(c *gin.Context, parses *jobs.Queue) {
+	status, ok := parses.Get(c.Param("id"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Unknown or expired job",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
 
-	bp := &bitparse.BitParser{
-		Source:       fileIn,
-		ObjectStore:  objectStore,
-		PowerStore:   powerStore,
-		UpgradeStore: upgradeStore,
-		ColorStore:   colorStore,
+// jobResultHandler returns a done parse job's result.
+// @Summary Parse job result
+// @Description Returns the parsed replay of a done parse job, as POST /replay would have. 409 with the job's status until it is done, or if it failed.
+// @Tags replay
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} zhreplay.EnhancedReplayV2
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} jobs.Status
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /jobs/{id}/result [get]
+func jobResultHandler(c *gin.Context, parses *jobs.Queue, results storage.Backend) {
+	status, ok := parses.Get(c.Param("id"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Unknown or expired job",
+		})
+		return
+	}
+	if status.State != jobs.StateDone {
+		c.AbortWithStatusJSON(http.StatusConflict, status)
+		return
 	}
+	info, err := results.Stat(parseResultKey(status.ID))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read job result",
+			"details": err.Error(),
+		})
+		return
+	}
+	rc, err := results.Get(info.Key)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read job result",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer rc.Close()
+	c.DataFromReader(http.StatusOK, info.Size, "application/json; charset=utf-8", rc, nil)
+}
+
+// replayUpload is a replay file and who sent it.
+type replayUpload struct {
+	name, client string
+	data         []byte
+}
 
-	replay := zhreplay.NewReplay(bp)
+// parseReplay parses a replay with the bundle's data set, merging the
+// stats stored for its match, and records it with the replay file. It
+// returns the parsed replay and its record, nil if it wasn't recorded.
+func parseReplay(bundle *datastore.Bundle, repos *repositories, upload replayUpload, sections statsfile.Section, language string) (*zhreplay.EnhancedReplayV2, *matchdb.Match) {
+	replay := zhreplay.NewReplay(bundle.BitParser(bytes.NewReader(upload.data)))
 
 	// If a stats file exists for this seed, return enhanced v2 replay
 	seed := replay.Header.Metadata.Seed
@@ -520,313 +1097,1728 @@ func saveFileHandler(c *gin.Context, objectStore *iniparse.ObjectStore, powerSto
 	log.WithFields(log.Fields{
 		"seed":   seed,
 		"map":    replay.Header.Metadata.MapPath,
-		"client": clientName(c),
+		"client": upload.client,
 	}).Info("Replay parsed")
-	if seed != "" && statsfile.Exists(seed) {
-		stats, err := statsfile.Load(seed)
+	var v2Replay *zhreplay.EnhancedReplayV2
+	statsMatch := replayStatsMatch(repos.matches, repos.stats, replay)
+	if statsMatch != "" {
+		stats, err := repos.stats.LoadSections(statsMatch, sections)
 		if err != nil {
 			log.WithError(err).Warn("Failed to load stats file, returning replay-only v2")
-			c.JSON(http.StatusOK, zhreplay.ConvertToBasicEnhancedReplayV2(replay))
-			return
+			v2Replay = zhreplay.ConvertToBasicEnhancedReplayV2(replay)
+			statsMatch = ""
 		} else {
-			v2Replay := zhreplay.ConvertToEnhancedReplayV2(replay, stats, objectStore)
-			c.JSON(http.StatusOK, v2Replay)
-			return
+			v2Replay = zhreplay.ConvertToEnhancedReplayV2(replay, stats, bundle.Objects)
 		}
+	} else {
+		// No stats file, return v2 with replay data only
+		v2Replay = zhreplay.ConvertToBasicEnhancedReplayV2(replay)
 	}
 
-	// No stats file, return v2 with replay data only
-	c.JSON(http.StatusOK, zhreplay.ConvertToBasicEnhancedReplayV2(replay))
+	v2Replay.BuildPowerTimelines(bundle.Powers)
+	localizeReplay(v2Replay, bundle, repos.maps, language, replay.Header.Metadata.MapCRC)
+	m := recordMatch(repos, v2Replay, statsMatch, upload)
+	return v2Replay, m
 }
 
-// uploadStatsHandler stores a gzip-compressed stats payload.
-// @Summary Upload game stats
-// @Description Receive gzip-compressed JSON stats from a Generals game and store them keyed by seed. If a stats file already exists for the seed and the uploaded file is smaller, the upload is rejected with 409 unless force is set.
-// @Tags stats
-// @Accept octet-stream
+// replayUploadsHandler lists the replays stored for a match.
+// @Summary List stored replays for a match
+// @Description Return every distinct replay file stored for a match, in arrival order, with its content hash, size, uploaded file name, uploading client and upload time. Each player records their own replay of a match, so there can be several; identical uploads are kept once. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.
+// @Tags replay
 // @Produce json
-// @Param X-Game-Seed header string true "Game seed identifier"
-// @Param force query bool false "Overwrite an existing stats file even if the upload is smaller (can also be set via the X-Force header)"
-// @Success 200 {object} StatsUploadResponse
+// @Param seed query string true "Game seed identifier"
+// @Param mapCrc query string false "Map CRC (decimal)"
+// @Param start query int false "Game start time (Unix seconds)"
+// @Success 200 {array} replayfile.Upload
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
-// @Failure 409 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} MatchConflictResponse
 // @Failure 500 {object} ErrorResponse
 // @Security BearerAuth
 // @Security ApiKeyAuth
-// @Router /stats [post]
-func uploadStatsHandler(c *gin.Context) {
-	seed := c.GetHeader("X-Game-Seed")
-	if seed == "" {
+// @Router /replay_uploads [get]
+func replayUploadsHandler(c *gin.Context, matches *matchid.Index, replayRepo *replayfile.Repository) {
+	id, err := queryMatchID(c)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "X-Game-Seed header is required",
+			"error":   "Invalid match identity",
+			"details": err.Error(),
 		})
 		return
 	}
-
-	data, err := io.ReadAll(c.Request.Body)
+	match, ok := lookupMatch(c, matches, id, nil)
+	if !ok {
+		return
+	}
+	uploads, err := replayRepo.List(match)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to read request body",
+			"error":   "Failed to read replay uploads",
 			"details": err.Error(),
 		})
 		return
 	}
-
-	if len(data) == 0 {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "Empty request body",
+	if len(uploads) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "No replays stored for this match",
 		})
 		return
 	}
+	c.JSON(http.StatusOK, uploads)
+}
 
-	// Reject an upload that would replace an existing stats file with a
-	// smaller one, unless the caller explicitly forces the overwrite. This
-	// guards against a truncated or partial re-upload clobbering a more
-	// complete stats file for the same match.
-	force := isForced(c)
-	if !force {
-		if existing, err := statsfile.Size(seed); err == nil && int64(len(data)) < existing {
-			log.WithField("seed", seed).
-				WithField("size", len(data)).
-				WithField("existing", existing).
-				WithField("client", clientName(c)).
-				Warn("Rejected smaller stats upload")
-			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
-				"error":    "Stored stats file is larger than the uploaded file; refusing to overwrite. Set force=true to override.",
-				"seed":     seed,
-				"size":     len(data),
-				"existing": existing,
-			})
-			return
-		}
-	}
+// storedReplay loads the replay of the match the query names, the one
+// with the hash query parameter or else the first uploaded, or answers the
+// request itself and returns ok false.
+func storedReplay(c *gin.Context, matches *matchid.Index, replayRepo *replayfile.Repository) (data []byte, upload replayfile.Upload, ok bool) {
+	id, err := queryMatchID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid match identity",
+			"details": err.Error(),
+		})
+		return nil, upload, false
+	}
+	match, ok := lookupMatch(c, matches, id, nil)
+	if !ok {
+		return nil, upload, false
+	}
+	data, upload, err = replayRepo.Load(match, c.Query("hash"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "No such replay stored for this match",
+			"match": match,
+		})
+		return nil, upload, false
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load replay",
+			"details": err.Error(),
+		})
+		return nil, upload, false
+	}
+	return data, upload, true
+}
+
+// getReplayHandler returns a stored replay file.
+// @Summary Download a stored replay
+// @Description Return a replay file stored for a match, as it was uploaded: the one with the given hash (see /replay_uploads), or else the first uploaded. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.
+// @Tags replay
+// @Produce application/octet-stream
+// @Param seed query string true "Game seed identifier"
+// @Param mapCrc query string false "Map CRC (decimal)"
+// @Param start query int false "Game start time (Unix seconds)"
+// @Param hash query string false "Content hash of the replay"
+// @Success 200 {file} file "Replay file"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} MatchConflictResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /get_replay [get]
+func getReplayHandler(c *gin.Context, matches *matchid.Index, replayRepo *replayfile.Repository) {
+	data, upload, ok := storedReplay(c, matches, replayRepo)
+	if !ok {
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", upload.Name))
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// reparseHandler parses a stored replay again.
+// @Summary Parse a stored replay again
+// @Description Parse a replay stored for a match with the current parser and INI data, merging the stats stored now, and update the match's record, as if it had just been uploaded to /replay. Use it after a parser fix or a data set reload. Picks the replay with the given hash (see /replay_uploads), or else the first uploaded. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.
+// @Tags replay
+// @Produce json
+// @Param seed query string true "Game seed identifier"
+// @Param mapCrc query string false "Map CRC (decimal)"
+// @Param start query int false "Game start time (Unix seconds)"
+// @Param hash query string false "Content hash of the replay"
+// @Param lang query string false "Language for displayNames (default english)"
+// @Param sections query string false "Comma-separated stats sections to include, as for /replay (default all)"
+// @Success 200 {object} zhreplay.EnhancedReplayV2
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} MatchConflictResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /reparse [post]
+func reparseHandler(c *gin.Context, bundle *datastore.Bundle, repos *repositories) {
+	sections, err := statsfile.ParseSections(c.Query("sections"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid sections query parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+	sections |= statsfile.SectionPlayers | statsfile.SectionDeathEvents
+
+	data, stored, ok := storedReplay(c, repos.matches, repos.replays)
+	if !ok {
+		return
+	}
+	upload := replayUpload{name: stored.Name, client: stored.Client, data: data}
+	v2Replay, _ := parseReplay(bundle, repos, upload, sections, c.DefaultQuery("lang", "english"))
+	c.JSON(http.StatusOK, v2Replay)
+}
+
+// BatchFileResult reports on one file of a batch upload.
+type BatchFileResult struct {
+	// Name is the file's path in the archive.
+	Name string `json:"name" example:"Replays/Final.rep"`
+	Kind string `json:"kind" example:"replay"`
+	// Match is the identity the file was stored under; see pkg/matchid.
+	Match string `json:"match,omitempty" example:"12345-3862104857-1718000000"`
+	// Error is why the file wasn't stored.
+	Error string `json:"error,omitempty"`
+}
+
+// BatchUploadResponse reports on every file of a batch upload.
+type BatchUploadResponse struct {
+	// Replays and Stats count the files stored, Failed those that weren't.
+	Replays int `json:"replays" example:"12"`
+	Stats   int `json:"stats" example:"3"`
+	Failed  int `json:"failed" example:"1"`
+	// Ignored lists the archive's files that are neither replays nor stats.
+	Ignored []string          `json:"ignored"`
+	Files   []BatchFileResult `json:"files"`
+}
+
+// maxBatchUploadBytes caps a batch upload's request body; see pkg/batch
+// for the limits on what it decompresses to.
+const maxBatchUploadBytes = batch.MaxTotalBytes
+
+// batchUploadHandler stores and indexes an archive of replays.
+// @Summary Upload an archive of replays
+// @Description Upload a zip or tar.gz of .rep replay files, optionally with the .json.gz stats files the exporter wrote beside them, e.g. a tournament's replays collected from every player. Stats files are stored first, under the match their game section names; stats from older exporters without a map CRC and start time take them from the replay of the same name in the archive. Replays are then parsed concurrently and recorded as if uploaded one by one to /replay, merging their stats. The response reports on every file; one file failing doesn't stop the others. Other files are listed as ignored. Replays wait for the same parse workers as other uploads, and one batch takes at most half of them. The archive may be at most 64 MiB and hold at most 2000 files, 16 MiB each and 64 MiB in total.
+// @Tags replay
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Zip or tar.gz of replays and stats files"
+// @Success 200 {object} BatchUploadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /replay_batch [post]
+func batchUploadHandler(c *gin.Context, stores *datastore.Holder, repos *repositories, parses *jobs.Queue) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchUploadBytes)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   "Batch upload too large",
+				"details": fmt.Sprintf("request body exceeds %d bytes", maxBatchUploadBytes),
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "No file is received",
+			"details": err.Error(),
+		})
+		return
+	}
+	fileIn, err := file.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Could not open uploaded file",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer fileIn.Close()
+	data, err := io.ReadAll(fileIn)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Could not read uploaded file",
+			"details": err.Error(),
+		})
+		return
+	}
+	entries, ignored, err := batch.Read(data)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Upload is not a usable zip or tar.gz archive",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	bundle := stores.Current()
+	client := clientName(c)
+	replays := map[string][]byte{}
+	for _, e := range entries {
+		if e.Kind == batch.KindReplay {
+			replays[e.Stem()] = e.Data
+		}
+	}
+	files := make([]BatchFileResult, len(entries))
+	// Stats go first, so the replays' parses merge them.
+	for i, e := range entries {
+		files[i] = BatchFileResult{Name: e.Name, Kind: e.Kind}
+		if e.Kind == batch.KindStats {
+			files[i].Match, err = batchFile(func() (string, error) {
+				return storeBatchStats(repos, bundle, client, e.Data, replays[e.Stem()])
+			})
+			if err != nil {
+				files[i].Error = err.Error()
+			}
+		}
+	}
+	// A batch parses on at most half the parse workers' slots, so queued
+	// /replay jobs and other uploads keep the rest. A parsed replay's data
+	// is dropped as soon as it is recorded.
+	replays = nil
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range max(parses.Workers()/2, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				parses.Do(func() {
+					upload := replayUpload{name: path.Base(entries[i].Name), client: client, data: entries[i].Data}
+					match, err := batchFile(func() (string, error) {
+						return storeBatchReplay(bundle, repos, upload)
+					})
+					files[i].Match = match
+					if err != nil {
+						files[i].Error = err.Error()
+					}
+				})
+				entries[i].Data = nil
+			}
+		}()
+	}
+	for i, e := range entries {
+		if e.Kind == batch.KindReplay {
+			work <- i
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	resp := BatchUploadResponse{Ignored: ignored, Files: files}
+	if resp.Ignored == nil {
+		resp.Ignored = []string{}
+	}
+	for _, f := range files {
+		switch {
+		case f.Error != "":
+			resp.Failed++
+		case f.Kind == batch.KindReplay:
+			resp.Replays++
+		default:
+			resp.Stats++
+		}
+	}
+	log.WithFields(log.Fields{
+		"client":  client,
+		"replays": resp.Replays,
+		"stats":   resp.Stats,
+		"failed":  resp.Failed,
+		"ignored": len(ignored),
+	}).Info("Batch upload processed")
+	c.JSON(http.StatusOK, resp)
+}
+
+// batchFile stores one file of a batch upload, turning a panic into its
+// error so one broken file can't take the others down.
+func batchFile(store func() (string, error)) (match string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("could not be read: %v", p)
+		}
+	}()
+	return store()
+}
+
+// storeBatchStats stores a stats file from a batch upload as /stats
+// would, returning its match. replay is the archive's replay of the same
+// name, if any.
+func storeBatchStats(repos *repositories, bundle *datastore.Bundle, client string, data, replay []byte) (string, error) {
+	head, err := statsfile.DecodeGame(data)
+	if err != nil {
+		return "", fmt.Errorf("not gzip-compressed stats JSON: %w", err)
+	}
+	game := head.Game
+	seed := strconv.FormatUint(uint64(game.Seed), 10)
+	var id matchid.ID
+	if (game.MapCRC == "" || game.StartTime == 0) && replay != nil {
+		// Older exporters leave the map and start time out; the replay
+		// beside the stats has them.
+		id = matchid.FromHeader(header.NewHeader(bundle.BitParser(bytes.NewReader(replay))))
+		if game.Seed != 0 && id.Seed != seed {
+			return "", fmt.Errorf("stats seed %s doesn't match the replay's seed %s", seed, id.Seed)
+		}
+	} else {
+		if game.Seed == 0 {
+			return "", errors.New("stats name no seed")
+		}
+		if id, err = matchid.New(seed, game.MapCRC, game.StartTime); err != nil {
+			return "", err
+		}
+	}
+	if id, err = repos.matches.Resolve(id); err != nil {
+		return "", fmt.Errorf("update match index: %w", err)
+	}
+	match := id.String()
+
+	merged, _, err := repos.stats.StoreUpload(match, client, data, false)
+	if err != nil {
+		return match, err
+	}
+	linkMatch(repos.db, match, func(m *matchdb.Match) { m.Stats = match })
+	if repos.db != nil {
+		if m, err := repos.db.Get(match); err == nil {
+			observePlayers(repos.ids, m, merged.Players)
+		}
+	}
+	return match, nil
+}
+
+// storeBatchReplay parses and records a replay from a batch upload as
+// /replay would, returning its match.
+func storeBatchReplay(bundle *datastore.Bundle, repos *repositories, upload replayUpload) (string, error) {
+	// Only the players and who died are needed to record the match.
+	v2Replay, m := parseReplay(bundle, repos, upload, statsfile.SectionPlayers|statsfile.SectionDeathEvents, "english")
+	if m != nil {
+		return m.ID, nil
+	}
+	id := matchid.FromHeader(v2Replay.Header)
+	if id.Seed == "" {
+		return "", errors.New("not a replay: its header names no seed")
+	}
+	return id.String(), errors.New("parsed but not recorded; see the server log")
+}
+
+// reprocessParser parses stored replays again with the holder's current
+// data set, merging the stats stored for each match.
+func reprocessParser(stores *datastore.Holder, repos *repositories) reprocess.Parser {
+	return func(match string, data []byte) (*matchdb.Match, error) {
+		// The replay is stored already, so recording it again keeps the
+		// original upload.
+		upload := replayUpload{name: match + ".rep", client: "reprocess", data: data}
+		_, m := parseReplay(stores.Current(), repos, upload, statsfile.SectionPlayers|statsfile.SectionDeathEvents, "english")
+		if m == nil {
+			return nil, errors.New("the match wasn't recorded; see the server log")
+		}
+		return m, nil
+	}
+}
+
+// runReprocess runs a reprocessing job in the foreground, logging its
+// progress, and prints its report. Interrupting it stops the job after the
+// replay being parsed.
+func runReprocess(stores *datastore.Holder, repos *repositories) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	job := reprocess.NewJob(repos.replays, repos.db, reprocessParser(stores, repos))
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r := job.Report()
+				log.WithFields(log.Fields{"done": r.Done, "total": r.Total}).Info("Reprocessing replays")
+			}
+		}
+	}()
+	report, err := job.Run(ctx)
+	close(done)
+
+	out, jsonErr := json.MarshalIndent(report, "", "  ")
+	if jsonErr != nil {
+		log.WithError(jsonErr).Fatal("could not marshal reprocessing report")
+	}
+	fmt.Println(string(out))
+	log.WithFields(log.Fields{
+		"total":    report.Total,
+		"changed":  len(report.Changes),
+		"failures": len(report.Failures),
+	}).Info("Reprocessing finished")
+	if err != nil {
+		log.WithError(err).Fatal("Reprocessing stopped")
+	}
+}
+
+// startReprocessHandler starts a reprocessing job.
+// @Summary Parse every stored replay again
+// @Description Starts a background job that parses every stored replay again with the current parser and INI data set, merging the stats stored for each match, and updates the match database, as /reparse does for one match. Poll GET /admin/reprocess for its progress and report, and DELETE it to stop the job. Only one job runs at a time; 409 while one is running. Each match's replay is the one its record was built from, or the first uploaded for matches the database has no record of. The same job runs from the command line as "cncstats reprocess". Needs an operator key from CNC_ADMIN_KEYS.
+// @Tags admin
+// @Produce json
+// @Success 202 {object} reprocess.Report
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/reprocess [post]
+func startReprocessHandler(c *gin.Context, runner *reprocess.Runner, stores *datastore.Holder, repos *repositories) {
+	job := reprocess.NewJob(repos.replays, repos.db, reprocessParser(stores, repos))
+	if err := runner.Start(job); err != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error":   "A reprocessing job is already running",
+			"details": err.Error(),
+		})
+		return
+	}
+	log.WithField("client", clientName(c)).Info("Reprocessing job started")
+	c.JSON(http.StatusAccepted, job.Report())
+}
+
+// reprocessStatusHandler reports on the latest reprocessing job.
+// @Summary Reprocessing job status
+// @Description Returns the running or last finished reprocessing job's report: its state (running, done, failed or canceled), how many of the stored replays it has parsed, how many failed and why, and each match whose record changed (added when the match database had no record of it) with what changed: win method, or a slot's result, side, faction or spending. fields counts the changes by field. 404 before the first job. Needs an operator key from CNC_ADMIN_KEYS.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} reprocess.Report
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/reprocess [get]
+func reprocessStatusHandler(c *gin.Context, runner *reprocess.Runner) {
+	job := runner.Latest()
+	if job == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "No reprocessing job has run",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, job.Report())
+}
+
+// cancelReprocessHandler stops the running reprocessing job.
+// @Summary Cancel the reprocessing job
+// @Description Stops the running reprocessing job after the replay it is parsing. The job's state becomes canceled; GET /admin/reprocess reports how far it got. 409 when no job is running. Needs an operator key from CNC_ADMIN_KEYS.
+// @Tags admin
+// @Produce json
+// @Success 202 {object} reprocess.Report
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/reprocess [delete]
+func cancelReprocessHandler(c *gin.Context, runner *reprocess.Runner) {
+	if err := runner.Cancel(); err != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error":   "No reprocessing job is running",
+			"details": err.Error(),
+		})
+		return
+	}
+	log.WithField("client", clientName(c)).Info("Reprocessing job canceled")
+	c.JSON(http.StatusAccepted, runner.Latest().Report())
+}
+
+// recordMatch adds a parsed replay to the match database under the
+// identity of its match, storing the replay file and linking the stats and
+// logs stored for it. It returns the match's record, or nil if it wasn't
+// recorded. Failures are only logged: the caller still gets its parsed
+// replay.
+func recordMatch(repos *repositories, v2Replay *zhreplay.EnhancedReplayV2, statsMatch string, upload replayUpload) *matchdb.Match {
+	id := matchid.FromHeader(v2Replay.Header)
+	if repos.db == nil || id.Seed == "" {
+		return nil
+	}
+	resolved, err := repos.matches.Resolve(id)
+	if err != nil {
+		log.WithError(err).WithField("seed", id.Seed).Warn("Failed to resolve match, not recording it")
+		return nil
+	}
+	m := matchdb.FromReplay(resolved, v2Replay)
+	m.Stats = statsMatch
+	if u, _, err := repos.replays.Store(m.ID, upload.client, upload.name, upload.data); err != nil {
+		log.WithError(err).WithField("match", m.ID).Warn("Failed to store replay")
+	} else {
+		m.Replay, m.ReplayHash = m.ID, u.Hash
+	}
+	for _, key := range []string{resolved.String(), id.Seed} {
+		if repos.logs.Exists(key) {
+			m.Logs = key
+			break
+		}
+	}
+	if err := repos.db.Put(m); err != nil {
+		log.WithError(err).WithField("match", m.ID).Warn("Failed to record match")
+		return nil
+	}
+
+	var statsPlayers []statsfile.Player
+	if statsMatch != "" {
+		if stats, err := repos.stats.LoadSections(statsMatch, statsfile.SectionPlayers); err == nil {
+			statsPlayers = stats.Players
+		}
+	}
+	observePlayers(repos.ids, m, statsPlayers)
+	return m
+}
+
+// observePlayers records the humans of a recorded match, with their header
+// addresses and any stats DisplayName that differs from the header name,
+// as identity evidence. Failures are only logged.
+func observePlayers(ids *identity.Store, m *matchdb.Match, statsPlayers []statsfile.Player) {
+	var obs []identity.Observation
+	var nonObservers []matchdb.Player
+	for _, p := range m.Players {
+		if !p.Observer {
+			nonObservers = append(nonObservers, p)
+		}
+		if !p.Human || p.Name == "" {
+			continue
+		}
+		o := identity.Observation{Alias: p.Name, Match: m.ID, At: m.Time()}
+		// Skirmish and some LAN slots record the address as 0.
+		if strings.Trim(p.IP, "0") != "" {
+			o.Kind, o.Value = identity.KindIP, p.IP
+		}
+		obs = append(obs, o)
+	}
+	// Stats players are numbered from 1 over the non-observer slots.
+	for _, sp := range statsPlayers {
+		i := sp.Index - 1
+		if i < 0 || i >= len(nonObservers) || !nonObservers[i].Human {
+			continue
+		}
+		name := nonObservers[i].Name
+		if sp.DisplayName == "" || name == "" || sp.DisplayName == name {
+			continue
+		}
+		slot := fmt.Sprintf("%s/%d", m.ID, nonObservers[i].Slot)
+		obs = append(obs,
+			identity.Observation{Alias: name, Kind: identity.KindDisplay, Value: slot, Match: m.ID, At: m.Time()},
+			identity.Observation{Alias: sp.DisplayName, Kind: identity.KindDisplay, Value: slot, Match: m.ID, At: m.Time()},
+		)
+	}
+	if err := ids.Observe(obs...); err != nil {
+		log.WithError(err).WithField("match", m.ID).Warn("Failed to record player identities")
+	}
+}
+
+// linkMatch records in the match database that a file has been stored for
+// a match, if its replay has been parsed. Failures are only logged.
+func linkMatch(db *matchdb.DB, match string, link func(*matchdb.Match)) {
+	if db == nil {
+		return
+	}
+	if err := db.Update(match, link); err != nil && !errors.Is(err, matchdb.ErrNotFound) {
+		log.WithError(err).WithField("match", match).Warn("Failed to update match record")
+	}
+}
+
+// replayStatsMatch returns the key of the stats stored for a replay's
+// match, or "" if there are none. The header's map CRC and start time pick
+// out this replay's match among others that share its seed; stats stored
+// under the bare seed are used when no identified match fits.
+func replayStatsMatch(matches *matchid.Index, statsRepo *statsfile.Repository, replay *zhreplay.Replay) string {
+	id := matchid.FromHeader(replay.Header)
+	if id.Seed == "" {
+		return ""
+	}
+	found, err := matches.Lookup(id)
+	if err != nil {
+		log.WithError(err).WithField("seed", id.Seed).Warn("Failed to look up match, ignoring stats")
+		return ""
+	}
+	if key := matchid.StoredKey(found, statsRepo.Exists); statsRepo.Exists(key) {
+		return key
+	}
+	return ""
+}
+
+// localizeReplay fills in display names from the bundle's string tables,
+// preferring the map's own map.str (custom maps rename units there) when we
+// have one stored for the replay's map CRC.
+func localizeReplay(v2Replay *zhreplay.EnhancedReplayV2, bundle *datastore.Bundle, mapRepo *mapfile.Repository, language string, headerCRC string) {
+	if len(bundle.Strings) == 0 {
+		return
+	}
+	var mapStrings *gametext.Table
+	if crc, err := mapfile.CRCFromHeader(headerCRC); err == nil {
+		if data, err := mapRepo.LoadAsset(crc, mapfile.KindStr); err == nil {
+			mapStrings, err = gametext.ParseStr(bytes.NewReader(data), language)
+			if err != nil {
+				log.WithError(err).WithField("crc", crc).Warn("Failed to parse map.str, ignoring")
+			}
+		}
+	}
+	v2Replay.Localize(language, bundle.DisplayLabel, bundle.StringTables(language, mapStrings)...)
+}
+
+// uploadStatsHandler stores a gzip-compressed stats payload and merges it
+// with earlier uploads for the same match.
+// @Summary Upload game stats
+// @Description Receive gzip-compressed JSON stats from a Generals game, keyed by match: the seed plus, when known, the map CRC and start time from the payload's game info (or the X-Map-CRC and X-Game-Start headers), so unrelated games that share a seed are stored apart. Start times within 10 minutes of each other are the same match, allowing for the players' clocks to differ. Every player's exporter may upload the same match; each upload is kept as sent and the stored stats are rebuilt as their merge: events are unioned with duplicates removed, each player's longest time series is kept, and game info comes from the upload that ran the most frames. Set force to make this upload replace the earlier ones in the merge (they are still kept). Uploads are validated on arrival: corrupt gzip or JSON is rejected with 400, and payloads with an unsupported version, missing game info, out-of-range or duplicate player indices, events out of frame order or naming unknown players, or time series that don't fit the snapshot interval are rejected with 422 listing each problem. Version 1 payloads are migrated to the current version.
+// @Tags stats
+// @Accept octet-stream
+// @Produce json
+// @Param X-Game-Seed header string true "Game seed identifier"
+// @Param X-Map-CRC header string false "Map CRC (decimal), if the payload's game info lacks mapCRC"
+// @Param X-Game-Start header int false "Game start time in Unix seconds, if the payload's game info lacks startTime"
+// @Param force query bool false "Merge only this upload, superseding earlier ones (can also be set via the X-Force header)"
+// @Success 200 {object} StatsUploadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 422 {object} StatsValidationResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /stats [post]
+func uploadStatsHandler(c *gin.Context, matches *matchid.Index, statsRepo *statsfile.Repository, db *matchdb.DB, ids *identity.Store) {
+	seed := c.GetHeader("X-Game-Seed")
+	if seed == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "X-Game-Seed header is required",
+		})
+		return
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(data) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Empty request body",
+		})
+		return
+	}
+
+	head, err := statsfile.DecodeGame(data)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Stats upload is not gzip-compressed stats JSON",
+			"details": err.Error(),
+		})
+		return
+	}
+	id, err := uploadMatchID(c, seed, &head.Game)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid match identity",
+			"details": err.Error(),
+		})
+		return
+	}
+	if id, err = matches.Resolve(id); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update match index",
+			"details": err.Error(),
+		})
+		return
+	}
+	match := id.String()
+
+	merged, contributors, err := statsRepo.StoreUpload(match, clientName(c), data, isForced(c))
+	var invalid statsfile.ValidationErrors
+	switch {
+	case errors.As(err, &invalid):
+		log.WithField("match", match).
+			WithField("client", clientName(c)).
+			WithField("problems", len(invalid)).
+			Warn("Rejected invalid stats upload")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, StatsValidationResponse{
+			Error:      "Stats upload failed validation",
+			Validation: invalid,
+		})
+		return
+	case errors.Is(err, statsfile.ErrBadUpload):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Stats upload is not gzip-compressed stats JSON",
+			"details": err.Error(),
+		})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store stats file",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	log.WithField("match", match).
+		WithField("size", len(data)).
+		WithField("uploads", len(contributors)).
+		WithField("client", clientName(c)).
+		Info("Stats file stored")
+	linkMatch(db, match, func(m *matchdb.Match) { m.Stats = match })
+	if db != nil {
+		if m, err := db.Get(match); err == nil {
+			observePlayers(ids, m, merged.Players)
+		}
+	}
+	c.JSON(http.StatusOK, StatsUploadResponse{
+		Message:      "Stats stored successfully",
+		Seed:         seed,
+		Match:        match,
+		Size:         len(data),
+		Uploads:      len(contributors),
+		FrameCount:   merged.Game.FrameCount,
+		Contributors: contributors,
+	})
+}
+
+// statsContributorsHandler lists the uploads merged into a match's stats.
+// @Summary List stats uploads for a match
+// @Description Return every stats upload recorded for a match, in arrival order, with the uploading client, size, frame count and whether a later forced upload superseded it. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.
+// @Tags stats
+// @Produce json
+// @Param seed query string true "Game seed identifier"
+// @Param mapCrc query string false "Map CRC (decimal)"
+// @Param start query int false "Game start time (Unix seconds)"
+// @Success 200 {array} statsfile.Contributor
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} MatchConflictResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /stats_uploads [get]
+func statsContributorsHandler(c *gin.Context, matches *matchid.Index, statsRepo *statsfile.Repository) {
+	id, err := queryMatchID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid match identity",
+			"details": err.Error(),
+		})
+		return
+	}
+	match, ok := lookupMatch(c, matches, id, statsRepo.Exists)
+	if !ok {
+		return
+	}
+	contributors, err := statsRepo.Contributors(match)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read stats uploads",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(contributors) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "No stats uploads recorded for this match",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, contributors)
+}
+
+// uploadLogsHandler stores one or more log files for a match. The client
+// sends a multipart form containing that player's log file(s); the match
+// is identified by X-Game-Seed (with X-Map-CRC and X-Game-Start when
+// sent) and the player by X-Player. Each form file is stored under
+// <match>/<player>/<original filename>. Call once per player (each call
+// may carry multiple files).
+// @Summary Upload match log files for a player
+// @Description Store one or more client log files for a match, keyed by match (the game seed plus, when sent, the map CRC and start time) and grouped by player. Send a multipart/form-data body with one or more file parts (any field names); files are expected to be gzip-compressed by the client. Call once per player. The total request body is capped at 64 MiB.
+// @Tags logs
+// @Accept multipart/form-data
+// @Produce json
+// @Param X-Game-Seed header string true "Game seed identifying the match"
+// @Param X-Map-CRC header string false "Map CRC (decimal)"
+// @Param X-Game-Start header int false "Game start time in Unix seconds"
+// @Param X-Player header string true "Player identifier the logs belong to"
+// @Param files formData file true "One or more log files (gzip-compressed)"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /logs [post]
+func uploadLogsHandler(c *gin.Context, matches *matchid.Index, logRepo *logfile.Repository, db *matchdb.DB, ids *identity.Store) {
+	seed := c.GetHeader("X-Game-Seed")
+	if seed == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "X-Game-Seed header is required",
+		})
+		return
+	}
+	player := c.GetHeader("X-Player")
+	if player == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "X-Player header is required",
+		})
+		return
+	}
+	id, err := uploadMatchID(c, seed, nil)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid match identity",
+			"details": err.Error(),
+		})
+		return
+	}
+	if id, err = matches.Resolve(id); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update match index",
+			"details": err.Error(),
+		})
+		return
+	}
+	match := id.String()
+
+	// Cap the total request body so a misbehaving (or malicious) client can't
+	// exhaust server memory/disk. Logs are uploaded pre-gzipped by the client,
+	// so a whole match's worth of one player's logs sits comfortably under
+	// this ceiling; anything larger is rejected before it's read.
+	const maxLogUploadBytes = 64 << 20 // 64 MiB per request, post-gzip
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxLogUploadBytes)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   "Log upload too large",
+				"details": fmt.Sprintf("request body exceeds %d bytes", maxLogUploadBytes),
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Could not parse multipart form",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Gather every file part regardless of field name so clients can use
+	// whatever names are convenient.
+	var files []*multipart.FileHeader
+	for _, fhs := range form.File {
+		files = append(files, fhs...)
+	}
+	if len(files) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "No log files received",
+		})
+		return
+	}
+
+	stored := make([]string, 0, len(files))
+	var total int
+	for _, fh := range files {
+		fileIn, err := fh.Open()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":   "Could not open uploaded file",
+				"details": err.Error(),
+			})
+			return
+		}
+		data, err := io.ReadAll(fileIn)
+		fileIn.Close()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to read uploaded file",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := logRepo.Store(match, player, fh.Filename, data); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to store log file",
+				"details": err.Error(),
+			})
+			return
+		}
+		stored = append(stored, fh.Filename)
+		total += len(data)
+	}
+
+	log.WithFields(log.Fields{
+		"match":  match,
+		"player": player,
+		"files":  len(stored),
+		"size":   total,
+		"client": clientName(c),
+	}).Info("Log files stored")
+	linkMatch(db, match, func(m *matchdb.Match) { m.Logs = match })
+	// A client uploading as several players is evidence they are one;
+	// anonymous uploads say nothing.
+	obs := identity.Observation{Alias: player, Match: match}
+	if client := c.GetString("client"); client != "" {
+		obs.Kind, obs.Value = identity.KindClient, client
+	}
+	if err := ids.Observe(obs); err != nil {
+		log.WithError(err).WithField("match", match).Warn("Failed to record player identity")
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logs stored successfully",
+		"seed":    seed,
+		"match":   match,
+		"player":  player,
+		"files":   stored,
+		"size":    total,
+	})
+}
+
+// getLogsHandler returns a zip archive of every stored log file for a
+// match. Entries inside the zip are named "<player>/<filename>" so the
+// per-player grouping is preserved on extraction.
+// @Summary Download all match logs as a zip
+// @Description Returns a zip archive of every log file stored for the given match, with entries named "<player>/<filename>". 404 if no logs are stored for that match. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.
+// @Tags logs
+// @Produce application/zip
+// @Param seed query string true "Game seed identifying the match"
+// @Param mapCrc query string false "Map CRC (decimal)"
+// @Param start query int false "Game start time (Unix seconds)"
+// @Success 200 {file} file "Zip archive (application/zip)"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} MatchConflictResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /get_logs [get]
+func getLogsHandler(c *gin.Context, matches *matchid.Index, logRepo *logfile.Repository) {
+	id, err := queryMatchID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid match identity",
+			"details": err.Error(),
+		})
+		return
+	}
+	match, ok := lookupMatch(c, matches, id, logRepo.Exists)
+	if !ok {
+		return
+	}
 
-	if err := statsfile.Store(seed, data); err != nil {
+	files, err := logRepo.List(match)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to store stats file",
+			"error":   "Failed to list logs",
 			"details": err.Error(),
 		})
 		return
 	}
+	if len(files) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "no logs stored for that match",
+			"match": match,
+		})
+		return
+	}
 
-	log.WithField("seed", seed).WithField("size", len(data)).WithField("client", clientName(c)).Info("Stats file stored")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Stats stored successfully",
-		"seed":    seed,
-		"size":    len(data),
-	})
+	// Log files are text and modest in size; build the zip in memory.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	var zipErr error
+	for _, f := range files {
+		data, err := logRepo.Load(match, f.Player, f.Name)
+		if err != nil {
+			// List said it's there; if Load disagrees, race with a
+			// concurrent delete. Skip rather than abort.
+			continue
+		}
+		entry, err := zw.Create(f.Player + "/" + f.Name)
+		if err != nil {
+			zipErr = err
+			break
+		}
+		if _, err := entry.Write(data); err != nil {
+			zipErr = err
+			break
+		}
+	}
+	if zipErr == nil {
+		zipErr = zw.Close()
+	}
+	if zipErr != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build zip archive",
+			"details": zipErr.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", match+"-logs.zip"))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
 }
 
-// uploadLogsHandler stores one or more log files for a match. The client
-// sends a multipart form containing that player's log file(s); the match
-// is identified by X-Game-Seed and the player by X-Player. Each form file
-// is stored under <seed>/<player>/<original filename>. Call once per
-// player (each call may carry multiple files).
-// @Summary Upload match log files for a player
-// @Description Store one or more client log files for a match, keyed by game seed and grouped by player. Send a multipart/form-data body with one or more file parts (any field names); files are expected to be gzip-compressed by the client. Call once per player. The total request body is capped at 64 MiB.
-// @Tags logs
-// @Accept multipart/form-data
+// matchInfoHandler returns the match database's record of a parsed match.
+// @Summary Recorded match details
+// @Description Return what the server recorded when it parsed a match's replay: seed, map, date (from the header's SYSTEMTIME), game version, duration, win method, every player slot with faction, team, color and result, and the keys the match's stats, logs and replay are stored under. 404 until a replay of the match has been parsed. If several matches share the seed, pass mapCrc or start to choose one; otherwise the response is 409 listing them.
+// @Tags matches
 // @Produce json
-// @Param X-Game-Seed header string true "Game seed identifying the match"
-// @Param X-Player header string true "Player identifier the logs belong to"
-// @Param files formData file true "One or more log files (gzip-compressed)"
-// @Success 200 {object} map[string]any
+// @Param seed query string true "Game seed identifier"
+// @Param mapCrc query string false "Map CRC (decimal)"
+// @Param start query int false "Game start time (Unix seconds)"
+// @Success 200 {object} matchdb.Match
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
-// @Failure 413 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} MatchConflictResponse
 // @Failure 500 {object} ErrorResponse
 // @Security BearerAuth
 // @Security ApiKeyAuth
-// @Router /logs [post]
-func uploadLogsHandler(c *gin.Context) {
-	seed := c.GetHeader("X-Game-Seed")
-	if seed == "" {
+// @Router /match_info [get]
+func matchInfoHandler(c *gin.Context, matches *matchid.Index, db *matchdb.DB) {
+	id, err := queryMatchID(c)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "X-Game-Seed header is required",
+			"error":   "Invalid match identity",
+			"details": err.Error(),
 		})
 		return
 	}
-	player := c.GetHeader("X-Player")
-	if player == "" {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "X-Player header is required",
+	match, ok := lookupMatch(c, matches, id, nil)
+	if !ok {
+		return
+	}
+	m, err := db.Get(match)
+	switch {
+	case errors.Is(err, matchdb.ErrNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "no replay of that match has been parsed",
+			"match": match,
+		})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read match database",
+			"details": err.Error(),
 		})
 		return
 	}
+	c.JSON(http.StatusOK, m)
+}
 
-	// Cap the total request body so a misbehaving (or malicious) client can't
-	// exhaust server memory/disk. Logs are uploaded pre-gzipped by the client,
-	// so a whole match's worth of one player's logs sits comfortably under
-	// this ceiling; anything larger is rejected before it's read.
-	const maxLogUploadBytes = 64 << 20 // 64 MiB per request, post-gzip
-	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxLogUploadBytes)
+// MatchSearchResponse is one page of match search results.
+type MatchSearchResponse struct {
+	Total   int              `json:"total"`
+	Limit   int              `json:"limit"`
+	Offset  int              `json:"offset"`
+	Matches []*matchdb.Match `json:"matches"`
+}
 
-	form, err := c.MultipartForm()
+// Page size bounds for /matches.
+const (
+	defaultMatchSearchLimit = 50
+	maxMatchSearchLimit     = 500
+)
+
+// searchMatchesHandler searches the match database.
+// @Summary Search matches
+// @Description Returns the recorded matches every given filter finds, newest first by default. player finds every nickname linked to the player (see /player_identity); faction, result and used then apply to that player's seat, or to any seat without player. Observers' seats never match. map takes a map CRC or part of the map's name. teamSize is the size of the largest team: 1 for 1v1 and free-for-all games, 2 for 2v2. result is win or loss (both need player), or unknown for matches without two teams that finished differently. used is a unit, building, upgrade or power template name, e.g. AmericaJetRaptor; templates are recorded when a replay is parsed, so matches parsed before have none until their replay is parsed again.
+// @Tags matches
+// @Produce json
+// @Param player query string false "Player nickname or ID"
+// @Param faction query string false "Faction name, e.g. \"USA Airforce\", case-insensitive"
+// @Param map query string false "Map CRC, or part of the map name"
+// @Param version query string false "Game version from the replay header, e.g. \"Version 1.04\""
+// @Param from query string false "Earliest date played, YYYY-MM-DD or RFC 3339"
+// @Param to query string false "Last date played (inclusive), YYYY-MM-DD, or an RFC 3339 time (exclusive)"
+// @Param minDuration query int false "Shortest duration, seconds"
+// @Param maxDuration query int false "Longest duration, seconds"
+// @Param teamSize query int false "Players on the largest team"
+// @Param result query string false "win, loss or unknown"
+// @Param hasStats query bool false "Whether stats were uploaded"
+// @Param hasLogs query bool false "Whether logs were uploaded"
+// @Param used query string false "Unit, building, upgrade or power template name"
+// @Param sort query string false "time (default), duration or map"
+// @Param order query string false "desc (default) or asc"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {object} MatchSearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /matches [get]
+func searchMatchesHandler(c *gin.Context, searches *search.Cache) {
+	badRequest := func(name, v string) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("invalid %s query parameter", name),
+			"details": fmt.Sprintf("got %q", v),
+		})
+	}
+	ints := map[string]int{"minDuration": 0, "maxDuration": 0, "teamSize": 0, "limit": defaultMatchSearchLimit, "offset": 0}
+	for name := range ints {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (name == "limit" && (n < 1 || n > maxMatchSearchLimit)) {
+			badRequest(name, v)
+			return
+		}
+		ints[name] = n
+	}
+	q := search.Query{
+		Player:     c.Query("player"),
+		Faction:    c.Query("faction"),
+		Map:        c.Query("map"),
+		Version:    c.Query("version"),
+		MinSeconds: ints["minDuration"],
+		MaxSeconds: ints["maxDuration"],
+		TeamSize:   ints["teamSize"],
+		Result:     c.Query("result"),
+		Used:       c.Query("used"),
+		Sort:       c.Query("sort"),
+	}
+	for name, dst := range map[string]**bool{"hasStats": &q.HasStats, "hasLogs": &q.HasLogs} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			badRequest(name, v)
+			return
+		}
+		*dst = &b
+	}
+	switch order := c.Query("order"); order {
+	case "", "desc":
+		q.Descending = true
+	case "asc":
+	default:
+		badRequest("order", order)
+		return
+	}
+	var err error
+	if q.From, err = queryDate(c, "from", false); err == nil {
+		q.To, err = queryDate(c, "to", true)
+	}
+	if err == nil {
+		err = q.Validate()
+	}
 	if err != nil {
-		var maxErr *http.MaxBytesError
-		if errors.As(err, &maxErr) {
-			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
-				"error":   "Log upload too large",
-				"details": fmt.Sprintf("request body exceeds %d bytes", maxLogUploadBytes),
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid search",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	found, err := searches.Run(q)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read match database",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	resp := MatchSearchResponse{Total: len(found), Limit: ints["limit"], Offset: ints["offset"]}
+	start := min(resp.Offset, len(found))
+	end := min(start+resp.Limit, len(found))
+	resp.Matches = found[start:end]
+	if resp.Matches == nil {
+		resp.Matches = []*matchdb.Match{}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RatingListResponse is one page of the rating leaderboard.
+type RatingListResponse struct {
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+	Rated   int            `json:"rated"`
+	Skipped map[string]int `json:"skipped"`
+	Ratings []rating.Entry `json:"ratings"`
+}
+
+// Page size bounds for /ratings.
+const (
+	defaultRatingListLimit = 50
+	maxRatingListLimit     = 500
+)
+
+// ratingsHandler pages through the rating leaderboard.
+// @Summary Rating leaderboard
+// @Description Returns Glicko-2 ratings computed from every recorded match, best first. Players are rated by identity, across every nickname linked to them (see /player_identity); name is the one they last played under. Matches are rated oldest first; each player is rated against every opposing team that finished differently, and deviation grows by one rating period per day without games, up to now. Games with AI players, games whose winner was guessed from the last command (winMethod "lastCommand") and games without two teams with different results are left out and counted in skipped. With faction, ranks players' ratings with that faction instead of their overall ratings.
+// @Tags ratings
+// @Produce json
+// @Param faction query string false "Faction name, e.g. \"USA Airforce\""
+// @Param minGames query int false "Only players with at least this many rated games"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {object} RatingListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ratings [get]
+func ratingsHandler(c *gin.Context, ratings *rating.Cache) {
+	ints := map[string]int{"minGames": 0, "limit": defaultRatingListLimit, "offset": 0}
+	for name := range ints {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (name == "limit" && (n < 1 || n > maxRatingListLimit)) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("invalid %s query parameter", name),
+				"details": fmt.Sprintf("got %q", v),
 			})
 			return
 		}
+		ints[name] = n
+	}
+
+	table, err := ratings.Table()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute ratings",
+			"details": err.Error(),
+		})
+		return
+	}
+	entries := table.Leaderboard(c.Query("faction"), ints["minGames"], time.Now())
+
+	resp := RatingListResponse{
+		Total:   len(entries),
+		Limit:   ints["limit"],
+		Offset:  ints["offset"],
+		Rated:   table.Rated,
+		Skipped: table.Skipped,
+	}
+	start := min(resp.Offset, len(entries))
+	end := min(start+resp.Limit, len(entries))
+	resp.Ratings = entries[start:end]
+	c.JSON(http.StatusOK, resp)
+}
+
+// PlayerRatingResponse is a player's overall rating and their rating with
+// each faction they played.
+type PlayerRatingResponse struct {
+	rating.Entry
+	Factions []rating.Entry `json:"factions"`
+}
+
+// playerRatingHandler returns one player's ratings.
+// @Summary Player rating
+// @Description Returns a player's overall Glicko-2 rating and their rating with each faction they played, best first, computed as for /ratings. Games under every nickname linked to the player (see /player_identity) count.
+// @Tags ratings
+// @Produce json
+// @Param player query string true "Player nickname or ID"
+// @Success 200 {object} PlayerRatingResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /player_rating [get]
+func playerRatingHandler(c *gin.Context, ratings *rating.Cache) {
+	name := c.Query("player")
+	if name == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "player query parameter is required",
+		})
+		return
+	}
+	table, err := ratings.Table()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute ratings",
+			"details": err.Error(),
+		})
+		return
+	}
+	overall, factions, ok := table.Player(name, time.Now())
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error":  "player has no rated games",
+			"player": name,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, PlayerRatingResponse{Entry: overall, Factions: factions})
+}
+
+// playerIdentityHandler returns the player a nickname belongs to.
+// @Summary Player identity
+// @Description Returns the player a nickname or player ID belongs to: a stable ID, the nickname they were last seen under and every nickname linked to them, oldest first. Nicknames are linked when seen in enough matches with the same header IP address or uploading logs as X-Player through the same API client, unless they played in one match, or under a different stats DisplayName in one match slot. An address or client seen with many nicknames links none of them. Admins can merge and split players.
+// @Tags players
+// @Produce json
+// @Param player query string true "Player nickname or ID"
+// @Success 200 {object} identity.Player
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /player_identity [get]
+func playerIdentityHandler(c *gin.Context, ids *identity.Store) {
+	name := c.Query("player")
+	if name == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "player query parameter is required",
+		})
+		return
+	}
+	dir, err := ids.Directory()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read player identities",
+			"details": err.Error(),
+		})
+		return
+	}
+	p, ok := dir.Lookup(name)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error":  "player has never been seen",
+			"player": name,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// MergePlayersRequest asks for two players to be recorded as one.
+type MergePlayersRequest struct {
+	// Player and Into are nicknames or player IDs.
+	Player string `json:"player" binding:"required"`
+	Into   string `json:"into" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// SplitPlayerRequest asks for a nickname to be separated from the others
+// linked to it.
+type SplitPlayerRequest struct {
+	Player string `json:"player" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// identityChangeError answers a failed merge or split: 404 for an unknown
+// player, 409 for a change that does not apply, 500 otherwise.
+func identityChangeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, identity.ErrUnknown):
+		status = http.StatusNotFound
+	case errors.Is(err, identity.ErrNoChange):
+		status = http.StatusConflict
+	}
+	c.AbortWithStatusJSON(status, gin.H{
+		"error":   "Failed to change player identities",
+		"details": err.Error(),
+	})
+}
+
+// mergePlayersHandler records an admin decision that two players are one.
+// @Summary Merge two players
+// @Description Record that two players, each named by a nickname or ID, are one person, whatever the evidence says. Splits between their nicknames are dropped. The merged player keeps the older ID. Returns the merged player. The decision is written to the audit trail with the calling operator. Needs an operator key from CNC_ADMIN_KEYS.
+// @Tags players
+// @Accept json
+// @Produce json
+// @Param request body MergePlayersRequest true "Players to merge"
+// @Success 200 {object} identity.Player
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/merge_players [post]
+func mergePlayersHandler(c *gin.Context, ids *identity.Store) {
+	var req MergePlayersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid merge request",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := ids.Merge(req.Player, req.Into, clientName(c), req.Reason); err != nil {
+		identityChangeError(c, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"player": req.Player,
+		"into":   req.Into,
+		"client": clientName(c),
+	}).Info("Players merged")
+	playerIdentityResponse(c, ids, req.Into)
+}
+
+// splitPlayerHandler records an admin decision that a nickname is a
+// different player from the others linked to it.
+// @Summary Split a nickname off its player
+// @Description Record that a nickname belongs to a different person from every other nickname currently linked to it, whatever the evidence says. Merges naming the nickname are dropped. Returns the nickname's player after the split. The decision is written to the audit trail with the calling operator. Needs an operator key from CNC_ADMIN_KEYS.
+// @Tags players
+// @Accept json
+// @Produce json
+// @Param request body SplitPlayerRequest true "Nickname to split off"
+// @Success 200 {object} identity.Player
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/split_player [post]
+func splitPlayerHandler(c *gin.Context, ids *identity.Store) {
+	var req SplitPlayerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error":   "Could not parse multipart form",
+			"error":   "Invalid split request",
 			"details": err.Error(),
 		})
 		return
 	}
+	if err := ids.Split(req.Player, clientName(c), req.Reason); err != nil {
+		identityChangeError(c, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"player": req.Player,
+		"client": clientName(c),
+	}).Info("Player split")
+	playerIdentityResponse(c, ids, req.Player)
+}
 
-	// Gather every file part regardless of field name so clients can use
-	// whatever names are convenient.
-	var files []*multipart.FileHeader
-	for _, fhs := range form.File {
-		files = append(files, fhs...)
+// playerIdentityResponse answers with the player a name now belongs to.
+func playerIdentityResponse(c *gin.Context, ids *identity.Store, name string) {
+	dir, err := ids.Directory()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read player identities",
+			"details": err.Error(),
+		})
+		return
 	}
-	if len(files) == 0 {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "No log files received",
+	p, _ := dir.Lookup(name)
+	c.JSON(http.StatusOK, p)
+}
+
+// identityAuditHandler returns the identity audit trail.
+// @Summary Identity audit trail
+// @Description Returns every change to player identities, oldest first: links the evidence made and undid when an address or client turned out shared (actor "auto", with the kind of evidence as reason) and admin merges and splits (actor is the calling operator). With player, only the events naming one of that player's nicknames. Needs an operator key from CNC_ADMIN_KEYS.
+// @Tags players
+// @Produce json
+// @Param player query string false "Player nickname or ID"
+// @Success 200 {array} identity.Event
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/identity_audit [get]
+func identityAuditHandler(c *gin.Context, ids *identity.Store) {
+	events, err := ids.Audit(c.Query("player"))
+	switch {
+	case errors.Is(err, identity.ErrUnknown):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error":   "player has never been seen",
+			"details": err.Error(),
+		})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read identity audit trail",
+			"details": err.Error(),
 		})
 		return
 	}
+	c.JSON(http.StatusOK, events)
+}
 
-	stored := make([]string, 0, len(files))
-	var total int
-	for _, fh := range files {
-		fileIn, err := fh.Open()
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error":   "Could not open uploaded file",
-				"details": err.Error(),
-			})
-			return
-		}
-		data, err := io.ReadAll(fileIn)
-		fileIn.Close()
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to read uploaded file",
-				"details": err.Error(),
-			})
-			return
-		}
-		if err := logfile.Store(seed, player, fh.Filename, data); err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to store log file",
-				"details": err.Error(),
-			})
-			return
+// queryDate parses a date query parameter, either a day (2006-01-02, UTC)
+// or an RFC 3339 time. With endOfDay a bare day means the end of that day,
+// so a range can name its last day.
+func queryDate(c *gin.Context, name string, endOfDay bool) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
 		}
-		stored = append(stored, fh.Filename)
-		total += len(data)
+		return t, nil
 	}
-
-	log.WithFields(log.Fields{
-		"seed":   seed,
-		"player": player,
-		"files":  len(stored),
-		"size":   total,
-		"client": clientName(c),
-	}).Info("Log files stored")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Logs stored successfully",
-		"seed":    seed,
-		"player":  player,
-		"files":   stored,
-		"size":    total,
-	})
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: want YYYY-MM-DD or RFC 3339, got %q", name, v)
+	}
+	return t, nil
 }
 
-// getLogsHandler returns a zip archive of every stored log file for a
-// match. Entries inside the zip are named "<player>/<filename>" so the
-// per-player grouping is preserved on extraction.
-// @Summary Download all match logs as a zip
-// @Description Returns a zip archive of every log file stored for the given match seed, with entries named "<player>/<filename>". 404 if no logs are stored for that seed.
-// @Tags logs
-// @Produce application/zip
-// @Param seed query string true "Game seed identifying the match"
-// @Success 200 {file} file "Zip archive (application/zip)"
+// balanceHandler reports faction and map balance.
+// @Summary Faction and map balance
+// @Description Aggregates recorded matches into faction-versus-faction win rates for 1v1 games and for team games (each faction pairing across opposing teams, once per match), win rates per map by start position and by side, and game length statistics with a histogram of 5-minute buckets, overall and per map. Win rates come with a 95% Wilson score interval (low, high) and mean lengths with a 95% confidence interval. Games with AI players, games whose winner was guessed from the last command and games without two teams that finished differently are left out and counted in skipped. Random start positions aren't recorded in replays, so they are left out of the start position rates.
+// @Tags balance
+// @Produce json
+// @Param version query string false "Game version from the replay header, e.g. \"Version 1.04\""
+// @Param from query string false "Earliest date played, YYYY-MM-DD or RFC 3339"
+// @Param to query string false "Last date played (inclusive), YYYY-MM-DD, or an RFC 3339 time (exclusive)"
+// @Param map query string false "Map name without its directory, case-insensitive"
+// @Success 200 {object} balance.Report
 // @Failure 400 {object} ErrorResponse
-// @Failure 401 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
-// @Security BearerAuth
-// @Security ApiKeyAuth
-// @Router /get_logs [get]
-func getLogsHandler(c *gin.Context) {
-	seed := c.Query("seed")
-	if seed == "" {
+// @Router /balance [get]
+func balanceHandler(c *gin.Context, reports *balance.Cache) {
+	filter := balance.Filter{Version: c.Query("version"), Map: c.Query("map")}
+	var err error
+	if filter.From, err = queryDate(c, "from", false); err == nil {
+		filter.To, err = queryDate(c, "to", true)
+	}
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "seed query parameter is required",
+			"error":   "Invalid date range",
+			"details": err.Error(),
 		})
 		return
 	}
 
-	files, err := logfile.List(seed)
+	report, err := reports.Report(filter)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to list logs",
+			"error":   "Failed to read match database",
 			"details": err.Error(),
 		})
 		return
 	}
-	if len(files) == 0 {
+	c.JSON(http.StatusOK, report)
+}
+
+// playerHistory returns the match history of the player named by the
+// player query parameter and the identity directory it was resolved in,
+// or answers the request itself and returns ok false.
+func playerHistory(c *gin.Context, profiles *profile.Cache) (dir *identity.Directory, history []profile.Appearance, ok bool) {
+	name := c.Query("player")
+	if name == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "player query parameter is required",
+		})
+		return nil, nil, false
+	}
+	dir, history, err := profiles.History(name)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read match database",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
+	if len(history) == 0 {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
-			"error": "no logs stored for that seed",
-			"seed":  seed,
+			"error":  "no recorded matches for that player",
+			"player": name,
 		})
+		return nil, nil, false
+	}
+	return dir, history, true
+}
+
+// playerProfileHandler summarizes a player's recorded matches.
+// @Summary Player profile
+// @Description Returns a player's record across every recorded match played under any nickname linked to them (see /player_identity): games, wins, losses and win rate overall and by faction, map, teammate and opponent; average game length and APM; their most played build openings (first units, buildings and upgrades ordered) and how they fared; and the APM of their latest matches. A game counts as a win or loss only when the teams finished differently. Built from what the server recorded when each replay was parsed.
+// @Tags players
+// @Produce json
+// @Param player query string true "Player nickname or ID"
+// @Success 200 {object} profile.Profile
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /player_profile [get]
+func playerProfileHandler(c *gin.Context, profiles *profile.Cache) {
+	dir, history, ok := playerHistory(c, profiles)
+	if !ok {
 		return
 	}
+	c.JSON(http.StatusOK, profile.Build(dir, c.Query("player"), history))
+}
 
-	// Log files are text and modest in size; build the zip in memory.
-	var buf bytes.Buffer
-	zw := zip.NewWriter(&buf)
-	var zipErr error
-	for _, f := range files {
-		data, err := logfile.Load(seed, f.Player, f.Name)
-		if err != nil {
-			// List said it's there; if Load disagrees, race with a
-			// concurrent delete. Skip rather than abort.
+// PlayerMatchesResponse is one page of a player's match history.
+type PlayerMatchesResponse struct {
+	Total   int                  `json:"total"`
+	Limit   int                  `json:"limit"`
+	Offset  int                  `json:"offset"`
+	Matches []profile.Appearance `json:"matches"`
+}
+
+// Page size bounds for /player_matches.
+const (
+	defaultPlayerMatchesLimit = 20
+	maxPlayerMatchesLimit     = 200
+)
+
+// playerMatchesHandler pages through a player's match history.
+// @Summary Player match history
+// @Description Returns the recorded matches a player played under any nickname linked to them, newest first: map, the nickname, side and faction they played, team, result (win, loss, or unknown when no team finished differently), win method, duration, APM, opening, teammates and opponents.
+// @Tags players
+// @Produce json
+// @Param player query string true "Player nickname or ID"
+// @Param limit query int false "Page size (default 20, max 200)"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {object} PlayerMatchesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /player_matches [get]
+func playerMatchesHandler(c *gin.Context, profiles *profile.Cache) {
+	ints := map[string]int{"limit": defaultPlayerMatchesLimit, "offset": 0}
+	for name := range ints {
+		v := c.Query(name)
+		if v == "" {
 			continue
 		}
-		entry, err := zw.Create(f.Player + "/" + f.Name)
-		if err != nil {
-			zipErr = err
-			break
-		}
-		if _, err := entry.Write(data); err != nil {
-			zipErr = err
-			break
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (name == "limit" && (n < 1 || n > maxPlayerMatchesLimit)) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("invalid %s query parameter", name),
+				"details": fmt.Sprintf("got %q", v),
+			})
+			return
 		}
+		ints[name] = n
 	}
-	if zipErr == nil {
-		zipErr = zw.Close()
-	}
-	if zipErr != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to build zip archive",
-			"details": zipErr.Error(),
-		})
+	_, history, ok := playerHistory(c, profiles)
+	if !ok {
 		return
 	}
+	// The history is shared with other requests.
+	history = slices.Clone(history)
+	slices.Reverse(history)
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", seed+"-logs.zip"))
-	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+	resp := PlayerMatchesResponse{Total: len(history), Limit: ints["limit"], Offset: ints["offset"]}
+	start := min(resp.Offset, len(history))
+	end := min(start+resp.Limit, len(history))
+	resp.Matches = history[start:end]
+	c.JSON(http.StatusOK, resp)
 }
 
 // mapExistsHandler reports whether the server already has a map for the
 // given CRC.
 // @Summary Check whether a map exists on the server
-// @Description Returns plain-text "true" if a .map file is already stored under MAPS_DIR for the given crc, "false" otherwise. Used by the Generals client right after a game ends to decide whether to upload its played map.
+// @Description Returns plain-text "true" if a .map file is already stored under MAPS_DIR for the given crc (and, when a manifest was posted, every asset it lists), "false" otherwise. Used by the Generals client right after a game ends to decide whether to upload its played map.
 // @Tags maps
 // @Produce plain
 // @Param crc query string true "Map CRC (decimal, as emitted by MapMetaData::m_CRC)"
 // @Success 200 {string} string "\"true\" or \"false\""
 // @Failure 400 {object} ErrorResponse
 // @Router /map_exists [get]
-func mapExistsHandler(c *gin.Context) {
+func mapExistsHandler(c *gin.Context, mapRepo *mapfile.Repository) {
 	crc := c.Query("crc")
 	if crc == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -836,7 +2828,7 @@ func mapExistsHandler(c *gin.Context) {
 	}
 
 	answer := "false"
-	if mapfile.Exists(crc) {
+	if mapRepo.Exists(crc) {
 		answer = "true"
 	}
 	c.Header("Content-Type", "text/plain; charset=utf-8")
@@ -847,7 +2839,7 @@ func mapExistsHandler(c *gin.Context) {
 // The Generals client makes one POST per asset (X-Map-File: map, preview,
 // ini, str, solo, assets, readme), all sharing the same X-Map-CRC.
 // @Summary Upload a map asset (.map / .tga / sidecar)
-// @Description Stores one asset that makes up a map, keyed by X-Map-CRC. Supported X-Map-File values: "map", "preview", "ini", "str", "solo", "assets", "readme". Identical CRCs overwrite silently.
+// @Description Stores one asset that makes up a map, keyed by X-Map-CRC. Supported X-Map-File values: "map", "preview", "ini", "str", "solo", "assets", "readme". A "map" upload is rejected with 422 unless its bytes hash to X-Map-CRC under the game's map CRC, and a "preview" unless its header is a true-color TGA of at most 2048 pixels a side; other sidecars are stored as sent. Assets are replaced atomically; identical CRCs overwrite silently, except that a verified "map" is only replaced by the same bytes: different bytes that hash to the same CRC are refused with 409. Large assets can be sent in chunks with X-Upload-Offset and X-Upload-Total: each chunk must start where the previous one ended (409 returns the offset to resume from), and the asset is only stored once the last chunk arrives. When a manifest was posted to /map_manifest, sizes that disagree with it are rejected with 422.
 // @Tags maps
 // @Accept octet-stream
 // @Produce json
@@ -855,14 +2847,224 @@ func mapExistsHandler(c *gin.Context) {
 // @Param X-Map-Name header string false "Original map path/name from the game (e.g. \"Maps\\Tournament Desert\\Tournament Desert.map\")"
 // @Param X-Map-File header string true "Asset kind: \"map\", \"preview\", \"ini\", \"str\", \"solo\", \"assets\", \"readme\""
 // @Param X-Game-Seed header string false "Game seed for telemetry correlation"
+// @Param X-Upload-Offset header int false "Chunked upload: byte offset of this chunk"
+// @Param X-Upload-Total header int false "Chunked upload: full size of the asset"
 // @Success 200 {object} map[string]any
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /add_map [post]
+func addMapHandler(c *gin.Context, mapRepo *mapfile.Repository) {
+	crc := c.GetHeader("X-Map-CRC")
+	if crc == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "X-Map-CRC header is required",
+		})
+		return
+	}
+	kind := c.GetHeader("X-Map-File")
+	if !mapfile.IsValidKind(kind) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("X-Map-File header must be one of %v", mapfile.AllKinds),
+			"details": fmt.Sprintf("got %q", kind),
+		})
+		return
+	}
+	mapName := c.GetHeader("X-Map-Name")
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, mapfile.MaxAssetSize+1))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(data) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Empty request body",
+		})
+		return
+	}
+	if len(data) > mapfile.MaxAssetSize {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("Map assets are limited to %d bytes", mapfile.MaxAssetSize),
+		})
+		return
+	}
+
+	// Chunked upload: append to the part and stop here until the last
+	// chunk arrives, which then goes through the same checks as a whole
+	// upload.
+	if c.GetHeader("X-Upload-Offset") != "" || c.GetHeader("X-Upload-Total") != "" {
+		offset, errOffset := strconv.ParseInt(c.GetHeader("X-Upload-Offset"), 10, 64)
+		total, errTotal := strconv.ParseInt(c.GetHeader("X-Upload-Total"), 10, 64)
+		if errOffset != nil || errTotal != nil || offset < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "X-Upload-Offset and X-Upload-Total must both be non-negative integers",
+			})
+			return
+		}
+		complete, err := mapRepo.AppendChunk(crc, kind, offset, total, data)
+		if err != nil {
+			switch {
+			case errors.Is(err, mapfile.ErrOffsetMismatch):
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error":   "Chunk does not start where the upload left off",
+					"details": err.Error(),
+					"offset":  mapRepo.UploadOffset(crc, kind),
+				})
+			case errors.Is(err, mapfile.ErrSizeMismatch):
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+					"error":   "Upload size does not match",
+					"details": err.Error(),
+				})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to store chunk",
+					"details": err.Error(),
+				})
+			}
+			return
+		}
+		if complete == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message":  "Chunk stored",
+				"crc":      crc,
+				"kind":     kind,
+				"offset":   offset + int64(len(data)),
+				"total":    total,
+				"complete": false,
+			})
+			return
+		}
+		data = complete
+	} else if err := mapRepo.CheckSize(crc, kind, int64(len(data))); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Upload size does not match",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// /map_preview decodes the preview for anyone who asks, so only a
+	// header it can decode at a bounded size is accepted.
+	if kind == mapfile.KindPreview {
+		if _, err := tga.DecodeConfig(bytes.NewReader(data)); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Preview is not a supported TGA",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	// Peers download this map by CRC mid-lobby, so a .map that doesn't hash
+	// to the CRC it claims would desync their game. Sidecars have no CRC of
+	// their own and are stored as sent.
+	var verification *mapfile.Verification
+	if kind == mapfile.KindMap {
+		computed, err := mapfile.VerifyCRC(crc, data)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, mapfile.ErrCRCMismatch) {
+				status = http.StatusUnprocessableEntity
+			}
+			log.WithFields(log.Fields{
+				"crc":      crc,
+				"computed": computed,
+				"name":     mapName,
+				"client":   clientName(c),
+			}).Warn("Rejected map upload with bad CRC")
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":       "Map data does not match X-Map-CRC",
+				"details":     err.Error(),
+				"crc":         crc,
+				"computedCrc": computed,
+			})
+			return
+		}
+		verification = &mapfile.Verification{
+			Verified:    true,
+			ComputedCRC: computed,
+			Size:        len(data),
+			VerifiedAt:  time.Now().UTC(),
+		}
+	}
+
+	if verification != nil {
+		err = mapRepo.StoreMap(crc, mapName, data, *verification)
+	} else {
+		err = mapRepo.Store(crc, mapName, kind, data)
+	}
+	if errors.Is(err, mapfile.ErrMapVerified) {
+		log.WithFields(log.Fields{
+			"crc":    crc,
+			"name":   mapName,
+			"client": clientName(c),
+		}).Warn("Refused to replace a verified map with different bytes")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error":   "A different verified map is already stored under X-Map-CRC",
+			"details": err.Error(),
+			"crc":     crc,
+		})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store map asset",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"crc":    crc,
+		"kind":   kind,
+		"size":   len(data),
+		"name":   mapName,
+		"client": clientName(c),
+	}).Info("Map asset stored")
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Map asset stored successfully",
+		"crc":      crc,
+		"kind":     kind,
+		"size":     len(data),
+		"verified": verification != nil,
+		"complete": mapRepo.Complete(crc),
+	})
+}
+
+// MapManifestRequest declares the assets a client is about to upload.
+type MapManifestRequest struct {
+	Name string `json:"name"`
+	// Assets maps asset kind ("map", "preview", ...) to size in bytes.
+	Assets map[string]int64 `json:"assets"`
+}
+
+// mapManifestHandler records which assets make up a map before they are
+// uploaded, so an interrupted upload set isn't mistaken for a complete one.
+// @Summary Declare a map's assets before uploading them
+// @Description Records the asset kinds and sizes the client will upload for X-Map-CRC. Until every listed asset is stored at its declared size, /map_exists reports false and the map isn't served, so peers never download a partial set. Must include "map". Replaces any earlier manifest, unless the map is already stored and its CRC verified: then the response is 409 with the stored set's status, and the manifest is ignored.
+// @Tags maps
+// @Accept json
+// @Produce json
+// @Param X-Map-CRC header string true "Map CRC (decimal)"
+// @Param manifest body MapManifestRequest true "Expected assets"
+// @Success 200 {object} mapfile.UploadStatus
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} mapfile.UploadStatus
 // @Failure 500 {object} ErrorResponse
 // @Security BearerAuth
 // @Security ApiKeyAuth
-// @Router /add_map [post]
-func addMapHandler(c *gin.Context) {
+// @Router /map_manifest [post]
+func mapManifestHandler(c *gin.Context, mapRepo *mapfile.Repository) {
 	crc := c.GetHeader("X-Map-CRC")
 	if crc == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -870,52 +3072,54 @@ func addMapHandler(c *gin.Context) {
 		})
 		return
 	}
-	kind := c.GetHeader("X-Map-File")
-	if !mapfile.IsValidKind(kind) {
+	var req MapManifestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error":   fmt.Sprintf("X-Map-File header must be one of %v", mapfile.AllKinds),
-			"details": fmt.Sprintf("got %q", kind),
-		})
-		return
-	}
-	mapName := c.GetHeader("X-Map-Name")
-
-	data, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to read request body",
+			"error":   "Invalid manifest",
 			"details": err.Error(),
 		})
 		return
 	}
-	if len(data) == 0 {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "Empty request body",
-		})
+	manifest := mapfile.Manifest{Name: req.Name, Assets: req.Assets, CreatedAt: time.Now().UTC()}
+	err := mapRepo.SaveManifest(crc, manifest)
+	if errors.Is(err, mapfile.ErrMapVerified) {
+		c.AbortWithStatusJSON(http.StatusConflict, mapRepo.Status(crc))
 		return
 	}
-
-	if err := mapfile.Store(crc, mapName, kind, data); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to store map asset",
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid manifest",
 			"details": err.Error(),
 		})
 		return
 	}
-
 	log.WithFields(log.Fields{
 		"crc":    crc,
-		"kind":   kind,
-		"size":   len(data),
-		"name":   mapName,
+		"assets": req.Assets,
 		"client": clientName(c),
-	}).Info("Map asset stored")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Map asset stored successfully",
-		"crc":     crc,
-		"kind":    kind,
-		"size":    len(data),
-	})
+	}).Info("Map manifest stored")
+	c.JSON(http.StatusOK, mapRepo.Status(crc))
+}
+
+// mapUploadStatusHandler reports how far a map's upload set has got, so an
+// interrupted client can resume instead of starting over.
+// @Summary Map upload progress
+// @Description Returns the manifest (if any), the size of every stored asset, the bytes received for chunked uploads in progress (the offset to resume from), the manifest kinds still missing, and whether the set is complete.
+// @Tags maps
+// @Produce json
+// @Param crc query string true "Map CRC (decimal)"
+// @Success 200 {object} mapfile.UploadStatus
+// @Failure 400 {object} ErrorResponse
+// @Router /map_upload_status [get]
+func mapUploadStatusHandler(c *gin.Context, mapRepo *mapfile.Repository) {
+	crc := c.Query("crc")
+	if crc == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "crc query parameter is required",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, mapRepo.Status(crc))
 }
 
 // getMapHandler returns a zip archive containing the .map file and (if
@@ -932,7 +3136,7 @@ func addMapHandler(c *gin.Context) {
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /get_map [get]
-func getMapHandler(c *gin.Context) {
+func getMapHandler(c *gin.Context, mapRepo *mapfile.Repository) {
 	crc := c.Query("crc")
 	if crc == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -941,7 +3145,7 @@ func getMapHandler(c *gin.Context) {
 		return
 	}
 
-	if !mapfile.Exists(crc) {
+	if !mapRepo.Exists(crc) {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
 			"error": "no map stored for that crc",
 			"crc":   crc,
@@ -949,7 +3153,7 @@ func getMapHandler(c *gin.Context) {
 		return
 	}
 
-	mapName := mapfile.LoadName(crc)
+	mapName := mapRepo.LoadName(crc)
 	base := mapfile.BaseName(mapName)
 
 	// Build the zip in memory; map plus sidecars top out around a MB.
@@ -957,8 +3161,8 @@ func getMapHandler(c *gin.Context) {
 	zw := zip.NewWriter(&buf)
 
 	var zipErr error
-	for _, kind := range mapfile.AvailableKinds(crc) {
-		data, err := mapfile.LoadAsset(crc, kind)
+	for _, kind := range mapRepo.AvailableKinds(crc) {
+		data, err := mapRepo.LoadAsset(crc, kind)
 		if err != nil {
 			// AvailableKinds said it's there; if LoadAsset disagrees,
 			// race with concurrent deletion. Skip rather than abort.
@@ -1003,7 +3207,7 @@ func getMapHandler(c *gin.Context) {
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /get_map_file [get]
-func getMapFileHandler(c *gin.Context) {
+func getMapFileHandler(c *gin.Context, mapRepo *mapfile.Repository) {
 	crc := c.Query("crc")
 	if crc == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -1020,7 +3224,7 @@ func getMapFileHandler(c *gin.Context) {
 		return
 	}
 
-	data, err := mapfile.LoadAsset(crc, kind)
+	data, err := mapRepo.LoadAsset(crc, kind)
 	if err != nil {
 		if os.IsNotExist(err) {
 			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
@@ -1040,18 +3244,298 @@ func getMapFileHandler(c *gin.Context) {
 	c.Data(http.StatusOK, "application/octet-stream", data)
 }
 
+// mapPreviewHandler serves the stored preview.tga as a PNG so browsers can
+// show map thumbnails. Conversions are cached on disk per width.
+// @Summary Map preview as PNG
+// @Description Decodes the stored preview.tga (uncompressed or RLE, 24/32-bit) and returns it as PNG. With width, the image is scaled to that width keeping its aspect ratio. 404 if no preview was uploaded for the CRC.
+// @Tags maps
+// @Produce png
+// @Param crc query string true "Map CRC (decimal)"
+// @Param width query int false "Output width in pixels (1-1024); omit for the original size"
+// @Success 200 {file} file "PNG image (image/png)"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /map_preview [get]
+func mapPreviewHandler(c *gin.Context, mapRepo *mapfile.Repository) {
+	crc := c.Query("crc")
+	if crc == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "crc query parameter is required",
+		})
+		return
+	}
+	width := 0
+	if w := c.Query("width"); w != "" {
+		n, err := strconv.Atoi(w)
+		if err != nil || n < 1 || n > mapfile.MaxPreviewWidth {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("width must be between 1 and %d", mapfile.MaxPreviewWidth),
+				"details": fmt.Sprintf("got %q", w),
+			})
+			return
+		}
+		width = n
+	}
+
+	data, err := mapRepo.PreviewPNG(crc, width)
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "preview not stored",
+				"crc":   crc,
+			})
+		case errors.Is(err, mapfile.ErrBadPreview):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Stored preview could not be decoded",
+				"details": err.Error(),
+			})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to convert preview",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// statsMinimapHandler renders a match's stats events over the terrain of
+// the map it was played on.
+// @Summary Minimap with stats overlays
+// @Description Renders the stored .map's terrain as a top-down PNG and draws the match's stats events on it: units and structures built (dots), kills (heatmap, or crosses with heatmap=false) and the map's start positions, in player colors. The map is found by crc, or by the stats file's map name when crc is omitted.
+// @Tags maps
+// @Produce png
+// @Param seed query string true "Game seed identifying the stats file"
+// @Param mapCrc query string false "Map CRC (decimal) of the match, when several matches share the seed"
+// @Param start query int false "Game start time (Unix seconds), when several matches share the seed"
+// @Param crc query string false "Map CRC (decimal); defaults to the stored map named in the stats file"
+// @Param from query int false "First frame to draw events from (30 frames per second)"
+// @Param to query int false "Last frame to draw events from; omit for the end of the game"
+// @Param width query int false "Image width in pixels (default 512, max 2048)"
+// @Param heatmap query bool false "Draw kills as a heatmap (default true)"
+// @Success 200 {file} file "PNG image (image/png)"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} MatchConflictResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /minimap [get]
+func statsMinimapHandler(c *gin.Context, bundle *datastore.Bundle, matches *matchid.Index, statsRepo *statsfile.Repository, mapRepo *mapfile.Repository) {
+	opts, ok := minimapOptions(c)
+	if !ok {
+		return
+	}
+	id, err := queryMatchID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid match identity",
+			"details": err.Error(),
+		})
+		return
+	}
+	match, ok := lookupMatch(c, matches, id, statsRepo.Exists)
+	if !ok {
+		return
+	}
+	if !statsRepo.Exists(match) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "no stats stored for that match",
+			"match": match,
+		})
+		return
+	}
+	stats, err := statsRepo.LoadSections(match, minimapSections)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load stats file",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	crc := c.Query("crc")
+	if crc == "" {
+		crc = mapRepo.FindByName(stats.Game.Map)
+	}
+	m, ok := loadMinimapMap(c, mapRepo, crc)
+	if !ok {
+		return
+	}
+	opts.Colors = minimap.StatsColors(stats, bundle.Colors)
+	markers := append(minimap.StartMarkers(m, nil), minimap.StatsMarkers(stats)...)
+	writeMinimap(c, m, markers, opts)
+}
+
+// replayMinimapHandler renders a replay's structure placements, plus the
+// stats events when a stats file exists for its seed.
+// @Summary Minimap with replay overlays
+// @Description Like GET /minimap, but takes the replay file: structures are drawn where BuildObject placed them (squares), start positions are colored by who started there, and the stats overlays are added when a stats file exists for the replay's seed. The map is found by the replay's map CRC unless crc is given.
+// @Tags maps
+// @Accept multipart/form-data
+// @Produce png
+// @Param file formData file true "Replay file"
+// @Param crc query string false "Map CRC (decimal); defaults to the replay header's map CRC"
+// @Param from query int false "First frame to draw events from (30 frames per second)"
+// @Param to query int false "Last frame to draw events from; omit for the end of the game"
+// @Param width query int false "Image width in pixels (default 512, max 2048)"
+// @Param heatmap query bool false "Draw kills as a heatmap (default true)"
+// @Success 200 {file} file "PNG image (image/png)"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /minimap [post]
+func replayMinimapHandler(c *gin.Context, bundle *datastore.Bundle, matches *matchid.Index, statsRepo *statsfile.Repository, mapRepo *mapfile.Repository) {
+	opts, ok := minimapOptions(c)
+	if !ok {
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "No file is received",
+		})
+		return
+	}
+	fileIn, err := file.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Could not open uploaded file",
+		})
+		return
+	}
+	defer fileIn.Close()
+	replay := zhreplay.NewReplay(bundle.BitParser(fileIn))
+
+	crc := c.Query("crc")
+	if crc == "" {
+		crc, _ = mapfile.CRCFromHeader(replay.Header.Metadata.MapCRC)
+	}
+	m, ok := loadMinimapMap(c, mapRepo, crc)
+	if !ok {
+		return
+	}
+
+	opts.Colors = minimap.ReplayColors(replay, bundle.Colors)
+	markers := append(minimap.StartMarkers(m, minimap.ReplayStartOwners(replay)), minimap.ReplayMarkers(replay)...)
+	if match := replayStatsMatch(matches, statsRepo, replay); match != "" {
+		if stats, err := statsRepo.LoadSections(match, minimapSections); err == nil {
+			for player, col := range minimap.StatsColors(stats, bundle.Colors) {
+				opts.Colors[player] = col
+			}
+			markers = append(markers, minimap.StatsMarkers(stats)...)
+		} else {
+			log.WithError(err).WithField("match", match).Warn("Failed to load stats file, drawing replay events only")
+		}
+	}
+	writeMinimap(c, m, markers, opts)
+}
+
+// minimapSections is what the minimap overlays draw from: player colors,
+// structures built and kills.
+const minimapSections = statsfile.SectionPlayers | statsfile.SectionBuildEvents | statsfile.SectionKillEvents
+
+// minimapOptions reads the query parameters shared by both minimap
+// endpoints, answering 400 itself when one is malformed.
+func minimapOptions(c *gin.Context) (minimap.Options, bool) {
+	opts := minimap.Options{Heatmap: true}
+	bad := func(name, value string) (minimap.Options, bool) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("invalid %s query parameter", name),
+			"details": fmt.Sprintf("got %q", value),
+		})
+		return opts, false
+	}
+	for name, dst := range map[string]*uint{"from": &opts.FromFrame, "to": &opts.ToFrame} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return bad(name, v)
+			}
+			*dst = uint(n)
+		}
+	}
+	if v := c.Query("width"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > minimap.MaxWidth {
+			return bad("width", v)
+		}
+		opts.Width = n
+	}
+	if v := c.Query("heatmap"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return bad("heatmap", v)
+		}
+		opts.Heatmap = b
+	}
+	return opts, true
+}
+
+// loadMinimapMap loads and decodes the stored .map for crc, answering the
+// request itself when it can't.
+func loadMinimapMap(c *gin.Context, mapRepo *mapfile.Repository, crc string) (*mapparse.Map, bool) {
+	if crc == "" || !mapRepo.Exists(crc) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "map for this match is not stored",
+			"crc":   crc,
+		})
+		return nil, false
+	}
+	data, err := mapRepo.LoadAsset(crc, mapfile.KindMap)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load map",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	m, err := mapparse.Parse(data)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Stored map could not be decoded",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	for _, w := range m.Warnings {
+		log.WithField("crc", crc).Warn("Map decoded with warning: " + w)
+	}
+	return m, true
+}
+
+func writeMinimap(c *gin.Context, m *mapparse.Map, markers []minimap.Marker, opts minimap.Options) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, minimap.Render(m, markers, opts)); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to encode minimap",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
+
 // listMapAssetsHandler returns the set of asset kinds present on disk
 // for a given CRC, plus the stored map name. Lets a peer fetch only
 // what's actually available without probing every kind with a HEAD/GET.
 // @Summary List stored asset kinds for a CRC
-// @Description Returns JSON: {"crc": "...", "name": "...", "kinds": ["map","preview","ini",...]}. Empty kinds array if the CRC is unknown.
+// @Description Returns JSON: {"crc": "...", "name": "...", "kinds": ["map","preview","ini",...], "verification": {...}}. Empty kinds array if the CRC is unknown; verification is null for maps stored before server-side CRC checks.
 // @Tags maps
 // @Produce json
 // @Param crc query string true "Map CRC (decimal)"
 // @Success 200 {object} map[string]any
 // @Failure 400 {object} ErrorResponse
 // @Router /list_map_assets [get]
-func listMapAssetsHandler(c *gin.Context) {
+func listMapAssetsHandler(c *gin.Context, mapRepo *mapfile.Repository) {
 	crc := c.Query("crc")
 	if crc == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -1060,8 +3544,168 @@ func listMapAssetsHandler(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"crc":   crc,
-		"name":  mapfile.LoadName(crc),
-		"kinds": mapfile.AvailableKinds(crc),
+		"crc":          crc,
+		"name":         mapRepo.LoadName(crc),
+		"kinds":        mapRepo.AvailableKinds(crc),
+		"verification": mapRepo.LoadVerification(crc),
+	})
+}
+
+// MapListResponse is one page of the map catalog.
+type MapListResponse struct {
+	Total  int                    `json:"total"`
+	Limit  int                    `json:"limit"`
+	Offset int                    `json:"offset"`
+	Maps   []mapfile.CatalogEntry `json:"maps"`
+}
+
+// Page size bounds for /maps.
+const (
+	defaultMapListLimit = 50
+	maxMapListLimit     = 500
+)
+
+// listMapsHandler pages through every stored map, optionally filtered.
+// @Summary List stored maps
+// @Description Returns the maps the server can distribute, sorted by title: CRC, original name, .map size, player count (start positions), upload time, stored asset kinds and CRC verification. q matches title, name or CRC (every word must match, case-insensitive); players keeps only maps with that many start positions.
+// @Tags maps
+// @Produce json
+// @Param q query string false "Search words"
+// @Param players query int false "Exact number of start positions"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {object} MapListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /maps [get]
+func listMapsHandler(c *gin.Context, mapRepo *mapfile.Repository) {
+	ints := map[string]int{"players": 0, "limit": defaultMapListLimit, "offset": 0}
+	for name := range ints {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (name == "limit" && (n < 1 || n > maxMapListLimit)) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("invalid %s query parameter", name),
+				"details": fmt.Sprintf("got %q", v),
+			})
+			return
+		}
+		ints[name] = n
+	}
+
+	entries, err := mapRepo.Catalog()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read map catalog",
+			"details": err.Error(),
+		})
+		return
+	}
+	entries = mapfile.SearchCatalog(entries, c.Query("q"), ints["players"])
+
+	resp := MapListResponse{Total: len(entries), Limit: ints["limit"], Offset: ints["offset"]}
+	start := min(resp.Offset, len(entries))
+	end := min(start+resp.Limit, len(entries))
+	resp.Maps = entries[start:end]
+	c.JSON(http.StatusOK, resp)
+}
+
+// mapInfoHandler describes one stored map from its decoded .map.
+// @Summary Describe a stored map
+// @Description Returns the map's catalog entry plus its layout from the decoded .map: playable size in world units, start positions, and counts of supply docks, oil derricks and other tech buildings.
+// @Tags maps
+// @Produce json
+// @Param crc query string true "Map CRC (decimal)"
+// @Success 200 {object} mapfile.MapInfo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Router /map_info [get]
+func mapInfoHandler(c *gin.Context, mapRepo *mapfile.Repository) {
+	crc := c.Query("crc")
+	if crc == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "crc query parameter is required",
+		})
+		return
+	}
+	info, err := mapRepo.Describe(crc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "no map stored for that crc",
+				"crc":   crc,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Stored map could not be decoded",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// StatusResponse reports the server's state for monitoring.
+type StatusResponse struct {
+	DataSet datastore.Status `json:"dataSet"`
+}
+
+// statusHandler reports which INI data set the server is parsing replays
+// with, so operators can confirm a data push took effect.
+// @Summary Server status
+// @Description Returns the INI data set currently in service (directory, content version, load time, store sizes) and the outcome of the most recent reload attempt.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} StatusResponse
+// @Router /status [get]
+func statusHandler(c *gin.Context, stores *datastore.Holder) {
+	c.JSON(http.StatusOK, StatusResponse{
+		DataSet: stores.Status(),
+	})
+}
+
+// reloadStoresHandler re-reads the INI data set and swaps it in atomically.
+// Requests already parsing keep the bundle they started with.
+// @Summary Reload INI data
+// @Description Re-parses the configured INI data directory (-objdata / CNC_INI) and, if the result validates, swaps it in without a restart. In-flight parses finish on the previous data set. On failure the previous data set stays in service and 422 is returned. Sending SIGHUP to the process does the same reload. Under -no-stores nothing is loaded and 409 is returned. Needs an operator key from CNC_ADMIN_KEYS.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/reload [post]
+func reloadStoresHandler(c *gin.Context, stores *datastore.Holder) {
+	bundle, err := stores.Reload()
+	if errors.Is(err, datastore.ErrDisabled) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error":   "INI stores are disabled (-no-stores)",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("client", clientName(c)).Error("INI store reload failed; keeping previous data set")
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Reload failed; previous data set still in service",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"version": bundle.Version,
+		"dir":     bundle.Dir,
+		"client":  clientName(c),
+	}).Info("INI stores reloaded")
+	c.JSON(http.StatusOK, StatusResponse{
+		DataSet: stores.Status(),
 	})
 }
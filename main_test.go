@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/mapfile"
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func TestLocalModeNoStores(t *testing.T) {
+	bundle, err := localBundle(filepath.Join(t.TempDir(), "missing"), true)
+	if err != nil {
+		t.Fatalf("expected -no-stores not to load the INI directory, got %v", err)
+	}
+	f, err := os.Open("example/simple-generals-replay.rep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	out, err := localReplay(f, bundle, mapfile.NewRepository(storage.NewFS(t.TempDir())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replay struct {
+		Header struct {
+			GameType string `json:"gameType"`
+		} `json:"header"`
+	}
+	if err := json.Unmarshal(out, &replay); err != nil {
+		t.Fatal(err)
+	}
+	if replay.Header.GameType != "GENREP" {
+		t.Errorf("expected the replay's header, got %s", out)
+	}
+}
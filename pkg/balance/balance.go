@@ -0,0 +1,414 @@
+// Package balance aggregates the match database into the numbers a balance
+// discussion needs: faction-versus-faction win rates in 1v1 and team
+// games, per-map win rates by start position and side, and game lengths,
+// each with a 95% confidence interval.
+//
+// Only matches a result can be trusted from count: games with AI players,
+// games whose winner was guessed from the last command (WinMethod
+// "lastCommand") and games without two teams that finished differently
+// are left out, as they are from ratings.
+package balance
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+)
+
+// z is the normal quantile of the 95% confidence intervals.
+const z = 1.959964
+
+// LengthBucket is the width of the game length histogram's buckets.
+const LengthBucket = 5 * time.Minute
+
+// MaxCached is how many filters' reports a Cache keeps.
+const MaxCached = 256
+
+// Filter picks the matches a report covers. Zero fields don't filter.
+type Filter struct {
+	// Version is the game version string from the replay header, e.g.
+	// "Version 1.04".
+	Version string
+	// From and To bound when the match was played, To exclusive.
+	From, To time.Time
+	// Map is the map's name without its directory (see Match.MapName),
+	// case-insensitive.
+	Map string
+}
+
+func (f Filter) match(m *matchdb.Match) bool {
+	t := m.Time()
+	switch {
+	case f.Version != "" && m.Version != f.Version:
+		return false
+	case !f.From.IsZero() && t.Before(f.From):
+		return false
+	case !f.To.IsZero() && !t.Before(f.To):
+		return false
+	case f.Map != "" && !strings.EqualFold(m.MapName(), f.Map):
+		return false
+	}
+	return true
+}
+
+// Rate is a win rate with its Wilson score interval.
+type Rate struct {
+	Games   int     `json:"games"`
+	Wins    int     `json:"wins"`
+	WinRate float64 `json:"winRate"`
+	Low     float64 `json:"low"`
+	High    float64 `json:"high"`
+}
+
+func (r *Rate) add(win bool) {
+	r.Games++
+	if win {
+		r.Wins++
+	}
+}
+
+// finish fills in the win rate and its interval.
+func (r *Rate) finish() {
+	if r.Games == 0 {
+		return
+	}
+	n := float64(r.Games)
+	p := float64(r.Wins) / n
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	half := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	r.WinRate = p
+	r.Low = max(0, center-half)
+	r.High = min(1, center+half)
+}
+
+// Matchup is how one faction fared against another.
+type Matchup struct {
+	Faction  string `json:"faction"`
+	Opponent string `json:"opponent"`
+	Rate
+}
+
+// Position is how players starting at one start position fared.
+type Position struct {
+	Position int `json:"position"`
+	Rate
+}
+
+// Side is how players of one side fared.
+type Side struct {
+	Side string `json:"side"`
+	Rate
+}
+
+// MapStats is the balance of one map.
+type MapStats struct {
+	Map   string `json:"map"`
+	Games int    `json:"games"`
+	// Positions leaves out random starts, whose position isn't recorded.
+	Positions []Position `json:"positions"`
+	Sides     []Side     `json:"sides"`
+	Length    Lengths    `json:"length"`
+}
+
+// Bucket counts games whose length falls in [FromMinutes, ToMinutes).
+type Bucket struct {
+	FromMinutes int `json:"fromMinutes"`
+	ToMinutes   int `json:"toMinutes"`
+	Games       int `json:"games"`
+}
+
+// Lengths describes how long games lasted, in seconds.
+type Lengths struct {
+	Games  int     `json:"games"`
+	Mean   float64 `json:"mean"`
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+	Median float64 `json:"median"`
+	P25    float64 `json:"p25"`
+	P75    float64 `json:"p75"`
+	// Histogram has LengthBucket-wide buckets from 0 to the longest game.
+	Histogram []Bucket `json:"histogram"`
+
+	seconds []float64
+}
+
+func (l *Lengths) add(seconds int) {
+	l.seconds = append(l.seconds, float64(seconds))
+}
+
+// finish computes the statistics of the lengths added. The mean's
+// interval uses the normal approximation.
+func (l *Lengths) finish() {
+	l.Histogram = []Bucket{}
+	n := len(l.seconds)
+	l.Games = n
+	if n == 0 {
+		return
+	}
+	sort.Float64s(l.seconds)
+	var sum float64
+	for _, s := range l.seconds {
+		sum += s
+	}
+	l.Mean = sum / float64(n)
+	if n > 1 {
+		var ss float64
+		for _, s := range l.seconds {
+			ss += (s - l.Mean) * (s - l.Mean)
+		}
+		half := z * math.Sqrt(ss/float64(n-1)/float64(n))
+		l.Low, l.High = max(0, l.Mean-half), l.Mean+half
+	} else {
+		l.Low, l.High = l.Mean, l.Mean
+	}
+	l.Median = quantile(l.seconds, 0.5)
+	l.P25 = quantile(l.seconds, 0.25)
+	l.P75 = quantile(l.seconds, 0.75)
+
+	width := LengthBucket.Seconds()
+	for _, s := range l.seconds {
+		i := int(s / width)
+		for len(l.Histogram) <= i {
+			from := len(l.Histogram) * int(LengthBucket.Minutes())
+			l.Histogram = append(l.Histogram, Bucket{FromMinutes: from, ToMinutes: from + int(LengthBucket.Minutes())})
+		}
+		l.Histogram[i].Games++
+	}
+}
+
+// quantile interpolates the q quantile of sorted values.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// Report is the balance of the matches a Filter picks.
+type Report struct {
+	// Matches is how many matches were counted; Skipped how many the
+	// filter picked but were left out for an untrustworthy result.
+	Matches int `json:"matches"`
+	Skipped int `json:"skipped"`
+	// OneVsOne lists 1v1 matchups. Team lists, for games with more than
+	// one player on a team, the factions of players on opposing teams,
+	// each pairing once per match so every game counted is an independent
+	// trial: a 2v2 adds up to four. A pairing found on both the winning
+	// and the losing side of one match isn't counted for it. Both list
+	// each matchup from both sides and leave out mirrors.
+	OneVsOne []Matchup  `json:"oneVsOne"`
+	Team     []Matchup  `json:"team"`
+	Maps     []MapStats `json:"maps"`
+	Length   Lengths    `json:"length"`
+}
+
+// Compute aggregates every match in db that f picks.
+func Compute(db *matchdb.DB, f Filter) (*Report, error) {
+	r := &Report{}
+	oneVsOne := map[[2]string]*Matchup{}
+	team := map[[2]string]*Matchup{}
+	maps := map[string]*mapTally{}
+
+	err := db.Each(func(m *matchdb.Match) error {
+		if !f.match(m) {
+			return nil
+		}
+		if m.HasAI() || m.WinMethod == "lastCommand" || !m.Decided() {
+			r.Skipped++
+			return nil
+		}
+		r.Matches++
+		r.Length.add(m.DurationSeconds)
+
+		name := m.MapName()
+		mt, ok := maps[name]
+		if !ok {
+			mt = &mapTally{positions: map[int]*Rate{}, sides: map[string]*Rate{}}
+			maps[name] = mt
+		}
+		mt.add(m)
+
+		ts := m.Teams()
+		matchups := team
+		if len(ts) == 2 && len(ts[0].Players) == 1 && len(ts[1].Players) == 1 {
+			matchups = oneVsOne
+		} else if !teamGame(ts) {
+			// Free-for-alls are neither.
+			return nil
+		}
+		// outcomes has bit 1 set for each pairing that won, bit 2 for each
+		// that lost.
+		outcomes := map[[2]string]int{}
+		for i, a := range ts {
+			for j, b := range ts {
+				if i == j || a.Win == b.Win {
+					continue
+				}
+				for _, pa := range a.Players {
+					for _, pb := range b.Players {
+						k := [2]string{pa.FactionName(), pb.FactionName()}
+						if a.Win {
+							outcomes[k] |= 1
+						} else {
+							outcomes[k] |= 2
+						}
+					}
+				}
+			}
+		}
+		for k, o := range outcomes {
+			if o != 3 {
+				addMatchup(matchups, k[0], k[1], o == 1)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.OneVsOne = sortedMatchups(oneVsOne)
+	r.Team = sortedMatchups(team)
+	r.Maps = []MapStats{}
+	for name, mt := range maps {
+		r.Maps = append(r.Maps, mt.stats(name))
+	}
+	sort.Slice(r.Maps, func(i, j int) bool {
+		if r.Maps[i].Games != r.Maps[j].Games {
+			return r.Maps[i].Games > r.Maps[j].Games
+		}
+		return r.Maps[i].Map < r.Maps[j].Map
+	})
+	r.Length.finish()
+	return r, nil
+}
+
+// Cache holds the reports asked for since the database was last written.
+type Cache struct {
+	db *matchdb.DB
+
+	mu      sync.Mutex
+	rev     uint64
+	reports map[Filter]*Report
+}
+
+// NewCache returns a Cache over db.
+func NewCache(db *matchdb.DB) *Cache {
+	return &Cache{db: db}
+}
+
+// Report returns the report Compute makes for f. It is shared; callers
+// must not modify it.
+func (c *Cache) Report(f Filter) (*Report, error) {
+	rev, err := c.db.Revision()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reports == nil || c.rev != rev || len(c.reports) >= MaxCached {
+		c.rev, c.reports = rev, map[Filter]*Report{}
+	}
+	if r, ok := c.reports[f]; ok {
+		return r, nil
+	}
+	r, err := Compute(c.db, f)
+	if err != nil {
+		return nil, err
+	}
+	c.reports[f] = r
+	return r, nil
+}
+
+// teamGame reports whether some team has more than one player.
+func teamGame(ts []matchdb.Team) bool {
+	for _, t := range ts {
+		if len(t.Players) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func addMatchup(matchups map[[2]string]*Matchup, faction, opponent string, win bool) {
+	if faction == opponent {
+		return
+	}
+	k := [2]string{faction, opponent}
+	mu, ok := matchups[k]
+	if !ok {
+		mu = &Matchup{Faction: faction, Opponent: opponent}
+		matchups[k] = mu
+	}
+	mu.add(win)
+}
+
+func sortedMatchups(matchups map[[2]string]*Matchup) []Matchup {
+	out := make([]Matchup, 0, len(matchups))
+	for _, mu := range matchups {
+		mu.finish()
+		out = append(out, *mu)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Faction != out[j].Faction {
+			return out[i].Faction < out[j].Faction
+		}
+		return out[i].Opponent < out[j].Opponent
+	})
+	return out
+}
+
+// mapTally accumulates one map's statistics.
+type mapTally struct {
+	games     int
+	positions map[int]*Rate
+	sides     map[string]*Rate
+	length    Lengths
+}
+
+func (t *mapTally) add(m *matchdb.Match) {
+	t.games++
+	t.length.add(m.DurationSeconds)
+	for _, p := range m.Players {
+		if p.Observer {
+			continue
+		}
+		if p.StartPosition > 0 {
+			r, ok := t.positions[p.StartPosition]
+			if !ok {
+				r = &Rate{}
+				t.positions[p.StartPosition] = r
+			}
+			r.add(m.Result(p) == matchdb.ResultWin)
+		}
+		r, ok := t.sides[p.Side]
+		if !ok {
+			r = &Rate{}
+			t.sides[p.Side] = r
+		}
+		r.add(m.Result(p) == matchdb.ResultWin)
+	}
+}
+
+func (t *mapTally) stats(name string) MapStats {
+	s := MapStats{Map: name, Games: t.games, Positions: []Position{}, Sides: []Side{}}
+	for pos, r := range t.positions {
+		r.finish()
+		s.Positions = append(s.Positions, Position{Position: pos, Rate: *r})
+	}
+	sort.Slice(s.Positions, func(i, j int) bool { return s.Positions[i].Position < s.Positions[j].Position })
+	for side, r := range t.sides {
+		r.finish()
+		s.Sides = append(s.Sides, Side{Side: side, Rate: *r})
+	}
+	sort.Slice(s.Sides, func(i, j int) bool { return s.Sides[i].Side < s.Sides[j].Side })
+	t.length.finish()
+	s.Length = t.length
+	return s
+}
@@ -0,0 +1,139 @@
+package balance
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+)
+
+func player(faction string, team, start int, win bool) matchdb.Player {
+	side := faction[:3]
+	return matchdb.Player{Human: true, Side: side, Faction: faction, Team: team, StartPosition: start, Win: win}
+}
+
+func TestRate(t *testing.T) {
+	r := Rate{Games: 10, Wins: 7}
+	r.finish()
+	// Wilson score interval for 7/10.
+	if r.WinRate != 0.7 || math.Abs(r.Low-0.3968) > 0.0001 || math.Abs(r.High-0.8922) > 0.0001 {
+		t.Errorf("unexpected interval: %+v", r)
+	}
+	r = Rate{Games: 3, Wins: 3}
+	r.finish()
+	if r.High != 1 || r.Low <= 0 {
+		t.Errorf("unexpected interval for a clean sweep: %+v", r)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	db, err := matchdb.Open(filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	matches := []*matchdb.Match{
+		{MapPath: "maps/tournament desert", Version: "1.04", DurationSeconds: 300, Players: []matchdb.Player{
+			player("USA Airforce", 1, 1, true), player("China Nuke", 2, 2, false),
+		}},
+		{MapPath: "maps/tournament desert", Version: "1.04", DurationSeconds: 900, Players: []matchdb.Player{
+			player("USA Airforce", 1, 2, false), player("China Nuke", 2, 1, true),
+		}},
+		{MapPath: "maps/tournament desert", Version: "1.04", DurationSeconds: 1500, Players: []matchdb.Player{
+			player("USA Airforce", 1, 0, true), player("China Nuke", 2, 1, false),
+		}},
+		// 2v2: four opposing pairs, but USA against China and against
+		// Stealth only once each.
+		{MapPath: "maps/winter wolf", Version: "1.04", DurationSeconds: 1200, Players: []matchdb.Player{
+			player("USA Airforce", 1, 1, true), player("USA Airforce", 1, 2, true),
+			player("China Nuke", 2, 3, false), player("GLA Stealth", 2, 4, false),
+		}},
+		// Left out: an AI, a guessed winner, no result.
+		{MapPath: "maps/winter wolf", Version: "1.04", Players: []matchdb.Player{
+			player("USA Airforce", 1, 1, true), {Side: "China", Team: 2},
+		}},
+		{MapPath: "maps/winter wolf", Version: "1.04", WinMethod: "lastCommand", Players: []matchdb.Player{
+			player("USA Airforce", 1, 1, true), player("China Nuke", 2, 2, false),
+		}},
+		{MapPath: "maps/winter wolf", Version: "1.04", Players: []matchdb.Player{
+			player("USA Airforce", 1, 1, false), player("China Nuke", 2, 2, false),
+		}},
+		// Other version.
+		{MapPath: "maps/winter wolf", Version: "1.05", Players: []matchdb.Player{
+			player("USA Airforce", 1, 1, true), player("China Nuke", 2, 2, false),
+		}},
+	}
+	for i, m := range matches {
+		m.ID = fmt.Sprint(i)
+		m.StartTime = day.AddDate(0, 0, i).Unix()
+		if err := db.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := Compute(db, Filter{Version: "1.04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Matches != 4 || r.Skipped != 3 {
+		t.Errorf("expected 4 matches and 3 skipped, got %d and %d", r.Matches, r.Skipped)
+	}
+	if len(r.OneVsOne) != 2 || r.OneVsOne[1].Faction != "USA Airforce" || r.OneVsOne[1].Games != 3 || r.OneVsOne[1].Wins != 2 {
+		t.Errorf("unexpected 1v1 matchups: %+v", r.OneVsOne)
+	}
+	if len(r.Team) != 4 {
+		t.Errorf("expected 4 team matchups, got %+v", r.Team)
+	}
+	for _, mu := range r.Team {
+		if mu.Games != 1 {
+			t.Errorf("expected one game per faction pairing, got %+v", mu)
+		}
+	}
+
+	if len(r.Maps) != 2 || r.Maps[0].Map != "tournament desert" || r.Maps[0].Games != 3 {
+		t.Fatalf("unexpected maps: %+v", r.Maps)
+	}
+	desert := r.Maps[0]
+	if len(desert.Positions) != 2 || desert.Positions[0].Games != 3 || desert.Positions[0].Wins != 2 || desert.Positions[1].Games != 2 {
+		t.Errorf("expected random starts to be left out, got %+v", desert.Positions)
+	}
+	if len(desert.Sides) != 2 || desert.Sides[1].Side != "USA" || desert.Sides[1].Wins != 2 {
+		t.Errorf("unexpected sides: %+v", desert.Sides)
+	}
+
+	l := r.Length
+	if l.Games != 4 || l.Mean != 975 || l.Median != 1050 || len(l.Histogram) != 6 || l.Histogram[1].Games != 1 || l.Low >= l.Mean || l.High <= l.Mean {
+		t.Errorf("unexpected lengths: %+v", l)
+	}
+
+	// Date range and map filters.
+	r, _ = Compute(db, Filter{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 3)})
+	if r.Matches != 2 {
+		t.Errorf("expected the date range to pick 2 matches, got %d", r.Matches)
+	}
+	r, _ = Compute(db, Filter{Map: "Winter Wolf"})
+	if r.Matches != 2 || r.Skipped != 3 {
+		t.Errorf("expected the map filter to pick winter wolf, got %d and %d", r.Matches, r.Skipped)
+	}
+
+	// The cache serves a report until the database changes.
+	cache := NewCache(db)
+	f := Filter{Version: "1.04"}
+	if r, err := cache.Report(f); err != nil || r.Matches != 4 {
+		t.Fatalf("unexpected cached report: %+v, %v", r, err)
+	}
+	m := &matchdb.Match{ID: "new", Version: "1.04", MapPath: "maps/winter wolf", Players: []matchdb.Player{
+		player("USA Airforce", 1, 1, true), player("China Nuke", 2, 2, false),
+	}}
+	if err := db.Put(m); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := cache.Report(f); r.Matches != 5 {
+		t.Errorf("expected the new match in the report, got %d", r.Matches)
+	}
+}
@@ -0,0 +1,172 @@
+// Package batch reads archives of replays, as tournament admins collect
+// them from every player's Replays folder: a zip or a gzip-compressed tar
+// holding .rep files, optionally with the .json.gz stats files the
+// exporter wrote next to them.
+package batch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Kinds of archive entries.
+const (
+	KindReplay = "replay"
+	KindStats  = "stats"
+)
+
+// Limits on what an archive may hold, against archives that decompress
+// far beyond their upload size. Every entry is held in memory until the
+// upload is done, so the total stays small; replays are rarely more than
+// a few megabytes.
+const (
+	MaxEntries    = 2000
+	MaxEntryBytes = 16 << 20
+	MaxTotalBytes = 64 << 20
+)
+
+// ErrBadArchive is returned, wrapped, when the data isn't a zip or a
+// gzip-compressed tar, or breaks the limits.
+var ErrBadArchive = errors.New("batch: bad archive")
+
+// Entry is one replay or stats file from an archive.
+type Entry struct {
+	// Name is the entry's path in the archive.
+	Name string
+	Kind string
+	Data []byte
+}
+
+// Stem is the name a replay and its stats file pair by: the entry's path
+// without its .rep or .json.gz extension, lower-cased.
+func (e Entry) Stem() string {
+	name := strings.ToLower(e.Name)
+	for _, ext := range []string{".rep", ".json.gz"} {
+		if s, ok := strings.CutSuffix(name, ext); ok {
+			return s
+		}
+	}
+	return name
+}
+
+// kind returns the kind of the file at name, or "" for files that are
+// neither replays nor stats. macOS resource forks are left out.
+func kind(name string) string {
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, "__macosx/") || strings.HasPrefix(path.Base(lower), "._") {
+		return ""
+	}
+	switch {
+	case strings.HasSuffix(lower, ".rep"):
+		return KindReplay
+	case strings.HasSuffix(lower, ".json.gz"):
+		return KindStats
+	}
+	return ""
+}
+
+// Read returns the replays and stats files in an archive, a zip or a
+// gzip-compressed tar told apart by their content, in archive order.
+// ignored lists the other files.
+func Read(data []byte) (entries []Entry, ignored []string, err error) {
+	r := &reader{}
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		err = r.readZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		err = r.readTarGz(data)
+	default:
+		err = fmt.Errorf("%w: not a zip or tar.gz", ErrBadArchive)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.entries, r.ignored, nil
+}
+
+type reader struct {
+	entries []Entry
+	ignored []string
+	total   int64
+	files   int
+}
+
+// add reads one file of the archive, keeping it if it's a replay or stats
+// file.
+func (r *reader) add(name string, open func() (io.ReadCloser, error)) error {
+	r.files++
+	if r.files > MaxEntries {
+		return fmt.Errorf("%w: more than %d files", ErrBadArchive, MaxEntries)
+	}
+	k := kind(name)
+	if k == "" {
+		r.ignored = append(r.ignored, name)
+		return nil
+	}
+	rc, err := open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBadArchive, name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, MaxEntryBytes+1))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBadArchive, name, err)
+	}
+	if len(data) > MaxEntryBytes {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrBadArchive, name, MaxEntryBytes)
+	}
+	r.total += int64(len(data))
+	if r.total > MaxTotalBytes {
+		return fmt.Errorf("%w: files total more than %d bytes", ErrBadArchive, MaxTotalBytes)
+	}
+	r.entries = append(r.entries, Entry{Name: name, Kind: k, Data: data})
+	return nil
+}
+
+func (r *reader) readZip(data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadArchive, err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := r.add(f.Name, f.Open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reader) readTarGz(data []byte) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadArchive, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBadArchive, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err := r.add(h.Name, open); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package batch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+)
+
+var files = []struct{ name, body string }{
+	{"Replays/Final.rep", "replay one"},
+	{"Replays/Final.json.gz", "stats one"},
+	{"Replays/readme.txt", "hello"},
+	{"__MACOSX/Replays/._Final.rep", "resource fork"},
+	{"Replays/Semi.REP", "replay two"},
+}
+
+func zipArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("Replays/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "Replays/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(f.body))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.body))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	for name, data := range map[string][]byte{"zip": zipArchive(t), "tar.gz": tarGzArchive(t)} {
+		entries, ignored, err := Read(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(entries) != 3 || entries[0].Kind != KindReplay || entries[1].Kind != KindStats || entries[2].Name != "Replays/Semi.REP" {
+			t.Errorf("%s: unexpected entries: %+v", name, entries)
+		}
+		if string(entries[1].Data) != "stats one" || entries[0].Stem() != entries[1].Stem() || entries[2].Stem() != "replays/semi" {
+			t.Errorf("%s: unexpected entry contents or stems: %+v", name, entries)
+		}
+		if len(ignored) != 2 {
+			t.Errorf("%s: expected the text file and the resource fork ignored, got %v", name, ignored)
+		}
+	}
+
+	if _, _, err := Read([]byte("not an archive")); !errors.Is(err, ErrBadArchive) {
+		t.Errorf("expected ErrBadArchive, got %v", err)
+	}
+	if _, _, err := Read([]byte{0x1f, 0x8b, 0, 0}); !errors.Is(err, ErrBadArchive) {
+		t.Errorf("expected ErrBadArchive for a broken gzip stream, got %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+// Package datastore bundles the INI-derived stores the server parses replays
+// with, and lets the whole bundle be swapped atomically while the server is
+// running. Handlers grab the current Bundle once per request, so an in-flight
+// parse keeps using the data set it started with even if a reload lands
+// half-way through.
+//
+// A reload builds a complete new Bundle from disk, validates it, and only
+// then publishes it; a bad data push leaves the previous bundle in service.
+package datastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/bitparse"
+	"github.com/bill-rich/cncstats/pkg/gametext"
+	"github.com/bill-rich/cncstats/pkg/iniparse"
+)
+
+// Bundle is one consistent set of stores loaded from a single Data/INI tree.
+// A Bundle is never modified after it is published; reloads replace it.
+type Bundle struct {
+	Objects  *iniparse.ObjectStore
+	Powers   *iniparse.PowerStore
+	Upgrades *iniparse.UpgradeStore
+	Colors   *iniparse.ColorStore
+	// Strings holds the localized string tables found next to the INI
+	// directory (Data/<Language>/generals.csf), keyed by lower-cased
+	// language name. Empty when the data set ships no string files.
+	Strings map[string]*gametext.Table
+
+	// labels maps object, upgrade and power template names to their
+	// string-table label; see DisplayLabel.
+	labels map[string]string
+
+	// Dir is the INI directory the bundle was loaded from.
+	Dir string
+	// Version identifies the data set: a short content hash over every
+	// .ini file under Dir and every string file loaded, so two servers (or
+	// two reloads) with identical data report the same version regardless
+	// of file timestamps.
+	Version  string
+	LoadedAt time.Time
+}
+
+// Load reads every store from dir and stamps the result with a content
+// version. It does not validate the result; see Validate.
+func Load(dir string) (*Bundle, error) {
+	if dir == "" {
+		return nil, errors.New("datastore: no INI directory configured")
+	}
+	objects, err := iniparse.NewObjectStore(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load object store: %w", err)
+	}
+	powers, err := iniparse.NewPowerStore(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load power store: %w", err)
+	}
+	powers.LinkObjects(objects)
+	upgrades, err := iniparse.NewUpgradeStore(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load upgrade store: %w", err)
+	}
+	colors, err := iniparse.NewColorStore(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not load color store: %w", err)
+	}
+	// String tables live beside the INI tree in the game's layout:
+	// Data/INI/... and Data/English/generals.csf.
+	tables, stringFiles, err := gametext.LoadLanguages(filepath.Dir(filepath.Clean(dir)))
+	if err != nil {
+		return nil, fmt.Errorf("could not load string tables: %w", err)
+	}
+	version, err := hashDir(dir, stringFiles)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash INI directory: %w", err)
+	}
+	return &Bundle{
+		Objects:  objects,
+		Powers:   powers,
+		Upgrades: upgrades,
+		Colors:   colors,
+		Strings:  tables,
+		labels:   buildLabels(objects, powers, upgrades),
+		Dir:      dir,
+		Version:  version,
+		LoadedAt: time.Now().UTC(),
+	}, nil
+}
+
+// buildLabels indexes every template's DisplayName by template name. Objects
+// win over upgrades and powers on the (rare) name collision.
+func buildLabels(objects *iniparse.ObjectStore, powers *iniparse.PowerStore, upgrades *iniparse.UpgradeStore) map[string]string {
+	labels := map[string]string{}
+	for _, p := range powers.Power {
+		if p.DisplayName != "" {
+			labels[p.Name] = p.DisplayName
+		}
+	}
+	for _, u := range upgrades.Upgrade {
+		if u.DisplayName != "" {
+			labels[u.Name] = u.DisplayName
+		}
+	}
+	for _, o := range objects.Object {
+		if o.DisplayName != "" {
+			labels[o.Name] = o.DisplayName
+		}
+	}
+	return labels
+}
+
+// Validate reports whether the bundle is fit to serve. It rejects data sets
+// that parsed without error but are clearly broken: an empty store (wrong
+// directory, or a file that lost its contents in transit) or an object with
+// no name, which would shift every later object ID.
+func (b *Bundle) Validate() error {
+	if b == nil {
+		return errors.New("datastore: nil bundle")
+	}
+	if b.Objects == nil || len(b.Objects.Object) == 0 {
+		return errors.New("datastore: no objects loaded")
+	}
+	if b.Powers == nil || len(b.Powers.Power) == 0 {
+		return errors.New("datastore: no special powers loaded")
+	}
+	if b.Upgrades == nil || len(b.Upgrades.Upgrade) == 0 {
+		return errors.New("datastore: no upgrades loaded")
+	}
+	if b.Colors == nil || len(b.Colors.Color) == 0 {
+		return errors.New("datastore: no multiplayer colors loaded")
+	}
+	for i, obj := range b.Objects.Object {
+		if obj.Name == "" {
+			return fmt.Errorf("datastore: object %d has no name", i+iniparse.ObjectStoreOffset)
+		}
+	}
+	return nil
+}
+
+// DisplayLabel returns the string-table label for a template name, or "" if
+// the INI gives it none. Safe on a nil or empty bundle.
+func (b *Bundle) DisplayLabel(name string) string {
+	if b == nil {
+		return ""
+	}
+	return b.labels[name]
+}
+
+// StringTables returns the tables to resolve labels against for language, in
+// lookup order: mapStrings (a map.str sidecar, may be nil), the requested
+// language, then english as a fallback.
+func (b *Bundle) StringTables(language string, mapStrings *gametext.Table) []*gametext.Table {
+	tables := []*gametext.Table{mapStrings}
+	if b == nil {
+		return tables
+	}
+	language = strings.ToLower(language)
+	if t, ok := b.Strings[language]; ok {
+		tables = append(tables, t)
+	}
+	if language != "english" {
+		if t, ok := b.Strings["english"]; ok {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// Languages returns the languages with string tables, sorted.
+func (b *Bundle) Languages() []string {
+	out := make([]string, 0, len(b.Strings))
+	for lang := range b.Strings {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// BitParser returns a parser reading from src that resolves IDs against the
+// bundle's stores. A nil bundle yields a parser with no stores, matching the
+// server's -no-stores mode.
+func (b *Bundle) BitParser(src io.Reader) *bitparse.BitParser {
+	bp := &bitparse.BitParser{Source: src}
+	if b != nil {
+		bp.ObjectStore = b.Objects
+		bp.PowerStore = b.Powers
+		bp.UpgradeStore = b.Upgrades
+		bp.ColorStore = b.Colors
+	}
+	return bp
+}
+
+// hashDir returns a short sha256 over the relative path and contents of every
+// .ini file under dir, visited in sorted order, followed by extra files.
+func hashDir(dir string, extra []string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".ini") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	files = append(files, extra...)
+
+	h := sha256.New()
+	for _, path := range files {
+		rel, err := filepath.Rel(filepath.Dir(dir), path)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, filepath.ToSlash(rel))
+		h.Write([]byte{0})
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// ErrDisabled is returned by Reload on a holder made without a directory,
+// as the server is under -no-stores.
+var ErrDisabled = errors.New("datastore: INI stores are disabled")
+
+// Holder owns the bundle currently in service. The zero value is not usable;
+// create one with NewHolder.
+type Holder struct {
+	current atomic.Pointer[Bundle]
+
+	// mu serializes reloads and guards the fields below. Readers of the
+	// current bundle never take it.
+	mu            sync.Mutex
+	dir           string
+	lastReloadAt  time.Time
+	lastReloadErr error
+}
+
+// NewHolder returns a holder that loads from dir. Nothing is loaded until the
+// first Reload; until then Current returns an empty bundle, which parses
+// replays with blank names exactly like the -no-stores mode. With an empty
+// dir nothing is ever loaded and Reload returns ErrDisabled.
+func NewHolder(dir string) *Holder {
+	h := &Holder{dir: dir}
+	h.current.Store(&Bundle{})
+	return h
+}
+
+// Current returns the bundle in service. It never returns nil.
+func (h *Holder) Current() *Bundle {
+	return h.current.Load()
+}
+
+// Reload loads and validates a fresh bundle from the holder's directory and,
+// if it is valid, swaps it in. On failure the previous bundle stays in
+// service and the error is returned (and reported by Status).
+func (h *Holder) Reload() (*Bundle, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.dir == "" {
+		return nil, ErrDisabled
+	}
+
+	h.lastReloadAt = time.Now().UTC()
+
+	b, err := Load(h.dir)
+	if err == nil {
+		err = b.Validate()
+	}
+	if err != nil {
+		h.lastReloadErr = err
+		return nil, err
+	}
+
+	h.lastReloadErr = nil
+	h.current.Store(b)
+	return b, nil
+}
+
+// Status is a read-only snapshot of the data set in service, for the HTTP
+// status endpoint.
+type Status struct {
+	Loaded          bool      `json:"loaded"`
+	Dir             string    `json:"dir"`
+	Version         string    `json:"version,omitempty"`
+	LoadedAt        time.Time `json:"loadedAt,omitempty"`
+	Objects         int       `json:"objects"`
+	Powers          int       `json:"powers"`
+	Upgrades        int       `json:"upgrades"`
+	Colors          int       `json:"colors"`
+	Languages       []string  `json:"languages"`
+	LastReloadAt    time.Time `json:"lastReloadAt,omitempty"`
+	LastReloadError string    `json:"lastReloadError,omitempty"`
+}
+
+// Status returns a snapshot of the bundle in service and the outcome of the
+// last reload attempt.
+func (h *Holder) Status() Status {
+	b := h.Current()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := Status{
+		Loaded:       b.Version != "",
+		Dir:          h.dir,
+		Version:      b.Version,
+		LoadedAt:     b.LoadedAt,
+		LastReloadAt: h.lastReloadAt,
+	}
+	if b.Objects != nil {
+		st.Objects = len(b.Objects.Object)
+	}
+	if b.Powers != nil {
+		st.Powers = len(b.Powers.Power)
+	}
+	if b.Upgrades != nil {
+		st.Upgrades = len(b.Upgrades.Upgrade)
+	}
+	if b.Colors != nil {
+		st.Colors = len(b.Colors.Color)
+	}
+	st.Languages = b.Languages()
+	if h.lastReloadErr != nil {
+		st.LastReloadError = h.lastReloadErr.Error()
+	}
+	return st
+}
@@ -0,0 +1,167 @@
+package datastore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeINITree writes a minimal but valid Data/INI tree into dir.
+func writeINITree(t *testing.T, dir string, objects string) {
+	t.Helper()
+	files := map[string]string{
+		"Object/test.ini":  objects,
+		"SpecialPower.ini": "SpecialPower TestPower\nEnd\n",
+		"Upgrade.ini":      "Upgrade TestUpgrade\n  BuildCost=100\nEnd\n",
+		"multiplayer.ini":  "MultiplayerColor Gold\n  RGBColor = R:221 G:226 B:13\n  TooltipName = Color:Gold\nEnd\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadAndValidate(t *testing.T) {
+	dir := t.TempDir()
+	writeINITree(t, dir, "Object TestUnit\n  BuildCost=100\nEnd\n")
+
+	b, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("expected valid bundle, got %v", err)
+	}
+	if len(b.Version) != 12 {
+		t.Errorf("expected 12-char version, got %q", b.Version)
+	}
+
+	again, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again.Version != b.Version {
+		t.Errorf("version not stable across loads: %q vs %q", b.Version, again.Version)
+	}
+}
+
+func TestValidateRejectsEmptyObjects(t *testing.T) {
+	dir := t.TempDir()
+	writeINITree(t, dir, "; emptied by a bad sync\n")
+
+	b, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Validate(); err == nil {
+		t.Error("expected validation error for empty object store")
+	}
+}
+
+func TestHolderReload(t *testing.T) {
+	dir := t.TempDir()
+	writeINITree(t, dir, "Object TestUnit\n  BuildCost=100\nEnd\n")
+
+	h := NewHolder(dir)
+	if h.Current() == nil {
+		t.Fatal("Current must never be nil")
+	}
+	if h.Status().Loaded {
+		t.Error("expected holder to report not loaded before first reload")
+	}
+
+	first, err := h.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.Current() != first {
+		t.Error("expected reloaded bundle to be current")
+	}
+
+	// Changing the data changes the version.
+	writeINITree(t, dir, "Object TestUnit\n  BuildCost=200\nEnd\n")
+	second, err := h.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Version == first.Version {
+		t.Error("expected version to change with data")
+	}
+
+	// A broken push keeps the previous bundle in service.
+	writeINITree(t, dir, "")
+	if _, err := h.Reload(); err == nil {
+		t.Fatal("expected reload of empty object store to fail")
+	}
+	if h.Current() != second {
+		t.Error("failed reload must not replace the current bundle")
+	}
+	st := h.Status()
+	if st.LastReloadError == "" {
+		t.Error("expected status to report the failed reload")
+	}
+	if st.Version != second.Version {
+		t.Errorf("expected status version %q, got %q", second.Version, st.Version)
+	}
+
+	if _, err := NewHolder("").Reload(); !errors.Is(err, ErrDisabled) {
+		t.Errorf("expected ErrDisabled without a directory, got %v", err)
+	}
+}
+
+func TestBundleBitParser(t *testing.T) {
+	var nilBundle *Bundle
+	bp := nilBundle.BitParser(bytes.NewReader(nil))
+	if bp.ObjectStore != nil || bp.Source == nil {
+		t.Errorf("unexpected parser from nil bundle: %+v", bp)
+	}
+}
+
+func TestLoadStringTables(t *testing.T) {
+	data := t.TempDir()
+	dir := filepath.Join(data, "INI")
+	writeINITree(t, dir, "Object TestUnit\n  DisplayName = OBJECT:TestUnit\nEnd\n")
+
+	b, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.Strings) != 0 {
+		t.Errorf("expected no string tables, got %v", b.Languages())
+	}
+	if got := b.DisplayLabel("TestUnit"); got != "OBJECT:TestUnit" {
+		t.Errorf("expected label OBJECT:TestUnit, got %q", got)
+	}
+
+	english := filepath.Join(data, "English")
+	if err := os.MkdirAll(english, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	str := "OBJECT:TestUnit\n\"Test Unit\"\nEnd\n"
+	if err := os.WriteFile(filepath.Join(english, "generals.str"), []byte(str), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	withStrings, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if withStrings.Version == b.Version {
+		t.Error("expected string files to change the version")
+	}
+
+	// German is missing, so lookups fall back to english after the map table.
+	tables := withStrings.StringTables("German", nil)
+	if len(tables) != 2 || tables[0] != nil || tables[1].Language != "english" {
+		t.Fatalf("unexpected lookup order: %+v", tables)
+	}
+	if got, _ := tables[1].Lookup(withStrings.DisplayLabel("TestUnit")); got != "Test Unit" {
+		t.Errorf("expected Test Unit, got %q", got)
+	}
+}
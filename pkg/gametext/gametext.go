@@ -0,0 +1,348 @@
+// Package gametext parses the game's localized string tables: the binary
+// generals.csf shipped per language (Data/<Language>/generals.csf) and the
+// plain-text .str format used by mods and by per-map map.str sidecars.
+//
+// Both formats map a label such as "OBJECT:BattleMaster" to display text.
+// INI entries reference labels (an Object's DisplayName, a CommandButton's
+// TextLabel); Table.Lookup turns those into the names players see in game.
+// Labels are case-insensitive, matching GameText's lookup in the engine.
+package gametext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// Table holds one language's strings keyed by lower-cased label.
+type Table struct {
+	// Language is the lower-cased language name, e.g. "english". CSF files
+	// carry it in their header; .str files take it from the caller.
+	Language string
+	Strings  map[string]string
+}
+
+// Lookup returns the text for label and whether it was found. A nil table
+// finds nothing.
+func (t *Table) Lookup(label string) (string, bool) {
+	if t == nil || label == "" {
+		return "", false
+	}
+	s, ok := t.Strings[strings.ToLower(label)]
+	return s, ok
+}
+
+// Len returns the number of labels in the table.
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.Strings)
+}
+
+// Merge copies every label from other into t, overwriting existing labels.
+// Used to layer a mod's .str file over the base CSF.
+func (t *Table) Merge(other *Table) {
+	if other == nil {
+		return
+	}
+	for k, v := range other.Strings {
+		t.Strings[k] = v
+	}
+}
+
+// CSF chunk identifiers. The engine builds them with MAKE_ID('C','S','F',' ')
+// and reads them as little-endian ints, so they appear reversed on disk.
+const (
+	csfMagic       = " FSC"
+	csfLabel       = " LBL"
+	csfString      = " RTS"
+	csfStringWave  = "WRTS" // string followed by an ASCII wave filename
+	csfHeaderBytes = 24
+
+	// maxCSFField caps any single length field so a corrupt file can't make
+	// us allocate gigabytes.
+	maxCSFField = 1 << 20
+)
+
+// csfLanguages maps the CSF header language ID to a name. The order follows
+// the engine's LanguageID enum.
+var csfLanguages = []string{
+	"english", "english", "german", "french", "spanish", "italian",
+	"japanese", "jabber", "korean", "chinese", "unknown", "unknown",
+	"brazilian", "polish",
+}
+
+// LanguageName returns the language name for a CSF language ID.
+func LanguageName(id int) string {
+	if id < 0 || id >= len(csfLanguages) {
+		return "unknown"
+	}
+	return csfLanguages[id]
+}
+
+// ParseCSF reads a compiled string file. Strings are UTF-16LE with every bit
+// inverted; labels with more than one string keep the first, which is the
+// only one the engine displays.
+func ParseCSF(r io.Reader) (*Table, error) {
+	br := bufio.NewReader(r)
+
+	var hdr [csfHeaderBytes]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read CSF header: %w", err)
+	}
+	if string(hdr[0:4]) != csfMagic {
+		return nil, fmt.Errorf("not a CSF file (magic %q)", hdr[0:4])
+	}
+	version := int(binary.LittleEndian.Uint32(hdr[4:8]))
+	numLabels := int(binary.LittleEndian.Uint32(hdr[8:12]))
+	language := "english"
+	if version > 1 {
+		language = LanguageName(int(binary.LittleEndian.Uint32(hdr[20:24])))
+	}
+	if numLabels < 0 || numLabels > maxCSFField {
+		return nil, fmt.Errorf("CSF label count %d out of range", numLabels)
+	}
+
+	t := &Table{Language: language, Strings: make(map[string]string, numLabels)}
+	for i := 0; i < numLabels; i++ {
+		id, err := readCSFTag(br)
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", i, err)
+		}
+		if id != csfLabel {
+			return nil, fmt.Errorf("label %d: expected %q chunk, got %q", i, csfLabel, id)
+		}
+		numStrings, err := readCSFInt(br)
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", i, err)
+		}
+		labelLen, err := readCSFInt(br)
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", i, err)
+		}
+		label := make([]byte, labelLen)
+		if _, err := io.ReadFull(br, label); err != nil {
+			return nil, fmt.Errorf("label %d: read name: %w", i, err)
+		}
+
+		for j := 0; j < numStrings; j++ {
+			text, err := readCSFString(br)
+			if err != nil {
+				return nil, fmt.Errorf("label %q string %d: %w", label, j, err)
+			}
+			if j == 0 {
+				t.Strings[strings.ToLower(string(label))] = text
+			}
+		}
+	}
+	return t, nil
+}
+
+func readCSFTag(r io.Reader) (string, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return "", err
+	}
+	return string(b[:]), nil
+}
+
+func readCSFInt(r io.Reader) (int, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	n := int(int32(binary.LittleEndian.Uint32(b[:])))
+	if n < 0 || n > maxCSFField {
+		return 0, fmt.Errorf("length %d out of range", n)
+	}
+	return n, nil
+}
+
+// readCSFString reads one " RTS" or "WRTS" chunk and returns its text.
+func readCSFString(r io.Reader) (string, error) {
+	id, err := readCSFTag(r)
+	if err != nil {
+		return "", err
+	}
+	if id != csfString && id != csfStringWave {
+		return "", fmt.Errorf("expected string chunk, got %q", id)
+	}
+	chars, err := readCSFInt(r)
+	if err != nil {
+		return "", err
+	}
+	raw := make([]byte, chars*2)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return "", err
+	}
+	units := make([]uint16, chars)
+	for i := range units {
+		units[i] = ^binary.LittleEndian.Uint16(raw[i*2:])
+	}
+	if id == csfStringWave {
+		waveLen, err := readCSFInt(r)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(waveLen)); err != nil {
+			return "", err
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// ParseStr reads a .str string file:
+//
+//	// comment
+//	OBJECT:BattleMaster
+//	"Battlemaster Tank"
+//	End
+//
+// The value may be split over several quoted lines, which are concatenated;
+// an optional unquoted line (a wave filename) is ignored. Escapes \n, \t,
+// \" and \\ are decoded. language is recorded on the returned table.
+func ParseStr(r io.Reader, language string) (*Table, error) {
+	t := &Table{Language: strings.ToLower(language), Strings: map[string]string{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxCSFField)
+
+	label := ""
+	var value strings.Builder
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if label == "" {
+			label = line
+			value.Reset()
+			continue
+		}
+		if strings.EqualFold(line, "End") {
+			t.Strings[strings.ToLower(label)] = value.String()
+			label = ""
+			continue
+		}
+		if strings.HasPrefix(line, "\"") {
+			text, err := unquoteStr(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value.WriteString(text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if label != "" {
+		return nil, fmt.Errorf("label %q missing End", label)
+	}
+	return t, nil
+}
+
+// unquoteStr decodes one quoted .str value line. Text after the closing
+// quote is ignored.
+func unquoteStr(line string) (string, error) {
+	var b strings.Builder
+	escaped := false
+	for _, r := range line[1:] {
+		switch {
+		case escaped:
+			switch r {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				b.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return b.String(), nil
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", errors.New("unterminated quoted string")
+}
+
+// LoadLanguages loads the string tables under a game Data directory, laid out
+// as the game ships them: one subdirectory per language holding generals.csf
+// (Data/English/generals.csf, Data/German/generals.csf, ...). Any .str files
+// in a language directory are layered over its CSF, which is how mods ship
+// extra labels. Tables are keyed by the lower-cased directory name. A missing
+// Data directory, or one with no string files, yields an empty map. files
+// lists every file read, in load order, so callers can fingerprint the set.
+func LoadLanguages(dataDir string) (tables map[string]*Table, files []string, err error) {
+	tables = map[string]*Table{}
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tables, nil, nil
+		}
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		language := strings.ToLower(entry.Name())
+		langDir := filepath.Join(dataDir, entry.Name())
+		langFiles, err := os.ReadDir(langDir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// CSFs first so .str overrides land on top regardless of name order.
+		var csfs, strs []string
+		for _, f := range langFiles {
+			if f.IsDir() {
+				continue
+			}
+			switch strings.ToLower(filepath.Ext(f.Name())) {
+			case ".csf":
+				csfs = append(csfs, filepath.Join(langDir, f.Name()))
+			case ".str":
+				strs = append(strs, filepath.Join(langDir, f.Name()))
+			}
+		}
+		if len(csfs)+len(strs) == 0 {
+			continue
+		}
+
+		table := &Table{Language: language, Strings: map[string]string{}}
+		for _, path := range append(csfs, strs...) {
+			part, err := parseFile(path, language)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			table.Merge(part)
+			files = append(files, path)
+		}
+		tables[language] = table
+	}
+	return tables, files, nil
+}
+
+func parseFile(path, language string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csf") {
+		return ParseCSF(f)
+	}
+	return ParseStr(f, language)
+}
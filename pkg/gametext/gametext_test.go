@@ -0,0 +1,155 @@
+package gametext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+type csfEntry struct {
+	label string
+	text  string
+	wave  string // non-empty writes a WRTS chunk
+}
+
+// buildCSF writes a version 3 CSF with the given language ID.
+func buildCSF(language uint32, entries []csfEntry) []byte {
+	var b bytes.Buffer
+	le := func(v uint32) { binary.Write(&b, binary.LittleEndian, v) }
+	b.WriteString(csfMagic)
+	le(3)
+	le(uint32(len(entries)))
+	le(uint32(len(entries)))
+	le(0)
+	le(language)
+	for _, e := range entries {
+		b.WriteString(csfLabel)
+		le(1)
+		le(uint32(len(e.label)))
+		b.WriteString(e.label)
+		if e.wave != "" {
+			b.WriteString(csfStringWave)
+		} else {
+			b.WriteString(csfString)
+		}
+		units := utf16.Encode([]rune(e.text))
+		le(uint32(len(units)))
+		for _, u := range units {
+			binary.Write(&b, binary.LittleEndian, ^u)
+		}
+		if e.wave != "" {
+			le(uint32(len(e.wave)))
+			b.WriteString(e.wave)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestParseCSF(t *testing.T) {
+	data := buildCSF(2, []csfEntry{
+		{label: "OBJECT:BattleMaster", text: "Battlemaster-Panzer"},
+		{label: "GUI:Ok", text: "Oké", wave: "ok.wav"},
+	})
+	table, err := ParseCSF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if table.Language != "german" {
+		t.Errorf("expected language german, got %q", table.Language)
+	}
+	if table.Len() != 2 {
+		t.Errorf("expected 2 labels, got %d", table.Len())
+	}
+	if got, _ := table.Lookup("object:battlemaster"); got != "Battlemaster-Panzer" {
+		t.Errorf("lookup is case-insensitive: got %q", got)
+	}
+	if got, _ := table.Lookup("GUI:Ok"); got != "Oké" {
+		t.Errorf("WRTS string: got %q", got)
+	}
+}
+
+func TestParseCSFErrors(t *testing.T) {
+	if _, err := ParseCSF(strings.NewReader("not a csf file at all....")); err == nil {
+		t.Error("expected error for bad magic")
+	}
+	data := buildCSF(0, []csfEntry{{label: "A", text: "alpha"}})
+	if _, err := ParseCSF(bytes.NewReader(data[:len(data)-3])); err == nil {
+		t.Error("expected error for truncated file")
+	}
+}
+
+func TestParseStr(t *testing.T) {
+	input := "\ufeff// map strings\r\n" +
+		"OBJECT:BattleMaster\r\n" +
+		"\"Big \\\"Red\\\" \"\r\n" +
+		"\"Tank\\n\"\r\n" +
+		"End\r\n" +
+		"\r\n" +
+		"MAP:Title\n" +
+		"\"Tournament Desert\" ; trailing\n" +
+		"TournamentDesert.wav\n" +
+		"END\n"
+	table, err := ParseStr(strings.NewReader(input), "English")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if table.Language != "english" {
+		t.Errorf("expected language english, got %q", table.Language)
+	}
+	if got, _ := table.Lookup("OBJECT:BattleMaster"); got != "Big \"Red\" Tank\n" {
+		t.Errorf("got %q", got)
+	}
+	if got, _ := table.Lookup("map:title"); got != "Tournament Desert" {
+		t.Errorf("got %q", got)
+	}
+
+	if _, err := ParseStr(strings.NewReader("LABEL\n\"text\"\n"), "english"); err == nil {
+		t.Error("expected error for missing End")
+	}
+}
+
+func TestLoadLanguages(t *testing.T) {
+	dataDir := t.TempDir()
+	english := filepath.Join(dataDir, "English")
+	if err := os.MkdirAll(english, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, "INI"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	csf := buildCSF(0, []csfEntry{
+		{label: "OBJECT:BattleMaster", text: "Battlemaster"},
+		{label: "OBJECT:Overlord", text: "Overlord"},
+	})
+	if err := os.WriteFile(filepath.Join(english, "generals.csf"), csf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A .str named to sort before the CSF must still override it.
+	mod := "OBJECT:Overlord\n\"Overlord Mk II\"\nEnd\n"
+	if err := os.WriteFile(filepath.Join(english, "a_mod.str"), []byte(mod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tables, files, err := LoadLanguages(dataDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tables) != 1 || len(files) != 2 {
+		t.Fatalf("expected 1 table from 2 files, got %d tables, %v", len(tables), files)
+	}
+	if got, _ := tables["english"].Lookup("OBJECT:Overlord"); got != "Overlord Mk II" {
+		t.Errorf("expected .str override, got %q", got)
+	}
+	if got, _ := tables["english"].Lookup("OBJECT:BattleMaster"); got != "Battlemaster" {
+		t.Errorf("got %q", got)
+	}
+
+	tables, _, err = LoadLanguages(filepath.Join(dataDir, "missing"))
+	if err != nil || len(tables) != 0 {
+		t.Errorf("expected empty result for missing dir, got %v, %v", tables, err)
+	}
+}
@@ -0,0 +1,688 @@
+// Package identity links the nicknames players go by into stable player
+// identities, so ratings and profiles follow a player across renames.
+//
+// Every name seen is an alias. Evidence that two aliases are one player is
+// recorded as sightings of an alias with some key:
+//
+//   - KindIP: the hex address a replay header records for the slot
+//   - KindClient: the API client that uploaded logs as X-Player
+//   - KindDisplay: one slot of one match, seen under its header name and
+//     under a different stats DisplayName
+//
+// Aliases each seen with the same key in at least that kind's threshold of
+// matches are linked. A key seen with more than MaxShared aliases (a LAN
+// party's address, the client a tournament admin uploads everyone's logs
+// with) links nothing, and aliases recorded as different players of one
+// match are never linked by the evidence: they are different players,
+// even behind one address or client. Admins can merge players the
+// evidence misses and split aliases it wrongly links; their decisions
+// override the evidence, and both they and the links the evidence makes
+// are written to an audit trail.
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+// Kinds of evidence.
+const (
+	KindIP      = "ip"
+	KindClient  = "client"
+	KindDisplay = "display"
+)
+
+// Thresholds is how many matches two aliases must each be seen in with a
+// key of each kind before they are linked. Sightings of other kinds link
+// nothing.
+var Thresholds = map[string]int{
+	KindIP:      3,
+	KindClient:  2,
+	KindDisplay: 1,
+}
+
+// MaxShared is the most aliases a key can be seen with and still link
+// them.
+const MaxShared = 4
+
+// maxMatches caps the matches remembered per alias and key; only the
+// count up to the threshold matters.
+const maxMatches = 10
+
+const (
+	stateKey    = "identities.json"
+	auditPrefix = "audit/"
+)
+
+var (
+	// ErrUnknown is returned for a name that is neither an alias nor a
+	// player ID.
+	ErrUnknown = errors.New("identity: unknown player")
+	// ErrNoChange is returned for a merge of one player with itself and a
+	// split of an alias linked to no other.
+	ErrNoChange = errors.New("identity: nothing to change")
+)
+
+// Observation is one sighting of an alias. Kind and Value name the
+// evidence key; with an empty Kind the alias is only recorded as seen.
+type Observation struct {
+	Alias string
+	Kind  string
+	Value string
+	// Match is the match the alias was seen in. Repeated sightings in one
+	// match count once.
+	Match string
+	At    time.Time
+}
+
+// Alias is a name a player has been seen under.
+type Alias struct {
+	Name string `json:"name"`
+	// Seq numbers aliases in the order they were first seen. A player's ID
+	// comes from its oldest alias.
+	Seq       int       `json:"seq"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Event is one entry of the audit trail.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Actor is the API client of an admin decision, "auto" for a link the
+	// evidence made.
+	Actor string `json:"actor"`
+	// Action is "link" or "unlink" for the evidence, "merge" or "split"
+	// for an admin decision.
+	Action  string   `json:"action"`
+	Aliases []string `json:"aliases"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// state is everything stored in stateKey.
+type state struct {
+	// Revision counts writes, so derived data can be cached.
+	Revision int               `json:"revision"`
+	Aliases  map[string]*Alias `json:"aliases"`
+	// Sightings maps an evidence key, kind and value joined by a NUL, to
+	// the matches each alias was seen with it in.
+	Sightings map[string]map[string][]string `json:"sightings"`
+	// Merges are admin-confirmed alias pairs; Splits are alias pairs an
+	// admin has ruled are different players.
+	Merges [][2]string `json:"merges,omitempty"`
+	Splits [][2]string `json:"splits,omitempty"`
+}
+
+func evidenceKey(kind, value string) string {
+	return kind + "\x00" + value
+}
+
+func pair(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// Store keeps the identity state in a storage backend: one object for
+// the aliases, evidence and admin decisions, and one per audit event.
+type Store struct {
+	backend storage.Backend
+
+	// mu serializes read-modify-write of the state.
+	mu sync.Mutex
+
+	// dirMu guards dir, the Directory of the state object last read, and
+	// dirInfo, what Stat said of that object.
+	dirMu   sync.Mutex
+	dir     *Directory
+	dirInfo storage.Info
+}
+
+// NewStore returns a Store that keeps its records in b.
+func NewStore(b storage.Backend) *Store {
+	return &Store{backend: b}
+}
+
+func (s *Store) load() (*state, error) {
+	st := &state{Aliases: map[string]*Alias{}, Sightings: map[string]map[string][]string{}}
+	data, err := storage.ReadAll(s.backend, stateKey)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read identities: %w", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("parse identities: %w", err)
+	}
+	return st, nil
+}
+
+func (s *Store) save(st *state, events []Event) error {
+	st.Revision++
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := storage.PutBytes(s.backend, stateKey, data); err != nil {
+		return fmt.Errorf("write identities: %w", err)
+	}
+	s.dirMu.Lock()
+	s.dir = nil
+	s.dirMu.Unlock()
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s%020d-%d.json", auditPrefix, e.Time.UnixNano(), i)
+		if err := storage.PutBytes(s.backend, key, data); err != nil {
+			return fmt.Errorf("write identity audit: %w", err)
+		}
+	}
+	return nil
+}
+
+// Observe records sightings of aliases and the evidence they carry.
+// Evidence that reaches its threshold is written to the audit trail. A
+// sighting already recorded, such as one from a duplicate upload or a
+// reprocessed match, changes nothing, and when none changes anything the
+// state isn't written, so caches over it stay valid.
+func (s *Store) Observe(obs ...Observation) error {
+	if len(obs) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	var events []Event
+	changed := false
+	for _, o := range obs {
+		if o.Alias == "" {
+			continue
+		}
+		at := o.At
+		if at.IsZero() {
+			at = time.Now().UTC()
+		}
+		a, ok := st.Aliases[o.Alias]
+		if !ok {
+			a = &Alias{Name: o.Alias, Seq: len(st.Aliases) + 1, FirstSeen: at, LastSeen: at}
+			st.Aliases[o.Alias] = a
+			changed = true
+		}
+
+		threshold, ok := Thresholds[o.Kind]
+		evidence := ok && o.Value != ""
+		key := evidenceKey(o.Kind, o.Value)
+		matches := st.Sightings[key][o.Alias]
+		if evidence && slices.Contains(matches, o.Match) {
+			continue
+		}
+		if at.Before(a.FirstSeen) {
+			a.FirstSeen = at
+			changed = true
+		}
+		if at.After(a.LastSeen) {
+			a.LastSeen = at
+			changed = true
+		}
+		if !evidence || len(matches) >= maxMatches {
+			continue
+		}
+		seen := st.Sightings[key]
+		if seen == nil {
+			seen = map[string][]string{}
+			st.Sightings[key] = seen
+		}
+		changed = true
+		if len(matches) == 0 && len(seen) == MaxShared {
+			// This alias makes the key shared, so the links it made no
+			// longer hold.
+			if linked := paired(qualified(seen, threshold), st.together()); len(linked) > 1 {
+				events = append(events, Event{
+					Time:    time.Now().UTC(),
+					Actor:   "auto",
+					Action:  "unlink",
+					Aliases: linked,
+					Reason:  fmt.Sprintf("%s evidence shared by more than %d nicknames", o.Kind, MaxShared),
+				})
+			}
+		}
+		seen[o.Alias] = append(matches, o.Match)
+		if len(seen[o.Alias]) != threshold || len(seen) > MaxShared {
+			continue
+		}
+		// This alias just reached the threshold; record a link if others
+		// already have, leaving out those it played alongside. The reason
+		// doesn't name the evidence value, which for KindIP is an address.
+		apart := st.together()
+		var linked []string
+		for _, alias := range qualified(seen, threshold) {
+			if alias == o.Alias || !apart[pair(alias, o.Alias)] {
+				linked = append(linked, alias)
+			}
+		}
+		if len(linked) > 1 {
+			events = append(events, Event{
+				Time:    time.Now().UTC(),
+				Actor:   "auto",
+				Action:  "link",
+				Aliases: linked,
+				Reason:  fmt.Sprintf("%s evidence in %d matches each", o.Kind, threshold),
+			})
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save(st, events)
+}
+
+// together returns the pairs of aliases recorded with their addresses, or
+// as uploaders of logs, in the same match: two players of one match are
+// two people. Only the matches remembered per alias and key are known, so
+// this is best effort.
+func (st *state) together() map[[2]string]bool {
+	byMatch := map[string][]string{}
+	for key, seen := range st.Sightings {
+		if kind, _, _ := strings.Cut(key, "\x00"); kind != KindIP && kind != KindClient {
+			continue
+		}
+		for alias, matches := range seen {
+			for _, m := range matches {
+				byMatch[m] = append(byMatch[m], alias)
+			}
+		}
+	}
+	out := map[[2]string]bool{}
+	for _, aliases := range byMatch {
+		for i, a := range aliases {
+			for _, b := range aliases[i+1:] {
+				if a != b {
+					out[pair(a, b)] = true
+				}
+			}
+		}
+	}
+	return out
+}
+
+// paired returns the aliases with at least one other in aliases they
+// weren't seen alongside, which are those a key links.
+func paired(aliases []string, apart map[[2]string]bool) []string {
+	var out []string
+	for _, a := range aliases {
+		for _, b := range aliases {
+			if a != b && !apart[pair(a, b)] {
+				out = append(out, a)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// qualified returns the aliases seen with a key in at least threshold
+// matches, sorted.
+func qualified(seen map[string][]string, threshold int) []string {
+	var out []string
+	for alias, matches := range seen {
+		if len(matches) >= threshold {
+			out = append(out, alias)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Merge records an admin decision that a and b, each an alias or a player
+// ID, are one player, overriding earlier splits between their aliases.
+func (s *Store) Merge(a, b, actor, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+	dir := st.directory()
+	pa, ok := dir.Lookup(a)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknown, a)
+	}
+	pb, ok := dir.Lookup(b)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknown, b)
+	}
+	if pa.ID == pb.ID {
+		return fmt.Errorf("%w: %s and %s are already player %s", ErrNoChange, a, b, pa.ID)
+	}
+
+	across := map[[2]string]bool{}
+	for _, x := range pa.Aliases {
+		for _, y := range pb.Aliases {
+			across[pair(x.Name, y.Name)] = true
+		}
+	}
+	splits := st.Splits[:0]
+	for _, p := range st.Splits {
+		if !across[p] {
+			splits = append(splits, p)
+		}
+	}
+	st.Splits = splits
+	st.Merges = append(st.Merges, pair(pa.Aliases[0].Name, pb.Aliases[0].Name))
+
+	return s.save(st, []Event{{
+		Time:    time.Now().UTC(),
+		Actor:   actor,
+		Action:  "merge",
+		Aliases: append(pa.names(), pb.names()...),
+		Reason:  reason,
+	}})
+}
+
+// Split records an admin decision that alias is a different player from
+// every other alias it is linked with, dropping merges that named it.
+func (s *Store) Split(alias, actor, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+	p, ok := st.directory().byAlias[alias]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknown, alias)
+	}
+	if len(p.Aliases) == 1 {
+		return fmt.Errorf("%w: %s is not linked to another alias", ErrNoChange, alias)
+	}
+
+	merges := st.Merges[:0]
+	for _, m := range st.Merges {
+		if m[0] != alias && m[1] != alias {
+			merges = append(merges, m)
+		}
+	}
+	st.Merges = merges
+	for _, other := range p.Aliases {
+		if other.Name != alias {
+			st.Splits = append(st.Splits, pair(alias, other.Name))
+		}
+	}
+
+	return s.save(st, []Event{{
+		Time:    time.Now().UTC(),
+		Actor:   actor,
+		Action:  "split",
+		Aliases: p.names(),
+		Reason:  reason,
+	}})
+}
+
+// Directory returns the players the current aliases and evidence resolve
+// to. It is built again only once the state object has changed, which
+// costs a Stat, so callers can ask for it on every request; they must not
+// modify it.
+func (s *Store) Directory() (*Directory, error) {
+	info, err := s.backend.Stat(stateKey)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read identities: %w", err)
+	}
+	s.dirMu.Lock()
+	defer s.dirMu.Unlock()
+	if s.dir != nil && info.Size == s.dirInfo.Size && info.ModTime.Equal(s.dirInfo.ModTime) {
+		return s.dir, nil
+	}
+	st, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.dir, s.dirInfo = st.directory(), info
+	return s.dir, nil
+}
+
+// Audit returns the audit trail, oldest first. With an alias or player
+// ID it returns only the events naming one of that player's aliases.
+func (s *Store) Audit(player string) ([]Event, error) {
+	var names map[string]bool
+	if player != "" {
+		dir, err := s.Directory()
+		if err != nil {
+			return nil, err
+		}
+		p, ok := dir.Lookup(player)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknown, player)
+		}
+		names = map[string]bool{}
+		for _, a := range p.Aliases {
+			names[a.Name] = true
+		}
+	}
+
+	infos, err := s.backend.List(auditPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list identity audit: %w", err)
+	}
+	events := []Event{}
+	for _, info := range infos {
+		data, err := storage.ReadAll(s.backend, info.Key)
+		if err != nil {
+			return nil, fmt.Errorf("read identity audit: %w", err)
+		}
+		var e Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("parse identity audit %s: %w", info.Key, err)
+		}
+		if names == nil || containsAny(names, e.Aliases) {
+			events = append(events, redact(e))
+		}
+	}
+	return events, nil
+}
+
+// redact drops the evidence value from the reason of a link event written
+// before reasons left it out ("ip 0A000001 seen in 3 matches each"): for
+// KindIP it is an address.
+func redact(e Event) Event {
+	if e.Actor != "auto" {
+		return e
+	}
+	if f := strings.Fields(e.Reason); len(f) > 3 && f[2] == "seen" {
+		e.Reason = f[0] + " evidence " + strings.Join(f[3:], " ")
+	}
+	return e
+}
+
+func containsAny(set map[string]bool, list []string) bool {
+	for _, s := range list {
+		if set[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// Player is one player identity and the aliases it goes by.
+type Player struct {
+	// ID is "p" and the Seq of the player's oldest alias. It stays the
+	// same as new aliases are linked, but a merge keeps only the older
+	// player's ID.
+	ID string `json:"id"`
+	// Name is the alias the player was most recently seen under.
+	Name string `json:"name"`
+	// Aliases are ordered oldest first.
+	Aliases []Alias `json:"aliases"`
+}
+
+func (p *Player) names() []string {
+	out := make([]string, len(p.Aliases))
+	for i, a := range p.Aliases {
+		out[i] = a.Name
+	}
+	return out
+}
+
+// Directory resolves aliases to players.
+type Directory struct {
+	// Revision changes whenever the stored identities do.
+	Revision int
+
+	byAlias map[string]*Player
+	byID    map[string]*Player
+}
+
+// Lookup returns the player with an alias or ID.
+func (d *Directory) Lookup(name string) (*Player, bool) {
+	if p, ok := d.byAlias[name]; ok {
+		return p, true
+	}
+	p, ok := d.byID[name]
+	return p, ok
+}
+
+// ID returns the ID of the player an alias belongs to, or the alias itself
+// if it has never been recorded.
+func (d *Directory) ID(alias string) string {
+	if p, ok := d.byAlias[alias]; ok {
+		return p.ID
+	}
+	return alias
+}
+
+// directory groups the aliases: admin merges first, then links from the
+// evidence, never joining two groups an admin split keeps apart or, for
+// the evidence, two aliases seen in the same match.
+func (st *state) directory() *Directory {
+	names := make([]string, 0, len(st.Aliases))
+	for name := range st.Aliases {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return st.Aliases[names[i]].Seq < st.Aliases[names[j]].Seq })
+
+	split := map[[2]string]bool{}
+	for _, p := range st.Splits {
+		split[p] = true
+	}
+	g := newGroups(names, split)
+	for _, m := range st.Merges {
+		g.union(m[0], m[1])
+	}
+	for p := range st.together() {
+		split[p] = true
+	}
+	keys := make([]string, 0, len(st.Sightings))
+	for key := range st.Sightings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		seen := st.Sightings[key]
+		kind, _, _ := strings.Cut(key, "\x00")
+		threshold, ok := Thresholds[kind]
+		if !ok || len(seen) > MaxShared {
+			continue
+		}
+		linked := qualified(seen, threshold)
+		for i, a := range linked {
+			for _, b := range linked[:i] {
+				g.union(b, a)
+			}
+		}
+	}
+
+	d := &Directory{Revision: st.Revision, byAlias: map[string]*Player{}, byID: map[string]*Player{}}
+	byRoot := map[string]*Player{}
+	// Aliases are visited oldest first, so each player's first alias is
+	// its oldest and names it.
+	for _, name := range names {
+		a := *st.Aliases[name]
+		root := g.find(name)
+		p, ok := byRoot[root]
+		if !ok {
+			p = &Player{ID: "p" + strconv.Itoa(a.Seq), Name: a.Name}
+			byRoot[root] = p
+			d.byID[p.ID] = p
+		}
+		if a.LastSeen.After(p.latest().LastSeen) {
+			p.Name = a.Name
+		}
+		p.Aliases = append(p.Aliases, a)
+		d.byAlias[name] = p
+	}
+	return d
+}
+
+// latest returns the alias the player was most recently seen under.
+func (p *Player) latest() Alias {
+	for _, a := range p.Aliases {
+		if a.Name == p.Name {
+			return a
+		}
+	}
+	return Alias{}
+}
+
+// groups is a union-find over aliases that refuses unions joining a split
+// pair.
+type groups struct {
+	parent  map[string]string
+	members map[string][]string
+	split   map[[2]string]bool
+}
+
+func newGroups(names []string, split map[[2]string]bool) *groups {
+	g := &groups{parent: map[string]string{}, members: map[string][]string{}, split: split}
+	for _, n := range names {
+		g.parent[n] = n
+		g.members[n] = []string{n}
+	}
+	return g
+}
+
+func (g *groups) find(n string) string {
+	for g.parent[n] != n {
+		g.parent[n] = g.parent[g.parent[n]]
+		n = g.parent[n]
+	}
+	return n
+}
+
+func (g *groups) union(a, b string) {
+	if _, ok := g.parent[a]; !ok {
+		return
+	}
+	if _, ok := g.parent[b]; !ok {
+		return
+	}
+	ra, rb := g.find(a), g.find(b)
+	if ra == rb {
+		return
+	}
+	for _, x := range g.members[ra] {
+		for _, y := range g.members[rb] {
+			if g.split[pair(x, y)] {
+				return
+			}
+		}
+	}
+	g.parent[rb] = ra
+	g.members[ra] = append(g.members[ra], g.members[rb]...)
+	delete(g.members, rb)
+}
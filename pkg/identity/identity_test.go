@@ -0,0 +1,229 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func seenAt(alias, kind, value string, match int) Observation {
+	return Observation{
+		Alias: alias,
+		Kind:  kind,
+		Value: value,
+		Match: fmt.Sprint(match),
+		At:    time.Unix(int64(1000+match), 0).UTC(),
+	}
+}
+
+func lookup(t *testing.T, s *Store, name string) *Player {
+	t.Helper()
+	dir, err := s.Directory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := dir.Lookup(name)
+	if !ok {
+		t.Fatalf("expected %s to be known", name)
+	}
+	return p
+}
+
+func TestEvidence(t *testing.T) {
+	s := NewStore(storage.NewFS(t.TempDir()))
+
+	// alice renames to alice2 on the same address; the address is seen in
+	// the same match twice, which counts once.
+	var obs []Observation
+	for m := 1; m <= 3; m++ {
+		obs = append(obs, seenAt("alice", KindIP, "C0A80001", m), seenAt("alice", KindIP, "C0A80001", m))
+	}
+	obs = append(obs, seenAt("alice2", KindIP, "C0A80001", 4), seenAt("alice2", KindIP, "C0A80001", 5))
+	if err := s.Observe(obs...); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "alice2"); len(p.Aliases) != 1 {
+		t.Errorf("expected two shared matches to be below the threshold, got %+v", p)
+	}
+
+	if err := s.Observe(seenAt("alice2", KindIP, "C0A80001", 6)); err != nil {
+		t.Fatal(err)
+	}
+	p := lookup(t, s, "alice2")
+	if p.ID != "p1" || p.Name != "alice2" || len(p.Aliases) != 2 || p.Aliases[0].Name != "alice" {
+		t.Errorf("expected alice2 to join alice's player, got %+v", p)
+	}
+	if got := lookup(t, s, "p1"); got.ID != p.ID {
+		t.Errorf("expected lookup by ID, got %+v", got)
+	}
+
+	// A stats DisplayName differing from the header name links at once.
+	if err := s.Observe(seenAt("bob", KindDisplay, "7/1", 7), seenAt("[CLAN]bob", KindDisplay, "7/1", 7)); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "[CLAN]bob"); len(p.Aliases) != 2 {
+		t.Errorf("expected the display name to be linked, got %+v", p)
+	}
+
+	// A client uploading logs as two names in 2 matches each links them,
+	// unless it uploaded as both for one match.
+	obs = []Observation{
+		seenAt("carl", KindClient, "zulu", 10), seenAt("carl", KindClient, "zulu", 11),
+		seenAt("carl2", KindClient, "zulu", 12), seenAt("carl2", KindClient, "zulu", 13),
+		seenAt("ref1", KindClient, "league", 14), seenAt("ref2", KindClient, "league", 14),
+		seenAt("ref1", KindClient, "league", 15), seenAt("ref2", KindClient, "league", 15),
+	}
+	if err := s.Observe(obs...); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "carl2"); len(p.Aliases) != 2 {
+		t.Errorf("expected the uploading client to link carl2, got %+v", p)
+	}
+	if p := lookup(t, s, "ref2"); len(p.Aliases) != 1 {
+		t.Errorf("expected names uploaded for one match not to link, got %+v", p)
+	}
+
+	// Names on one address in the same match are different players.
+	obs = nil
+	for m := 20; m < 23; m++ {
+		obs = append(obs, seenAt("host", KindIP, "0A000002", m), seenAt("guest", KindIP, "0A000002", m))
+	}
+	if err := s.Observe(obs...); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "guest"); len(p.Aliases) != 1 {
+		t.Errorf("expected names seen in one match not to link, got %+v", p)
+	}
+
+	// An address shared by more than MaxShared names links none of them,
+	// undoing the links it made before.
+	obs = nil
+	for i := 0; i <= MaxShared; i++ {
+		for m := 0; m < 3; m++ {
+			obs = append(obs, seenAt(fmt.Sprintf("lan%d", i), KindIP, "0A000001", 30+3*i+m))
+		}
+	}
+	if err := s.Observe(obs...); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "lan0"); len(p.Aliases) != 1 {
+		t.Errorf("expected a shared address not to link, got %+v", p)
+	}
+	events, err := s.Audit("lan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(events); n == 0 || events[n-1].Action != "unlink" || len(events[n-1].Aliases) != MaxShared {
+		t.Errorf("expected the shared address to end in an unlink event, got %+v", events)
+	}
+
+	events, err = s.Audit("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != "link" || events[0].Actor != "auto" {
+		t.Errorf("expected one automatic link in alice's audit trail, got %+v", events)
+	}
+	if events[0].Reason != "ip evidence in 3 matches each" {
+		t.Errorf("expected the reason not to name the address, got %q", events[0].Reason)
+	}
+}
+
+func TestMergeSplit(t *testing.T) {
+	s := NewStore(storage.NewFS(t.TempDir()))
+	var obs []Observation
+	for m := 1; m <= 3; m++ {
+		obs = append(obs, seenAt("carol", KindIP, "C0A80002", m), seenAt("dave", KindIP, "C0A80002", m+3))
+	}
+	obs = append(obs, seenAt("erin", "", "", 7))
+	if err := s.Observe(obs...); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "dave"); len(p.Aliases) != 2 {
+		t.Fatalf("expected carol and dave to be linked by their address, got %+v", p)
+	}
+
+	if err := s.Split("dave", "admin", "siblings sharing a PC"); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "dave"); len(p.Aliases) != 1 || p.ID != "p2" {
+		t.Errorf("expected dave to be split off, got %+v", p)
+	}
+	// Further evidence doesn't undo the split.
+	if err := s.Observe(seenAt("carol", KindIP, "C0A80002", 8), seenAt("dave", KindIP, "C0A80002", 9)); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "carol"); len(p.Aliases) != 1 {
+		t.Errorf("expected the split to hold, got %+v", p)
+	}
+	if err := s.Split("erin", "admin", ""); err == nil {
+		t.Error("expected splitting an unlinked alias to fail")
+	}
+
+	if err := s.Merge("erin", "p1", "admin", "same player, new install"); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "erin"); p.ID != "p1" || len(p.Aliases) != 2 || p.Name != "carol" {
+		t.Errorf("expected erin merged into carol's player, got %+v", p)
+	}
+	if err := s.Merge("erin", "carol", "admin", ""); err == nil {
+		t.Error("expected merging one player with itself to fail")
+	}
+	if err := s.Merge("nobody", "carol", "admin", ""); !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected ErrUnknown, got %v", err)
+	}
+
+	// Merging undoes the split.
+	if err := s.Merge("dave", "carol", "admin", "confirmed after all"); err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup(t, s, "dave"); p.ID != "p1" || len(p.Aliases) != 3 {
+		t.Errorf("expected all three aliases as one player, got %+v", p)
+	}
+
+	events, err := s.Audit("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	if fmt.Sprint(actions) != "[link split merge merge]" {
+		t.Errorf("unexpected audit trail: %v", actions)
+	}
+}
+
+func TestObserveRepeat(t *testing.T) {
+	s := NewStore(storage.NewFS(t.TempDir()))
+	obs := []Observation{seenAt("frank", "", "", 1), seenAt("frank", KindIP, "C0A80003", 1)}
+	if err := s.Observe(obs...); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := s.Directory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same match again, as a duplicate upload or reprocessing sends it.
+	if err := s.Observe(obs...); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Directory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Revision != dir.Revision {
+		t.Errorf("expected repeated sightings not to write the state, revision %d became %d", dir.Revision, again.Revision)
+	}
+
+	if err := s.Observe(seenAt("frank", KindIP, "C0A80003", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ = s.Directory(); again.Revision == dir.Revision {
+		t.Error("expected a new sighting to write the state")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ObjectType classifies game objects by their category.
@@ -23,12 +24,18 @@ const (
 type ObjectStore struct {
 	Object []Object
 	byName map[string]*Object
+	// specialPowers maps an object name to the SpecialPowerTemplate values
+	// of its modules: the powers owning that object grants.
+	specialPowers map[string][]string
 }
 
 type Object struct {
 	Name string
 	Cost int
 	Type ObjectType
+	// DisplayName is the string-table label for the player-facing name
+	// (e.g. "OBJECT:BattleMaster"); resolve it with a gametext.Table.
+	DisplayName string
 }
 
 type UpgradeStore struct {
@@ -41,16 +48,34 @@ type UpgradeStore struct {
 }
 
 type Upgrade struct {
-	Name string
-	Cost int
+	Name        string
+	Cost        int
+	DisplayName string // string-table label, e.g. "UPGRADE:Nationalism"
 }
 
 type PowerStore struct {
 	Power []Power
+	// grantedBy maps a power name to the objects whose modules reference
+	// it through SpecialPowerTemplate; filled in by LinkObjects.
+	grantedBy map[string][]string
 }
 
 type Power struct {
 	Name string
+	// DisplayName is the TextLabel of the first CommandButton that fires
+	// this power. SpecialPower.ini has no name of its own; the button is
+	// what the player sees.
+	DisplayName string
+	// ReloadTime is the cooldown between firings.
+	ReloadTime time.Duration
+	// RequiredScience is the science that unlocks the power, set for
+	// general's powers bought with promotion points.
+	RequiredScience string
+	// SharedSyncedTimer means every source of the power shares one
+	// countdown; otherwise each granting object (e.g. each Particle
+	// Cannon) has its own.
+	SharedSyncedTimer  bool
+	RadiusCursorRadius float64
 }
 
 type ColorStore struct {
@@ -140,6 +165,13 @@ var IniKey = []string{
 	"  RGBColor",
 	"  RGBNightColor",
 	"  TooltipName",
+	"  DisplayName",
+	"CommandButton",
+	"  TextLabel",
+	"  ReloadTime",
+	"  RequiredScience",
+	"  SharedSyncedTimer",
+	"  RadiusCursorRadius",
 }
 
 func NewObjectStore(dir string) (*ObjectStore, error) {
@@ -198,6 +230,24 @@ func (o *ObjectStore) GetObjectByName(name string) *Object {
 	return o.byName[name]
 }
 
+// SpecialPowers returns the powers the named object grants, in INI order.
+func (o *ObjectStore) SpecialPowers(name string) []string {
+	if o == nil {
+		return nil
+	}
+	return o.specialPowers[name]
+}
+
+func (o *ObjectStore) addSpecialPower(object, power string) {
+	if o.specialPowers == nil {
+		o.specialPowers = map[string][]string{}
+	}
+	if containsString(o.specialPowers[object], power) {
+		return
+	}
+	o.specialPowers[object] = append(o.specialPowers[object], power)
+}
+
 func NewPowerStore(dir string) (*PowerStore, error) {
 	if dir == "" {
 		return nil, fmt.Errorf("directory path cannot be empty")
@@ -220,13 +270,112 @@ func (p *PowerStore) GetPower(i int) (*Power, error) {
 	return &p.Power[index], nil
 }
 
+// GetPowerByName returns the Power with the given name, or nil if not found.
+func (p *PowerStore) GetPowerByName(name string) *Power {
+	if p == nil {
+		return nil
+	}
+	for i := range p.Power {
+		if p.Power[i].Name == name {
+			return &p.Power[i]
+		}
+	}
+	return nil
+}
+
+// LinkObjects records which objects grant each power, from the objects'
+// SpecialPowerTemplate fields. Call it once both stores are loaded.
+func (p *PowerStore) LinkObjects(objects *ObjectStore) {
+	p.grantedBy = map[string][]string{}
+	if objects == nil {
+		return
+	}
+	for _, obj := range objects.Object {
+		for _, power := range objects.SpecialPowers(obj.Name) {
+			if !containsString(p.grantedBy[power], obj.Name) {
+				p.grantedBy[power] = append(p.grantedBy[power], obj.Name)
+			}
+		}
+	}
+}
+
+// GrantedBy returns the objects that grant the named power, in INI order.
+// Empty until LinkObjects has been called.
+func (p *PowerStore) GrantedBy(name string) []string {
+	if p == nil {
+		return nil
+	}
+	return p.grantedBy[name]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *PowerStore) loadPowers(dir string) error {
 	file, err := os.Open(dir + "/SpecialPower.ini")
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	return p.parseFile(file)
+	if err := p.parseFile(file); err != nil {
+		return err
+	}
+
+	// Display labels come from the command buttons; a data set without
+	// CommandButton.ini just has unnamed powers.
+	buttons, err := os.Open(dir + "/CommandButton.ini")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer buttons.Close()
+	return p.parseCommandButtons(buttons)
+}
+
+// parseCommandButtons fills in each power's DisplayName from the TextLabel
+// of the first CommandButton whose SpecialPower field names it.
+func (p *PowerStore) parseCommandButtons(file io.Reader) error {
+	byName := make(map[string]*Power, len(p.Power))
+	for i := range p.Power {
+		byName[p.Power[i].Name] = &p.Power[i]
+	}
+
+	var power, label string
+	commit := func() {
+		if pw, ok := byName[power]; ok && pw.DisplayName == "" && label != "" {
+			pw.DisplayName = label
+		}
+		power, label = "", ""
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch matchKey(line) {
+		case "CommandButton":
+			commit()
+		case "TextLabel":
+			label = parseStringFromLine(line)
+		case "End":
+			commit()
+		default:
+			// "  SpecialPower" can't go in IniKey: it would collide with the
+			// top-level SpecialPower block key.
+			if value, ok := fieldValue(line, "SpecialPower"); ok {
+				power = value
+			}
+		}
+	}
+	commit()
+	return scanner.Err()
 }
 
 func (p *PowerStore) parseFile(file io.Reader) error {
@@ -246,6 +395,32 @@ func (p *PowerStore) parseFile(file io.Reader) error {
 			power = &Power{
 				Name: name,
 			}
+		case "ReloadTime":
+			if power == nil {
+				break
+			}
+			ms, err := parseCostFromLine(line)
+			if err != nil {
+				return fmt.Errorf("%s: ReloadTime: %w", power.Name, err)
+			}
+			power.ReloadTime = time.Duration(ms) * time.Millisecond
+		case "RequiredScience":
+			if power != nil {
+				power.RequiredScience = parseStringFromLine(line)
+			}
+		case "SharedSyncedTimer":
+			if power != nil {
+				power.SharedSyncedTimer = strings.EqualFold(parseStringFromLine(line), "Yes")
+			}
+		case "RadiusCursorRadius":
+			if power == nil {
+				break
+			}
+			radius, err := strconv.ParseFloat(parseStringFromLine(line), 64)
+			if err != nil {
+				return fmt.Errorf("%s: RadiusCursorRadius: %w", power.Name, err)
+			}
+			power.RadiusCursorRadius = radius
 		case "End":
 		default:
 		}
@@ -333,6 +508,10 @@ func (u *UpgradeStore) parseFile(file io.Reader) error {
 				return err
 			}
 			upgrade.Cost = cost
+		case "DisplayName":
+			if upgrade != nil {
+				upgrade.DisplayName = parseStringFromLine(line)
+			}
 		case "End":
 		default:
 		}
@@ -367,6 +546,28 @@ func parseNameFromLine(line string) (string, error) {
 	return fields[1], nil
 }
 
+// fieldValue returns the value of a "Name = value" line for a field at any
+// indent, for module fields whose nesting depth varies between objects.
+func fieldValue(line, name string) (string, bool) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok || strings.TrimSpace(key) != name {
+		return "", false
+	}
+	return parseStringFromLine("=" + value), true
+}
+
+// parseStringFromLine extracts a single string value from a line like
+// "  DisplayName = OBJECT:BattleMaster ; comment".
+func parseStringFromLine(line string) string {
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	value := strings.SplitN(parts[1], ";", 2)[0]
+	value = strings.ReplaceAll(value, "\r", "")
+	return strings.TrimSpace(value)
+}
+
 // parseKindOfFromLine extracts KindOf flags from a line like "  KindOf = INFANTRY SELECTABLE"
 func parseKindOfFromLine(line string) []string {
 	parts := strings.SplitN(line, "=", 2)
@@ -448,8 +649,18 @@ func (o *ObjectStore) parseFile(file io.Reader) error {
 			}
 			flags := parseKindOfFromLine(line)
 			object.Type = classifyObject(flags)
+		case "DisplayName":
+			if object != nil {
+				object.DisplayName = parseStringFromLine(line)
+			}
 		case "End":
 		default:
+			if object == nil {
+				break
+			}
+			if value, ok := fieldValue(line, "SpecialPowerTemplate"); ok && value != "" {
+				o.addSpecialPower(object.Name, value)
+			}
 		}
 	}
 	if object != nil {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMatchKey(t *testing.T) {
@@ -927,3 +928,119 @@ func TestParseFileWithKindOf(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDisplayName(t *testing.T) {
+	objects := &ObjectStore{}
+	objInput := "Object ChinaTankBattleMaster\n  DisplayName = OBJECT:BattleMaster ; tank\r\n  BuildCost = 800\nEnd\n"
+	if err := objects.parseFile(strings.NewReader(objInput)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := objects.Object[0].DisplayName; got != "OBJECT:BattleMaster" {
+		t.Errorf("object DisplayName = %q", got)
+	}
+
+	upgrades := &UpgradeStore{}
+	upInput := "Upgrade Upgrade_Nationalism\n  DisplayName = UPGRADE:Nationalism\n  BuildCost = 2000\nEnd\n"
+	if err := upgrades.parseFile(strings.NewReader(upInput)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := upgrades.Upgrade[0].DisplayName; got != "UPGRADE:Nationalism" {
+		t.Errorf("upgrade DisplayName = %q", got)
+	}
+}
+
+func TestPowerStoreCommandButtons(t *testing.T) {
+	powers := &PowerStore{}
+	if err := powers.parseFile(strings.NewReader("SpecialPower SuperweaponNeutronMissile\nEnd\nSpecialPower SpecialPowerNapalmStrike\nEnd\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buttons := `CommandButton Command_NeutronMissile
+  Command       = SPECIAL_POWER
+  SpecialPower  = SuperweaponNeutronMissile
+  TextLabel     = CONTROLBAR:NeutronMissile
+End
+
+CommandButton Command_NeutronMissileFromShortcut
+  SpecialPower  = SuperweaponNeutronMissile
+  TextLabel     = CONTROLBAR:NeutronMissileShortcut
+End
+`
+	if err := powers.parseCommandButtons(strings.NewReader(buttons)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := powers.Power[0].DisplayName; got != "CONTROLBAR:NeutronMissile" {
+		t.Errorf("expected first button's label, got %q", got)
+	}
+	if got := powers.Power[1].DisplayName; got != "" {
+		t.Errorf("expected no label for power without a button, got %q", got)
+	}
+}
+
+func TestPowerStoreParseTimers(t *testing.T) {
+	input := `SpecialPower SuperweaponDaisyCutter
+  Enum                = SPECIAL_DAISY_CUTTER
+  ReloadTime          = 360000   ; in milliseconds
+  RequiredScience     = SCIENCE_DaisyCutter
+  SharedSyncedTimer   = Yes
+  RadiusCursorRadius  = 170 ; shared by MOAB
+End
+SpecialPower SuperweaponDetonateDirtyNuke
+  ReloadTime          = 30000; 
+End
+`
+	powers := &PowerStore{}
+	if err := powers.parseFile(strings.NewReader(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	daisy := powers.GetPowerByName("SuperweaponDaisyCutter")
+	if daisy == nil {
+		t.Fatal("expected SuperweaponDaisyCutter")
+	}
+	want := Power{
+		Name:               "SuperweaponDaisyCutter",
+		ReloadTime:         6 * time.Minute,
+		RequiredScience:    "SCIENCE_DaisyCutter",
+		SharedSyncedTimer:  true,
+		RadiusCursorRadius: 170,
+	}
+	if *daisy != want {
+		t.Errorf("expected %+v, got %+v", want, *daisy)
+	}
+	if got := powers.Power[1].ReloadTime; got != 30*time.Second {
+		t.Errorf("expected 30s reload, got %v", got)
+	}
+}
+
+func TestPowerStoreLinkObjects(t *testing.T) {
+	input := `Object AmericaParticleCannonUplink
+  Behavior = SpecialPowerModule ModuleTag_07
+    SpecialPowerTemplate = SuperweaponParticleUplinkCannon
+  End
+  Behavior = ParticleUplinkCannonUpdate ModuleTag_08
+    SpecialPowerTemplate            = SuperweaponParticleUplinkCannon
+  End
+End
+Object Lazr_AmericaParticleCannonUplink
+	Behavior = SpecialPowerModule ModuleTag_07
+		SpecialPowerTemplate = SuperweaponParticleUplinkCannon
+	End
+End
+`
+	objects := &ObjectStore{}
+	if err := objects.parseFile(strings.NewReader(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := objects.SpecialPowers("AmericaParticleCannonUplink"); len(got) != 1 || got[0] != "SuperweaponParticleUplinkCannon" {
+		t.Errorf("expected one deduplicated power, got %v", got)
+	}
+
+	powers := &PowerStore{Power: []Power{{Name: "SuperweaponParticleUplinkCannon"}}}
+	if got := powers.GrantedBy("SuperweaponParticleUplinkCannon"); got != nil {
+		t.Errorf("expected no grants before LinkObjects, got %v", got)
+	}
+	powers.LinkObjects(objects)
+	got := powers.GrantedBy("SuperweaponParticleUplinkCannon")
+	if len(got) != 2 || got[0] != "AmericaParticleCannonUplink" || got[1] != "Lazr_AmericaParticleCannonUplink" {
+		t.Errorf("unexpected grantors: %v", got)
+	}
+}
@@ -0,0 +1,387 @@
+package iniparse
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Lint severities. Errors are data the parser will silently get wrong
+// (blank or shifted names in replay output); warnings are suspicious but
+// may be intentional.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Lint check names, as reported in Finding.Check.
+const (
+	CheckDuplicateObject  = "duplicate-object"
+	CheckDuplicateUpgrade = "duplicate-upgrade"
+	CheckDuplicatePower   = "duplicate-power"
+	CheckMissingCost      = "missing-cost"
+	CheckUnknownKindOf    = "unknown-kindof"
+	CheckUndefinedObject  = "undefined-object"
+	CheckUndefinedUpgrade = "undefined-upgrade"
+	CheckUndefinedPower   = "undefined-power"
+	CheckMissingColor     = "missing-zulu-color"
+)
+
+// ZuluColors lists the TooltipNames the Zulu client adds after the eight
+// retail colors. A replay using one of them resolves to a blank color if
+// ZuluColors.ini doesn't define it.
+var ZuluColors = []string{
+	"Color:Maroon",
+	"Color:Lime",
+	"Color:Brown",
+	"Color:MetallicGrey",
+	"Color:Violet",
+}
+
+// builtinUpgrades are created by the engine's UpgradeCenter rather than
+// Upgrade.ini, so references to them are always valid. "None" is the
+// engine's explicit empty reference.
+var builtinUpgrades = map[string]bool{
+	"None":                      true,
+	"Upgrade_Veterancy_VETERAN": true,
+	"Upgrade_Veterancy_ELITE":   true,
+	"Upgrade_Veterancy_HEROIC":  true,
+}
+
+// Finding is one problem reported by Lint.
+type Finding struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	// File is relative to the linted directory; empty for findings about
+	// the data set as a whole.
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	loc := f.File
+	if loc == "" {
+		loc = "."
+	}
+	if f.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, f.Line)
+	}
+	return fmt.Sprintf("%s: %s [%s] %s", loc, f.Severity, f.Check, f.Message)
+}
+
+// iniPos is where a definition or reference was found.
+type iniPos struct {
+	file string
+	line int
+}
+
+func (p iniPos) String() string {
+	return fmt.Sprintf("%s:%d", p.file, p.line)
+}
+
+// iniRef is a by-name reference to another definition.
+type iniRef struct {
+	iniPos
+	from string // the object or button holding the reference
+	name string
+}
+
+type lintObject struct {
+	iniPos
+	name    string
+	hasCost bool
+	kindOf  []string
+}
+
+type linter struct {
+	dir      string
+	findings []Finding
+
+	objects      []lintObject
+	objectByName map[string]int // first definition
+	upgrades     map[string]iniPos
+	powers       map[string]iniPos
+
+	upgradeRefs []iniRef
+	powerRefs   []iniRef
+	objectRefs  []iniRef
+	buildable   map[string]bool
+}
+
+// Lint loads the Data/INI tree in dir the same way the stores do and
+// reports problems that would otherwise only show up as blank or wrong names
+// in replay output: duplicate definitions, buildable objects with no cost
+// or with KindOf flags classifyObject can't map, references to undefined
+// objects, upgrades and special powers, and missing Zulu colors.
+//
+// Objects count as buildable when a UNIT_BUILD or DOZER_CONSTRUCT command
+// button builds them; without CommandButton.ini those checks are skipped.
+// An error is returned only when the tree can't be read at all.
+func Lint(dir string) ([]Finding, error) {
+	l := &linter{
+		dir:          dir,
+		objectByName: map[string]int{},
+		upgrades:     map[string]iniPos{},
+		powers:       map[string]iniPos{},
+		buildable:    map[string]bool{},
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "Object"))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if err := l.scanFile(filepath.Join("Object", entry.Name()), l.objectLine); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.scanFile("Upgrade.ini", l.upgradeLine); err != nil {
+		return nil, err
+	}
+	if err := l.scanFile("SpecialPower.ini", l.powerLine); err != nil {
+		return nil, err
+	}
+	if err := l.scanButtons(); err != nil {
+		return nil, err
+	}
+
+	l.checkObjects()
+	l.checkRefs()
+	if err := l.checkColors(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return l.findings, nil
+}
+
+func (l *linter) add(severity, check string, pos iniPos, name, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{
+		Severity: severity,
+		Check:    check,
+		File:     filepath.ToSlash(pos.file),
+		Line:     pos.line,
+		Name:     name,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// scanFile calls fn for every line of rel (relative to the linted dir).
+func (l *linter) scanFile(rel string, fn func(pos iniPos, line string)) error {
+	file, err := os.Open(filepath.Join(l.dir, rel))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	pos := iniPos{file: rel}
+	for scanner.Scan() {
+		pos.line++
+		fn(pos, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func (l *linter) objectLine(pos iniPos, line string) {
+	switch matchKey(line) {
+	case "Object":
+		name, err := parseNameFromLine(line)
+		if err != nil {
+			return
+		}
+		if first, ok := l.objectByName[name]; ok {
+			l.add(SeverityError, CheckDuplicateObject, pos, name,
+				"object %s redefines the one at %s; name lookups now return this definition", name, l.objects[first].iniPos)
+		} else {
+			l.objectByName[name] = len(l.objects)
+		}
+		l.objects = append(l.objects, lintObject{iniPos: pos, name: name})
+		return
+	}
+	if len(l.objects) == 0 {
+		return
+	}
+	obj := &l.objects[len(l.objects)-1]
+	switch matchKey(line) {
+	case "BuildCost":
+		obj.hasCost = true
+	case "KindOf":
+		obj.kindOf = parseKindOfFromLine(line)
+	default:
+		if value, ok := fieldValue(line, "SpecialPowerTemplate"); ok && value != "" {
+			l.powerRefs = append(l.powerRefs, iniRef{iniPos: pos, from: obj.name, name: value})
+		} else if value, ok := fieldValue(line, "TriggeredBy"); ok {
+			for _, name := range strings.Fields(value) {
+				l.upgradeRefs = append(l.upgradeRefs, iniRef{iniPos: pos, from: obj.name, name: name})
+			}
+		}
+	}
+}
+
+func (l *linter) upgradeLine(pos iniPos, line string) {
+	if matchKey(line) != "Upgrade" {
+		return
+	}
+	name, err := parseNameFromLine(line)
+	if err != nil {
+		return
+	}
+	if first, ok := l.upgrades[name]; ok {
+		l.add(SeverityError, CheckDuplicateUpgrade, pos, name,
+			"upgrade %s is already defined at %s; UpgradeStore indexes it twice, shifting every later upgrade ID by one", name, first)
+		return
+	}
+	l.upgrades[name] = pos
+}
+
+func (l *linter) powerLine(pos iniPos, line string) {
+	if matchKey(line) != "SpecialPower" {
+		return
+	}
+	name, err := parseNameFromLine(line)
+	if err != nil {
+		return
+	}
+	if first, ok := l.powers[name]; ok {
+		l.add(SeverityError, CheckDuplicatePower, pos, name,
+			"special power %s is already defined at %s; PowerStore indexes it twice, shifting every later power ID by one", name, first)
+		return
+	}
+	l.powers[name] = pos
+}
+
+// scanButtons collects the references made by CommandButton.ini, which is
+// optional like it is for PowerStore.
+func (l *linter) scanButtons() error {
+	if _, err := os.Stat(filepath.Join(l.dir, "CommandButton.ini")); os.IsNotExist(err) {
+		l.buildable = nil
+		return nil
+	}
+	var button, command string
+	var objectRef *iniRef
+	commit := func() {
+		if objectRef != nil {
+			l.objectRefs = append(l.objectRefs, *objectRef)
+			if command == "UNIT_BUILD" || command == "DOZER_CONSTRUCT" {
+				l.buildable[objectRef.name] = true
+			}
+		}
+		button, command, objectRef = "", "", nil
+	}
+	err := l.scanFile("CommandButton.ini", func(pos iniPos, line string) {
+		switch matchKey(line) {
+		case "CommandButton":
+			commit()
+			button, _ = parseNameFromLine(line)
+			return
+		case "End":
+			commit()
+			return
+		}
+		if button == "" {
+			return
+		}
+		if value, ok := fieldValue(line, "Command"); ok {
+			command = value
+		} else if value, ok := fieldValue(line, "Object"); ok && value != "" {
+			objectRef = &iniRef{iniPos: pos, from: button, name: value}
+		} else if value, ok := fieldValue(line, "Upgrade"); ok && value != "" {
+			l.upgradeRefs = append(l.upgradeRefs, iniRef{iniPos: pos, from: button, name: value})
+		} else if value, ok := fieldValue(line, "SpecialPower"); ok && value != "" {
+			l.powerRefs = append(l.powerRefs, iniRef{iniPos: pos, from: button, name: value})
+		}
+	})
+	commit()
+	return err
+}
+
+func (l *linter) checkObjects() {
+	if l.buildable == nil {
+		return
+	}
+	for i, obj := range l.objects {
+		// Only the definition the store's name lookup returns matters.
+		if !l.buildable[obj.name] || l.lastDefinition(obj.name) != i {
+			continue
+		}
+		if !obj.hasCost {
+			l.add(SeverityWarning, CheckMissingCost, obj.iniPos, obj.name,
+				"buildable object %s has no BuildCost; replays will report it as free", obj.name)
+		}
+		if classifyObject(obj.kindOf) == ObjectTypeUnknown {
+			flags := strings.Join(obj.kindOf, " ")
+			if flags == "" {
+				flags = "none"
+			}
+			l.add(SeverityWarning, CheckUnknownKindOf, obj.iniPos, obj.name,
+				"buildable object %s has no KindOf that maps to an object type (KindOf: %s)", obj.name, flags)
+		}
+	}
+}
+
+func (l *linter) lastDefinition(name string) int {
+	last := -1
+	for i, obj := range l.objects {
+		if obj.name == name {
+			last = i
+		}
+	}
+	return last
+}
+
+func (l *linter) checkRefs() {
+	for _, ref := range l.objectRefs {
+		if _, ok := l.objectByName[ref.name]; !ok && ref.name != "None" {
+			l.add(SeverityError, CheckUndefinedObject, ref.iniPos, ref.name,
+				"%s references undefined object %s", ref.from, ref.name)
+		}
+	}
+	for _, ref := range l.upgradeRefs {
+		if _, ok := l.upgrades[ref.name]; !ok && !builtinUpgrades[ref.name] {
+			l.add(SeverityError, CheckUndefinedUpgrade, ref.iniPos, ref.name,
+				"%s references undefined upgrade %s", ref.from, ref.name)
+		}
+	}
+	for _, ref := range l.powerRefs {
+		if _, ok := l.powers[ref.name]; !ok && ref.name != "None" {
+			l.add(SeverityError, CheckUndefinedPower, ref.iniPos, ref.name,
+				"%s references undefined special power %s", ref.from, ref.name)
+		}
+	}
+}
+
+// checkColors loads the colors exactly as ColorStore does, so Zulu override
+// blocks that re-tint a retail color are accounted for.
+func (l *linter) checkColors() error {
+	colors, err := NewColorStore(l.dir)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for _, c := range colors.Color {
+		have[c.TooltipName] = true
+	}
+	pos := iniPos{file: "ZuluColors.ini"}
+	if _, err := os.Stat(filepath.Join(l.dir, "ZuluColors.ini")); os.IsNotExist(err) {
+		pos.file = ""
+	}
+	for _, name := range ZuluColors {
+		if !have[name] {
+			l.add(SeverityWarning, CheckMissingColor, pos, name,
+				"no MultiplayerColor with TooltipName %s; Zulu replays using it will show a blank color", name)
+		}
+	}
+	return nil
+}
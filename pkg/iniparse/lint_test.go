@@ -0,0 +1,112 @@
+package iniparse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Object/a.ini": `Object Tank
+  BuildCost = 800
+  KindOf = SELECTABLE VEHICLE
+  Behavior = ArmorUpgrade ModuleTag_01
+    TriggeredBy = Upgrade_Armor Upgrade_Missing
+  End
+End
+Object FreeThing
+  KindOf = SELECTABLE
+End
+`,
+		"Object/b.ini": `Object Tank
+  BuildCost = 900
+  KindOf = VEHICLE
+  Behavior = SpecialPowerModule ModuleTag_02
+    SpecialPowerTemplate = PowerMissing
+  End
+End
+`,
+		"Upgrade.ini":      "Upgrade Upgrade_Armor\nEnd\nUpgrade Upgrade_Armor\nEnd\n",
+		"SpecialPower.ini": "SpecialPower PowerA\nEnd\n",
+		"CommandButton.ini": `CommandButton Command_BuildTank
+  Command       = UNIT_BUILD
+  Object        = Tank
+End
+CommandButton Command_BuildFree
+  Command       = DOZER_CONSTRUCT
+  Object        = FreeThing
+End
+CommandButton Command_BuildGhost
+  Command       = UNIT_BUILD
+  Object        = Ghost
+End
+CommandButton Command_Fire
+  Command       = SPECIAL_POWER
+  SpecialPower  = PowerA
+  Upgrade       = None
+End
+`,
+		"multiplayer.ini": "MultiplayerColor Gold\n  RGBColor = R:221 G:226 B:13\n  TooltipName = Color:Gold\nEnd\n",
+		"ZuluColors.ini":  "MultiplayerColor ColorMaroon\n  TooltipName = Color:Maroon\nEnd\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	findings, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := map[string][]string{}
+	for _, f := range findings {
+		got[f.Check] = append(got[f.Check], f.Name)
+	}
+	want := map[string][]string{
+		CheckDuplicateObject:  {"Tank"},
+		CheckDuplicateUpgrade: {"Upgrade_Armor"},
+		CheckMissingCost:      {"FreeThing"},
+		CheckUnknownKindOf:    {"FreeThing"},
+		CheckUndefinedObject:  {"Ghost"},
+		CheckUndefinedUpgrade: {"Upgrade_Missing"},
+		CheckUndefinedPower:   {"PowerMissing"},
+		CheckMissingColor:     {"Color:Lime", "Color:Brown", "Color:MetallicGrey", "Color:Violet"},
+	}
+	for check, names := range want {
+		if len(got[check]) != len(names) {
+			t.Errorf("%s: expected %v, got %v", check, names, got[check])
+			continue
+		}
+		for i := range names {
+			if got[check][i] != names[i] {
+				t.Errorf("%s: expected %v, got %v", check, names, got[check])
+				break
+			}
+		}
+	}
+	for check := range got {
+		if _, ok := want[check]; !ok {
+			t.Errorf("unexpected %s findings: %v", check, got[check])
+		}
+	}
+
+	for _, f := range findings {
+		if f.Check == CheckDuplicateObject && (f.File != "Object/b.ini" || f.Line != 1) {
+			t.Errorf("duplicate reported at %s:%d, expected Object/b.ini:1", f.File, f.Line)
+		}
+	}
+}
+
+func TestLintMissingObjectDir(t *testing.T) {
+	if _, err := Lint(t.TempDir()); err == nil {
+		t.Error("expected error for a directory with no Object folder")
+	}
+}
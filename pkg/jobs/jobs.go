@@ -0,0 +1,224 @@
+// Package jobs runs work in the background on a fixed pool of workers,
+// fed from a bounded queue. When the queue is full, Submit refuses new
+// work instead of starting more goroutines, so a flood of uploads gets
+// backpressure.
+//
+// Jobs are kept in memory: a job's status is only known to the process
+// that took it. Finished jobs are forgotten after a retention period.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// States of a job.
+const (
+	StateQueued  = "queued"
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+var (
+	// ErrFull is returned by Submit when the queue has no room.
+	ErrFull = errors.New("jobs: queue is full")
+	// ErrClosed is returned by Submit after Close.
+	ErrClosed = errors.New("jobs: queue is closed")
+)
+
+// Func is the work of one job, given the job's ID. It returns where the
+// job's result can be fetched.
+type Func func(id string) (result string, err error)
+
+// Status is what is known about a job.
+type Status struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	// Position is how many jobs are ahead of a queued job, 0 once it runs.
+	Position  int       `json:"position,omitempty"`
+	Submitted time.Time `json:"submitted"`
+	Started   time.Time `json:"started,omitzero"`
+	Finished  time.Time `json:"finished,omitzero"`
+	// Result is where a done job's result can be fetched.
+	Result string `json:"result,omitempty"`
+	// Error is why a failed job failed.
+	Error string `json:"error,omitempty"`
+}
+
+type job struct {
+	Status
+	seq uint64
+	fn  Func
+}
+
+// Queue runs submitted jobs on a fixed number of workers.
+type Queue struct {
+	work chan *job
+	// slots holds a token for each running job and each call of Do, so
+	// both share the workers' limit.
+	slots     chan struct{}
+	retention time.Duration
+	expire    func(Status)
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	closed bool
+	// submitted and started count jobs, so a queued job's position is
+	// the difference of its sequence number and started.
+	submitted, started uint64
+}
+
+// NewQueue starts workers that run jobs from a queue holding up to depth
+// waiting jobs. Finished jobs are forgotten retention after they finish,
+// calling expire (if not nil) so their results can be deleted.
+func NewQueue(workers, depth int, retention time.Duration, expire func(Status)) *Queue {
+	q := &Queue{
+		work:      make(chan *job, depth),
+		slots:     make(chan struct{}, max(workers, 1)),
+		retention: retention,
+		expire:    expire,
+		jobs:      map[string]*job{},
+	}
+	for range max(workers, 1) {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for j := range q.work {
+		q.slots <- struct{}{}
+		q.mu.Lock()
+		j.State = StateRunning
+		j.Started = time.Now().UTC()
+		q.started++
+		q.mu.Unlock()
+
+		result, err := run(j)
+		<-q.slots
+
+		q.mu.Lock()
+		j.Finished = time.Now().UTC()
+		if err != nil {
+			j.State = StateFailed
+			j.Error = err.Error()
+		} else {
+			j.State = StateDone
+			j.Result = result
+		}
+		q.mu.Unlock()
+	}
+}
+
+// run runs a job's work, turning a panic into its error so one bad job
+// can't take a worker down.
+func run(j *job) (result string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return j.fn(j.ID)
+}
+
+// Submit queues fn and returns the new job's status, or ErrFull if the
+// queue has no room.
+func (q *Queue) Submit(fn Func) (Status, error) {
+	id, err := newID()
+	if err != nil {
+		return Status{}, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return Status{}, ErrClosed
+	}
+	q.prune()
+	j := &job{Status: Status{ID: id, State: StateQueued, Submitted: time.Now().UTC()}, seq: q.submitted, fn: fn}
+	select {
+	case q.work <- j:
+	default:
+		return Status{}, ErrFull
+	}
+	q.submitted++
+	q.jobs[id] = j
+	return q.status(j), nil
+}
+
+// Do runs fn on the calling goroutine once a worker's share of the limit
+// is free, for work that must finish before its caller returns but
+// shouldn't add to how much runs at once.
+func (q *Queue) Do(fn func()) {
+	q.slots <- struct{}{}
+	defer func() { <-q.slots }()
+	fn()
+}
+
+// Workers returns how many jobs and calls of Do run at once.
+func (q *Queue) Workers() int {
+	return cap(q.slots)
+}
+
+// Get returns a job's status, or false if the job is unknown or was
+// forgotten.
+func (q *Queue) Get(id string) (Status, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Status{}, false
+	}
+	return q.status(j), true
+}
+
+// Close stops taking jobs and waits for the queued ones to finish.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.work)
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) status(j *job) Status {
+	s := j.Status
+	if s.State == StateQueued {
+		s.Position = int(j.seq - q.started)
+	}
+	return s
+}
+
+// prune forgets jobs that finished more than the retention period ago.
+// The caller holds q.mu.
+func (q *Queue) prune() {
+	cutoff := time.Now().Add(-q.retention)
+	for id, j := range q.jobs {
+		if !j.Finished.IsZero() && j.Finished.Before(cutoff) {
+			delete(q.jobs, id)
+			if q.expire != nil {
+				go q.expire(j.Status)
+			}
+		}
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("jobs: job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// wait polls until the job reaches a finished state.
+func wait(t *testing.T, q *Queue, id string) Status {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s, ok := q.Get(id)
+		if !ok {
+			t.Fatalf("job %s is unknown", id)
+		}
+		if s.State == StateDone || s.State == StateFailed {
+			return s
+		}
+	}
+	t.Fatalf("job %s didn't finish", id)
+	return Status{}
+}
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(1, 2, time.Hour, nil)
+	defer q.Close()
+
+	// Hold the only worker so later jobs wait in the queue.
+	release := make(chan struct{})
+	busy, err := q.Submit(func(id string) (string, error) {
+		<-release
+		return "/results/" + id, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s, _ := q.Get(busy.ID); s.State != StateRunning; s, _ = q.Get(busy.ID) {
+		time.Sleep(time.Millisecond)
+	}
+
+	failing, err := q.Submit(func(string) (string, error) { return "", errors.New("bad replay") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicking, err := q.Submit(func(string) (string, error) { panic("oops") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failing.State != StateQueued || panicking.Position != 1 {
+		t.Errorf("unexpected queued jobs: %+v, %+v", failing, panicking)
+	}
+	if _, err := q.Submit(func(string) (string, error) { return "", nil }); !errors.Is(err, ErrFull) {
+		t.Errorf("expected ErrFull, got %v", err)
+	}
+
+	close(release)
+	if s := wait(t, q, busy.ID); s.State != StateDone || s.Result != "/results/"+busy.ID || s.Started.IsZero() {
+		t.Errorf("unexpected done job: %+v", s)
+	}
+	if s := wait(t, q, failing.ID); s.Error != "bad replay" {
+		t.Errorf("unexpected failed job: %+v", s)
+	}
+	if s := wait(t, q, panicking.ID); s.State != StateFailed || s.Error == "" {
+		t.Errorf("expected the panic to fail the job, got %+v", s)
+	}
+	if _, ok := q.Get("nope"); ok {
+		t.Error("expected an unknown job")
+	}
+}
+
+func TestDo(t *testing.T) {
+	q := NewQueue(1, 1, time.Hour, nil)
+	defer q.Close()
+	if n := q.Workers(); n != 1 {
+		t.Errorf("expected 1 worker, got %d", n)
+	}
+
+	var s Status
+	q.Do(func() {
+		var err error
+		if s, err = q.Submit(func(string) (string, error) { return "", nil }); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if got, _ := q.Get(s.ID); got.State != StateQueued {
+			t.Errorf("expected the job to wait for Do, got %+v", got)
+		}
+	})
+	if got := wait(t, q, s.ID); got.State != StateDone {
+		t.Errorf("unexpected job after Do: %+v", got)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	expired := make(chan Status, 1)
+	q := NewQueue(1, 1, 0, func(s Status) { expired <- s })
+	s, err := q.Submit(func(string) (string, error) { return "result", nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if _, ok := q.Get(s.ID); ok {
+		t.Error("expected the finished job to be forgotten")
+	}
+	select {
+	case e := <-expired:
+		if e.ID != s.ID || e.Result != "result" {
+			t.Errorf("unexpected expired job: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected expire to be called")
+	}
+	if _, err := q.Submit(func(string) (string, error) { return "", nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
@@ -1,37 +1,37 @@
-// Package logfile persists per-match client log files, keyed by the game
-// seed (the same identifier used by pkg/statsfile), grouped by the player
-// that produced them. It mirrors the on-disk pattern used by pkg/mapfile.
+// Package logfile persists per-match client log files, keyed by the match
+// identity (a matchid.ID string, the same key pkg/statsfile uses), grouped
+// by the player that produced them. It mirrors the on-disk pattern used by pkg/mapfile.
 //
-// Layout under LogsDir:
+// Layout in the repository's storage backend:
 //
-//	<seed>/
+//	<match>/
 //	  <player>/
 //	    <filename>      # one uploaded log file, original basename preserved
 //	    ...
 //	  <player>/
 //	    ...
 //
-// A match "exists" once its seed directory has been created by at least
-// one successful upload.
+// A match "exists" once at least one log file has been stored for it.
 package logfile
 
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"path"
 	"sort"
 	"strings"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
 )
 
-// LogsDir is the directory where log files are stored.
-// Set via LOGS_DIR env var or defaults to ./logs/
-var LogsDir = "./logs"
+// Repository stores match log files in a storage backend.
+type Repository struct {
+	backend storage.Backend
+}
 
-func init() {
-	if dir := os.Getenv("LOGS_DIR"); dir != "" {
-		LogsDir = dir
-	}
+// NewRepository returns a Repository that keeps logs in b.
+func NewRepository(b storage.Backend) *Repository {
+	return &Repository{backend: b}
 }
 
 // LogFile describes one stored log file for a match.
@@ -41,11 +41,6 @@ type LogFile struct {
 	Size   int64  `json:"size"`
 }
 
-// MatchDir returns the per-seed storage directory for a match.
-func MatchDir(seed string) string {
-	return filepath.Join(LogsDir, seed)
-}
-
 // sanitizeComponent reduces an untrusted path component (player id or
 // filename) to a safe single-segment name, guarding against path
 // traversal. Returns an error if nothing usable remains.
@@ -53,87 +48,76 @@ func sanitizeComponent(raw string) (string, error) {
 	// The game runs on Windows and may send backslash separators; treat
 	// both separators as directory boundaries before taking the basename.
 	cleaned := strings.ReplaceAll(raw, "\\", "/")
-	base := filepath.Base(cleaned)
+	base := path.Base(cleaned)
 	base = strings.TrimSpace(base)
-	if base == "" || base == "." || base == ".." {
+	if base == "" || base == "." || base == ".." || base == "/" {
 		return "", fmt.Errorf("logfile: invalid path component %q", raw)
 	}
 	return base, nil
 }
 
-// Store writes one uploaded log file under <seed>/<player>/<filename>.
-// player and filename are sanitized to their basenames; a repeated
-// (seed, player, filename) overwrites silently.
-func Store(seed, player, filename string, data []byte) error {
-	if seed == "" {
-		return errors.New("logfile.Store: empty seed")
-	}
+// key returns the storage key for one log file, sanitizing player and
+// filename.
+func key(match, player, filename string) (string, error) {
 	safePlayer, err := sanitizeComponent(player)
 	if err != nil {
-		return err
+		return "", err
 	}
 	safeName, err := sanitizeComponent(filename)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return path.Join(match, safePlayer, safeName), nil
+}
 
-	dir := filepath.Join(MatchDir(seed), safePlayer)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("create log dir: %w", err)
+// Store writes one uploaded log file under <match>/<player>/<filename>.
+// player and filename are sanitized to their basenames; a repeated
+// (match, player, filename) overwrites silently.
+func (r *Repository) Store(match, player, filename string, data []byte) error {
+	if match == "" {
+		return errors.New("logfile.Store: empty match")
 	}
-	if err := os.WriteFile(filepath.Join(dir, safeName), data, 0644); err != nil {
+	k, err := key(match, player, filename)
+	if err != nil {
+		return err
+	}
+	if err := storage.PutBytes(r.backend, k, data); err != nil {
 		return fmt.Errorf("write log file: %w", err)
 	}
 	return nil
 }
 
-// Exists reports whether any logs have been stored for the given seed.
-func Exists(seed string) bool {
-	if seed == "" {
+// Exists reports whether any logs have been stored for the given match.
+func (r *Repository) Exists(match string) bool {
+	if match == "" {
 		return false
 	}
-	info, err := os.Stat(MatchDir(seed))
-	return err == nil && info.IsDir()
+	files, err := r.backend.List(match + "/")
+	return err == nil && len(files) > 0
 }
 
 // List returns every stored log file for a match, sorted by player then
 // filename. Returns an empty slice if the match has no logs.
-func List(seed string) ([]LogFile, error) {
-	if seed == "" {
-		return nil, errors.New("logfile.List: empty seed")
+func (r *Repository) List(match string) ([]LogFile, error) {
+	if match == "" {
+		return nil, errors.New("logfile.List: empty match")
 	}
-	matchDir := MatchDir(seed)
-	players, err := os.ReadDir(matchDir)
+	objects, err := r.backend.List(match + "/")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []LogFile{}, nil
-		}
-		return nil, fmt.Errorf("read match dir: %w", err)
+		return nil, fmt.Errorf("list match logs: %w", err)
 	}
 
 	out := []LogFile{}
-	for _, p := range players {
-		if !p.IsDir() {
+	for _, o := range objects {
+		parts := strings.Split(strings.TrimPrefix(o.Key, match+"/"), "/")
+		if len(parts) != 2 {
 			continue
 		}
-		files, err := os.ReadDir(filepath.Join(matchDir, p.Name()))
-		if err != nil {
-			return nil, fmt.Errorf("read player dir: %w", err)
-		}
-		for _, f := range files {
-			if f.IsDir() {
-				continue
-			}
-			info, err := f.Info()
-			if err != nil {
-				return nil, fmt.Errorf("stat log file: %w", err)
-			}
-			out = append(out, LogFile{
-				Player: p.Name(),
-				Name:   f.Name(),
-				Size:   info.Size(),
-			})
-		}
+		out = append(out, LogFile{
+			Player: parts[0],
+			Name:   parts[1],
+			Size:   o.Size,
+		})
 	}
 
 	sort.Slice(out, func(i, j int) bool {
@@ -147,17 +131,13 @@ func List(seed string) ([]LogFile, error) {
 
 // Load returns the raw bytes of one stored log file. Returns
 // os.ErrNotExist if the file isn't stored.
-func Load(seed, player, filename string) ([]byte, error) {
-	if seed == "" {
-		return nil, errors.New("logfile.Load: empty seed")
+func (r *Repository) Load(match, player, filename string) ([]byte, error) {
+	if match == "" {
+		return nil, errors.New("logfile.Load: empty match")
 	}
-	safePlayer, err := sanitizeComponent(player)
-	if err != nil {
-		return nil, err
-	}
-	safeName, err := sanitizeComponent(filename)
+	k, err := key(match, player, filename)
 	if err != nil {
 		return nil, err
 	}
-	return os.ReadFile(filepath.Join(MatchDir(seed), safePlayer, safeName))
+	return storage.ReadAll(r.backend, k)
 }
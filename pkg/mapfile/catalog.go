@@ -0,0 +1,211 @@
+package mapfile
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/mapparse"
+)
+
+// CatalogEntry describes one stored map.
+type CatalogEntry struct {
+	CRC string `json:"crc"`
+	// Name is the original X-Map-Name; Title its BaseName ("Tournament
+	// Desert"), which is what players know the map by.
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	// Size is the .map file size in bytes.
+	Size int64 `json:"size"`
+	// Players is the number of start positions, or 0 if the .map couldn't
+	// be decoded.
+	Players int `json:"players"`
+	// UploadedAt is when the .map was last written.
+	UploadedAt time.Time `json:"uploadedAt"`
+	Kinds      []string  `json:"kinds"`
+	Verified   bool      `json:"verified"`
+	// Complete is false while assets listed in the upload manifest are
+	// still missing; see Exists.
+	Complete bool `json:"complete"`
+}
+
+// MapInfo is a catalog entry plus what the decoded .map says about the
+// layout.
+type MapInfo struct {
+	CatalogEntry
+	// Width and Height are the playable area in world units.
+	Width          float64                  `json:"width"`
+	Height         float64                  `json:"height"`
+	StartPositions []mapparse.StartPosition `json:"startPositions"`
+	SupplyDocks    int                      `json:"supplyDocks"`
+	OilDerricks    int                      `json:"oilDerricks"`
+	TechBuildings  []string                 `json:"techBuildings"`
+	Warnings       []string                 `json:"warnings,omitempty"`
+}
+
+// parsedMap caches what Catalog needs from a decoded .map, keyed by CRC
+// and invalidated when the file's size or modification time changes.
+type parsedMap struct {
+	size    int64
+	modTime time.Time
+	players int
+}
+
+// catalogTTL is how long a built catalog is served before it is built
+// again. Writes through this Repository drop it at once; the TTL bounds
+// how long maps stored by another replica sharing the backend stay unlisted.
+const catalogTTL = time.Minute
+
+// Catalog lists every stored map, sorted by title and then CRC. CRCs
+// without a map.map (sidecars only) are skipped. Each .map is decoded once
+// for its player count and cached until it changes, and the whole list is
+// cached until the next write or catalogTTL.
+func (r *Repository) Catalog() ([]CatalogEntry, error) {
+	r.catalogMu.Lock()
+	if r.catalog != nil && time.Since(r.catalogAt) < catalogTTL {
+		entries := slices.Clone(r.catalog)
+		r.catalogMu.Unlock()
+		return entries, nil
+	}
+	gen := r.catalogGen
+	r.catalogMu.Unlock()
+
+	entries, err := r.buildCatalog()
+	if err != nil {
+		return nil, err
+	}
+	r.catalogMu.Lock()
+	// A write while building may have been missed; don't cache then.
+	if r.catalogGen == gen {
+		r.catalog, r.catalogAt = entries, time.Now()
+	}
+	r.catalogMu.Unlock()
+	return slices.Clone(entries), nil
+}
+
+// invalidateCatalog drops the cached catalog after a write.
+func (r *Repository) invalidateCatalog() {
+	r.catalogMu.Lock()
+	r.catalog = nil
+	r.catalogGen++
+	r.catalogMu.Unlock()
+}
+
+func (r *Repository) buildCatalog() ([]CatalogEntry, error) {
+	crcs, err := r.crcs()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]CatalogEntry, 0, len(crcs))
+	for _, crc := range crcs {
+		if entry, ok := r.catalogEntry(crc); ok {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := strings.ToLower(entries[i].Title), strings.ToLower(entries[j].Title)
+		if a != b {
+			return a < b
+		}
+		return entries[i].CRC < entries[j].CRC
+	})
+	return entries, nil
+}
+
+func (r *Repository) catalogEntry(crc string) (CatalogEntry, bool) {
+	info, err := r.backend.Stat(key(crc, kindFilename[KindMap]))
+	if err != nil {
+		return CatalogEntry{}, false
+	}
+	name := r.LoadName(crc)
+	entry := CatalogEntry{
+		CRC:        crc,
+		Name:       name,
+		Title:      BaseName(name),
+		Size:       info.Size,
+		UploadedAt: info.ModTime.UTC(),
+		Kinds:      r.AvailableKinds(crc),
+		Complete:   r.Complete(crc),
+	}
+	if v := r.LoadVerification(crc); v != nil {
+		entry.Verified = v.Verified
+	}
+
+	r.catalogMu.Lock()
+	cached, ok := r.catalogCache[crc]
+	r.catalogMu.Unlock()
+	if !ok || cached.size != info.Size || !cached.modTime.Equal(info.ModTime) {
+		cached = parsedMap{size: info.Size, modTime: info.ModTime}
+		if m, err := r.parseStored(crc); err == nil {
+			cached.players = len(m.StartPositions)
+		}
+		r.catalogMu.Lock()
+		r.catalogCache[crc] = cached
+		r.catalogMu.Unlock()
+	}
+	entry.Players = cached.players
+	return entry, true
+}
+
+func (r *Repository) parseStored(crc string) (*mapparse.Map, error) {
+	data, err := r.LoadAsset(crc, KindMap)
+	if err != nil {
+		return nil, err
+	}
+	return mapparse.Parse(data)
+}
+
+// SearchCatalog filters entries to those whose title, original name or CRC
+// contains every word of query (case-insensitive) and, when players is
+// non-zero, that have exactly that many start positions.
+func SearchCatalog(entries []CatalogEntry, query string, players int) []CatalogEntry {
+	words := strings.Fields(strings.ToLower(query))
+	out := make([]CatalogEntry, 0, len(entries))
+	for _, e := range entries {
+		if players != 0 && e.Players != players {
+			continue
+		}
+		haystack := strings.ToLower(e.Title + " " + e.Name + " " + e.CRC)
+		match := true
+		for _, w := range words {
+			if !strings.Contains(haystack, w) {
+				match = false
+				break
+			}
+		}
+		if match {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Describe returns the catalog entry for crc together with the decoded
+// map's layout. Returns os.ErrNotExist if no .map is stored for crc, or the
+// decode error if the .map is unreadable.
+func (r *Repository) Describe(crc string) (*MapInfo, error) {
+	entry, ok := r.catalogEntry(crc)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	m, err := r.parseStored(crc)
+	if err != nil {
+		return nil, err
+	}
+	info := &MapInfo{
+		CatalogEntry:   entry,
+		Width:          m.Width,
+		Height:         m.Height,
+		StartPositions: m.StartPositions,
+		SupplyDocks:    len(m.SupplyDocks),
+		OilDerricks:    len(m.OilDerricks),
+		TechBuildings:  make([]string, 0, len(m.TechBuildings)),
+		Warnings:       m.Warnings,
+	}
+	for _, o := range m.TechBuildings {
+		info.TechBuildings = append(info.TechBuildings, o.Template)
+	}
+	return info, nil
+}
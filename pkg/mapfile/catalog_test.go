@@ -0,0 +1,135 @@
+package mapfile
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/mapparse"
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+// newTestRepository returns a Repository on a filesystem backend in a
+// fresh temp dir, and the dir.
+func newTestRepository(t *testing.T) (*Repository, string) {
+	dir := t.TempDir()
+	return NewRepository(storage.NewFS(dir)), dir
+}
+
+// testMap builds an uncompressed .map holding only start waypoints.
+func testMap(starts int) []byte {
+	le := binary.LittleEndian
+	str := func(b []byte, s string) []byte {
+		return append(le.AppendUint16(b, uint16(len(s))), s...)
+	}
+	chunk := func(id uint32, version uint16, data []byte) []byte {
+		b := le.AppendUint32(nil, id)
+		b = le.AppendUint16(b, version)
+		b = le.AppendUint32(b, uint32(len(data)))
+		return append(b, data...)
+	}
+
+	var objects []byte
+	for i := 1; i <= starts; i++ {
+		var o []byte
+		for _, f := range []float32{float32(i * 100), 100, 0, 0} {
+			o = le.AppendUint32(o, math.Float32bits(f))
+		}
+		o = le.AppendUint32(o, 0) // flags
+		o = str(o, mapparse.WaypointTemplate)
+		o = le.AppendUint16(o, 1)
+		o = le.AppendUint32(o, 3<<8|3) // waypointName, ASCII string
+		o = str(o, "Player_"+string(rune('0'+i))+"_Start")
+		objects = append(objects, chunk(2, 3, o)...)
+	}
+
+	b := []byte("CkMp")
+	b = le.AppendUint32(b, 3)
+	for i, name := range []string{"ObjectsList", "Object", "waypointName"} {
+		b = append(b, byte(len(name)))
+		b = append(b, name...)
+		b = le.AppendUint32(b, uint32(i+1))
+	}
+	return append(b, chunk(1, 3, objects)...)
+}
+
+func TestCatalog(t *testing.T) {
+	r, dir := newTestRepository(t)
+
+	if entries, err := r.Catalog(); err != nil || len(entries) != 0 {
+		t.Fatalf("expected empty catalog, got %v, %v", entries, err)
+	}
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(r.Store("300", "Maps\\Tournament Desert\\Tournament Desert.map", KindMap, testMap(2)))
+	must(r.Store("300", "", KindPreview, []byte("tga")))
+	must(r.SaveVerification("300", Verification{Verified: true}))
+	must(r.Store("100", "Maps\\Winter Wolf\\Winter Wolf.map", KindMap, testMap(4)))
+	must(r.Store("200", "Maps\\Broken\\Broken.map", KindMap, []byte("not a map")))
+	must(r.Store("400", "Maps\\Sidecar\\Sidecar.map", KindStr, []byte("only a sidecar")))
+
+	entries, err := r.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 maps, got %+v", entries)
+	}
+	if entries[0].Title != "Broken" || entries[1].Title != "Tournament Desert" || entries[2].Title != "Winter Wolf" {
+		t.Errorf("expected entries sorted by title, got %s, %s, %s", entries[0].Title, entries[1].Title, entries[2].Title)
+	}
+	desert := entries[1]
+	if desert.Players != 2 || !desert.Verified || len(desert.Kinds) != 2 || desert.Size != int64(len(testMap(2))) {
+		t.Errorf("unexpected entry: %+v", desert)
+	}
+	if entries[0].Players != 0 {
+		t.Errorf("expected 0 players for an undecodable map, got %d", entries[0].Players)
+	}
+
+	if got := SearchCatalog(entries, "desert", 0); len(got) != 1 || got[0].CRC != "300" {
+		t.Errorf("expected search to find Tournament Desert, got %+v", got)
+	}
+	if got := SearchCatalog(entries, "", 4); len(got) != 1 || got[0].CRC != "100" {
+		t.Errorf("expected the 4 player map, got %+v", got)
+	}
+	if got := SearchCatalog(entries, "wolf desert", 0); len(got) != 0 {
+		t.Errorf("expected every word to have to match, got %+v", got)
+	}
+
+	// Re-uploading the .map refreshes the cached player count.
+	later := time.Now().Add(time.Minute)
+	must(r.Store("300", "", KindMap, testMap(3)))
+	must(os.Chtimes(filepath.Join(dir, "300", "map.map"), later, later))
+	info, err := r.Describe("300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Players != 3 || len(info.StartPositions) != 3 {
+		t.Errorf("expected 3 players after re-upload, got %+v", info)
+	}
+	if _, err := r.Describe("400"); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist for a CRC without a .map, got %v", err)
+	}
+
+	// The list is cached until a write through this repository.
+	if entries, _ := r.Catalog(); len(entries) != 3 {
+		t.Fatalf("expected 3 maps, got %+v", entries)
+	}
+	other := NewRepository(storage.NewFS(dir))
+	must(other.Store("500", "Maps\\Other\\Other.map", KindMap, testMap(2)))
+	if entries, _ := r.Catalog(); len(entries) != 3 {
+		t.Errorf("expected the cached catalog, got %+v", entries)
+	}
+	must(r.Store("600", "Maps\\Mine\\Mine.map", KindMap, testMap(2)))
+	if entries, _ := r.Catalog(); len(entries) != 5 {
+		t.Errorf("expected the write to refresh the catalog, got %+v", entries)
+	}
+}
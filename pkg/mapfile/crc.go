@@ -0,0 +1,133 @@
+package mapfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+// verifyFilename holds the outcome of the server-side CRC check for the
+// stored map.map. Maps stored before verification existed have none.
+const verifyFilename = "verify.json"
+
+// ErrCRCMismatch is returned by VerifyCRC when the .map bytes don't hash
+// to the CRC they were uploaded under.
+var ErrCRCMismatch = errors.New("mapfile: map CRC mismatch")
+
+// ComputeCRC returns the CRC the game identifies a map by: the engine's
+// CRC class (Common/CRC.h) run over the raw .map file bytes, exactly as
+// MapCache's calcCRC reads them (no decompression). Each byte is added to
+// the running value after a one-bit left rotate, and CRC::get returns the
+// result through htonl, so the value is byte-swapped on the x86 clients
+// that report it.
+func ComputeCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		hibit := crc >> 31
+		crc = crc<<1 + uint32(b) + hibit
+	}
+	return bits.ReverseBytes32(crc)
+}
+
+// VerifyCRC checks data against the decimal CRC it was uploaded under and
+// returns the computed CRC in the same form. The error wraps
+// ErrCRCMismatch when the values differ.
+func VerifyCRC(crc string, data []byte) (string, error) {
+	want, err := strconv.ParseUint(strings.TrimSpace(crc), 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("mapfile: bad map CRC %q: %w", crc, err)
+	}
+	got := ComputeCRC(data)
+	computed := strconv.FormatUint(uint64(got), 10)
+	if uint32(want) != got {
+		return computed, fmt.Errorf("%w: uploaded as %s, data hashes to %s", ErrCRCMismatch, crc, computed)
+	}
+	return computed, nil
+}
+
+// Verification records the server-side CRC check of a stored map.
+type Verification struct {
+	Verified    bool      `json:"verified"`
+	ComputedCRC string    `json:"computedCrc"`
+	Size        int       `json:"size"`
+	VerifiedAt  time.Time `json:"verifiedAt"`
+}
+
+// SaveVerification writes the verification record next to the CRC's
+// assets, replacing any earlier one.
+func (r *Repository) SaveVerification(crc string, v Verification) error {
+	if crc == "" {
+		return errors.New("mapfile.SaveVerification: empty crc")
+	}
+	defer r.invalidateCatalog()
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := storage.PutBytes(r.backend, key(crc, verifyFilename), b); err != nil {
+		return fmt.Errorf("write %s: %w", verifyFilename, err)
+	}
+	return nil
+}
+
+// StoreMap stores the .map of crc, which passed VerifyCRC, together with
+// its verification record v. The CRC is a rotate-add sum, so other bytes
+// can be padded to hash the same; once a map is verified, StoreMap refuses
+// different bytes with ErrMapVerified rather than change the map peers
+// download under that CRC. If the record can't be written, the previous
+// .map is put back.
+func (r *Repository) StoreMap(crc, mapName string, data []byte, v Verification) error {
+	r.mapMu.Lock()
+	defer r.mapMu.Unlock()
+	old, err := r.LoadAsset(crc, KindMap)
+	switch {
+	case err == nil:
+		if !bytes.Equal(old, data) && r.verified(crc, int64(len(old))) {
+			return ErrMapVerified
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	if err := r.Store(crc, mapName, KindMap, data); err != nil {
+		return err
+	}
+	if err := r.SaveVerification(crc, v); err != nil {
+		mapKey := key(crc, kindFilename[KindMap])
+		var rerr error
+		if old != nil {
+			rerr = storage.PutBytes(r.backend, mapKey, old)
+		} else {
+			rerr = r.backend.Delete(mapKey)
+		}
+		if rerr != nil {
+			return fmt.Errorf("%w; restoring the previous map: %v", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// LoadVerification returns the verification record for a CRC, or nil if
+// the map was stored without one.
+func (r *Repository) LoadVerification(crc string) *Verification {
+	if crc == "" {
+		return nil
+	}
+	b, err := storage.ReadAll(r.backend, key(crc, verifyFilename))
+	if err != nil {
+		return nil
+	}
+	var v Verification
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+	return &v
+}
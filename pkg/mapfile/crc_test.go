@@ -0,0 +1,95 @@
+package mapfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComputeCRC(t *testing.T) {
+	// 0 -> 1 -> 4, then byte-swapped.
+	if got := ComputeCRC([]byte{1, 2}); got != 0x04000000 {
+		t.Errorf("expected 0x04000000, got %#08x", got)
+	}
+	// 0x80 reaches bit 31 after 24 more bytes; the next byte rotates it
+	// around to bit 0.
+	data := make([]byte, 26)
+	data[0] = 0x80
+	if got := ComputeCRC(data); got != 0x01000000 {
+		t.Errorf("expected high bit to wrap around, got %#08x", got)
+	}
+	if got := ComputeCRC(nil); got != 0 {
+		t.Errorf("expected 0 for empty data, got %#08x", got)
+	}
+}
+
+func TestVerifyCRC(t *testing.T) {
+	data := []byte{1, 2}
+	computed, err := VerifyCRC("67108864", data)
+	if err != nil || computed != "67108864" {
+		t.Fatalf("expected match, got %q, %v", computed, err)
+	}
+	computed, err = VerifyCRC("12345", data)
+	if !errors.Is(err, ErrCRCMismatch) || computed != "67108864" {
+		t.Errorf("expected mismatch reporting 67108864, got %q, %v", computed, err)
+	}
+	if _, err := VerifyCRC("not-a-crc", data); err == nil || errors.Is(err, ErrCRCMismatch) {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestVerificationRoundTrip(t *testing.T) {
+	r, _ := newTestRepository(t)
+
+	if err := r.Store("67108864", "Maps\\Test\\Test.map", KindMap, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if v := r.LoadVerification("67108864"); v != nil {
+		t.Errorf("expected no verification before SaveVerification, got %+v", v)
+	}
+	if err := r.SaveVerification("67108864", Verification{Verified: true, ComputedCRC: "67108864", Size: 2}); err != nil {
+		t.Fatal(err)
+	}
+	v := r.LoadVerification("67108864")
+	if v == nil || !v.Verified || v.ComputedCRC != "67108864" || v.Size != 2 {
+		t.Errorf("unexpected verification: %+v", v)
+	}
+}
+
+func TestStoreMap(t *testing.T) {
+	r, dir := newTestRepository(t)
+	const crc = "67108864"
+	verification := func(data []byte) Verification {
+		return Verification{Verified: true, ComputedCRC: crc, Size: len(data)}
+	}
+
+	// The record can't be written, so the .map isn't left behind.
+	if err := os.MkdirAll(filepath.Join(dir, crc, verifyFilename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.StoreMap(crc, "", []byte{1, 2}, verification([]byte{1, 2})); err == nil {
+		t.Fatal("expected the failed verification record to fail the store")
+	}
+	if _, err := r.LoadAsset(crc, KindMap); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the map to be removed again, got %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, crc, verifyFilename)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.StoreMap(crc, "", []byte{1, 2}, verification([]byte{1, 2})); err != nil {
+		t.Fatal(err)
+	}
+	// {0, 4} hashes to the same CRC, but the verified map stays.
+	if err := r.StoreMap(crc, "", []byte{0, 4}, verification([]byte{0, 4})); !errors.Is(err, ErrMapVerified) {
+		t.Errorf("expected ErrMapVerified, got %v", err)
+	}
+	if err := r.StoreMap(crc, "", []byte{1, 2}, verification([]byte{1, 2})); err != nil {
+		t.Errorf("expected the same bytes to be stored again, got %v", err)
+	}
+	if data, _ := r.LoadAsset(crc, KindMap); !bytes.Equal(data, []byte{1, 2}) {
+		t.Errorf("expected the verified map to be kept, got %v", data)
+	}
+}
@@ -2,7 +2,7 @@
 // and any sidecar files) keyed by the .map's CRC, mirroring the on-disk
 // pattern used by pkg/statsfile.
 //
-// Layout under MapsDir:
+// Layout in the repository's storage backend:
 //
 //	<crc>/
 //	  meta.txt         # one line: original X-Map-Name from the upload (e.g. "Maps\Tournament Desert\Tournament Desert.map")
@@ -13,28 +13,54 @@
 //	  solo.ini         # optional solo-mode INI
 //	  assetusage.txt   # optional asset usage report
 //	  readme.txt       # optional readme
+//	  preview*.png     # PNG conversions of preview.tga, one per requested width (cache)
+//	  preview.bad      # why preview.tga couldn't be converted (cache)
+//	  manifest.json    # asset kinds and sizes the uploader declared, when it sent one
+//	  parts/<kind>/... # chunks of uploads in progress, joined into place once complete
+//	  verify.json      # server-side CRC check of map.map (absent for maps stored before verification)
 //
-// Existence is checked by the .map file: if "<MapsDir>/<crc>/map.map"
-// exists, we already have that map (sidecars are best-effort and may be
-// absent even when the map is present).
+// Existence is checked by the .map file: if "<crc>/map.map" exists, we already have that map (sidecars are best-effort and may be
+// absent even when the map is present). When the uploader sent a manifest,
+// the map only counts as present once every asset it lists is stored at
+// the declared size.
 package mapfile
 
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
 )
 
-// MapsDir is the directory where map files are stored.
-// Set via MAPS_DIR env var or defaults to ./maps/
-var MapsDir = "./maps"
+// Repository stores maps and their sidecars in a storage backend.
+type Repository struct {
+	backend storage.Backend
 
-func init() {
-	if dir := os.Getenv("MAPS_DIR"); dir != "" {
-		MapsDir = dir
-	}
+	// uploadMu serializes chunk appends within this process so two
+	// requests can't interleave on the same upload.
+	uploadMu sync.Mutex
+	// mapMu serializes StoreMap, so the .map it checks is the one it
+	// replaces.
+	mapMu sync.Mutex
+
+	// catalogCache remembers each map's decoded player count, and catalog
+	// the last built list; see Catalog. catalogGen counts writes, so a
+	// list built across one isn't cached.
+	catalogMu    sync.Mutex
+	catalogCache map[string]parsedMap
+	catalog      []CatalogEntry
+	catalogAt    time.Time
+	catalogGen   uint64
+}
+
+// NewRepository returns a Repository that keeps maps in b.
+func NewRepository(b storage.Backend) *Repository {
+	return &Repository{backend: b, catalogCache: map[string]parsedMap{}}
 }
 
 // File-kind constants matching the X-Map-File header values sent by the
@@ -74,19 +100,20 @@ var kindFilename = map[string]string{
 
 const metaFilename = "meta.txt"
 
-// MapDir returns the per-CRC storage directory for a given CRC.
-func MapDir(crc string) string {
-	return filepath.Join(MapsDir, crc)
+// key returns the storage key of one file in a CRC's directory.
+func key(crc, filename string) string {
+	return path.Join(crc, filename)
 }
 
-// Exists reports whether we already have the .map file for this CRC.
-// Sidecars are best-effort; missing sidecars do not flip Exists to false.
-func Exists(crc string) bool {
+// Exists reports whether we have the complete map for this CRC: the .map
+// file, plus every asset its manifest lists at the declared size when the
+// uploader sent one. Without a manifest, sidecars are best-effort and
+// missing ones do not flip Exists to false.
+func (r *Repository) Exists(crc string) bool {
 	if crc == "" {
 		return false
 	}
-	info, err := os.Stat(filepath.Join(MapDir(crc), kindFilename[KindMap]))
-	return err == nil && info.Mode().IsRegular()
+	return r.Complete(crc)
 }
 
 // IsValidKind reports whether the given X-Map-File value is a kind we
@@ -98,8 +125,10 @@ func IsValidKind(kind string) bool {
 
 // Store writes one asset into the CRC dir and refreshes meta.txt with
 // the supplied original map name. kind must be a value from AllKinds.
-// Calling Store with the same kind twice overwrites silently.
-func Store(crc string, mapName string, kind string, data []byte) error {
+// Calling Store with the same kind twice overwrites silently. Files are
+// replaced atomically, so a crash mid-write never leaves a truncated
+// asset in place.
+func (r *Repository) Store(crc string, mapName string, kind string, data []byte) error {
 	if crc == "" {
 		return errors.New("mapfile.Store: empty crc")
 	}
@@ -108,12 +137,8 @@ func Store(crc string, mapName string, kind string, data []byte) error {
 		return fmt.Errorf("mapfile: unknown X-Map-File kind %q", kind)
 	}
 
-	dir := MapDir(crc)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("create map dir: %w", err)
-	}
-
-	if err := os.WriteFile(filepath.Join(dir, target), data, 0644); err != nil {
+	defer r.invalidateCatalog()
+	if err := storage.PutBytes(r.backend, key(crc, target), data); err != nil {
 		return fmt.Errorf("write %s: %w", target, err)
 	}
 
@@ -121,7 +146,7 @@ func Store(crc string, mapName string, kind string, data []byte) error {
 	// In normal operation all uploads for the same map carry the same
 	// X-Map-Name, so this is just idempotent overwrite.
 	if mapName != "" {
-		if err := os.WriteFile(filepath.Join(dir, metaFilename), []byte(mapName), 0644); err != nil {
+		if err := storage.PutBytes(r.backend, key(crc, metaFilename), []byte(mapName)); err != nil {
 			return fmt.Errorf("write meta: %w", err)
 		}
 	}
@@ -130,7 +155,7 @@ func Store(crc string, mapName string, kind string, data []byte) error {
 
 // LoadAsset returns the raw bytes of one asset kind for a CRC. Returns
 // os.ErrNotExist if the asset isn't stored.
-func LoadAsset(crc string, kind string) ([]byte, error) {
+func (r *Repository) LoadAsset(crc string, kind string) ([]byte, error) {
 	if crc == "" {
 		return nil, errors.New("mapfile.LoadAsset: empty crc")
 	}
@@ -138,43 +163,59 @@ func LoadAsset(crc string, kind string) ([]byte, error) {
 	if !ok {
 		return nil, fmt.Errorf("mapfile: unknown kind %q", kind)
 	}
-	return os.ReadFile(filepath.Join(MapDir(crc), target))
+	return storage.ReadAll(r.backend, key(crc, target))
 }
 
 // LoadName returns the original X-Map-Name stored alongside the assets,
 // or an empty string if meta.txt is missing.
-func LoadName(crc string) string {
+func (r *Repository) LoadName(crc string) string {
 	if crc == "" {
 		return ""
 	}
-	b, err := os.ReadFile(filepath.Join(MapDir(crc), metaFilename))
+	b, err := storage.ReadAll(r.backend, key(crc, metaFilename))
 	if err != nil {
 		return ""
 	}
 	return strings.TrimSpace(string(b))
 }
 
-// AvailableKinds returns the set of asset kinds actually present on
-// disk for the given CRC, in AllKinds order. Useful for /list_map_assets
-// so a peer can request only what's there.
-func AvailableKinds(crc string) []string {
+// AvailableKinds returns the set of asset kinds actually stored for the
+// given CRC, in AllKinds order. Useful for /list_map_assets so a peer can
+// request only what's there.
+func (r *Repository) AvailableKinds(crc string) []string {
 	if crc == "" {
 		return nil
 	}
-	dir := MapDir(crc)
+	sizes := r.storedSizes(crc)
 	out := make([]string, 0, len(AllKinds))
 	for _, k := range AllKinds {
-		fn, ok := kindFilename[k]
-		if !ok {
-			continue
-		}
-		if info, err := os.Stat(filepath.Join(dir, fn)); err == nil && info.Mode().IsRegular() {
+		if _, ok := sizes[k]; ok {
 			out = append(out, k)
 		}
 	}
 	return out
 }
 
+// storedSizes maps each asset kind stored for crc to its size, using one
+// List of the CRC's directory.
+func (r *Repository) storedSizes(crc string) map[string]int64 {
+	sizes := map[string]int64{}
+	objects, err := r.backend.List(crc + "/")
+	if err != nil {
+		return sizes
+	}
+	byName := make(map[string]string, len(kindFilename))
+	for kind, fn := range kindFilename {
+		byName[crc+"/"+fn] = kind
+	}
+	for _, o := range objects {
+		if kind, ok := byName[o.Key]; ok {
+			sizes[kind] = o.Size
+		}
+	}
+	return sizes
+}
+
 // Load returns the stored map name (from meta.txt), the .map bytes, and
 // the .tga preview bytes for the given CRC. previewData is nil when the
 // preview wasn't uploaded. Returns os.ErrNotExist if the .map is missing.
@@ -182,24 +223,69 @@ func AvailableKinds(crc string) []string {
 // Kept for backwards compatibility with the original /get_map zip
 // endpoint, which only ever bundled map + preview. New callers should
 // prefer LoadAsset for per-kind access.
-func Load(crc string) (mapName string, mapData []byte, previewData []byte, err error) {
+func (r *Repository) Load(crc string) (mapName string, mapData []byte, previewData []byte, err error) {
 	if crc == "" {
 		return "", nil, nil, errors.New("mapfile.Load: empty crc")
 	}
 
-	mapData, err = LoadAsset(crc, KindMap)
+	mapData, err = r.LoadAsset(crc, KindMap)
 	if err != nil {
 		return "", nil, nil, err
 	}
 
-	mapName = LoadName(crc)
+	mapName = r.LoadName(crc)
 
-	if previewBytes, previewErr := LoadAsset(crc, KindPreview); previewErr == nil {
+	if previewBytes, previewErr := r.LoadAsset(crc, KindPreview); previewErr == nil {
 		previewData = previewBytes
 	}
 	return mapName, mapData, previewData, nil
 }
 
+// FindByName returns the CRC of a stored map whose original name has the
+// same BaseName as name (case-insensitive), or "" if none does. Used when a
+// stats file only knows the map by path. With several versions of a map
+// stored, which one is returned is unspecified.
+func (r *Repository) FindByName(name string) string {
+	if name == "" {
+		return ""
+	}
+	want := strings.ToLower(BaseName(name))
+	crcs, _ := r.crcs()
+	for _, crc := range crcs {
+		if strings.ToLower(BaseName(r.LoadName(crc))) == want && r.Exists(crc) {
+			return crc
+		}
+	}
+	return ""
+}
+
+// crcs returns every CRC with a stored .map, in key order.
+func (r *Repository) crcs() ([]string, error) {
+	objects, err := r.backend.List("")
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, o := range objects {
+		crc, name, ok := strings.Cut(o.Key, "/")
+		if ok && name == kindFilename[KindMap] {
+			out = append(out, crc)
+		}
+	}
+	return out, nil
+}
+
+// CRCFromHeader converts the replay header's map CRC (the "MC" field, hex)
+// to the decimal form maps are stored under, so a replay can find the map
+// it was played on.
+func CRCFromHeader(hexCRC string) (string, error) {
+	v, err := strconv.ParseUint(strings.TrimSpace(hexCRC), 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("mapfile: bad header CRC %q: %w", hexCRC, err)
+	}
+	return strconv.FormatUint(v, 10), nil
+}
+
 // BaseName extracts the filename stem from an original X-Map-Name. The
 // game sends paths with backslashes (e.g. "Maps\Foo\Foo.map"); we want
 // "Foo" so /get_map can rename the zip entries to "Foo.map" / "Foo.tga".
@@ -209,7 +295,7 @@ func BaseName(mapName string) string {
 	}
 	// Strip directory components for both separators (Windows + POSIX).
 	cleaned := strings.ReplaceAll(mapName, "\\", "/")
-	last := filepath.Base(cleaned)
+	last := path.Base(cleaned)
 	// Strip extension.
 	if dot := strings.LastIndex(last, "."); dot > 0 {
 		last = last[:dot]
@@ -0,0 +1,129 @@
+package mapfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"strconv"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+	"github.com/bill-rich/cncstats/pkg/tga"
+)
+
+// MaxPreviewWidth caps the width PreviewPNG will scale to. Previews are
+// 128px wide in the game, so anything bigger is just upscaled blur.
+const MaxPreviewWidth = 1024
+
+// ErrBadPreview is returned by PreviewPNG when the stored preview.tga
+// can't be decoded.
+var ErrBadPreview = errors.New("mapfile: preview is not a supported TGA")
+
+// badPreviewFilename records why the stored preview.tga couldn't be
+// decoded, so a bad preview is only decoded once.
+const badPreviewFilename = "preview.bad"
+
+// PreviewPNG returns the stored preview.tga as PNG bytes. width 0 keeps the
+// original size; otherwise the image is scaled to that width with the
+// aspect ratio kept. Results are cached next to the asset as
+// preview[_<width>].png, and decode failures as preview.bad, until
+// preview.tga is newer. Returns os.ErrNotExist if no preview was uploaded.
+func (r *Repository) PreviewPNG(crc string, width int) ([]byte, error) {
+	if crc == "" {
+		return nil, errors.New("mapfile.PreviewPNG: empty crc")
+	}
+	if width < 0 || width > MaxPreviewWidth {
+		return nil, fmt.Errorf("mapfile: preview width must be between 0 and %d", MaxPreviewWidth)
+	}
+	src := key(crc, kindFilename[KindPreview])
+	srcInfo, err := r.backend.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheName := "preview.png"
+	if width > 0 {
+		cacheName = "preview_" + strconv.Itoa(width) + ".png"
+	}
+	cacheKey := key(crc, cacheName)
+	if info, err := r.backend.Stat(cacheKey); err == nil && !info.ModTime.Before(srcInfo.ModTime) {
+		if b, err := storage.ReadAll(r.backend, cacheKey); err == nil {
+			return b, nil
+		}
+	}
+
+	badKey := key(crc, badPreviewFilename)
+	if info, err := r.backend.Stat(badKey); err == nil && !info.ModTime.Before(srcInfo.ModTime) {
+		if b, err := storage.ReadAll(r.backend, badKey); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrBadPreview, b)
+		}
+	}
+
+	file, err := r.backend.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, err := tga.Decode(file)
+	if err != nil {
+		// A failed write only costs another decode next time.
+		_ = storage.PutBytes(r.backend, badKey, []byte(err.Error()))
+		return nil, fmt.Errorf("%w: %v", ErrBadPreview, err)
+	}
+	if width > 0 && width != img.Bounds().Dx() {
+		b := img.Bounds()
+		height := (b.Dy()*width + b.Dx()/2) / b.Dx()
+		if height < 1 {
+			height = 1
+		}
+		img = scale(img, width, height)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	// A failed cache write only costs a re-encode next time.
+	_ = storage.PutBytes(r.backend, cacheKey, buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+// scale resizes img to w x h. Each destination pixel averages the source
+// pixels it covers, which reduces to nearest-neighbor when upscaling.
+func scale(img image.Image, w, h int) *image.NRGBA {
+	src := image.NewNRGBA(img.Bounds())
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		for x := src.Rect.Min.X; x < src.Rect.Max.X; x++ {
+			src.Set(x, y, img.At(x, y))
+		}
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					p := src.Pix[sy*src.Stride+sx*4:]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = byte(r/n), byte(g/n), byte(b/n), byte(a/n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,76 @@
+package mapfile
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// solidTGA builds an uncompressed 32-bit TGA filled with one color.
+func solidTGA(w, h int, c color.NRGBA) []byte {
+	data := []byte{0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, byte(w), byte(w >> 8), byte(h), byte(h >> 8), 32, 8}
+	for i := 0; i < w*h; i++ {
+		data = append(data, c.B, c.G, c.R, c.A)
+	}
+	return data
+}
+
+func TestPreviewPNG(t *testing.T) {
+	r, dir := newTestRepository(t)
+
+	if _, err := r.PreviewPNG("1", 0); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist for missing preview, got %v", err)
+	}
+
+	teal := color.NRGBA{0, 0x80, 0x80, 0xFF}
+	if err := r.Store("1", "", KindPreview, solidTGA(8, 4, teal)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.PreviewPNG("1", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("result is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Errorf("expected 4x2 after scaling, got %v", b)
+	}
+	if got := color.NRGBAModel.Convert(img.At(1, 1)); got != teal {
+		t.Errorf("expected %v, got %v", teal, got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1", "preview_4.png")); err != nil {
+		t.Errorf("expected cached conversion: %v", err)
+	}
+
+	if err := r.Store("2", "", KindPreview, []byte("not a tga")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.PreviewPNG("2", 0); !errors.Is(err, ErrBadPreview) {
+		t.Errorf("expected ErrBadPreview, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2", badPreviewFilename)); err != nil {
+		t.Errorf("expected the failure to be cached: %v", err)
+	}
+	if _, err := r.PreviewPNG("2", 64); !errors.Is(err, ErrBadPreview) {
+		t.Errorf("expected the cached failure for any width, got %v", err)
+	}
+}
+
+func TestScaleAverages(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0xFF})
+	src.SetNRGBA(1, 0, color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF})
+	if got := scale(src, 1, 1).NRGBAAt(0, 0); got != (color.NRGBA{0x7F, 0x7F, 0x7F, 0xFF}) {
+		t.Errorf("expected mid grey, got %v", got)
+	}
+	if got := scale(src, 4, 2).NRGBAAt(3, 1); got != (color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Errorf("expected upscaled white, got %v", got)
+	}
+}
@@ -0,0 +1,353 @@
+package mapfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+// manifestFilename lists the asset kinds and sizes a client declared for a
+// CRC before uploading, so the server can tell a finished upload set from
+// one that was cut off.
+const manifestFilename = "manifest.json"
+
+// partsDir holds the chunks of an asset still being uploaded, one object
+// per chunk named by its zero-padded offset, so a chunk is a single
+// atomic Put on any backend. They're joined into the asset only once
+// complete and verified.
+const partsDir = "parts"
+
+// MaxAssetSize caps a single asset, whole or chunked. The largest maps in
+// circulation are a few tens of megabytes uncompressed.
+const MaxAssetSize = 256 << 20
+
+var (
+	// ErrOffsetMismatch is returned by AppendChunk when a chunk doesn't
+	// start where the stored part ends; UploadOffset says where to resume.
+	ErrOffsetMismatch = errors.New("mapfile: chunk offset does not match uploaded size")
+	// ErrSizeMismatch is returned when an asset's size disagrees with the
+	// manifest or with the total declared for a chunked upload.
+	ErrSizeMismatch = errors.New("mapfile: asset size does not match declared size")
+	// ErrMapVerified is returned by SaveManifest, and by StoreMap for
+	// different bytes, when the CRC's .map is already stored and passed the
+	// CRC check.
+	ErrMapVerified = errors.New("mapfile: map is already stored and verified")
+)
+
+// Manifest is the set of assets a client intends to upload for a CRC.
+type Manifest struct {
+	Name string `json:"name,omitempty"`
+	// Assets maps asset kind to size in bytes. It must include KindMap.
+	Assets    map[string]int64 `json:"assets"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// SaveManifest records the assets expected for crc, replacing any earlier
+// manifest, and the map name in meta.txt when one is given. Until every
+// listed asset is stored at its declared size, Complete (and so Exists)
+// reports the map as absent. Once the .map is stored and verified, the set
+// is known good and SaveManifest returns ErrMapVerified instead, so a
+// manifest can't hide it.
+func (r *Repository) SaveManifest(crc string, m Manifest) error {
+	if crc == "" {
+		return errors.New("mapfile.SaveManifest: empty crc")
+	}
+	if _, ok := m.Assets[KindMap]; !ok {
+		return fmt.Errorf("mapfile: manifest must list the %q asset", KindMap)
+	}
+	for kind, size := range m.Assets {
+		if !IsValidKind(kind) {
+			return fmt.Errorf("mapfile: unknown kind %q in manifest", kind)
+		}
+		if size <= 0 || size > MaxAssetSize {
+			return fmt.Errorf("mapfile: manifest size %d for %q out of range", size, kind)
+		}
+	}
+	if info, err := r.backend.Stat(key(crc, kindFilename[KindMap])); err == nil && r.verified(crc, info.Size) {
+		return ErrMapVerified
+	}
+	defer r.invalidateCatalog()
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := storage.PutBytes(r.backend, key(crc, manifestFilename), b); err != nil {
+		return fmt.Errorf("write %s: %w", manifestFilename, err)
+	}
+	if m.Name != "" {
+		if err := storage.PutBytes(r.backend, key(crc, metaFilename), []byte(m.Name)); err != nil {
+			return fmt.Errorf("write meta: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadManifest returns the manifest for crc, or nil if the client that
+// uploaded it didn't send one.
+func (r *Repository) LoadManifest(crc string) *Manifest {
+	if crc == "" {
+		return nil
+	}
+	b, err := storage.ReadAll(r.backend, key(crc, manifestFilename))
+	if err != nil {
+		return nil
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+	return &m
+}
+
+// CheckSize returns an error wrapping ErrSizeMismatch if crc has a
+// manifest that declares a different size for kind.
+func (r *Repository) CheckSize(crc, kind string, size int64) error {
+	m := r.LoadManifest(crc)
+	if m != nil {
+		m = r.trusted(crc, m, r.storedSizes(crc))
+	}
+	if m == nil {
+		return nil
+	}
+	if want, ok := m.Assets[kind]; ok && want != size {
+		return fmt.Errorf("%w: %s is %d bytes, manifest says %d", ErrSizeMismatch, kind, size, want)
+	}
+	return nil
+}
+
+// UploadStatus reports how far the upload set for a CRC has got.
+type UploadStatus struct {
+	CRC      string    `json:"crc"`
+	Manifest *Manifest `json:"manifest"`
+	// Stored maps each stored kind to its size.
+	Stored map[string]int64 `json:"stored"`
+	// Partial maps kinds with a chunked upload in progress to the bytes
+	// received so far, which is where the next chunk must start.
+	Partial map[string]int64 `json:"partial"`
+	// Missing lists manifest kinds not yet stored at their declared size.
+	Missing  []string `json:"missing"`
+	Complete bool     `json:"complete"`
+}
+
+// Status reports the stored, partial and missing assets for crc.
+func (r *Repository) Status(crc string) UploadStatus {
+	st := UploadStatus{
+		CRC:      crc,
+		Manifest: r.LoadManifest(crc),
+		Stored:   map[string]int64{},
+		Partial:  map[string]int64{},
+		Missing:  []string{},
+	}
+	if crc == "" {
+		return st
+	}
+	st.Stored = r.storedSizes(crc)
+	for _, kind := range AllKinds {
+		if parts, err := r.parts(crc, kind); err == nil && len(parts) > 0 {
+			st.Partial[kind] = contiguous(parts)
+		}
+	}
+	st.Manifest = r.trusted(crc, st.Manifest, st.Stored)
+	st.Missing = missing(st.Manifest, st.Stored)
+	_, hasMap := st.Stored[KindMap]
+	st.Complete = hasMap && len(st.Missing) == 0
+	return st
+}
+
+// Complete reports whether crc has its .map and, if a manifest was sent,
+// every asset it lists at the declared size. Without a manifest it's one
+// Stat of the .map.
+func (r *Repository) Complete(crc string) bool {
+	if crc == "" {
+		return false
+	}
+	m := r.LoadManifest(crc)
+	if m == nil {
+		_, err := r.backend.Stat(key(crc, kindFilename[KindMap]))
+		return err == nil
+	}
+	stored := r.storedSizes(crc)
+	_, hasMap := stored[KindMap]
+	return hasMap && len(missing(r.trusted(crc, m, stored), stored)) == 0
+}
+
+// missing lists the kinds m declares that aren't stored at their declared
+// size, in AllKinds order.
+func missing(m *Manifest, stored map[string]int64) []string {
+	out := []string{}
+	if m == nil {
+		return out
+	}
+	for _, kind := range AllKinds {
+		want, ok := m.Assets[kind]
+		if !ok {
+			continue
+		}
+		if got, ok := stored[kind]; !ok || got != want {
+			out = append(out, kind)
+		}
+	}
+	return out
+}
+
+// trusted returns m, or nil if it declares a different .map size from the
+// stored .map that passed the CRC check. Such a manifest can only be wrong,
+// and would otherwise hide a good map (manifests saved before SaveManifest
+// refused verified maps).
+func (r *Repository) trusted(crc string, m *Manifest, stored map[string]int64) *Manifest {
+	size, ok := stored[KindMap]
+	if m == nil || !ok || m.Assets[KindMap] == size || !r.verified(crc, size) {
+		return m
+	}
+	return nil
+}
+
+// verified reports whether the stored .map of crc, size bytes long, passed
+// the CRC check.
+func (r *Repository) verified(crc string, size int64) bool {
+	v := r.LoadVerification(crc)
+	return v != nil && v.Verified && int64(v.Size) == size
+}
+
+// chunk is one stored piece of an in-progress upload.
+type chunk struct {
+	key    string
+	offset int64
+	size   int64
+}
+
+func partPrefix(crc, kind string) string {
+	return path.Join(crc, partsDir, kind) + "/"
+}
+
+// parts lists the stored chunks of kind's upload in offset order.
+func (r *Repository) parts(crc, kind string) ([]chunk, error) {
+	prefix := partPrefix(crc, kind)
+	objects, err := r.backend.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]chunk, 0, len(objects))
+	for _, o := range objects {
+		offset, err := strconv.ParseInt(strings.TrimPrefix(o.Key, prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, chunk{key: o.Key, offset: offset, size: o.Size})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].offset < out[j].offset })
+	return out, nil
+}
+
+// contiguous returns how many bytes from offset 0 the chunks cover without
+// a gap.
+func contiguous(parts []chunk) int64 {
+	var n int64
+	for _, c := range parts {
+		if c.offset != n {
+			break
+		}
+		n += c.size
+	}
+	return n
+}
+
+// UploadOffset returns the number of bytes received so far for a chunked
+// upload of kind, 0 if none is in progress.
+func (r *Repository) UploadOffset(crc, kind string) int64 {
+	if crc == "" || !IsValidKind(kind) {
+		return 0
+	}
+	parts, err := r.parts(crc, kind)
+	if err != nil {
+		return 0
+	}
+	return contiguous(parts)
+}
+
+// AppendChunk adds data at offset to the in-progress upload of kind, whose
+// full size is total. offset must equal UploadOffset; a mismatch returns
+// ErrOffsetMismatch so the client can resume from the right place. When
+// the chunk completes the asset, the chunks are removed and the joined
+// bytes are returned for the caller to verify and Store; otherwise the
+// result is nil.
+func (r *Repository) AppendChunk(crc, kind string, offset, total int64, data []byte) ([]byte, error) {
+	if crc == "" {
+		return nil, errors.New("mapfile.AppendChunk: empty crc")
+	}
+	if !IsValidKind(kind) {
+		return nil, fmt.Errorf("mapfile: unknown X-Map-File kind %q", kind)
+	}
+	if total <= 0 || total > MaxAssetSize {
+		return nil, fmt.Errorf("mapfile: upload total %d out of range", total)
+	}
+	if offset+int64(len(data)) > total {
+		return nil, fmt.Errorf("%w: chunk ends at %d, past declared total %d", ErrSizeMismatch, offset+int64(len(data)), total)
+	}
+	if err := r.CheckSize(crc, kind, total); err != nil {
+		return nil, err
+	}
+
+	r.uploadMu.Lock()
+	defer r.uploadMu.Unlock()
+	parts, err := r.parts(crc, kind)
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 {
+		// Starting over, whatever was there before.
+		if err := r.deleteParts(parts); err != nil {
+			return nil, err
+		}
+		parts = nil
+	}
+	if have := contiguous(parts); have != offset {
+		return nil, fmt.Errorf("%w: have %d bytes, chunk starts at %d", ErrOffsetMismatch, have, offset)
+	}
+	chunkKey := fmt.Sprintf("%s%012d", partPrefix(crc, kind), offset)
+	if err := storage.PutBytes(r.backend, chunkKey, data); err != nil {
+		return nil, fmt.Errorf("write chunk: %w", err)
+	}
+	if offset+int64(len(data)) < total {
+		return nil, nil
+	}
+
+	complete := make([]byte, 0, total)
+	for _, c := range append(parts, chunk{key: chunkKey, offset: offset, size: int64(len(data))}) {
+		if c.offset != int64(len(complete)) {
+			continue // stale chunk past a restart point
+		}
+		b, err := storage.ReadAll(r.backend, c.key)
+		if err != nil {
+			return nil, err
+		}
+		complete = append(complete, b...)
+	}
+	if int64(len(complete)) != total {
+		return nil, fmt.Errorf("%w: joined %d bytes, declared total %d", ErrSizeMismatch, len(complete), total)
+	}
+	all, err := r.parts(crc, kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.deleteParts(all); err != nil {
+		return nil, err
+	}
+	return complete, nil
+}
+
+func (r *Repository) deleteParts(parts []chunk) error {
+	for _, c := range parts {
+		if err := r.backend.Delete(c.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
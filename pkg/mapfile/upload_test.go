@@ -0,0 +1,126 @@
+package mapfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendChunk(t *testing.T) {
+	r, dir := newTestRepository(t)
+
+	data := []byte("0123456789")
+	got, err := r.AppendChunk("1", KindMap, 0, 10, data[:4])
+	if err != nil || got != nil {
+		t.Fatalf("first chunk: got %q, %v", got, err)
+	}
+	if r.Exists("1") {
+		t.Error("a partial upload must not count as stored")
+	}
+	if off := r.UploadOffset("1", KindMap); off != 4 {
+		t.Errorf("expected offset 4, got %d", off)
+	}
+
+	// A retried or skipped chunk is refused without touching the part.
+	if _, err := r.AppendChunk("1", KindMap, 6, 10, data[6:]); !errors.Is(err, ErrOffsetMismatch) {
+		t.Errorf("expected ErrOffsetMismatch, got %v", err)
+	}
+	if _, err := r.AppendChunk("1", KindMap, 4, 10, []byte("too many bytes")); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+
+	got, err = r.AppendChunk("1", KindMap, 4, 10, data[4:])
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("last chunk: got %q, %v", got, err)
+	}
+	if parts, err := r.parts("1", KindMap); err != nil || len(parts) != 0 {
+		t.Errorf("expected chunks to be removed once complete, got %v, %v", parts, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1", "parts")); !os.IsNotExist(err) {
+		t.Errorf("expected the parts dir to be pruned, got %v", err)
+	}
+	if r.Exists("1") {
+		t.Error("AppendChunk must leave storing the asset to the caller")
+	}
+}
+
+func TestManifestCompleteness(t *testing.T) {
+	r, dir := newTestRepository(t)
+
+	if err := r.SaveManifest("1", Manifest{Assets: map[string]int64{KindPreview: 3}}); err == nil {
+		t.Error("expected a manifest without the map to be rejected")
+	}
+	if err := r.SaveManifest("1", Manifest{Name: "Maps\\A\\A.map", Assets: map[string]int64{KindMap: 4, KindPreview: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.LoadName("1"); got != "Maps\\A\\A.map" {
+		t.Errorf("expected manifest name in meta.txt, got %q", got)
+	}
+	if err := r.CheckSize("1", KindMap, 5); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+	if err := r.CheckSize("1", KindStr, 5); err != nil {
+		t.Errorf("kinds outside the manifest are unconstrained, got %v", err)
+	}
+
+	if err := r.Store("1", "", KindMap, []byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	st := r.Status("1")
+	if st.Complete || len(st.Missing) != 1 || st.Missing[0] != KindPreview || st.Stored[KindMap] != 4 {
+		t.Errorf("expected preview missing, got %+v", st)
+	}
+	if r.Exists("1") {
+		t.Error("expected map to be absent until the preview arrives")
+	}
+
+	if err := r.Store("1", "", KindPreview, []byte("tga")); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Exists("1") {
+		t.Errorf("expected complete set, got %+v", r.Status("1"))
+	}
+
+	// Without a manifest the .map alone is enough, as before.
+	if err := r.Store("2", "", KindMap, []byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Exists("2") {
+		t.Error("expected map without manifest to exist")
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "2"))
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".map" {
+			t.Errorf("unexpected leftover file %s", e.Name())
+		}
+	}
+}
+
+func TestManifestVerifiedMap(t *testing.T) {
+	r, _ := newTestRepository(t)
+	if err := r.Store("1", "", KindMap, []byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A manifest saved before the map was verified, declaring a bogus size.
+	if err := r.SaveManifest("1", Manifest{Assets: map[string]int64{KindMap: 99}}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Exists("1") {
+		t.Fatal("expected the unverified map to wait for its manifest")
+	}
+
+	if err := r.SaveVerification("1", Verification{Verified: true, Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Exists("1") {
+		t.Errorf("expected a manifest disagreeing with the verified map to be ignored, got %+v", r.Status("1"))
+	}
+	if err := r.CheckSize("1", KindMap, 4); err != nil {
+		t.Errorf("expected the ignored manifest not to constrain uploads, got %v", err)
+	}
+	if err := r.SaveManifest("1", Manifest{Assets: map[string]int64{KindMap: 99}}); !errors.Is(err, ErrMapVerified) {
+		t.Errorf("expected ErrMapVerified, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package mapparse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// chunkHeaderSize is the id (int32), version (uint16) and data size (int32)
+// in front of every chunk.
+const chunkHeaderSize = 10
+
+// Dict value types, the low byte of each key word (Dict::DataType).
+const (
+	dictBool          = 0
+	dictInt           = 1
+	dictReal          = 2
+	dictASCIIString   = 3
+	dictUnicodeString = 4
+)
+
+var errShort = errors.New("mapparse: unexpected end of data")
+
+// reader reads the little-endian primitives of the DataChunk format from a
+// byte slice. The first error sticks; later reads return zero values, so a
+// parser can read a whole record and check err once.
+type reader struct {
+	data  []byte
+	pos   int
+	err   error
+	names map[uint32]string // CkMp name table, for chunk and dict keys
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > r.remaining() {
+		r.err = errShort
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	b := r.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.take(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *reader) int32() int32 {
+	b := r.take(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+func (r *reader) float32() float32 {
+	b := r.take(4)
+	if b == nil {
+		return 0
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+// count reads an int32 element count and rejects values that couldn't fit
+// in the remaining data at minSize bytes per element.
+func (r *reader) count(minSize int) int {
+	n := int(r.int32())
+	if r.err == nil && (n < 0 || n*minSize > r.remaining()) {
+		r.err = fmt.Errorf("mapparse: count %d exceeds remaining data", n)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (r *reader) asciiString() string {
+	n := int(r.uint16())
+	return string(r.take(n))
+}
+
+func (r *reader) unicodeString() string {
+	n := int(r.uint16())
+	b := r.take(n * 2)
+	if b == nil {
+		return ""
+	}
+	units := make([]uint16, n)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// dict reads a Dict: a uint16 pair count, then for each pair an int32 whose
+// high 24 bits are a name-table ID and low byte the value type.
+func (r *reader) dict() map[string]interface{} {
+	n := int(r.uint16())
+	d := make(map[string]interface{}, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		keyAndType := uint32(r.int32())
+		key := r.names[keyAndType>>8]
+		if key == "" {
+			key = fmt.Sprintf("#%d", keyAndType>>8)
+		}
+		switch keyAndType & 0xFF {
+		case dictBool:
+			d[key] = r.byte() != 0
+		case dictInt:
+			d[key] = int(r.int32())
+		case dictReal:
+			d[key] = float64(r.float32())
+		case dictASCIIString:
+			d[key] = r.asciiString()
+		case dictUnicodeString:
+			d[key] = r.unicodeString()
+		default:
+			r.err = fmt.Errorf("mapparse: dict key %q has unknown type %d", key, keyAndType&0xFF)
+		}
+	}
+	return d
+}
+
+// chunk is one DataChunk with its payload.
+type chunk struct {
+	name    string
+	version uint16
+	data    []byte
+}
+
+// chunks splits data into consecutive chunks. Nested chunks (an Object
+// inside ObjectsList) are read by calling chunks again on the payload.
+func (r *reader) chunks(data []byte) ([]chunk, error) {
+	var out []chunk
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < chunkHeaderSize {
+			return out, errShort
+		}
+		id := binary.LittleEndian.Uint32(data[pos:])
+		version := binary.LittleEndian.Uint16(data[pos+4:])
+		size := int(int32(binary.LittleEndian.Uint32(data[pos+6:])))
+		pos += chunkHeaderSize
+		if size < 0 || size > len(data)-pos {
+			return out, fmt.Errorf("mapparse: chunk %q size %d exceeds remaining data", r.names[id], size)
+		}
+		out = append(out, chunk{name: r.names[id], version: version, data: data[pos : pos+size]})
+		pos += size
+	}
+	return out, nil
+}
+
+// sub returns a reader over a chunk's payload sharing the name table.
+func (r *reader) sub(c chunk) *reader {
+	return &reader{data: c.data, names: r.names}
+}
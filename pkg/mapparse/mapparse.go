@@ -0,0 +1,370 @@
+// Package mapparse decodes Generals / Zero Hour .map files: the stored
+// map.map bytes that pkg/mapfile keeps opaque.
+//
+// A .map is usually wrapped in a compression container ("EAR\0" RefPack, or
+// "ZL<n>\0" zlib). Inside is the engine's DataChunk format:
+//
+//	"CkMp"                          magic
+//	int32 count, then count x       name table: uint8 length, name, uint32 id
+//	chunks...                       uint32 id, uint16 version, int32 size, data
+//
+// Chunk ids and Dict keys are name-table ids. Parse reads the chunks that
+// matter for analysis — HeightMapData, WorldInfo, ObjectsList,
+// PolygonTriggers and WaypointsList — and skips the rest (textures,
+// lighting, scripts, sides).
+package mapparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CellSize is the world-space width of one height map cell (the engine's
+// MAP_XY_FACTOR). HeightScale converts a height map sample to world units.
+const (
+	CellSize    = 10.0
+	HeightScale = CellSize / 16
+)
+
+// WaypointTemplate is the object template WorldBuilder uses for waypoints.
+const WaypointTemplate = "*Waypoints/Waypoint"
+
+// Point is a position in height map cells.
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// HeightMap is the terrain grid. Data holds Width*Height samples, row by
+// row from y=0 (the south edge, world y=0 after the border); multiply by
+// HeightScale for world units. Border cells lie outside the playable area.
+type HeightMap struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	Border int `json:"border"`
+	// Boundaries are the playable-area rectangles (one per player count
+	// setting); most maps have a single one.
+	Boundaries []Point `json:"boundaries,omitempty"`
+	Data       []byte  `json:"-"`
+}
+
+// At returns the raw sample at cell (x, y), clamping to the grid.
+func (h *HeightMap) At(x, y int) byte {
+	if h == nil || len(h.Data) == 0 {
+		return 0
+	}
+	x = clamp(x, 0, h.Width-1)
+	y = clamp(y, 0, h.Height-1)
+	return h.Data[y*h.Width+x]
+}
+
+// HeightAt returns the terrain height in world units under world position
+// (x, y), using the nearest sample.
+func (h *HeightMap) HeightAt(x, y float64) float64 {
+	if h == nil {
+		return 0
+	}
+	cx := int(x/CellSize+0.5) + h.Border
+	cy := int(y/CellSize+0.5) + h.Border
+	return float64(h.At(cx, cy)) * HeightScale
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// Object is one placed map object. X, Y and Z are world coordinates.
+type Object struct {
+	Template   string                 `json:"template"`
+	X          float64                `json:"x"`
+	Y          float64                `json:"y"`
+	Z          float64                `json:"z"`
+	Angle      float64                `json:"angle"`
+	Flags      int                    `json:"flags,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Property returns a string property ("objectName", "originalOwner", ...)
+// or "" if unset.
+func (o *Object) Property(key string) string {
+	s, _ := o.Properties[key].(string)
+	return s
+}
+
+// Waypoint is a named point from the map's waypoint objects.
+type Waypoint struct {
+	ID   int     `json:"id"`
+	Name string  `json:"name"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// WaypointLink joins two waypoints by ID into a path.
+type WaypointLink struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// StartPosition is a Player_N_Start waypoint. Player is 1-based, matching
+// the waypoint name and the lobby's start position numbers.
+type StartPosition struct {
+	Player int     `json:"player"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+}
+
+// PolygonTrigger is a named area: a script trigger zone, water or river.
+// Points are in world units.
+type PolygonTrigger struct {
+	Name       string  `json:"name"`
+	Layer      string  `json:"layer,omitempty"`
+	ID         int     `json:"id"`
+	Water      bool    `json:"water,omitempty"`
+	River      bool    `json:"river,omitempty"`
+	RiverStart int     `json:"riverStart,omitempty"`
+	Points     [][]int `json:"points"`
+}
+
+// Map is the decoded content of a .map file.
+type Map struct {
+	HeightMap *HeightMap `json:"heightMap,omitempty"`
+	// Width and Height are the playable area in world units.
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+
+	WorldInfo       map[string]interface{} `json:"worldInfo,omitempty"`
+	Objects         []Object               `json:"objects"`
+	Waypoints       []Waypoint             `json:"waypoints"`
+	WaypointLinks   []WaypointLink         `json:"waypointLinks,omitempty"`
+	PolygonTriggers []PolygonTrigger       `json:"polygonTriggers,omitempty"`
+
+	// Derived from Objects and Waypoints.
+	StartPositions []StartPosition `json:"startPositions"`
+	SupplyDocks    []Object        `json:"supplyDocks"`
+	// TechBuildings are capturable Tech* structures other than oil
+	// derricks, which are listed on their own.
+	TechBuildings []Object `json:"techBuildings"`
+	OilDerricks   []Object `json:"oilDerricks"`
+
+	// Warnings lists chunks that were skipped because they failed to parse
+	// or have a version we don't know. The rest of the map is still usable.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// SupplyTemplates are the neutral supply source objects placed on maps.
+var SupplyTemplates = map[string]bool{
+	"SupplyDock":      true,
+	"SupplyPile":      true,
+	"SupplyPileSmall": true,
+	"SupplyWarehouse": true,
+	"ToxinRepository": true,
+}
+
+// OilDerrickTemplate is the capturable oil derrick.
+const OilDerrickTemplate = "TechOilDerrick"
+
+var startWaypoint = regexp.MustCompile(`^Player_(\d+)_Start$`)
+
+// Parse decodes a .map file, compressed or not.
+func Parse(data []byte) (*Map, error) {
+	raw, err := decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 8 || string(raw[:4]) != "CkMp" {
+		return nil, errors.New("mapparse: not a map file (missing CkMp header)")
+	}
+
+	r := &reader{data: raw, pos: 4, names: map[uint32]string{}}
+	n := r.count(5)
+	for i := 0; i < n && r.err == nil; i++ {
+		name := string(r.take(int(r.byte())))
+		id := uint32(r.int32())
+		r.names[id] = name
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("mapparse: name table: %w", r.err)
+	}
+
+	top, err := r.chunks(raw[r.pos:])
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Map{Objects: []Object{}, Waypoints: []Waypoint{}}
+	for _, c := range top {
+		var err error
+		switch c.name {
+		case "HeightMapData":
+			err = m.parseHeightMap(r.sub(c), c.version)
+		case "WorldInfo":
+			sub := r.sub(c)
+			m.WorldInfo = sub.dict()
+			err = sub.err
+		case "ObjectsList":
+			err = m.parseObjects(r, c)
+		case "PolygonTriggers":
+			err = m.parsePolygonTriggers(r.sub(c), c.version)
+		case "WaypointsList":
+			err = m.parseWaypointLinks(r.sub(c))
+		}
+		if err != nil {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("%s (version %d): %v", c.name, c.version, err))
+		}
+	}
+	m.derive()
+	return m, nil
+}
+
+func (m *Map) parseHeightMap(r *reader, version uint16) error {
+	if version < 1 || version > 4 {
+		return errors.New("unsupported version")
+	}
+	h := &HeightMap{}
+	h.Width = int(r.int32())
+	h.Height = int(r.int32())
+	if version >= 3 {
+		h.Border = int(r.int32())
+	}
+	if version >= 4 {
+		n := r.count(8)
+		for i := 0; i < n; i++ {
+			h.Boundaries = append(h.Boundaries, Point{X: int(r.int32()), Y: int(r.int32())})
+		}
+	}
+	size := r.count(1)
+	data := r.take(size)
+	if r.err != nil {
+		return r.err
+	}
+	if h.Width <= 0 || h.Height <= 0 || size != h.Width*h.Height {
+		return fmt.Errorf("%dx%d grid does not match %d samples", h.Width, h.Height, size)
+	}
+	h.Data = append([]byte(nil), data...)
+
+	// Version 1 stored the grid at twice the resolution the engine uses;
+	// it keeps every other sample.
+	if version == 1 {
+		w, ht := (h.Width+1)/2, (h.Height+1)/2
+		half := make([]byte, w*ht)
+		for y := 0; y < ht; y++ {
+			for x := 0; x < w; x++ {
+				half[y*w+x] = h.Data[(y*2)*h.Width+x*2]
+			}
+		}
+		h.Width, h.Height, h.Data = w, ht, half
+	}
+
+	m.HeightMap = h
+	m.Width = float64(h.Width-2*h.Border) * CellSize
+	m.Height = float64(h.Height-2*h.Border) * CellSize
+	return nil
+}
+
+func (m *Map) parseObjects(r *reader, list chunk) error {
+	children, err := r.chunks(list.data)
+	for _, c := range children {
+		if c.name != "Object" {
+			continue
+		}
+		if c.version < 1 || c.version > 3 {
+			return fmt.Errorf("object chunk version %d unsupported", c.version)
+		}
+		sub := r.sub(c)
+		obj := Object{
+			X: float64(sub.float32()),
+			Y: float64(sub.float32()),
+			Z: float64(sub.float32()),
+		}
+		if c.version <= 2 {
+			obj.Z = 0
+		}
+		obj.Angle = float64(sub.float32())
+		obj.Flags = int(sub.int32())
+		obj.Template = sub.asciiString()
+		if c.version >= 2 {
+			obj.Properties = sub.dict()
+		}
+		if sub.err != nil {
+			return fmt.Errorf("object %d: %w", len(m.Objects), sub.err)
+		}
+		m.Objects = append(m.Objects, obj)
+	}
+	return err
+}
+
+func (m *Map) parsePolygonTriggers(r *reader, version uint16) error {
+	if version < 1 || version > 4 {
+		return errors.New("unsupported version")
+	}
+	n := r.count(10)
+	for i := 0; i < n && r.err == nil; i++ {
+		t := PolygonTrigger{Name: r.asciiString()}
+		if version >= 4 {
+			t.Layer = r.asciiString()
+		}
+		t.ID = int(r.int32())
+		if version >= 2 {
+			t.Water = r.byte() != 0
+		}
+		if version >= 3 {
+			t.River = r.byte() != 0
+			t.RiverStart = int(r.int32())
+		}
+		points := r.count(12)
+		t.Points = make([][]int, 0, points)
+		for j := 0; j < points; j++ {
+			t.Points = append(t.Points, []int{int(r.int32()), int(r.int32()), int(r.int32())})
+		}
+		m.PolygonTriggers = append(m.PolygonTriggers, t)
+	}
+	return r.err
+}
+
+func (m *Map) parseWaypointLinks(r *reader) error {
+	n := r.count(8)
+	for i := 0; i < n; i++ {
+		m.WaypointLinks = append(m.WaypointLinks, WaypointLink{From: int(r.int32()), To: int(r.int32())})
+	}
+	return r.err
+}
+
+// derive fills the waypoint, start position and resource lists from the
+// placed objects.
+func (m *Map) derive() {
+	m.StartPositions = []StartPosition{}
+	m.SupplyDocks = []Object{}
+	m.TechBuildings = []Object{}
+	m.OilDerricks = []Object{}
+	for _, obj := range m.Objects {
+		switch {
+		case obj.Template == WaypointTemplate:
+			wp := Waypoint{Name: obj.Property("waypointName"), X: obj.X, Y: obj.Y}
+			wp.ID, _ = obj.Properties["waypointID"].(int)
+			m.Waypoints = append(m.Waypoints, wp)
+			if match := startWaypoint.FindStringSubmatch(wp.Name); match != nil {
+				player, _ := strconv.Atoi(match[1])
+				m.StartPositions = append(m.StartPositions, StartPosition{Player: player, X: wp.X, Y: wp.Y})
+			}
+		case SupplyTemplates[obj.Template]:
+			m.SupplyDocks = append(m.SupplyDocks, obj)
+		case obj.Template == OilDerrickTemplate:
+			m.OilDerricks = append(m.OilDerricks, obj)
+		case strings.HasPrefix(obj.Template, "Tech"):
+			m.TechBuildings = append(m.TechBuildings, obj)
+		}
+	}
+	sort.Slice(m.StartPositions, func(i, j int) bool {
+		return m.StartPositions[i].Player < m.StartPositions[j].Player
+	})
+}
@@ -0,0 +1,277 @@
+package mapparse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// mapBuilder writes a DataChunk file for tests.
+type mapBuilder struct {
+	names []string
+}
+
+func (b *mapBuilder) id(name string) uint32 {
+	for i, n := range b.names {
+		if n == name {
+			return uint32(i + 1)
+		}
+	}
+	b.names = append(b.names, name)
+	return uint32(len(b.names))
+}
+
+type buf struct{ bytes.Buffer }
+
+func (w *buf) i32(v int32)   { binary.Write(w, binary.LittleEndian, v) }
+func (w *buf) f32(v float32) { binary.Write(w, binary.LittleEndian, math.Float32bits(v)) }
+func (w *buf) str(s string) {
+	binary.Write(w, binary.LittleEndian, uint16(len(s)))
+	w.WriteString(s)
+}
+
+func (b *mapBuilder) chunk(name string, version uint16, data []byte) []byte {
+	var w buf
+	binary.Write(&w, binary.LittleEndian, b.id(name))
+	binary.Write(&w, binary.LittleEndian, version)
+	w.i32(int32(len(data)))
+	w.Write(data)
+	return w.Bytes()
+}
+
+// dict writes a Dict of string and int values.
+func (b *mapBuilder) dict(w *buf, strs map[string]string, ints map[string]int32) {
+	binary.Write(w, binary.LittleEndian, uint16(len(strs)+len(ints)))
+	for k, v := range strs {
+		w.i32(int32(b.id(k)<<8 | dictASCIIString))
+		w.str(v)
+	}
+	for k, v := range ints {
+		w.i32(int32(b.id(k)<<8 | dictInt))
+		w.i32(v)
+	}
+}
+
+func (b *mapBuilder) object(template string, x, y float32, strs map[string]string, ints map[string]int32) []byte {
+	var w buf
+	w.f32(x)
+	w.f32(y)
+	w.f32(5)
+	w.f32(0)
+	w.i32(0)
+	w.str(template)
+	b.dict(&w, strs, ints)
+	return b.chunk("Object", 3, w.Bytes())
+}
+
+// file prepends the CkMp header and name table to the chunks.
+func (b *mapBuilder) file(chunks ...[]byte) []byte {
+	var w buf
+	w.WriteString("CkMp")
+	w.i32(int32(len(b.names)))
+	for i, n := range b.names {
+		w.WriteByte(byte(len(n)))
+		w.WriteString(n)
+		w.i32(int32(i + 1))
+	}
+	for _, c := range chunks {
+		w.Write(c)
+	}
+	return w.Bytes()
+}
+
+func buildTestMap() []byte {
+	b := &mapBuilder{}
+
+	var hm buf
+	hm.i32(6) // width
+	hm.i32(5) // height
+	hm.i32(1) // border
+	hm.i32(1) // one boundary
+	hm.i32(4)
+	hm.i32(3)
+	hm.i32(30)
+	for i := 0; i < 30; i++ {
+		hm.WriteByte(byte(i))
+	}
+	heightChunk := b.chunk("HeightMapData", 4, hm.Bytes())
+
+	var wi buf
+	b.dict(&wi, map[string]string{"mapName": "Test Map"}, nil)
+	worldChunk := b.chunk("WorldInfo", 1, wi.Bytes())
+
+	var objs bytes.Buffer
+	objs.Write(b.object(WaypointTemplate, 10, 10, map[string]string{"waypointName": "Player_2_Start"}, map[string]int32{"waypointID": 2}))
+	objs.Write(b.object(WaypointTemplate, 30, 20, map[string]string{"waypointName": "Player_1_Start"}, map[string]int32{"waypointID": 1}))
+	objs.Write(b.object(WaypointTemplate, 15, 15, map[string]string{"waypointName": "Path1"}, map[string]int32{"waypointID": 3}))
+	objs.Write(b.object("SupplyDock", 20, 20, nil, nil))
+	objs.Write(b.object("TechOilDerrick", 25, 5, nil, nil))
+	objs.Write(b.object("TechHospital", 5, 25, map[string]string{"objectName": "Hospital"}, nil))
+	objs.Write(b.object("AmericaTankCrusader", 1, 1, nil, nil))
+	objectsChunk := b.chunk("ObjectsList", 3, objs.Bytes())
+
+	var pt buf
+	pt.i32(1)
+	pt.str("InnerLake")
+	pt.str("Default")
+	pt.i32(7)
+	pt.WriteByte(1) // water
+	pt.WriteByte(0) // river
+	pt.i32(0)
+	pt.i32(2)
+	for _, v := range []int32{0, 0, 0, 10, 10, 0} {
+		pt.i32(v)
+	}
+	triggerChunk := b.chunk("PolygonTriggers", 4, pt.Bytes())
+
+	var wl buf
+	wl.i32(1)
+	wl.i32(3)
+	wl.i32(1)
+	linksChunk := b.chunk("WaypointsList", 1, wl.Bytes())
+
+	skipped := b.chunk("GlobalLighting", 3, []byte{1, 2, 3})
+	return b.file(heightChunk, worldChunk, skipped, objectsChunk, triggerChunk, linksChunk)
+}
+
+func checkTestMap(t *testing.T, m *Map) {
+	t.Helper()
+	if len(m.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", m.Warnings)
+	}
+	h := m.HeightMap
+	if h == nil || h.Width != 6 || h.Height != 5 || h.Border != 1 || len(h.Boundaries) != 1 {
+		t.Fatalf("unexpected height map: %+v", h)
+	}
+	if m.Width != 40 || m.Height != 30 {
+		t.Errorf("expected 40x30 playable area, got %vx%v", m.Width, m.Height)
+	}
+	if got := h.At(2, 3); got != 20 {
+		t.Errorf("expected sample 20 at (2,3), got %d", got)
+	}
+	// World (10, 20) is cell (1, 2) past the one-cell border: (2, 3).
+	if got := h.HeightAt(10, 20); got != 20*HeightScale {
+		t.Errorf("expected height %v, got %v", 20*HeightScale, got)
+	}
+	if m.WorldInfo["mapName"] != "Test Map" {
+		t.Errorf("unexpected world info: %v", m.WorldInfo)
+	}
+	if len(m.Objects) != 7 || len(m.Waypoints) != 3 {
+		t.Fatalf("expected 7 objects and 3 waypoints, got %d and %d", len(m.Objects), len(m.Waypoints))
+	}
+	if len(m.StartPositions) != 2 || m.StartPositions[0].Player != 1 || m.StartPositions[0].X != 30 {
+		t.Errorf("unexpected start positions: %+v", m.StartPositions)
+	}
+	if len(m.SupplyDocks) != 1 || len(m.OilDerricks) != 1 || len(m.TechBuildings) != 1 {
+		t.Errorf("unexpected resources: %d docks, %d derricks, %d tech", len(m.SupplyDocks), len(m.OilDerricks), len(m.TechBuildings))
+	}
+	if got := m.TechBuildings[0].Property("objectName"); got != "Hospital" {
+		t.Errorf("expected objectName Hospital, got %q", got)
+	}
+	if len(m.PolygonTriggers) != 1 || !m.PolygonTriggers[0].Water || m.PolygonTriggers[0].Layer != "Default" || len(m.PolygonTriggers[0].Points) != 2 {
+		t.Errorf("unexpected triggers: %+v", m.PolygonTriggers)
+	}
+	if len(m.WaypointLinks) != 1 || m.WaypointLinks[0] != (WaypointLink{From: 3, To: 1}) {
+		t.Errorf("unexpected waypoint links: %+v", m.WaypointLinks)
+	}
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse(buildTestMap())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkTestMap(t, m)
+}
+
+// refpackLiterals encodes data as a RefPack stream of literal runs only.
+func refpackLiterals(data []byte) []byte {
+	out := []byte{0x10, 0xFB, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}
+	for len(data) > 3 {
+		n := len(data) &^ 3
+		if n > 112 {
+			n = 112
+		}
+		out = append(out, byte(0xE0|(n/4-1)))
+		out = append(out, data[:n]...)
+		data = data[n:]
+	}
+	out = append(out, byte(0xFC|len(data)))
+	return append(out, data...)
+}
+
+func TestParseCompressed(t *testing.T) {
+	raw := buildTestMap()
+	header := func(magic string) []byte {
+		h := []byte(magic)
+		return binary.LittleEndian.AppendUint32(h, uint32(len(raw)))
+	}
+
+	ear := append(header("EAR\x00"), refpackLiterals(raw)...)
+	m, err := Parse(ear)
+	if err != nil {
+		t.Fatalf("EAR: unexpected error: %v", err)
+	}
+	checkTestMap(t, m)
+
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(raw)
+	zw.Close()
+	m, err = Parse(append(header("ZL5\x00"), z.Bytes()...))
+	if err != nil {
+		t.Fatalf("ZL5: unexpected error: %v", err)
+	}
+	checkTestMap(t, m)
+}
+
+func TestRefPackBackReference(t *testing.T) {
+	// "ABC" as literals with a 6-byte copy from 3 back (overlapping), then
+	// the end command carrying one literal.
+	stream := []byte{0x10, 0xFB, 0, 0, 10, 0x0F, 0x02, 'A', 'B', 'C', 0xFD, 'X'}
+	got, err := refpackDecode(stream)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "ABCABCABCX" {
+		t.Errorf("expected ABCABCABCX, got %q", got)
+	}
+
+	if _, err := refpackDecode(stream[:len(stream)-3]); err == nil {
+		t.Error("expected error for truncated stream")
+	}
+	bad := append([]byte(nil), stream...)
+	bad[6] = 0x20 // offset past start of output
+	if _, err := refpackDecode(bad); err == nil {
+		t.Error("expected error for out-of-range back-reference")
+	}
+}
+
+func TestParseDamagedChunk(t *testing.T) {
+	b := &mapBuilder{}
+	var hm buf
+	hm.i32(4)
+	hm.i32(4)
+	hm.i32(0)
+	hm.i32(0)
+	hm.i32(99) // wrong sample count
+	bad := b.chunk("HeightMapData", 4, hm.Bytes())
+	objs := b.chunk("ObjectsList", 3, b.object("SupplyDock", 1, 2, nil, nil))
+
+	m, err := Parse(b.file(bad, objs))
+	if err != nil {
+		t.Fatalf("a damaged chunk should not fail the map: %v", err)
+	}
+	if m.HeightMap != nil || len(m.Warnings) != 1 {
+		t.Errorf("expected one warning and no height map, got %v", m.Warnings)
+	}
+	if len(m.SupplyDocks) != 1 {
+		t.Errorf("expected objects to parse past the damaged chunk")
+	}
+
+	if _, err := Parse([]byte("not a map file")); err == nil {
+		t.Error("expected error for non-map data")
+	}
+}
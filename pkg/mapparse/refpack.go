@@ -0,0 +1,157 @@
+package mapparse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxDecompressed caps the size a compressed container may claim, so a
+// corrupt header can't make us allocate gigabytes. The largest retail maps
+// decompress to a few megabytes.
+const maxDecompressed = 64 << 20
+
+// decompress unwraps the engine's CompressionManager containers: "EAR\0"
+// (RefPack) and "ZL1\0".."ZL9\0" (zlib), each followed by the uncompressed
+// size as a little-endian uint32. Anything else is returned unchanged.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 8 {
+		return data, nil
+	}
+	magic := string(data[:4])
+	size := binary.LittleEndian.Uint32(data[4:8])
+	switch {
+	case magic == "EAR\x00":
+		if size > maxDecompressed {
+			return nil, fmt.Errorf("mapparse: EAR container claims %d bytes", size)
+		}
+		out, err := refpackDecode(data[8:])
+		if err != nil {
+			return nil, err
+		}
+		if uint32(len(out)) != size {
+			return nil, fmt.Errorf("mapparse: EAR container decoded %d bytes, header says %d", len(out), size)
+		}
+		return out, nil
+	case magic[:2] == "ZL" && magic[2] >= '1' && magic[2] <= '9' && magic[3] == 0:
+		if size > maxDecompressed {
+			return nil, fmt.Errorf("mapparse: zlib container claims %d bytes", size)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data[8:]))
+		if err != nil {
+			return nil, fmt.Errorf("mapparse: zlib container: %w", err)
+		}
+		defer zr.Close()
+		out := make([]byte, size)
+		if _, err := io.ReadFull(zr, out); err != nil {
+			return nil, fmt.Errorf("mapparse: zlib container: %w", err)
+		}
+		return out, nil
+	}
+	return data, nil
+}
+
+var errRefPackTruncated = errors.New("mapparse: truncated RefPack stream")
+
+// refpackDecode decodes an EA RefPack (QFS) stream: a two-byte header
+// (flags, 0xFB), the uncompressed size in 3 or 4 big-endian bytes (with a
+// compressed size before it when flag 0x01 is set), then commands that mix
+// literal bytes with back-references into the output.
+func refpackDecode(src []byte) ([]byte, error) {
+	if len(src) < 2 || src[1] != 0xFB {
+		return nil, errors.New("mapparse: not a RefPack stream")
+	}
+	flags := src[0]
+	sizeBytes := 3
+	if flags&0x80 != 0 {
+		sizeBytes = 4
+	}
+	pos := 2
+	if flags&0x01 != 0 {
+		pos += sizeBytes // compressed size, unused
+	}
+	if len(src) < pos+sizeBytes {
+		return nil, errRefPackTruncated
+	}
+	size := 0
+	for i := 0; i < sizeBytes; i++ {
+		size = size<<8 | int(src[pos+i])
+	}
+	pos += sizeBytes
+	if size > maxDecompressed {
+		return nil, fmt.Errorf("mapparse: RefPack stream claims %d bytes", size)
+	}
+
+	dst := make([]byte, 0, size)
+	for {
+		if pos >= len(src) {
+			return nil, errRefPackTruncated
+		}
+		b0 := int(src[pos])
+		var literal, length, offset, cmdLen int
+		end := false
+		switch {
+		case b0 < 0x80:
+			cmdLen = 2
+			if pos+cmdLen > len(src) {
+				return nil, errRefPackTruncated
+			}
+			b1 := int(src[pos+1])
+			literal = b0 & 0x03
+			length = (b0&0x1C)>>2 + 3
+			offset = (b0&0x60)<<3 + b1 + 1
+		case b0 < 0xC0:
+			cmdLen = 3
+			if pos+cmdLen > len(src) {
+				return nil, errRefPackTruncated
+			}
+			b1, b2 := int(src[pos+1]), int(src[pos+2])
+			literal = b1 >> 6
+			length = b0&0x3F + 4
+			offset = (b1&0x3F)<<8 + b2 + 1
+		case b0 < 0xE0:
+			cmdLen = 4
+			if pos+cmdLen > len(src) {
+				return nil, errRefPackTruncated
+			}
+			b1, b2, b3 := int(src[pos+1]), int(src[pos+2]), int(src[pos+3])
+			literal = b0 & 0x03
+			length = (b0&0x0C)<<6 + b3 + 5
+			offset = (b0&0x10)<<12 + b1<<8 + b2 + 1
+		case b0 < 0xFC:
+			cmdLen = 1
+			literal = (b0&0x1F + 1) << 2
+		default:
+			cmdLen = 1
+			literal = b0 & 0x03
+			end = true
+		}
+		pos += cmdLen
+
+		if pos+literal > len(src) {
+			return nil, errRefPackTruncated
+		}
+		dst = append(dst, src[pos:pos+literal]...)
+		pos += literal
+
+		if length > 0 {
+			if offset > len(dst) {
+				return nil, fmt.Errorf("mapparse: RefPack back-reference %d past start of output", offset)
+			}
+			// Byte by byte: the source may overlap what we're writing.
+			from := len(dst) - offset
+			for i := 0; i < length; i++ {
+				dst = append(dst, dst[from+i])
+			}
+		}
+		if len(dst) > size {
+			return nil, fmt.Errorf("mapparse: RefPack stream overruns its %d byte size", size)
+		}
+		if end {
+			return dst, nil
+		}
+	}
+}
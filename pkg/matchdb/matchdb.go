@@ -0,0 +1,502 @@
+// Package matchdb is an embedded index of every match the server has
+// parsed, kept in a single bbolt file. It remembers what /replay worked
+// out (who played which faction on which team, who won and how) so that
+// profiles, ratings and search don't have to parse replays again.
+//
+// It is an index, not the source of truth: stats, logs and replays live
+// in their repositories, and a match's record links to them by key. The
+// file is local to one server; replicas sharing object storage each keep
+// their own.
+//
+// Buckets:
+//
+//	matches   <match id>                 -> Match as JSON
+//	bySeed    <seed> 0x00 <match id>     -> empty
+//	byTime    <8-byte start> <match id>  -> empty, chronological order
+package matchdb
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/matchid"
+	"github.com/bill-rich/cncstats/pkg/zhreplay"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/body"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/object"
+	bolt "go.etcd.io/bbolt"
+)
+
+const framesPerSecond = 30
+
+// OpeningLength is how many of a player's first build orders are kept as
+// their opening.
+const OpeningLength = 6
+
+var (
+	bucketMatches = []byte("matches")
+	bucketBySeed  = []byte("bySeed")
+	bucketByTime  = []byte("byTime")
+)
+
+// Results of a match for one player; see Match.Result.
+const (
+	ResultWin     = "win"
+	ResultLoss    = "loss"
+	ResultUnknown = "unknown"
+)
+
+// ErrNotFound is returned when no match is recorded under an ID.
+var ErrNotFound = errors.New("matchdb: match not found")
+
+// Player is one slot of a recorded match, observers included.
+type Player struct {
+	// Slot is the player's position in the replay header, from 0.
+	Slot int    `json:"slot"`
+	Name string `json:"name"`
+	// IP is the hex address the header records for humans.
+	IP       string `json:"ip,omitempty"`
+	Human    bool   `json:"human"`
+	Observer bool   `json:"observer,omitempty"`
+	Side     string `json:"side"`
+	Faction  string `json:"faction,omitempty"`
+	Team     int    `json:"team"`
+	Color    string `json:"color,omitempty"`
+	// StartPosition is the map start the player chose in the lobby, from
+	// 1 like the map's Player_N_Start waypoints; 0 for a random start,
+	// since the header doesn't record where those landed.
+	StartPosition int  `json:"startPosition,omitempty"`
+	Win           bool `json:"win"`
+	// Opening is the player's first OpeningLength units, buildings and
+	// upgrades ordered, in order.
+	Opening []string `json:"opening,omitempty"`
+	// Actions counts the player's commands, leaving out selections and
+	// other orders that change nothing in the game; APM is Actions per
+	// minute of the match.
+	Actions int     `json:"actions"`
+	APM     float64 `json:"apm"`
+	// Units, Buildings, Upgrades and Powers are the templates of what the
+	// player built, researched and fired, sorted; see Used.
+	Units     []string `json:"units,omitempty"`
+	Buildings []string `json:"buildings,omitempty"`
+	Upgrades  []string `json:"upgrades,omitempty"`
+	Powers    []string `json:"powers,omitempty"`
+	// Spent is what the player's units, buildings and upgrades cost, as
+	// the parser priced them from the INI data.
+	Spent int `json:"spent"`
+}
+
+// Match is the record of one parsed match.
+type Match struct {
+	// ID is the match's identity (see pkg/matchid) and its key here.
+	ID        string `json:"id"`
+	Seed      string `json:"seed"`
+	MapCRC    string `json:"mapCrc,omitempty"`
+	MapPath   string `json:"mapPath"`
+	StartTime int64  `json:"startTime,omitempty"`
+	// PlayedAt is the recording player's local clock from the header's
+	// SYSTEMTIME. It has no zone, so it is stored as if it were UTC.
+	PlayedAt        time.Time `json:"playedAt"`
+	Version         string    `json:"version"`
+	VersionNumber   int       `json:"versionNumber"`
+	FrameCount      int       `json:"frameCount"`
+	DurationSeconds int       `json:"durationSeconds"`
+	WinMethod       string    `json:"winMethod"`
+	Players         []Player  `json:"players"`
+
+	// Stats, Logs and Replay are the keys the match's files are stored
+	// under in their repositories, empty when none are stored.
+	Stats  string `json:"stats,omitempty"`
+	Logs   string `json:"logs,omitempty"`
+	Replay string `json:"replay,omitempty"`
+	// ReplayHash is the content hash (see pkg/replayfile) of the stored
+	// replay the record was built from.
+	ReplayHash string `json:"replayHash,omitempty"`
+
+	IndexedAt time.Time `json:"indexedAt"`
+}
+
+// FromReplay builds the record of a parsed replay. Players come from the
+// header slots and the summary, which list them in the same order.
+func FromReplay(id matchid.ID, v2 *zhreplay.EnhancedReplayV2) *Match {
+	h := v2.Header
+	m := &Match{
+		ID:              id.String(),
+		Seed:            id.Seed,
+		MapCRC:          id.MapCRC,
+		MapPath:         h.Metadata.MapPath,
+		StartTime:       id.Start,
+		Version:         h.Version,
+		VersionNumber:   h.VersionNumber,
+		FrameCount:      h.FrameCount,
+		DurationSeconds: h.FrameCount / framesPerSecond,
+		WinMethod:       v2.WinMethod,
+	}
+	if h.Year > 0 {
+		m.PlayedAt = time.Date(h.Year, time.Month(h.Month), h.Day, h.Hour, h.Minute, h.Second, h.Millisecond*int(time.Millisecond), time.UTC)
+	}
+	for i, slot := range h.Metadata.Players {
+		p := Player{
+			Slot:  i,
+			Name:  slot.Name,
+			IP:    slot.IP,
+			Human: slot.Type == "H",
+		}
+		if pos, err := strconv.Atoi(slot.StartingPosition); err == nil && pos >= 0 {
+			p.StartPosition = pos + 1
+		}
+		if i < len(v2.Summary) {
+			s := v2.Summary[i]
+			p.Side = s.Side
+			p.Faction = s.Faction
+			p.Team = s.Team
+			p.Color = s.Color
+			p.Win = s.Win
+			p.Observer = s.Side == "Observer"
+			p.Units = slices.Sorted(maps.Keys(s.UnitsCreated))
+			p.Buildings = slices.Sorted(maps.Keys(s.BuildingsBuilt))
+			p.Upgrades = slices.Sorted(maps.Keys(s.UpgradesBuilt))
+			p.Powers = slices.Sorted(maps.Keys(s.PowersUsed))
+			for _, objects := range []map[string]*object.ObjectSummary{s.UnitsCreated, s.BuildingsBuilt, s.UpgradesBuilt} {
+				for _, o := range objects {
+					p.Spent += o.TotalSpent
+				}
+			}
+			if p.Name == "" {
+				p.Name = s.Name
+			}
+		}
+		m.Players = append(m.Players, p)
+	}
+	addActions(m, v2)
+	return m
+}
+
+// addActions fills in each player's opening and action counts from the
+// replay's commands.
+func addActions(m *Match, v2 *zhreplay.EnhancedReplayV2) {
+	for _, chunk := range v2.Body {
+		i := chunk.PlayerID - v2.PlayerIDOffset
+		if i < 0 || i >= len(m.Players) || body.PassiveCommands[chunk.OrderCode] {
+			continue
+		}
+		p := &m.Players[i]
+		p.Actions++
+		switch chunk.OrderCode {
+		case 1045, 1047, 1049: // BuildUpgrade, CreateUnit, BuildObject
+			if chunk.Details != nil && len(p.Opening) < OpeningLength {
+				p.Opening = append(p.Opening, chunk.Details.GetName())
+			}
+		}
+	}
+	if minutes := float64(m.FrameCount) / framesPerSecond / 60; minutes > 0 {
+		for i := range m.Players {
+			m.Players[i].APM = float64(m.Players[i].Actions) / minutes
+		}
+	}
+}
+
+// FactionName returns the player's faction, or their side when the
+// faction isn't known (replays parsed without stats).
+func (p *Player) FactionName() string {
+	return cmp.Or(p.Faction, p.Side)
+}
+
+// Used reports whether the player built, researched or fired the template
+// name, compared case-insensitively.
+func (p *Player) Used(name string) bool {
+	for _, list := range [][]string{p.Units, p.Buildings, p.Upgrades, p.Powers} {
+		for _, t := range list {
+			if strings.EqualFold(t, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Team is one side of a match.
+type Team struct {
+	Players []Player
+	// Win is set if any of the team's players won.
+	Win bool
+}
+
+// Teams groups the match's players, observers left out, by team. Players
+// without a team (0 or -1) each make a team of their own.
+func (m *Match) Teams() []Team {
+	var out []Team
+	byTeam := map[int]int{}
+	for _, p := range m.Players {
+		if p.Observer {
+			continue
+		}
+		if i, ok := byTeam[p.Team]; ok && p.Team > 0 {
+			out[i].Players = append(out[i].Players, p)
+			out[i].Win = out[i].Win || p.Win
+			continue
+		}
+		byTeam[p.Team] = len(out)
+		out = append(out, Team{Players: []Player{p}, Win: p.Win})
+	}
+	return out
+}
+
+// HasAI reports whether a computer player took part.
+func (m *Match) HasAI() bool {
+	for _, p := range m.Players {
+		if !p.Observer && !p.Human {
+			return true
+		}
+	}
+	return false
+}
+
+// Decided reports whether the match has a result: at least two teams that
+// finished differently.
+func (m *Match) Decided() bool {
+	ts := m.Teams()
+	for _, t := range ts {
+		if t.Win != ts[0].Win {
+			return true
+		}
+	}
+	return false
+}
+
+// Result is p's result in m: a win or loss if some team finished
+// differently from p's, else unknown. As in Teams, p's team won if any of
+// its players did.
+func (m *Match) Result(p Player) string {
+	win := p.Win
+	if p.Team > 0 {
+		for _, o := range m.Players {
+			win = win || (!o.Observer && o.Team == p.Team && o.Win)
+		}
+	}
+	for _, t := range m.Teams() {
+		if t.Win != win {
+			if win {
+				return ResultWin
+			}
+			return ResultLoss
+		}
+	}
+	return ResultUnknown
+}
+
+// MapName returns the map's name without its directory, as players know
+// it.
+func (m *Match) MapName() string {
+	return path.Base(strings.ReplaceAll(m.MapPath, "\\", "/"))
+}
+
+// Time returns when the match was played: its start time when known,
+// else PlayedAt. Matches are ordered by it.
+func (m *Match) Time() time.Time {
+	if m.StartTime != 0 {
+		return time.Unix(m.StartTime, 0).UTC()
+	}
+	return m.PlayedAt
+}
+
+func (m *Match) sortTime() int64 {
+	t := m.Time()
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func seedKey(m *Match) []byte {
+	return append(append([]byte(m.Seed), 0), m.ID...)
+}
+
+func timeKey(m *Match) []byte {
+	k := make([]byte, 8, 8+len(m.ID))
+	binary.BigEndian.PutUint64(k, uint64(m.sortTime()))
+	return append(k, m.ID...)
+}
+
+// DB is an open match database.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Open opens the database file at path, creating it if needed. Only one
+// process can have it open; Open gives up after a second if another has.
+func Open(path string) (*DB, error) {
+	b, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("matchdb: open %s: %w", path, err)
+	}
+	err = b.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketMatches, bucketBySeed, bucketByTime} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("matchdb: create buckets: %w", err)
+	}
+	return &DB{bolt: b}, nil
+}
+
+// Close closes the database file.
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// Put records m, replacing an earlier record of the same match. Links the
+// earlier record had and m lacks are kept, as is its IndexedAt.
+func (db *DB) Put(m *Match) error {
+	if m.ID == "" {
+		return errors.New("matchdb.Put: empty match id")
+	}
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		old, err := get(tx, m.ID)
+		switch {
+		case err == nil:
+			m.Stats = cmp.Or(m.Stats, old.Stats)
+			m.Logs = cmp.Or(m.Logs, old.Logs)
+			if m.Replay == "" {
+				m.Replay, m.ReplayHash = old.Replay, old.ReplayHash
+			}
+			m.IndexedAt = old.IndexedAt
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+		if m.IndexedAt.IsZero() {
+			m.IndexedAt = time.Now().UTC()
+		}
+		return put(tx, old, m)
+	})
+}
+
+// Update applies fn to the record of a match and saves it. It returns
+// ErrNotFound if the match was never recorded.
+func (db *DB) Update(id string, fn func(*Match)) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		old, err := get(tx, id)
+		if err != nil {
+			return err
+		}
+		m := *old
+		fn(&m)
+		m.ID = id
+		return put(tx, old, &m)
+	})
+}
+
+// Get returns the record of a match, or ErrNotFound.
+func (db *DB) Get(id string) (*Match, error) {
+	var m *Match
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		var err error
+		m, err = get(tx, id)
+		return err
+	})
+	return m, err
+}
+
+// BySeed returns every match recorded with a seed.
+func (db *DB) BySeed(seed string) ([]*Match, error) {
+	var out []*Match
+	prefix := append([]byte(seed), 0)
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketBySeed).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			m, err := get(tx, string(k[len(prefix):]))
+			if err != nil {
+				return err
+			}
+			out = append(out, m)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Each calls fn for every match in the order they were played, oldest
+// first, stopping at the first error fn returns. fn must not write to
+// the database.
+func (db *DB) Each(fn func(*Match) error) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketByTime).ForEach(func(k, _ []byte) error {
+			m, err := get(tx, string(k[8:]))
+			if err != nil {
+				return err
+			}
+			return fn(m)
+		})
+	})
+}
+
+// Revision returns a number that changes whenever the database is
+// written, so callers can cache what they derive from it.
+func (db *DB) Revision() (uint64, error) {
+	var rev uint64
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		rev = uint64(tx.ID())
+		return nil
+	})
+	return rev, err
+}
+
+// Count returns how many matches are recorded.
+func (db *DB) Count() (int, error) {
+	var n int
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketMatches).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+func get(tx *bolt.Tx, id string) (*Match, error) {
+	data := tx.Bucket(bucketMatches).Get([]byte(id))
+	if data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	var m Match
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("matchdb: decode match %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// put writes m and its index entries, dropping those of old (the record
+// it replaces, or nil).
+func put(tx *bolt.Tx, old, m *Match) error {
+	if old != nil {
+		if err := tx.Bucket(bucketBySeed).Delete(seedKey(old)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketByTime).Delete(timeKey(old)); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketMatches).Put([]byte(m.ID), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketBySeed).Put(seedKey(m), []byte{}); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketByTime).Put(timeKey(m), []byte{})
+}
@@ -0,0 +1,175 @@
+package matchdb
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/matchid"
+	"github.com/bill-rich/cncstats/pkg/zhreplay"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/body"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/header"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/object"
+)
+
+func openTestDB(t *testing.T) (*DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "matches.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+func TestFromReplay(t *testing.T) {
+	h := &header.GeneralsHeader{
+		FrameCount: 54000,
+		Year:       2024, Month: 6, Day: 10, Hour: 20, Minute: 5, Second: 30,
+		Version: "Version 1.04",
+	}
+	h.Metadata.MapPath = "maps/tournament desert"
+	h.Metadata.Players = []header.Player{
+		{Type: "H", Name: "alice", IP: "C0A80001", StartingPosition: "2"},
+		{Type: "C", StartingPosition: "-1"},
+		{Type: "H", Name: "bob", IP: "C0A80002"},
+	}
+	v2 := &zhreplay.EnhancedReplayV2{
+		Header:    h,
+		WinMethod: "deathEvents",
+		Summary: []*zhreplay.PlayerSummaryV2{
+			{Name: "alice", Side: "USA", Team: 1, Win: true,
+				UnitsCreated:   map[string]*object.ObjectSummary{"AmericaVehicleDozer": {Count: 1, TotalSpent: 1000}, "AmericaInfantryRanger": {Count: 2, TotalSpent: 450}},
+				BuildingsBuilt: map[string]*object.ObjectSummary{"AmericaPowerPlant": {Count: 1, TotalSpent: 800}},
+				PowersUsed:     map[string]int{"SuperweaponDaisyCutter": 1},
+			},
+			{Name: "Hard AI", Side: "China", Team: 2, Faction: "China Nuke"},
+			{Name: "bob", Side: "Observer", Team: -1},
+		},
+		PlayerIDOffset: 2,
+		Body: []*body.BodyChunk{
+			{PlayerID: 2, OrderCode: 1001}, // SetSelection, passive
+			{PlayerID: 2, OrderCode: 1049, Details: &object.Building{Name: "AmericaPowerPlant"}},
+			{PlayerID: 2, OrderCode: 1047, Details: &object.Unit{Name: "AmericaVehicleDozer"}},
+			{PlayerID: 3, OrderCode: 1049, Details: &object.Building{Name: "ChinaBarracks"}},
+			{PlayerID: 9, OrderCode: 1049, Details: &object.Building{Name: "Unknown"}},
+		},
+	}
+	id := matchid.ID{Seed: "1", MapCRC: "42", Start: 1718049930}
+
+	m := FromReplay(id, v2)
+	if m.ID != "1-42-1718049930" || m.DurationSeconds != 1800 || m.PlayedAt.Format("2006-01-02 15:04:05") != "2024-06-10 20:05:30" {
+		t.Errorf("unexpected match fields: %+v", m)
+	}
+	if len(m.Players) != 3 {
+		t.Fatalf("expected 3 players, got %+v", m.Players)
+	}
+	alice, ai, bob := m.Players[0], m.Players[1], m.Players[2]
+	if !alice.Human || !alice.Win || alice.IP != "C0A80001" || alice.Team != 1 {
+		t.Errorf("unexpected alice: %+v", alice)
+	}
+	if alice.Actions != 2 || alice.APM != 2.0/30 || len(alice.Opening) != 2 || alice.Opening[0] != "AmericaPowerPlant" {
+		t.Errorf("unexpected alice actions: %d, APM %v, opening %v", alice.Actions, alice.APM, alice.Opening)
+	}
+	if len(alice.Units) != 2 || alice.Units[0] != "AmericaInfantryRanger" || !alice.Used("americapowerplant") || !alice.Used("SuperweaponDaisyCutter") || alice.Used("ChinaBarracks") || alice.Spent != 2250 {
+		t.Errorf("unexpected alice templates: %+v", alice)
+	}
+	if ai.Actions != 1 || len(ai.Opening) != 1 {
+		t.Errorf("unexpected AI actions: %+v", ai)
+	}
+	if alice.StartPosition != 3 || alice.FactionName() != "USA" {
+		t.Errorf("unexpected alice start and faction: %d, %s", alice.StartPosition, alice.FactionName())
+	}
+	if ai.Human || ai.Name != "Hard AI" || ai.Faction != "China Nuke" || ai.Win || ai.StartPosition != 0 {
+		t.Errorf("unexpected AI slot: %+v", ai)
+	}
+	if !bob.Observer || bob.Slot != 2 {
+		t.Errorf("unexpected observer: %+v", bob)
+	}
+	if ts := m.Teams(); len(ts) != 2 || !ts[0].Win || ts[1].Win || !m.Decided() || !m.HasAI() {
+		t.Errorf("unexpected teams: %+v", ts)
+	}
+	if m.Result(alice) != ResultWin || m.Result(ai) != ResultLoss {
+		t.Errorf("unexpected results: %s, %s", m.Result(alice), m.Result(ai))
+	}
+}
+
+func TestResult(t *testing.T) {
+	// A 2v2 where one winner's slot wasn't marked a win, and a draw.
+	m := &Match{Players: []Player{
+		{Name: "a", Team: 1, Win: true},
+		{Name: "b", Team: 1},
+		{Name: "c", Team: 2},
+		{Name: "d", Team: 2},
+		{Name: "obs", Observer: true},
+	}}
+	for i, want := range []string{ResultWin, ResultWin, ResultLoss, ResultLoss} {
+		if got := m.Result(m.Players[i]); got != want {
+			t.Errorf("%s: expected %s, got %s", m.Players[i].Name, want, got)
+		}
+	}
+	m.Players[0].Win = false
+	if got := m.Result(m.Players[0]); got != ResultUnknown {
+		t.Errorf("expected a game without a result, got %s", got)
+	}
+}
+
+func TestDB(t *testing.T) {
+	db, path := openTestDB(t)
+
+	late := &Match{ID: "1-42-2000", Seed: "1", StartTime: 2000, WinMethod: "lastCommand"}
+	early := &Match{ID: "1-77-1000", Seed: "1", StartTime: 1000, Stats: "1-77-1000", Replay: "1-77-1000", ReplayHash: "ab12"}
+	other := &Match{ID: "2-42-1500", Seed: "2", StartTime: 1500}
+	for _, m := range []*Match{late, early, other} {
+		if err := db.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.Get("3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if n, _ := db.Count(); n != 3 {
+		t.Errorf("expected 3 matches, got %d", n)
+	}
+	if got, _ := db.BySeed("1"); len(got) != 2 {
+		t.Errorf("expected 2 matches for seed 1, got %+v", got)
+	}
+
+	var order []string
+	db.Each(func(m *Match) error {
+		order = append(order, m.ID)
+		return nil
+	})
+	if len(order) != 3 || order[0] != early.ID || order[1] != other.ID || order[2] != late.ID {
+		t.Errorf("expected chronological order, got %v", order)
+	}
+
+	// Parsing another player's replay of the same match keeps its links.
+	if err := db.Put(&Match{ID: early.ID, Seed: "1", StartTime: 1000, WinMethod: "deathEvents"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.Get(early.ID)
+	if err != nil || got.Stats != early.Stats || got.ReplayHash != early.ReplayHash || got.WinMethod != "deathEvents" || !got.IndexedAt.Equal(early.IndexedAt) {
+		t.Errorf("expected the re-parse to keep links and first indexing time, got %+v, %v", got, err)
+	}
+
+	if err := db.Update(late.ID, func(m *Match) { m.Logs = late.ID }); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update("missing", func(*Match) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound updating an unknown match, got %v", err)
+	}
+
+	// Records survive reopening.
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if got, err := db.Get(late.ID); err != nil || got.Logs != late.ID {
+		t.Errorf("expected the logs link after reopening, got %+v, %v", got, err)
+	}
+}
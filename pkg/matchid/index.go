@@ -0,0 +1,150 @@
+package matchid
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+// AmbiguousError is returned by Lookup when a seed alone names more than
+// one stored match. Candidates lists them so the caller can ask again
+// with the map CRC or start time.
+type AmbiguousError struct {
+	Candidates []ID
+}
+
+func (e *AmbiguousError) Error() string {
+	ids := make([]string, len(e.Candidates))
+	for i, id := range e.Candidates {
+		ids[i] = id.String()
+	}
+	return "matchid: ambiguous match, candidates " + strings.Join(ids, ", ")
+}
+
+// Index records the identities stored for each seed, one object per seed
+// named "<seed>.json" listing them in the order they were first seen.
+// Bare IDs are never recorded: they are stored under the seed itself.
+type Index struct {
+	backend storage.Backend
+
+	// mu serializes Resolve's read-modify-write.
+	mu sync.Mutex
+}
+
+// NewIndex returns an Index that keeps its records in b.
+func NewIndex(b storage.Backend) *Index {
+	return &Index{backend: b}
+}
+
+func indexKey(seed string) string {
+	return seed + ".json"
+}
+
+// List returns every identity recorded for a seed, oldest first.
+func (x *Index) List(seed string) ([]ID, error) {
+	data, err := storage.ReadAll(x.backend, indexKey(seed))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read match index: %w", err)
+	}
+	var ids []ID
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("parse match index for seed %s: %w", seed, err)
+	}
+	return ids, nil
+}
+
+// Find returns the recorded identities id Matches. When id has a start
+// time they are ordered by how close theirs is, nearest first; otherwise
+// oldest first.
+func (x *Index) Find(id ID) ([]ID, error) {
+	ids, err := x.List(id.Seed)
+	if err != nil {
+		return nil, err
+	}
+	var out []ID
+	for _, c := range ids {
+		if id.Matches(c) {
+			out = append(out, c)
+		}
+	}
+	if id.Start != 0 {
+		sort.SliceStable(out, func(i, j int) bool {
+			if (out[i].Start == 0) != (out[j].Start == 0) {
+				return out[i].Start != 0
+			}
+			return startDistance(id, out[i]) < startDistance(id, out[j])
+		})
+	}
+	return out, nil
+}
+
+// Resolve returns the identity to store an upload under: the closest
+// recorded match, or id itself, recorded, if this is the first upload of
+// its match. Bare IDs are returned unchanged.
+func (x *Index) Resolve(id ID) (ID, error) {
+	if id.Bare() {
+		return id, nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	found, err := x.Find(id)
+	if err != nil {
+		return ID{}, err
+	}
+	if len(found) > 0 {
+		return found[0], nil
+	}
+	ids, err := x.List(id.Seed)
+	if err != nil {
+		return ID{}, err
+	}
+	b, err := json.Marshal(append(ids, id))
+	if err != nil {
+		return ID{}, err
+	}
+	if err := storage.PutBytes(x.backend, indexKey(id.Seed), b); err != nil {
+		return ID{}, fmt.Errorf("write match index: %w", err)
+	}
+	return id, nil
+}
+
+// Lookup returns the identity a match's files are stored under, without
+// recording anything. With no recorded match it returns the bare seed,
+// where files uploaded without an identity live. When several recorded
+// matches fit and id has no start time to choose between them, the error
+// is an *AmbiguousError.
+func (x *Index) Lookup(id ID) (ID, error) {
+	found, err := x.Find(id)
+	if err != nil {
+		return ID{}, err
+	}
+	switch {
+	case len(found) == 0:
+		return ID{Seed: id.Seed}, nil
+	case len(found) == 1 || id.Start != 0:
+		return found[0], nil
+	}
+	return ID{}, &AmbiguousError{Candidates: found}
+}
+
+// StoredKey returns the key found's files are stored under, where exists
+// reports whether a key holds any. Files uploaded without a map CRC or
+// start time (older exporters, log uploaders that send neither) stay under
+// the bare seed after an identified match is recorded for it, so the bare
+// seed is used when only it has files.
+func StoredKey(found ID, exists func(key string) bool) string {
+	key := found.String()
+	if !found.Bare() && !exists(key) && exists(found.Seed) {
+		return found.Seed
+	}
+	return key
+}
@@ -0,0 +1,133 @@
+// Package matchid identifies a match by more than its seed. Seeds are
+// 32-bit values the host picks, so unrelated games can share one, and
+// anyone can upload under any seed; the seed together with the map CRC
+// and the time the game started tells them apart.
+//
+// Every player records the start time with their own clock, so two
+// uploads of the same match can disagree on it by a little. Index keeps
+// one canonical ID per match and resolves an upload's ID to it.
+package matchid
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/zhreplay/header"
+)
+
+// StartTolerance is how far apart two start times may be and still belong
+// to the same match, allowing for skew between the players' clocks.
+const StartTolerance = 10 * time.Minute
+
+// ID identifies one match. MapCRC and Start are optional: files stored
+// before identities existed, and uploads from exporters that don't send
+// them, have only a seed.
+type ID struct {
+	Seed string `json:"seed"`
+	// MapCRC is the map CRC in decimal, as pkg/mapfile keys maps.
+	MapCRC string `json:"mapCrc,omitempty"`
+	// Start is when the game started, in Unix seconds.
+	Start int64 `json:"start,omitempty"`
+}
+
+// New builds an ID, normalizing the CRC to decimal without leading zeros.
+// mapCRC may be empty and start 0 when unknown.
+func New(seed, mapCRC string, start int64) (ID, error) {
+	if seed == "" {
+		return ID{}, errors.New("matchid: empty seed")
+	}
+	if strings.ContainsAny(seed, "-/\\") {
+		return ID{}, fmt.Errorf("matchid: invalid seed %q", seed)
+	}
+	if start < 0 {
+		return ID{}, fmt.Errorf("matchid: negative start time %d", start)
+	}
+	id := ID{Seed: seed, Start: start}
+	if mapCRC != "" {
+		crc, err := strconv.ParseUint(strings.TrimSpace(mapCRC), 10, 32)
+		if err != nil {
+			return ID{}, fmt.Errorf("matchid: bad map CRC %q: %w", mapCRC, err)
+		}
+		id.MapCRC = strconv.FormatUint(crc, 10)
+	}
+	return id, nil
+}
+
+// FromHeader returns the ID a replay was recorded under. The header keeps
+// the map CRC in hex; it is converted to decimal. Parts the header
+// doesn't have are left unknown.
+func FromHeader(h *header.GeneralsHeader) ID {
+	id := ID{Seed: h.Metadata.Seed}
+	if crc, err := strconv.ParseUint(strings.TrimSpace(h.Metadata.MapCRC), 16, 32); err == nil {
+		id.MapCRC = strconv.FormatUint(crc, 10)
+	}
+	if h.TimeStampBegin > 0 {
+		id.Start = int64(h.TimeStampBegin)
+	}
+	return id
+}
+
+// Parse reads an ID in the form String writes.
+func Parse(s string) (ID, error) {
+	parts := strings.Split(s, "-")
+	switch len(parts) {
+	case 1:
+		return New(s, "", 0)
+	case 3:
+		var start int64
+		if parts[2] != "" {
+			var err error
+			if start, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+				return ID{}, fmt.Errorf("matchid: bad start time in %q: %w", s, err)
+			}
+		}
+		return New(parts[0], parts[1], start)
+	}
+	return ID{}, fmt.Errorf("matchid: malformed match id %q", s)
+}
+
+// String returns "<seed>-<crc>-<start>", with unknown parts empty, or just
+// the seed when nothing else is known. Stores use it as the match's key,
+// so files kept under a bare seed need no migration.
+func (id ID) String() string {
+	if id.Bare() {
+		return id.Seed
+	}
+	start := ""
+	if id.Start != 0 {
+		start = strconv.FormatInt(id.Start, 10)
+	}
+	return id.Seed + "-" + id.MapCRC + "-" + start
+}
+
+// Bare reports whether only the seed is known.
+func (id ID) Bare() bool {
+	return id.MapCRC == "" && id.Start == 0
+}
+
+// Matches reports whether id and o can be the same match: the seeds are
+// equal, the map CRCs are equal if both are known, and the start times are
+// within StartTolerance if both are known.
+func (id ID) Matches(o ID) bool {
+	if id.Seed != o.Seed {
+		return false
+	}
+	if id.MapCRC != "" && o.MapCRC != "" && id.MapCRC != o.MapCRC {
+		return false
+	}
+	if id.Start != 0 && o.Start != 0 && startDistance(id, o) > StartTolerance {
+		return false
+	}
+	return true
+}
+
+func startDistance(a, b ID) time.Duration {
+	d := time.Duration(a.Start-b.Start) * time.Second
+	if d < 0 {
+		return -d
+	}
+	return d
+}
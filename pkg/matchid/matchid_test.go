@@ -0,0 +1,135 @@
+package matchid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/header"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ID
+		err  bool
+	}{
+		{"12345", ID{Seed: "12345"}, false},
+		{"12345-3862104857-1718000000", ID{Seed: "12345", MapCRC: "3862104857", Start: 1718000000}, false},
+		{"12345--1718000000", ID{Seed: "12345", Start: 1718000000}, false},
+		{"12345-0042-", ID{Seed: "12345", MapCRC: "42"}, false},
+		{"", ID{}, true},
+		{"12345-42", ID{}, true},
+		{"12345-x-1", ID{}, true},
+		{"12345-42-x", ID{}, true},
+		{"12345-99999999999-1", ID{}, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+		if err == nil && tt.in != "12345-0042-" && got.String() != tt.in {
+			t.Errorf("Parse(%q).String() = %q", tt.in, got.String())
+		}
+	}
+	if _, err := New("1/2", "", 0); err == nil {
+		t.Error("expected a seed with a path separator to be rejected")
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	h := &header.GeneralsHeader{TimeStampBegin: 1718000000}
+	h.Metadata.Seed = "12345"
+	h.Metadata.MapCRC = "E6334219"
+	if got, want := FromHeader(h), (ID{Seed: "12345", MapCRC: "3862118937", Start: 1718000000}); got != want {
+		t.Errorf("FromHeader = %+v, want %+v", got, want)
+	}
+	h.Metadata.MapCRC = ""
+	h.TimeStampBegin = 0
+	if got := FromHeader(h); !got.Bare() {
+		t.Errorf("expected a bare ID without CRC or start, got %+v", got)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	id := ID{Seed: "1", MapCRC: "42", Start: 1000}
+	tests := []struct {
+		other ID
+		want  bool
+	}{
+		{ID{Seed: "1", MapCRC: "42", Start: 1000 + 120}, true},
+		{ID{Seed: "1", MapCRC: "42", Start: 1000 - 600}, true},
+		{ID{Seed: "1", MapCRC: "42", Start: 1000 + 601}, false},
+		{ID{Seed: "1", MapCRC: "43", Start: 1000}, false},
+		{ID{Seed: "2", MapCRC: "42", Start: 1000}, false},
+		{ID{Seed: "1"}, true},
+		{ID{Seed: "1", MapCRC: "42"}, true},
+	}
+	for _, tt := range tests {
+		if got := id.Matches(tt.other); got != tt.want {
+			t.Errorf("%v.Matches(%v) = %v, want %v", id, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	x := NewIndex(storage.NewFS(t.TempDir()))
+
+	bare := ID{Seed: "1"}
+	if got, err := x.Resolve(bare); err != nil || got != bare {
+		t.Fatalf("expected a bare ID to resolve to itself, got %v, %v", got, err)
+	}
+	if ids, _ := x.List("1"); len(ids) != 0 {
+		t.Errorf("expected bare IDs not to be recorded, got %v", ids)
+	}
+
+	// Two players of one match, their clocks a minute apart, and an
+	// unrelated game on another map that drew the same seed.
+	first := ID{Seed: "1", MapCRC: "42", Start: 1000}
+	if got, err := x.Resolve(first); err != nil || got != first {
+		t.Fatalf("expected the first upload to be recorded as is, got %v, %v", got, err)
+	}
+	if got, _ := x.Resolve(ID{Seed: "1", MapCRC: "42", Start: 1060}); got != first {
+		t.Errorf("expected the second player's upload to resolve to %v, got %v", first, got)
+	}
+	other := ID{Seed: "1", MapCRC: "77", Start: 1030}
+	if got, _ := x.Resolve(other); got != other {
+		t.Errorf("expected a different map to be a new match, got %v", got)
+	}
+	if ids, _ := x.List("1"); len(ids) != 2 {
+		t.Errorf("expected two recorded matches, got %v", ids)
+	}
+
+	if got, err := x.Lookup(ID{Seed: "1", MapCRC: "77", Start: 1000}); err != nil || got != other {
+		t.Errorf("expected the replay's own map to pick %v, got %v, %v", other, got, err)
+	}
+	if got, err := x.Lookup(ID{Seed: "1", Start: 1030}); err != nil || got != other {
+		t.Errorf("expected the nearest start to win, got %v, %v", got, err)
+	}
+	var ambiguous *AmbiguousError
+	if _, err := x.Lookup(ID{Seed: "1"}); !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+		t.Errorf("expected a bare seed to be ambiguous, got %v", err)
+	}
+	if got, err := x.Lookup(ID{Seed: "2", MapCRC: "42", Start: 1000}); err != nil || got != (ID{Seed: "2"}) {
+		t.Errorf("expected an unknown match to fall back to its bare seed, got %v, %v", got, err)
+	}
+}
+
+func TestStoredKey(t *testing.T) {
+	found := ID{Seed: "1", MapCRC: "42", Start: 1000}
+	stored := map[string]bool{}
+	exists := func(key string) bool { return stored[key] }
+
+	if got := StoredKey(found, exists); got != found.String() {
+		t.Errorf("expected the identified key when nothing is stored, got %q", got)
+	}
+	stored["1"] = true
+	if got := StoredKey(found, exists); got != "1" {
+		t.Errorf("expected files under the bare seed to be found, got %q", got)
+	}
+	stored[found.String()] = true
+	if got := StoredKey(found, exists); got != found.String() {
+		t.Errorf("expected the identified key to win, got %q", got)
+	}
+}
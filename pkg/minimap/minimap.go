@@ -0,0 +1,352 @@
+// Package minimap renders a top-down image of a map's terrain from its
+// decoded height map, with replay and stats events drawn on top: building
+// placements, units built, start positions and a kill heatmap.
+package minimap
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/bill-rich/cncstats/pkg/mapparse"
+)
+
+// Marker kinds.
+const (
+	// MarkerBuilding is a structure placement (BuildObject command).
+	MarkerBuilding = "building"
+	// MarkerBuild is a stats BuildEvent: a unit or structure finished.
+	MarkerBuild = "build"
+	// MarkerKill is where something died; Player is the killer.
+	MarkerKill = "kill"
+	// MarkerStart is a start position; Player is 0 when nobody took it.
+	MarkerStart = "start"
+)
+
+// Marker is one event placed on the map. X and Y are world coordinates
+// with the origin at the south-west corner of the playable area. Player is
+// the stats player index (1-based); 0 means neutral.
+type Marker struct {
+	Kind   string  `json:"kind"`
+	Player int     `json:"player"`
+	Frame  uint    `json:"frame"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+}
+
+// DefaultWidth and MaxWidth bound Options.Width.
+const (
+	DefaultWidth = 512
+	MaxWidth     = 2048
+)
+
+// Options controls Render.
+type Options struct {
+	// Width of the image in pixels; the height follows the map's aspect
+	// ratio. 0 means DefaultWidth.
+	Width int
+	// FromFrame and ToFrame limit event markers to a frame range. ToFrame 0
+	// means the end of the game. Start positions are always drawn.
+	FromFrame uint
+	ToFrame   uint
+	// Heatmap draws kills as a density map instead of individual marks.
+	Heatmap bool
+	// Colors maps player index to marker color; players without one get a
+	// color from a fixed palette.
+	Colors map[int]color.NRGBA
+}
+
+// Terrain colors, low to high ground, and the fill for water areas.
+var (
+	lowGround  = color.NRGBA{0x4A, 0x5D, 0x32, 0xFF}
+	highGround = color.NRGBA{0xC8, 0xB8, 0x8A, 0xFF}
+	water      = color.NRGBA{0x2E, 0x5A, 0x88, 0xFF}
+	neutral    = color.NRGBA{0xE0, 0xE0, 0xE0, 0xFF}
+)
+
+// fallbackPalette colors players whose color couldn't be resolved, in the
+// retail MultiplayerColor order.
+var fallbackPalette = []color.NRGBA{
+	{0xDD, 0xE2, 0x0D, 0xFF}, // gold
+	{0xFF, 0x00, 0x00, 0xFF}, // red
+	{0x00, 0x00, 0xFF, 0xFF}, // blue
+	{0x00, 0xFF, 0x00, 0xFF}, // green
+	{0xFF, 0x99, 0x00, 0xFF}, // orange
+	{0x00, 0xFF, 0xFF, 0xFF}, // cyan
+	{0xFF, 0x00, 0xFF, 0xFF}, // purple
+	{0xFF, 0x99, 0xCC, 0xFF}, // pink
+}
+
+// Render draws the map's playable area and the markers that fall inside
+// opts' frame range. Maps without a height map render as flat ground.
+func Render(m *mapparse.Map, markers []Marker, opts Options) *image.NRGBA {
+	width := opts.Width
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	if width > MaxWidth {
+		width = MaxWidth
+	}
+	worldW, worldH := m.Width, m.Height
+	if worldW <= 0 || worldH <= 0 {
+		worldW, worldH = 1, 1
+	}
+	height := int(math.Round(float64(width) * worldH / worldW))
+	if height < 1 {
+		height = 1
+	}
+	c := &canvas{
+		img:   image.NewNRGBA(image.Rect(0, 0, width, height)),
+		scale: float64(width) / worldW,
+		h:     height,
+	}
+
+	c.terrain(m)
+	c.water(m)
+
+	var kills []Marker
+	for _, mk := range markers {
+		if mk.Kind != MarkerStart && !inRange(mk.Frame, opts) {
+			continue
+		}
+		col := playerColor(mk.Player, opts.Colors)
+		x, y := c.toPixel(mk.X, mk.Y)
+		r := c.radius(8)
+		switch mk.Kind {
+		case MarkerStart:
+			c.ring(x, y, c.radius(40), r/2+1, col)
+		case MarkerBuilding:
+			c.square(x, y, r, color.NRGBA{0, 0, 0, 0xFF})
+			c.square(x, y, r-1, col)
+		case MarkerBuild:
+			c.disc(x, y, r/2+1, col)
+		case MarkerKill:
+			if opts.Heatmap {
+				kills = append(kills, mk)
+				continue
+			}
+			c.cross(x, y, r/2+1, col)
+		}
+	}
+	if len(kills) > 0 {
+		c.heatmap(kills)
+	}
+	return c.img
+}
+
+// StartMarkers returns one MarkerStart per start position on the map.
+// owners maps a start position number (the N in Player_N_Start) to the
+// player who started there; positions missing from it are drawn neutral.
+func StartMarkers(m *mapparse.Map, owners map[int]int) []Marker {
+	out := make([]Marker, 0, len(m.StartPositions))
+	for _, sp := range m.StartPositions {
+		out = append(out, Marker{Kind: MarkerStart, Player: owners[sp.Player], X: sp.X, Y: sp.Y})
+	}
+	return out
+}
+
+func inRange(frame uint, opts Options) bool {
+	if frame < opts.FromFrame {
+		return false
+	}
+	return opts.ToFrame == 0 || frame <= opts.ToFrame
+}
+
+func playerColor(player int, colors map[int]color.NRGBA) color.NRGBA {
+	if player <= 0 {
+		return neutral
+	}
+	if c, ok := colors[player]; ok {
+		return c
+	}
+	return fallbackPalette[(player-1)%len(fallbackPalette)]
+}
+
+// canvas is the image being drawn plus the world-to-pixel transform.
+type canvas struct {
+	img   *image.NRGBA
+	scale float64 // pixels per world unit
+	h     int
+}
+
+// toPixel maps world coordinates to image pixels. World y grows north, so
+// it is flipped to put north at the top.
+func (c *canvas) toPixel(x, y float64) (int, int) {
+	return int(x * c.scale), c.h - 1 - int(y*c.scale)
+}
+
+func (c *canvas) toWorld(px, py int) (float64, float64) {
+	return (float64(px) + 0.5) / c.scale, (float64(c.h-1-py) + 0.5) / c.scale
+}
+
+// radius converts a size in world units to pixels, at least 1.
+func (c *canvas) radius(world float64) int {
+	r := int(world * c.scale)
+	if r < 1 {
+		return 1
+	}
+	return r
+}
+
+// terrain colors every pixel by height, shaded by the slope toward the
+// north-west so ridges and cliffs read as relief.
+func (c *canvas) terrain(m *mapparse.Map) {
+	hm := m.HeightMap
+	minH, maxH := 255.0, 0.0
+	if hm != nil {
+		for _, s := range hm.Data {
+			minH = math.Min(minH, float64(s))
+			maxH = math.Max(maxH, float64(s))
+		}
+	}
+	span := maxH - minH
+	if span <= 0 {
+		span = 1
+	}
+	b := c.img.Bounds()
+	for py := 0; py < b.Dy(); py++ {
+		for px := 0; px < b.Dx(); px++ {
+			wx, wy := c.toWorld(px, py)
+			h := hm.HeightAt(wx, wy) / mapparse.HeightScale
+			t := 0.0
+			if hm != nil {
+				t = (h - minH) / span
+			}
+			col := lerp(lowGround, highGround, t)
+			if hm != nil {
+				slope := hm.HeightAt(wx-mapparse.CellSize, wy+mapparse.CellSize) - hm.HeightAt(wx, wy)
+				col = shade(col, 1-math.Max(-0.35, math.Min(0.35, slope/40)))
+			}
+			c.img.SetNRGBA(px, py, col)
+		}
+	}
+}
+
+// water fills the map's water polygon triggers.
+func (c *canvas) water(m *mapparse.Map) {
+	b := c.img.Bounds()
+	for _, pt := range m.PolygonTriggers {
+		if !pt.Water || len(pt.Points) < 3 {
+			continue
+		}
+		minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, p := range pt.Points {
+			minX, maxX = math.Min(minX, float64(p[0])), math.Max(maxX, float64(p[0]))
+			minY, maxY = math.Min(minY, float64(p[1])), math.Max(maxY, float64(p[1]))
+		}
+		x0, y1 := c.toPixel(minX, minY)
+		x1, y0 := c.toPixel(maxX, maxY)
+		for py := max(y0, 0); py <= min(y1, b.Dy()-1); py++ {
+			for px := max(x0, 0); px <= min(x1, b.Dx()-1); px++ {
+				wx, wy := c.toWorld(px, py)
+				if insidePolygon(pt.Points, wx, wy) {
+					c.blend(px, py, water, 0.8)
+				}
+			}
+		}
+	}
+}
+
+// insidePolygon is the even-odd rule over the polygon's x/y.
+func insidePolygon(points [][]int, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		xi, yi := float64(points[i][0]), float64(points[i][1])
+		xj, yj := float64(points[j][0]), float64(points[j][1])
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// heatmap splats each kill as a smooth falloff, then colors the density
+// from transparent through yellow to red.
+func (c *canvas) heatmap(kills []Marker) {
+	b := c.img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	density := make([]float64, w*h)
+	r := c.radius(60)
+	peak := 0.0
+	for _, k := range kills {
+		cx, cy := c.toPixel(k.X, k.Y)
+		for y := max(cy-r, 0); y <= min(cy+r, h-1); y++ {
+			for x := max(cx-r, 0); x <= min(cx+r, w-1); x++ {
+				d := math.Hypot(float64(x-cx), float64(y-cy)) / float64(r)
+				if d >= 1 {
+					continue
+				}
+				v := &density[y*w+x]
+				*v += (1 - d*d) * (1 - d*d)
+				peak = math.Max(peak, *v)
+			}
+		}
+	}
+	if peak == 0 {
+		return
+	}
+	yellow := color.NRGBA{0xFF, 0xE0, 0x30, 0xFF}
+	red := color.NRGBA{0xD0, 0x10, 0x10, 0xFF}
+	for i, v := range density {
+		if v == 0 {
+			continue
+		}
+		t := v / peak
+		c.blend(i%w, i/w, lerp(yellow, red, t), 0.25+0.6*t)
+	}
+}
+
+func (c *canvas) blend(x, y int, col color.NRGBA, alpha float64) {
+	if !(image.Point{x, y}).In(c.img.Rect) {
+		return
+	}
+	c.img.SetNRGBA(x, y, lerp(c.img.NRGBAAt(x, y), col, alpha))
+}
+
+func (c *canvas) square(cx, cy, r int, col color.NRGBA) {
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			c.blend(x, y, col, 1)
+		}
+	}
+}
+
+func (c *canvas) disc(cx, cy, r int, col color.NRGBA) {
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+				c.blend(x, y, col, 1)
+			}
+		}
+	}
+}
+
+func (c *canvas) ring(cx, cy, r, thickness int, col color.NRGBA) {
+	inner := r - thickness
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			d := (x-cx)*(x-cx) + (y-cy)*(y-cy)
+			if d <= r*r && d > inner*inner {
+				c.blend(x, y, col, 1)
+			}
+		}
+	}
+}
+
+func (c *canvas) cross(cx, cy, r int, col color.NRGBA) {
+	for i := -r; i <= r; i++ {
+		c.blend(cx+i, cy+i, col, 1)
+		c.blend(cx+i, cy-i, col, 1)
+	}
+}
+
+func lerp(a, b color.NRGBA, t float64) color.NRGBA {
+	t = math.Max(0, math.Min(1, t))
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*t + 0.5) }
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xFF}
+}
+
+func shade(c color.NRGBA, f float64) color.NRGBA {
+	s := func(v uint8) uint8 { return uint8(math.Max(0, math.Min(255, float64(v)*f))) }
+	return color.NRGBA{s(c.R), s(c.G), s(c.B), c.A}
+}
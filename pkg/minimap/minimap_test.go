@@ -0,0 +1,137 @@
+package minimap
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/iniparse"
+	"github.com/bill-rich/cncstats/pkg/mapparse"
+	"github.com/bill-rich/cncstats/pkg/statsfile"
+	"github.com/bill-rich/cncstats/pkg/zhreplay"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/body"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/header"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/object"
+)
+
+func flatMap(size int) *mapparse.Map {
+	cells := size / int(mapparse.CellSize)
+	return &mapparse.Map{
+		HeightMap: &mapparse.HeightMap{Width: cells, Height: cells, Data: make([]byte, cells*cells)},
+		Width:     float64(size),
+		Height:    float64(size),
+	}
+}
+
+func TestRenderMarkers(t *testing.T) {
+	m := flatMap(1000)
+	m.StartPositions = []mapparse.StartPosition{{Player: 1, X: 100, Y: 100}}
+	red := color.NRGBA{0xFF, 0, 0, 0xFF}
+	markers := append(StartMarkers(m, map[int]int{1: 1}),
+		Marker{Kind: MarkerBuilding, Player: 1, Frame: 100, X: 500, Y: 900},
+		Marker{Kind: MarkerBuilding, Player: 1, Frame: 5000, X: 500, Y: 100},
+	)
+	img := Render(m, markers, Options{Width: 100, ToFrame: 1000, Colors: map[int]color.NRGBA{1: red}})
+
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 100 {
+		t.Fatalf("expected 100x100, got %v", b)
+	}
+	// World y=900 is near the top of the image.
+	if got := img.NRGBAAt(50, 9); got != red {
+		t.Errorf("expected building marker at (50,9), got %v", got)
+	}
+	// The second building is outside the frame range.
+	if got := img.NRGBAAt(50, 89); got == red {
+		t.Error("expected marker past ToFrame to be skipped")
+	}
+	// The start ring is drawn 4 pixels (40 world units) out from its center.
+	if got := img.NRGBAAt(10+4, 89); got != red {
+		t.Errorf("expected start ring at (14,89), got %v", got)
+	}
+}
+
+func TestRenderHeatmapAndWater(t *testing.T) {
+	m := flatMap(1000)
+	m.PolygonTriggers = []mapparse.PolygonTrigger{{
+		Water:  true,
+		Points: [][]int{{0, 0, 0}, {300, 0, 0}, {300, 300, 0}, {0, 300, 0}},
+	}}
+	kills := []Marker{
+		{Kind: MarkerKill, Player: 2, X: 700, Y: 700},
+		{Kind: MarkerKill, Player: 2, X: 700, Y: 700},
+	}
+	plain := Render(m, nil, Options{Width: 100})
+	img := Render(m, kills, Options{Width: 100, Heatmap: true})
+
+	if got, ground := img.NRGBAAt(5, 95), plain.NRGBAAt(50, 50); got.B <= ground.B {
+		t.Errorf("expected water to be bluer than ground, got %v vs %v", got, ground)
+	}
+	hot := img.NRGBAAt(70, 29)
+	if hot.R <= plain.NRGBAAt(70, 29).R || hot.B >= plain.NRGBAAt(70, 29).B {
+		t.Errorf("expected heat at the kill site, got %v", hot)
+	}
+	if img.NRGBAAt(90, 50) != plain.NRGBAAt(90, 50) {
+		t.Error("expected no heat away from the kills")
+	}
+}
+
+func TestStatsMarkers(t *testing.T) {
+	stats := &statsfile.GameStats{
+		BuildEvents: []statsfile.BuildEvent{{Frame: 10, Player: 1, X: 1, Y: 2}},
+		KillEvents:  []statsfile.KillEvent{{Frame: 20, KillerPlayer: 2, VictimPlayer: 1, X: 3, Y: 4}},
+	}
+	got := StatsMarkers(stats)
+	want := []Marker{
+		{Kind: MarkerBuild, Player: 1, Frame: 10, X: 1, Y: 2},
+		{Kind: MarkerKill, Player: 2, Frame: 20, X: 3, Y: 4},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestReplayMarkers(t *testing.T) {
+	r := &zhreplay.Replay{
+		PlayerIDOffset: 2,
+		Header: &header.GeneralsHeader{Metadata: header.Metadata{Players: []header.Player{
+			{Color: "-1", StartingPosition: "-1", PlayerTemplate: "-2"},
+			{Color: "1", StartingPosition: "2"},
+		}}},
+		Summary: []*object.PlayerSummary{{Side: "Observer"}, {}},
+		Body: []*body.BodyChunk{
+			{OrderCode: 1049, PlayerID: 3, TimeCode: 300, Arguments: []interface{}{5, body.Position3D{X: 10, Y: 20}, 0.5}},
+			{OrderCode: 1047, PlayerID: 3, Arguments: []interface{}{5}},
+		},
+	}
+	got := ReplayMarkers(r)
+	if len(got) != 1 || got[0] != (Marker{Kind: MarkerBuilding, Player: 1, Frame: 300, X: 10, Y: 20}) {
+		t.Errorf("unexpected markers: %v", got)
+	}
+	if owners := ReplayStartOwners(r); len(owners) != 1 || owners[3] != 1 {
+		t.Errorf("expected start 3 owned by player 1, got %v", owners)
+	}
+
+	colors := &iniparse.ColorStore{Color: []iniparse.MultiplayerColor{
+		{Name: "PlayerColorGold", TooltipName: "Color:Gold", RGBColor: iniparse.RGBColor{R: 221, G: 226, B: 13}},
+		{Name: "PlayerColorRed", TooltipName: "Color:Red", RGBColor: iniparse.RGBColor{R: 255}},
+	}}
+	if got := ReplayColors(r, colors); got[1] != (color.NRGBA{0xFF, 0, 0, 0xFF}) {
+		t.Errorf("expected player 1 red, got %v", got)
+	}
+}
+
+func TestResolveColor(t *testing.T) {
+	colors := &iniparse.ColorStore{Color: []iniparse.MultiplayerColor{
+		{Name: "PlayerColorRed", TooltipName: "Color:Red", RGBColor: iniparse.RGBColor{R: 255}},
+	}}
+	red := color.NRGBA{0xFF, 0, 0, 0xFF}
+	for _, s := range []string{"0", "Red", "color:red", "PlayerColorRed", "#ff0000"} {
+		if got, ok := ResolveColor(s, colors); !ok || got != red {
+			t.Errorf("%q: expected red, got %v %v", s, got, ok)
+		}
+	}
+	for _, s := range []string{"", "7", "Mauve"} {
+		if _, ok := ResolveColor(s, colors); ok {
+			t.Errorf("%q: expected no match", s)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package minimap
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/bill-rich/cncstats/pkg/iniparse"
+	"github.com/bill-rich/cncstats/pkg/statsfile"
+	"github.com/bill-rich/cncstats/pkg/zhreplay"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/body"
+)
+
+// StatsMarkers returns a MarkerBuild for every BuildEvent and a MarkerKill
+// for every KillEvent in the stats file.
+func StatsMarkers(stats *statsfile.GameStats) []Marker {
+	out := make([]Marker, 0, len(stats.BuildEvents)+len(stats.KillEvents))
+	for _, e := range stats.BuildEvents {
+		out = append(out, Marker{Kind: MarkerBuild, Player: e.Player, Frame: e.Frame, X: e.X, Y: e.Y})
+	}
+	for _, e := range stats.KillEvents {
+		out = append(out, Marker{Kind: MarkerKill, Player: e.KillerPlayer, Frame: e.Frame, X: e.X, Y: e.Y})
+	}
+	return out
+}
+
+// ReplayMarkers returns a MarkerBuilding for every BuildObject command in
+// the replay. Players are numbered like the stats file: 1-based over the
+// replay's players with observers skipped.
+func ReplayMarkers(r *zhreplay.Replay) []Marker {
+	index := playerIndexes(r)
+	var out []Marker
+	for _, chunk := range r.Body {
+		if chunk.OrderCode != 1049 { // BuildObject
+			continue
+		}
+		slot := chunk.PlayerID - r.PlayerIDOffset
+		for _, arg := range chunk.Arguments {
+			if pos, ok := arg.(body.Position3D); ok {
+				out = append(out, Marker{
+					Kind:   MarkerBuilding,
+					Player: index[slot],
+					Frame:  uint(chunk.TimeCode),
+					X:      float64(pos.X),
+					Y:      float64(pos.Y),
+				})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ReplayStartOwners maps start position numbers to the players who started
+// there, from the replay header's lobby slots. Random (-1) starts are
+// omitted since the header doesn't record where they landed.
+func ReplayStartOwners(r *zhreplay.Replay) map[int]int {
+	owners := map[int]int{}
+	if r.Header == nil {
+		return owners
+	}
+	index := playerIndexes(r)
+	for slot, p := range r.Header.Metadata.Players {
+		pos, err := strconv.Atoi(p.StartingPosition)
+		if err != nil || pos < 0 || index[slot] == 0 {
+			continue
+		}
+		owners[pos+1] = index[slot]
+	}
+	return owners
+}
+
+// ReplayColors resolves each replay player's lobby color.
+func ReplayColors(r *zhreplay.Replay, colors *iniparse.ColorStore) map[int]color.NRGBA {
+	out := map[int]color.NRGBA{}
+	if r.Header == nil {
+		return out
+	}
+	index := playerIndexes(r)
+	for slot, p := range r.Header.Metadata.Players {
+		if c, ok := ResolveColor(p.Color, colors); ok && index[slot] != 0 {
+			out[index[slot]] = c
+		}
+	}
+	return out
+}
+
+// playerIndexes maps replay player slots to stats player indexes.
+func playerIndexes(r *zhreplay.Replay) map[int]int {
+	index := map[int]int{}
+	n := 0
+	for slot, p := range r.Summary {
+		if p.Side == "Observer" {
+			continue
+		}
+		n++
+		index[slot] = n
+	}
+	return index
+}
+
+// StatsColors resolves each stats player's color.
+func StatsColors(stats *statsfile.GameStats, colors *iniparse.ColorStore) map[int]color.NRGBA {
+	out := map[int]color.NRGBA{}
+	for _, p := range stats.Players {
+		if c, ok := ResolveColor(p.Color, colors); ok {
+			out[p.Index] = c
+		}
+	}
+	return out
+}
+
+// ResolveColor turns a player color as the replay header or stats file
+// reports it into RGB. It accepts a MultiplayerColor index, its Name or
+// TooltipName (with or without the "Color:" prefix), or "#rrggbb".
+func ResolveColor(s string, colors *iniparse.ColorStore) (color.NRGBA, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "#") && len(s) == 7 {
+		if v, err := strconv.ParseUint(s[1:], 16, 32); err == nil {
+			return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}, true
+		}
+	}
+	if colors == nil || s == "" {
+		return color.NRGBA{}, false
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		mc, err := colors.GetColor(i)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		return rgb(mc.RGBColor), true
+	}
+	want := strings.TrimPrefix(strings.ToLower(s), "color:")
+	for _, mc := range colors.Color {
+		if strings.EqualFold(mc.Name, s) ||
+			strings.TrimPrefix(strings.ToLower(mc.TooltipName), "color:") == want {
+			return rgb(mc.RGBColor), true
+		}
+	}
+	return color.NRGBA{}, false
+}
+
+func rgb(c iniparse.RGBColor) color.NRGBA {
+	return color.NRGBA{uint8(c.R), uint8(c.G), uint8(c.B), 0xFF}
+}
@@ -0,0 +1,351 @@
+// Package profile builds player profiles and match histories from the
+// match database: what was recorded when each replay was parsed, so no
+// replay or stats file is read again.
+//
+// A match counts towards wins and losses only when its teams finished
+// differently; otherwise it is a game without a result. Players are
+// grouped by identity (see pkg/identity) when a directory is given.
+package profile
+
+import (
+	"cmp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/identity"
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+)
+
+// Limits on the lists a profile returns.
+const (
+	// MaxOpenings is how many of the most played openings are kept.
+	MaxOpenings = 5
+	// MaxTrend is how many of the latest matches the APM trend covers.
+	MaxTrend = 50
+	// MaxCached is how many players' histories a Cache keeps.
+	MaxCached = 1024
+)
+
+// Results of a match for one player, as matchdb.Match.Result gives them.
+const (
+	ResultWin     = matchdb.ResultWin
+	ResultLoss    = matchdb.ResultLoss
+	ResultUnknown = matchdb.ResultUnknown
+)
+
+// Appearance is one match a player played in, as their match history
+// lists it.
+type Appearance struct {
+	Match           string    `json:"match"`
+	Time            time.Time `json:"time"`
+	Map             string    `json:"map"`
+	Name            string    `json:"name"`
+	Side            string    `json:"side"`
+	Faction         string    `json:"faction,omitempty"`
+	Team            int       `json:"team"`
+	Result          string    `json:"result"`
+	WinMethod       string    `json:"winMethod"`
+	DurationSeconds int       `json:"durationSeconds"`
+	APM             float64   `json:"apm"`
+	Opening         []string  `json:"opening,omitempty"`
+	Teammates       []string  `json:"teammates,omitempty"`
+	Opponents       []string  `json:"opponents,omitempty"`
+
+	// teammateKeys and opponentKeys are the other players' identities.
+	teammateKeys, opponentKeys []string
+}
+
+// Record tallies the games a player played with a faction, on a map, or
+// with or against another player.
+type Record struct {
+	// Key is the faction, the map, or the other player's ID.
+	Key string `json:"key,omitempty"`
+	// Name is the other player's latest name, for teammates and opponents.
+	Name   string `json:"name,omitempty"`
+	Games  int    `json:"games"`
+	Wins   int    `json:"wins"`
+	Losses int    `json:"losses"`
+	// WinRate is Wins over the games with a result, 0 without any.
+	WinRate float64 `json:"winRate"`
+}
+
+func (r *Record) add(result string) {
+	r.Games++
+	switch result {
+	case ResultWin:
+		r.Wins++
+	case ResultLoss:
+		r.Losses++
+	}
+	if decided := r.Wins + r.Losses; decided > 0 {
+		r.WinRate = float64(r.Wins) / float64(decided)
+	}
+}
+
+// Opening is a build opening and how it fared.
+type Opening struct {
+	Builds []string `json:"builds"`
+	Record
+}
+
+// APMPoint is a player's APM in one match.
+type APMPoint struct {
+	Match string    `json:"match"`
+	Time  time.Time `json:"time"`
+	APM   float64   `json:"apm"`
+}
+
+// Profile summarizes every recorded match of one player.
+type Profile struct {
+	Player  string   `json:"player"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+	Record
+	AverageSeconds float64    `json:"averageSeconds"`
+	AverageAPM     float64    `json:"averageApm"`
+	FirstPlayed    time.Time  `json:"firstPlayed"`
+	LastPlayed     time.Time  `json:"lastPlayed"`
+	Factions       []Record   `json:"factions"`
+	Maps           []Record   `json:"maps"`
+	Teammates      []Record   `json:"teammates"`
+	Opponents      []Record   `json:"opponents"`
+	Openings       []Opening  `json:"openings"`
+	APMTrend       []APMPoint `json:"apmTrend"`
+}
+
+// resolver maps a name to the key a player is grouped under.
+type resolver struct {
+	dir *identity.Directory
+}
+
+func (r resolver) key(name string) string {
+	if r.dir == nil {
+		return name
+	}
+	return r.dir.ID(name)
+}
+
+// History returns the matches the player named by key (an ID when dir is
+// given, else a name) played in, oldest first. Observers' seats don't
+// count.
+func History(db *matchdb.DB, dir *identity.Directory, key string) ([]Appearance, error) {
+	r := resolver{dir: dir}
+	if p, ok := lookup(dir, key); ok {
+		key = p.ID
+	}
+	var out []Appearance
+	err := db.Each(func(m *matchdb.Match) error {
+		if a, ok := appearance(m, r, key); ok {
+			out = append(out, a)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Cache holds the histories asked for since the database and the
+// identities were last written, so a player's profile and the pages of
+// their match history are read from the database once.
+type Cache struct {
+	db  *matchdb.DB
+	ids *identity.Store
+
+	mu        sync.Mutex
+	rev       uint64
+	idsRev    int
+	histories map[string][]Appearance
+}
+
+// NewCache returns a Cache over db, grouping players by their identity in
+// ids, or by name if ids is nil.
+func NewCache(db *matchdb.DB, ids *identity.Store) *Cache {
+	return &Cache{db: db, ids: ids}
+}
+
+// History returns the history of the player named by name, a nickname or
+// player ID, as History does, and the directory it was resolved in. The
+// history is shared; callers must not modify it.
+func (c *Cache) History(name string) (*identity.Directory, []Appearance, error) {
+	rev, err := c.db.Revision()
+	if err != nil {
+		return nil, nil, err
+	}
+	var dir *identity.Directory
+	idsRev := 0
+	if c.ids != nil {
+		if dir, err = c.ids.Directory(); err != nil {
+			return nil, nil, err
+		}
+		idsRev = dir.Revision
+	}
+	key := name
+	if p, ok := lookup(dir, name); ok {
+		key = p.ID
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.histories == nil || c.rev != rev || c.idsRev != idsRev || len(c.histories) >= MaxCached {
+		c.rev, c.idsRev, c.histories = rev, idsRev, map[string][]Appearance{}
+	}
+	if h, ok := c.histories[key]; ok {
+		return dir, h, nil
+	}
+	h, err := History(c.db, dir, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.histories[key] = h
+	return dir, h, nil
+}
+
+func lookup(dir *identity.Directory, name string) (*identity.Player, bool) {
+	if dir == nil {
+		return nil, false
+	}
+	return dir.Lookup(name)
+}
+
+// appearance returns the player's appearance in m, if they played in it.
+func appearance(m *matchdb.Match, r resolver, key string) (Appearance, bool) {
+	self := -1
+	for i, p := range m.Players {
+		if !p.Observer && r.key(p.Name) == key {
+			self = i
+			break
+		}
+	}
+	if self < 0 {
+		return Appearance{}, false
+	}
+	p := m.Players[self]
+	a := Appearance{
+		Match:           m.ID,
+		Time:            m.Time(),
+		Map:             m.MapName(),
+		Name:            p.Name,
+		Side:            p.Side,
+		Faction:         p.Faction,
+		Team:            p.Team,
+		Result:          m.Result(p),
+		WinMethod:       m.WinMethod,
+		DurationSeconds: m.DurationSeconds,
+		APM:             p.APM,
+		Opening:         p.Opening,
+	}
+	for i, o := range m.Players {
+		if i == self || o.Observer {
+			continue
+		}
+		if p.Team > 0 && o.Team == p.Team {
+			a.Teammates = append(a.Teammates, o.Name)
+			a.teammateKeys = append(a.teammateKeys, r.key(o.Name))
+		} else {
+			a.Opponents = append(a.Opponents, o.Name)
+			a.opponentKeys = append(a.opponentKeys, r.key(o.Name))
+		}
+	}
+	return a, true
+}
+
+// Build returns the profile of the player named by key, from their
+// history as History returns it.
+func Build(dir *identity.Directory, key string, history []Appearance) *Profile {
+	pr := &Profile{Player: key, Name: key, Aliases: []string{key}}
+	if p, ok := lookup(dir, key); ok {
+		pr.Player, pr.Name, pr.Aliases = p.ID, p.Name, nil
+		for _, a := range p.Aliases {
+			pr.Aliases = append(pr.Aliases, a.Name)
+		}
+	}
+
+	factions := map[string]*Record{}
+	maps := map[string]*Record{}
+	teammates := map[string]*Record{}
+	opponents := map[string]*Record{}
+	openings := map[string]*Opening{}
+	var seconds, apm float64
+	tally := func(records map[string]*Record, key, name, result string) {
+		r, ok := records[key]
+		if !ok {
+			r = &Record{Key: key}
+			records[key] = r
+		}
+		r.Name = name
+		r.add(result)
+	}
+
+	for _, a := range history {
+		pr.add(a.Result)
+		seconds += float64(a.DurationSeconds)
+		apm += a.APM
+		if pr.FirstPlayed.IsZero() || a.Time.Before(pr.FirstPlayed) {
+			pr.FirstPlayed = a.Time
+		}
+		if a.Time.After(pr.LastPlayed) {
+			pr.LastPlayed = a.Time
+		}
+
+		tally(factions, cmp.Or(a.Faction, a.Side), "", a.Result)
+		tally(maps, a.Map, "", a.Result)
+		for i, k := range a.teammateKeys {
+			tally(teammates, k, a.Teammates[i], a.Result)
+		}
+		for i, k := range a.opponentKeys {
+			tally(opponents, k, a.Opponents[i], a.Result)
+		}
+		if len(a.Opening) > 0 {
+			k := strings.Join(a.Opening, "\x00")
+			o, ok := openings[k]
+			if !ok {
+				o = &Opening{Builds: a.Opening}
+				openings[k] = o
+			}
+			o.add(a.Result)
+		}
+	}
+	if n := float64(len(history)); n > 0 {
+		pr.AverageSeconds = seconds / n
+		pr.AverageAPM = apm / n
+	}
+
+	pr.Factions = sorted(factions)
+	pr.Maps = sorted(maps)
+	pr.Teammates = sorted(teammates)
+	pr.Opponents = sorted(opponents)
+
+	pr.Openings = []Opening{}
+	for _, o := range openings {
+		pr.Openings = append(pr.Openings, *o)
+	}
+	sort.Slice(pr.Openings, func(i, j int) bool {
+		a, b := pr.Openings[i], pr.Openings[j]
+		if a.Games != b.Games {
+			return a.Games > b.Games
+		}
+		return strings.Join(a.Builds, "\x00") < strings.Join(b.Builds, "\x00")
+	})
+	pr.Openings = pr.Openings[:min(len(pr.Openings), MaxOpenings)]
+
+	pr.APMTrend = []APMPoint{}
+	for _, a := range history[max(0, len(history)-MaxTrend):] {
+		pr.APMTrend = append(pr.APMTrend, APMPoint{Match: a.Match, Time: a.Time, APM: a.APM})
+	}
+	return pr
+}
+
+// sorted returns the records, most played first.
+func sorted(records map[string]*Record) []Record {
+	out := make([]Record, 0, len(records))
+	for _, r := range records {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Games != out[j].Games {
+			return out[i].Games > out[j].Games
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
@@ -0,0 +1,125 @@
+package profile
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/identity"
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func player(name, faction string, team int, win bool, apm float64, opening ...string) matchdb.Player {
+	return matchdb.Player{Name: name, Human: true, Side: "USA", Faction: faction, Team: team, Win: win, APM: apm, Opening: opening}
+}
+
+func TestProfile(t *testing.T) {
+	db, err := matchdb.Open(filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	matches := []*matchdb.Match{
+		{MapPath: "maps/tournament desert", DurationSeconds: 600, Players: []matchdb.Player{
+			player("alice", "USA Airforce", 1, true, 40, "PowerPlant", "Barracks"),
+			player("bob", "China Nuke", 2, false, 30),
+		}},
+		{MapPath: "maps/tournament desert", DurationSeconds: 1200, Players: []matchdb.Player{
+			player("[CLAN]alice", "USA Airforce", 1, false, 60, "PowerPlant", "Barracks"),
+			player("carol", "GLA Toxin", 1, false, 50),
+			player("bob", "China Nuke", 2, true, 35),
+			player("dave", "GLA Stealth", 2, true, 20),
+			{Name: "eve", Human: true, Observer: true, Side: "Observer", Team: -1},
+		}},
+		{MapPath: "maps\\winter wolf", DurationSeconds: 900, Players: []matchdb.Player{
+			player("alice", "China Tank", 1, false, 50, "Barracks"),
+			player("bob", "China Nuke", 2, false, 30),
+		}},
+		{MapPath: "maps/winter wolf", Players: []matchdb.Player{
+			player("bob", "China Nuke", 1, true, 30), player("eve", "USA Laser", 2, false, 30),
+		}},
+	}
+	for i, m := range matches {
+		m.ID = fmt.Sprint(i)
+		m.StartTime = int64(1000 * (i + 1))
+		if err := db.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := identity.NewStore(storage.NewFS(t.TempDir()))
+	err = ids.Observe(
+		identity.Observation{Alias: "alice", Kind: identity.KindDisplay, Value: "1/0", Match: "1"},
+		identity.Observation{Alias: "[CLAN]alice", Kind: identity.KindDisplay, Value: "1/0", Match: "1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ids.Directory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := History(db, dir, "[CLAN]alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Match != "0" || history[1].Name != "[CLAN]alice" || history[2].Map != "winter wolf" {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+	if h := history[1]; h.Result != ResultLoss || len(h.Teammates) != 1 || len(h.Opponents) != 2 {
+		t.Errorf("unexpected team game: %+v", h)
+	}
+	if h := history[2]; h.Result != ResultUnknown {
+		t.Errorf("expected a game everyone lost to have no result, got %+v", h)
+	}
+
+	pr := Build(dir, "alice", history)
+	if pr.Player != "p1" || len(pr.Aliases) != 2 || pr.Games != 3 || pr.Wins != 1 || pr.Losses != 1 || pr.WinRate != 0.5 {
+		t.Errorf("unexpected totals: %+v", pr.Record)
+	}
+	if pr.AverageSeconds != 900 || pr.AverageAPM != 50 {
+		t.Errorf("unexpected averages: %v seconds, %v APM", pr.AverageSeconds, pr.AverageAPM)
+	}
+	if len(pr.Factions) != 2 || pr.Factions[0].Key != "USA Airforce" || pr.Factions[0].Games != 2 {
+		t.Errorf("unexpected factions: %+v", pr.Factions)
+	}
+	if len(pr.Maps) != 2 || pr.Maps[0].Key != "tournament desert" {
+		t.Errorf("unexpected maps: %+v", pr.Maps)
+	}
+	if len(pr.Opponents) != 2 || pr.Opponents[0].Name != "bob" || pr.Opponents[0].Games != 3 || pr.Opponents[0].Wins != 1 {
+		t.Errorf("unexpected opponents: %+v", pr.Opponents)
+	}
+	if len(pr.Teammates) != 1 || pr.Teammates[0].Name != "carol" || pr.Teammates[0].Losses != 1 {
+		t.Errorf("unexpected teammates: %+v", pr.Teammates)
+	}
+	if len(pr.Openings) != 2 || pr.Openings[0].Games != 2 || pr.Openings[0].Builds[1] != "Barracks" {
+		t.Errorf("unexpected openings: %+v", pr.Openings)
+	}
+	if len(pr.APMTrend) != 3 || pr.APMTrend[1].APM != 60 {
+		t.Errorf("unexpected APM trend: %+v", pr.APMTrend)
+	}
+
+	// Without identities the aliases are separate players.
+	history, _ = History(db, nil, "alice")
+	if len(history) != 2 {
+		t.Errorf("expected alice's games under her own name only, got %+v", history)
+	}
+
+	// The cache serves a history until the database changes.
+	cache := NewCache(db, ids)
+	if _, h, err := cache.History("alice"); err != nil || len(h) != 3 {
+		t.Fatalf("unexpected cached history: %+v, %v", h, err)
+	}
+	m := &matchdb.Match{ID: "4", StartTime: 5000, Players: []matchdb.Player{
+		player("[CLAN]alice", "USA Airforce", 1, true, 40), player("bob", "China Nuke", 2, false, 30),
+	}}
+	if err := db.Put(m); err != nil {
+		t.Fatal(err)
+	}
+	if _, h, _ := cache.History("p1"); len(h) != 4 {
+		t.Errorf("expected the new match in the history, got %+v", h)
+	}
+}
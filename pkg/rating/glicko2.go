@@ -0,0 +1,122 @@
+package rating
+
+import "math"
+
+// Glicko-2 parameters, at the defaults Glickman recommends.
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+	// Tau limits how much volatility can change after one game. Smaller
+	// values suit games with fewer upsets.
+	Tau = 0.5
+
+	// scale converts between the Glicko and Glicko-2 scales.
+	scale = 173.7178
+	// epsilon is the convergence tolerance for the volatility iteration.
+	epsilon = 0.000001
+)
+
+// Rating is a Glicko-2 rating on the familiar Glicko scale. The true
+// skill is within about two Deviations of Rating with 95% confidence.
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// NewRating returns the rating of a player with no games.
+func NewRating() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Result is one outcome against an opponent: Score is 1 for a win, 0 for
+// a loss and 0.5 for a draw.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Decay returns the rating after periods rating periods without games:
+// the deviation grows with the volatility, up to DefaultDeviation, so an
+// inactive player's rating moves faster once they play again.
+func (r Rating) Decay(periods float64) Rating {
+	if periods <= 0 {
+		return r
+	}
+	phi := r.Deviation / scale
+	phi = math.Sqrt(phi*phi + r.Volatility*r.Volatility*periods)
+	r.Deviation = min(phi*scale, DefaultDeviation)
+	return r
+}
+
+// Update returns the rating after one rating period with the given
+// results, following steps 2-8 of Glickman's "Example of the Glicko-2
+// system". With no results only the deviation grows.
+func (r Rating) Update(results []Result) Rating {
+	if len(results) == 0 {
+		return r.Decay(1)
+	}
+	mu := (r.Rating - DefaultRating) / scale
+	phi := r.Deviation / scale
+
+	var vInv, delta float64
+	for _, res := range results {
+		muJ := (res.Opponent.Rating - DefaultRating) / scale
+		g := g(res.Opponent.Deviation / scale)
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		delta += g * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta *= v
+
+	sigma := volatility(phi, r.Volatility, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*delta/v
+
+	return Rating{
+		Rating:     muNew*scale + DefaultRating,
+		Deviation:  min(phiNew*scale, DefaultDeviation),
+		Volatility: sigma,
+	}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// volatility finds the new volatility by the Illinois algorithm (step 5).
+func volatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(Tau*Tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*Tau) < 0 {
+			k++
+		}
+		B = a - k*Tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,327 @@
+// Package rating computes Glicko-2 skill ratings from the matches recorded
+// in the match database, per player and per player-faction.
+//
+// Matches are replayed oldest first. Each player is rated against every
+// opposing team that finished differently, the team standing in as one
+// opponent with its players' mean rating and pooled deviation; everyone in
+// a match is updated from the ratings they had before it. A day without
+// games is one rating period of deviation decay.
+//
+// Games with AI players, games decided by WinMethod "lastCommand" (the
+// parser's guess from who acted last) and games without two teams with
+// different results are left out.
+//
+// Players are rated by identity (see pkg/identity) when a directory is
+// given, so a player keeps their rating across nicknames.
+package rating
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/identity"
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+)
+
+// Period is the length of one rating period.
+const Period = 24 * time.Hour
+
+// Reasons a match is left out, the keys of Table.Skipped.
+const (
+	SkipAI          = "ai"
+	SkipLastCommand = "lastCommand"
+	SkipNoOpponent  = "noOpponent"
+	SkipNoResult    = "noResult"
+)
+
+// Entry is the rating of a player, or of a player with one faction.
+type Entry struct {
+	// Player is the player's identity ID, or their name when ratings are
+	// computed without identities.
+	Player string `json:"player"`
+	// Name is the name the player last played under.
+	Name string `json:"name"`
+	// Faction is empty on a player's overall entry.
+	Faction    string    `json:"faction,omitempty"`
+	Rating     Rating    `json:"rating"`
+	Games      int       `json:"games"`
+	Wins       int       `json:"wins"`
+	Losses     int       `json:"losses"`
+	LastPlayed time.Time `json:"lastPlayed"`
+}
+
+// decayed returns e with its deviation grown for the rating periods since
+// it last played.
+func (e Entry) decayed(now time.Time) Entry {
+	if now.After(e.LastPlayed) {
+		e.Rating = e.Rating.Decay(float64(now.Sub(e.LastPlayed)) / float64(Period))
+	}
+	return e
+}
+
+// Table is the outcome of rating every recorded match.
+type Table struct {
+	players  map[string]*Entry
+	factions map[string]*Entry
+	dir      *identity.Directory
+
+	// Rated is how many matches were rated and Skipped how many were left
+	// out, by reason.
+	Rated   int
+	Skipped map[string]int
+}
+
+func factionKey(player, faction string) string {
+	return player + "\x00" + faction
+}
+
+// key returns the key a player is rated under.
+func (t *Table) key(name string) string {
+	if t.dir == nil {
+		return name
+	}
+	if p, ok := t.dir.Lookup(name); ok {
+		return p.ID
+	}
+	return name
+}
+
+// Player returns the overall entry and the per-faction entries, best
+// first, of the player with a name (or, with identities, an alias or ID),
+// with deviations decayed up to now. ok is false if the player has no
+// rated games.
+func (t *Table) Player(name string, now time.Time) (overall Entry, factions []Entry, ok bool) {
+	key := t.key(name)
+	e, ok := t.players[key]
+	if !ok {
+		return Entry{}, nil, false
+	}
+	for _, f := range t.factions {
+		if f.Player == key {
+			factions = append(factions, f.decayed(now))
+		}
+	}
+	sortEntries(factions)
+	return e.decayed(now), factions, true
+}
+
+// Leaderboard returns every entry with at least minGames games, best
+// first, with deviations decayed up to now. With a faction it ranks
+// player-faction entries of that faction, otherwise overall entries.
+func (t *Table) Leaderboard(faction string, minGames int, now time.Time) []Entry {
+	src := t.players
+	if faction != "" {
+		src = t.factions
+	}
+	out := []Entry{}
+	for _, e := range src {
+		if e.Games >= minGames && e.Faction == faction {
+			out = append(out, e.decayed(now))
+		}
+	}
+	sortEntries(out)
+	return out
+}
+
+// sortEntries orders entries by rating, then by deviation, so the better
+// established of two equal ratings ranks first.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Rating.Rating != b.Rating.Rating {
+			return a.Rating.Rating > b.Rating.Rating
+		}
+		if a.Rating.Deviation != b.Rating.Deviation {
+			return a.Rating.Deviation < b.Rating.Deviation
+		}
+		return factionKey(a.Player, a.Faction) < factionKey(b.Player, b.Faction)
+	})
+}
+
+// Compute rates every match recorded in db, players by their identity in
+// dir, or by name if dir is nil.
+func Compute(db *matchdb.DB, dir *identity.Directory) (*Table, error) {
+	t := &Table{
+		players:  map[string]*Entry{},
+		factions: map[string]*Entry{},
+		dir:      dir,
+		Skipped:  map[string]int{},
+	}
+	err := db.Each(func(m *matchdb.Match) error {
+		if reason := skipReason(m); reason != "" {
+			t.Skipped[reason]++
+			return nil
+		}
+		t.rate(m)
+		t.Rated++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func skipReason(m *matchdb.Match) string {
+	switch {
+	case m.HasAI():
+		return SkipAI
+	case m.WinMethod == "lastCommand":
+		return SkipLastCommand
+	case len(m.Teams()) < 2:
+		return SkipNoOpponent
+	case !m.Decided():
+		return SkipNoResult
+	}
+	return ""
+}
+
+// entry returns a player's entry, creating it at the default rating.
+func entry(entries map[string]*Entry, player, faction string) *Entry {
+	key := player
+	if faction != "" {
+		key = factionKey(player, faction)
+	}
+	e, ok := entries[key]
+	if !ok {
+		e = &Entry{Player: player, Faction: faction, Rating: NewRating()}
+		entries[key] = e
+	}
+	return e
+}
+
+// rate updates the entries of everyone who played m.
+func (t *Table) rate(m *matchdb.Match) {
+	at := m.Time()
+	ts := m.Teams()
+
+	// Ratings before the match, decayed to when it was played.
+	type side struct {
+		player, faction []*Entry
+		before, fBefore []Rating
+	}
+	sides := make([]side, len(ts))
+	for i, tm := range ts {
+		for _, p := range tm.Players {
+			faction := p.FactionName()
+			key := p.Name
+			if t.dir != nil {
+				key = t.dir.ID(p.Name)
+			}
+			pe := entry(t.players, key, "")
+			fe := entry(t.factions, key, faction)
+			pe.Name, fe.Name = p.Name, p.Name
+			sides[i].player = append(sides[i].player, pe)
+			sides[i].faction = append(sides[i].faction, fe)
+			sides[i].before = append(sides[i].before, beforeMatch(pe, at))
+			sides[i].fBefore = append(sides[i].fBefore, beforeMatch(fe, at))
+		}
+	}
+
+	for i, tm := range ts {
+		var results, fResults []Result
+		for j, other := range ts {
+			if i == j || other.Win == tm.Win {
+				continue
+			}
+			score := 0.0
+			if tm.Win {
+				score = 1
+			}
+			results = append(results, Result{Opponent: composite(sides[j].before), Score: score})
+			fResults = append(fResults, Result{Opponent: composite(sides[j].fBefore), Score: score})
+		}
+		if len(results) == 0 {
+			continue
+		}
+		for k := range tm.Players {
+			record(sides[i].player[k], sides[i].before[k].Update(results), tm.Win, at)
+			record(sides[i].faction[k], sides[i].fBefore[k].Update(fResults), tm.Win, at)
+		}
+	}
+}
+
+// beforeMatch returns e's rating decayed for the periods between its last
+// game and at.
+func beforeMatch(e *Entry, at time.Time) Rating {
+	if e.Games == 0 || !at.After(e.LastPlayed) {
+		return e.Rating
+	}
+	return e.Rating.Decay(float64(at.Sub(e.LastPlayed)) / float64(Period))
+}
+
+func record(e *Entry, r Rating, win bool, at time.Time) {
+	e.Rating = r
+	e.Games++
+	if win {
+		e.Wins++
+	} else {
+		e.Losses++
+	}
+	if at.After(e.LastPlayed) {
+		e.LastPlayed = at
+	}
+}
+
+// composite returns the rating a team plays at: its players' mean rating
+// and the root mean square of their deviations.
+func composite(ratings []Rating) Rating {
+	var c Rating
+	for _, r := range ratings {
+		c.Rating += r.Rating
+		c.Deviation += r.Deviation * r.Deviation
+		c.Volatility += r.Volatility
+	}
+	n := float64(len(ratings))
+	c.Rating /= n
+	c.Deviation = math.Sqrt(c.Deviation / n)
+	c.Volatility /= n
+	return c
+}
+
+// Cache holds the Table for the current revisions of the database and the
+// identities, recomputing it only after either has been written.
+type Cache struct {
+	db  *matchdb.DB
+	ids *identity.Store
+
+	mu     sync.Mutex
+	rev    uint64
+	idsRev int
+	table  *Table
+}
+
+// NewCache returns a Cache over db, rating players by their identity in
+// ids, or by name if ids is nil.
+func NewCache(db *matchdb.DB, ids *identity.Store) *Cache {
+	return &Cache{db: db, ids: ids}
+}
+
+// Table returns the ratings of every recorded match.
+func (c *Cache) Table() (*Table, error) {
+	rev, err := c.db.Revision()
+	if err != nil {
+		return nil, err
+	}
+	var dir *identity.Directory
+	idsRev := 0
+	if c.ids != nil {
+		if dir, err = c.ids.Directory(); err != nil {
+			return nil, err
+		}
+		idsRev = dir.Revision
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.table != nil && c.rev == rev && c.idsRev == idsRev {
+		return c.table, nil
+	}
+	t, err := Compute(c.db, dir)
+	if err != nil {
+		return nil, err
+	}
+	c.rev, c.idsRev, c.table = rev, idsRev, t
+	return t, nil
+}
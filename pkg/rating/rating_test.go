@@ -0,0 +1,158 @@
+package rating
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/identity"
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func TestUpdate(t *testing.T) {
+	// The worked example from Glickman's "Example of the Glicko-2 system".
+	r := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := r.Update([]Result{
+		{Opponent: Rating{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300}, Score: 0},
+	})
+	if math.Abs(got.Rating-1464.06) > 0.01 || math.Abs(got.Deviation-151.52) > 0.01 || math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Errorf("unexpected update: %+v", got)
+	}
+
+	if d := r.Decay(1000).Deviation; d != DefaultDeviation {
+		t.Errorf("expected decay to stop at %v, got %v", DefaultDeviation, d)
+	}
+	if d := r.Decay(30).Deviation; d <= r.Deviation {
+		t.Errorf("expected inactivity to grow the deviation, got %v", d)
+	}
+}
+
+func player(name, faction string, team int, win bool) matchdb.Player {
+	return matchdb.Player{Name: name, Human: true, Side: "USA", Faction: faction, Team: team, Win: win}
+}
+
+func TestCompute(t *testing.T) {
+	db, err := matchdb.Open(filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+	matches := []*matchdb.Match{
+		{ID: "1", StartTime: start, WinMethod: "deathEvents", Players: []matchdb.Player{
+			player("alice", "USA Airforce", 1, true), player("bob", "China Nuke", 2, false),
+		}},
+		{ID: "2", StartTime: start + 3600, WinMethod: "deathEvents", Players: []matchdb.Player{
+			player("alice", "USA Airforce", 1, true), player("carol", "GLA Toxin", 1, true),
+			player("bob", "China Nuke", 2, false), player("dave", "GLA Stealth", 2, false),
+			{Name: "eve", Human: true, Observer: true, Side: "Observer", Team: -1},
+		}},
+		{ID: "3", StartTime: start + 7200, WinMethod: "deathEvents", Players: []matchdb.Player{
+			player("bob", "China Nuke", 1, true), {Name: "Hard AI", Side: "China", Team: 2},
+		}},
+		{ID: "4", StartTime: start + 9000, WinMethod: "lastCommand", Players: []matchdb.Player{
+			player("bob", "China Nuke", 1, true), player("alice", "USA Airforce", 2, false),
+		}},
+		{ID: "5", StartTime: start + 9600, WinMethod: "deathEvents", Players: []matchdb.Player{
+			player("bob", "China Nuke", 1, false), player("alice", "USA Airforce", 1, false),
+		}},
+		{ID: "6", StartTime: start + 10800, WinMethod: "deathEvents", Players: []matchdb.Player{
+			player("bob", "China Nuke", 1, false), player("alice", "USA Airforce", 2, false),
+		}},
+	}
+	for _, m := range matches {
+		if err := db.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := NewCache(db, nil)
+	table, err := cache.Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Rated != 2 || table.Skipped[SkipAI] != 1 || table.Skipped[SkipLastCommand] != 1 ||
+		table.Skipped[SkipNoOpponent] != 1 || table.Skipped[SkipNoResult] != 1 {
+		t.Errorf("unexpected counts: rated %d, skipped %v", table.Rated, table.Skipped)
+	}
+
+	now := time.Unix(start+10800, 0)
+	alice, factions, ok := table.Player("alice", now)
+	if !ok || alice.Games != 2 || alice.Wins != 2 || alice.Rating.Rating <= DefaultRating {
+		t.Errorf("unexpected alice: %+v", alice)
+	}
+	if len(factions) != 1 || factions[0].Faction != "USA Airforce" || factions[0].Games != 2 {
+		t.Errorf("unexpected alice factions: %+v", factions)
+	}
+	if _, _, ok := table.Player("eve", now); ok {
+		t.Error("expected observers to be unrated")
+	}
+
+	board := table.Leaderboard("", 2, now)
+	if len(board) != 2 || board[0].Player != "alice" || board[1].Player != "bob" {
+		t.Errorf("unexpected leaderboard: %+v", board)
+	}
+	if board := table.Leaderboard("GLA Toxin", 0, now); len(board) != 1 || board[0].Player != "carol" {
+		t.Errorf("unexpected faction leaderboard: %+v", board)
+	}
+
+	// A month later everyone's rating is less certain.
+	later, _, _ := table.Player("alice", now.AddDate(0, 1, 0))
+	if later.Rating.Deviation <= alice.Rating.Deviation || later.Rating.Rating != alice.Rating.Rating {
+		t.Errorf("expected only the deviation to decay, got %+v from %+v", later.Rating, alice.Rating)
+	}
+
+	if again, _ := cache.Table(); again != table {
+		t.Error("expected the cached table while the database is unchanged")
+	}
+	if err := db.Put(&matchdb.Match{ID: "7", StartTime: start + 20000}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.Table(); again == table || again.Skipped[SkipNoOpponent] != 2 {
+		t.Errorf("expected the table to be recomputed after a write, got %+v", again)
+	}
+}
+
+func TestComputeIdentities(t *testing.T) {
+	db, err := matchdb.Open(filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i, name := range []string{"alice", "[CLAN]alice"} {
+		m := &matchdb.Match{ID: fmt.Sprint(i), StartTime: int64(1000 + i), WinMethod: "deathEvents", Players: []matchdb.Player{
+			player(name, "USA Airforce", 1, true), player("bob", "China Nuke", 2, false),
+		}}
+		if err := db.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := identity.NewStore(storage.NewFS(t.TempDir()))
+	cache := NewCache(db, ids)
+	if table, _ := cache.Table(); len(table.Leaderboard("", 0, time.Unix(2000, 0))) != 3 {
+		t.Error("expected players to be rated by name until they are known")
+	}
+
+	err = ids.Observe(
+		identity.Observation{Alias: "alice", Kind: identity.KindDisplay, Value: "1/1", Match: "1"},
+		identity.Observation{Alias: "[CLAN]alice", Kind: identity.KindDisplay, Value: "1/1", Match: "1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := cache.Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, factions, ok := table.Player("alice", time.Unix(2000, 0))
+	if !ok || e.Player != "p1" || e.Name != "[CLAN]alice" || e.Games != 2 || len(factions) != 1 || factions[0].Games != 2 {
+		t.Errorf("expected both aliases rated as one player, got %+v, %+v", e, factions)
+	}
+}
@@ -0,0 +1,190 @@
+// Package replayfile persists uploaded replay files, keyed by the match
+// identity (a matchid.ID string, the same key pkg/statsfile and
+// pkg/logfile use), so matches can be parsed again after the parser or
+// the INI data improves. It mirrors pkg/logfile.
+//
+// Every player of a match records their own replay of it, so a match can
+// have several. Identical uploads are kept once, by content hash.
+//
+// Layout in the repository's storage backend:
+//
+//	<match>/
+//	  <hash>.rep       # one distinct replay, as uploaded
+//	  ...
+//	  uploads.json     # who sent what, in arrival order
+package replayfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+const uploadsFilename = "uploads.json"
+
+// Upload records one upload of a match's replay.
+type Upload struct {
+	// Hash is the SHA-256 of the replay; repeated identical uploads share
+	// one stored copy.
+	Hash string `json:"hash"`
+	Size int    `json:"size"`
+	// Name is the uploaded file's base name.
+	Name string `json:"name"`
+	// Client is the API key name of the uploader ("anonymous" without one).
+	Client     string    `json:"client"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// Repository stores replay files in a storage backend.
+type Repository struct {
+	backend storage.Backend
+
+	// mu serializes Store's read-modify-write of uploads.json.
+	mu sync.Mutex
+}
+
+// NewRepository returns a Repository that keeps replays in b.
+func NewRepository(b storage.Backend) *Repository {
+	return &Repository{backend: b}
+}
+
+// Hash returns the content hash a replay is stored under.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func replayKey(match, hash string) string {
+	return path.Join(match, hash+".rep")
+}
+
+func uploadsKey(match string) string {
+	return path.Join(match, uploadsFilename)
+}
+
+// Store keeps data as a replay of match uploaded by client as filename,
+// and records the upload. It returns the upload and whether the replay
+// was new to the match; an identical replay is stored once and recorded
+// once, by whoever sent it first.
+//
+// Like statsfile.StoreUpload, recording is serialized within the process
+// only.
+func (r *Repository) Store(match, client, filename string, data []byte) (Upload, bool, error) {
+	if match == "" {
+		return Upload{}, false, errors.New("replayfile.Store: empty match")
+	}
+	if len(data) == 0 {
+		return Upload{}, false, errors.New("replayfile.Store: empty replay")
+	}
+	u := Upload{
+		Hash:       Hash(data),
+		Size:       len(data),
+		Name:       path.Base(strings.ReplaceAll(filename, "\\", "/")),
+		Client:     client,
+		UploadedAt: time.Now().UTC(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uploads, err := r.List(match)
+	if err != nil {
+		return Upload{}, false, err
+	}
+	for _, old := range uploads {
+		if old.Hash == u.Hash {
+			return old, false, nil
+		}
+	}
+	if err := storage.PutBytes(r.backend, replayKey(match, u.Hash), data); err != nil {
+		return Upload{}, false, fmt.Errorf("write replay: %w", err)
+	}
+	b, err := json.Marshal(append(uploads, u))
+	if err != nil {
+		return Upload{}, false, err
+	}
+	if err := storage.PutBytes(r.backend, uploadsKey(match), b); err != nil {
+		return Upload{}, false, fmt.Errorf("write %s: %w", uploadsFilename, err)
+	}
+	return u, true, nil
+}
+
+// Exists reports whether any replay has been stored for the match.
+func (r *Repository) Exists(match string) bool {
+	return match != "" && storage.Exists(r.backend, uploadsKey(match))
+}
+
+// Matches returns every match with a stored replay, sorted.
+func (r *Repository) Matches() ([]string, error) {
+	objects, err := r.backend.List("")
+	if err != nil {
+		return nil, fmt.Errorf("list replays: %w", err)
+	}
+	var out []string
+	for _, o := range objects {
+		if match, ok := strings.CutSuffix(o.Key, "/"+uploadsFilename); ok && !strings.Contains(match, "/") {
+			out = append(out, match)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// List returns the distinct replays stored for a match in arrival order,
+// or an empty slice if there are none.
+func (r *Repository) List(match string) ([]Upload, error) {
+	if match == "" {
+		return nil, errors.New("replayfile.List: empty match")
+	}
+	b, err := storage.ReadAll(r.backend, uploadsKey(match))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Upload{}, nil
+		}
+		return nil, err
+	}
+	var out []Upload
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", uploadsFilename, err)
+	}
+	return out, nil
+}
+
+// Open opens one stored replay of a match for reading, the first one
+// uploaded when hash is empty. The caller closes it. Returns an error for
+// which errors.Is(err, os.ErrNotExist) is true if there is no such replay.
+func (r *Repository) Open(match, hash string) (io.ReadCloser, Upload, error) {
+	uploads, err := r.List(match)
+	if err != nil {
+		return nil, Upload{}, err
+	}
+	for _, u := range uploads {
+		if hash == "" || u.Hash == hash {
+			rc, err := r.backend.Get(replayKey(match, u.Hash))
+			return rc, u, err
+		}
+	}
+	return nil, Upload{}, fmt.Errorf("replayfile: no replay %q for match %s: %w", hash, match, os.ErrNotExist)
+}
+
+// Load returns the bytes of one stored replay, as Open picks it.
+func (r *Repository) Load(match, hash string) ([]byte, Upload, error) {
+	rc, u, err := r.Open(match, hash)
+	if err != nil {
+		return nil, Upload{}, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, u, err
+}
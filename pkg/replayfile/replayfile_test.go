@@ -0,0 +1,62 @@
+package replayfile
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func TestRepository(t *testing.T) {
+	r := NewRepository(storage.NewFS(t.TempDir()))
+	const match = "1-42-1000"
+
+	if r.Exists(match) {
+		t.Fatal("expected no replays yet")
+	}
+	if _, _, err := r.Load(match, ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+
+	first, isNew, err := r.Store(match, "alice", `C:\Replays\Last Replay.rep`, []byte("alice's replay"))
+	if err != nil || !isNew {
+		t.Fatalf("expected a new replay, got %v, %v", isNew, err)
+	}
+	if first.Name != "Last Replay.rep" || first.Client != "alice" || first.Size != 14 || first.Hash != Hash([]byte("alice's replay")) {
+		t.Errorf("unexpected upload: %+v", first)
+	}
+
+	// The same bytes again, from someone else, are kept once.
+	again, isNew, err := r.Store(match, "bob", "copy.rep", []byte("alice's replay"))
+	if err != nil || isNew || again != first {
+		t.Errorf("expected the first upload back, got %+v, %v, %v", again, isNew, err)
+	}
+	second, isNew, err := r.Store(match, "bob", "bob.rep", []byte("bob's replay"))
+	if err != nil || !isNew {
+		t.Fatalf("expected a second replay, got %v, %v", isNew, err)
+	}
+
+	uploads, err := r.List(match)
+	if err != nil || len(uploads) != 2 || uploads[1].Hash != second.Hash {
+		t.Errorf("unexpected uploads: %+v, %v", uploads, err)
+	}
+	if data, u, err := r.Load(match, ""); err != nil || string(data) != "alice's replay" || u.Hash != first.Hash {
+		t.Errorf("expected the first replay by default, got %q, %+v, %v", data, u, err)
+	}
+	if data, _, err := r.Load(match, second.Hash); err != nil || string(data) != "bob's replay" {
+		t.Errorf("expected bob's replay by hash, got %q, %v", data, err)
+	}
+	if _, _, err := r.Load(match, "nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error for an unknown hash, got %v", err)
+	}
+	if _, _, err := r.Store("0-42-1000", "carol", "carol.rep", []byte("carol's replay")); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := r.Matches(); err != nil || len(matches) != 2 || matches[0] != "0-42-1000" || matches[1] != match {
+		t.Errorf("unexpected matches: %v, %v", matches, err)
+	}
+	if !r.Exists(match) || r.Exists("2-42-1000") {
+		t.Error("unexpected Exists")
+	}
+}
@@ -0,0 +1,305 @@
+// Package reprocess parses every stored replay again, so parser fixes and
+// INI data changes reach matches recorded before them. It compares each
+// match's new record with the one the match database had and reports
+// what changed: winners, sides and factions, and what players' builds
+// cost.
+//
+// Replays are walked from the replay repository rather than the match
+// database, so a job also records matches a replica's local database
+// never saw.
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+	"github.com/bill-rich/cncstats/pkg/replayfile"
+)
+
+// States of a job.
+const (
+	StatePending  = "pending"
+	StateRunning  = "running"
+	StateDone     = "done"
+	StateFailed   = "failed"
+	StateCanceled = "canceled"
+)
+
+// Fields a Difference can name.
+const (
+	FieldWinMethod = "winMethod"
+	FieldWin       = "win"
+	FieldSide      = "side"
+	FieldFaction   = "faction"
+	FieldSpent     = "spent"
+)
+
+var (
+	// ErrRunning is returned by Runner.Start while a job is running.
+	ErrRunning = errors.New("reprocess: a job is already running")
+	// ErrNotRunning is returned by Runner.Cancel when no job is running.
+	ErrNotRunning = errors.New("reprocess: no job is running")
+)
+
+// Parser parses a match's stored replay with the current parser and data
+// set, records it in the match database and returns the new record.
+type Parser func(match string, data []byte) (*matchdb.Match, error)
+
+// Difference is one value that changed when a match was parsed again.
+// Slot and Player are empty for match-level fields.
+type Difference struct {
+	Slot   *int   `json:"slot,omitempty"`
+	Player string `json:"player,omitempty"`
+	Field  string `json:"field"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// Change is a match whose record changed.
+type Change struct {
+	Match string `json:"match"`
+	// Added is set when the match database had no record of the match.
+	Added       bool         `json:"added,omitempty"`
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+// Failure is a match whose replay couldn't be parsed again.
+type Failure struct {
+	Match string `json:"match"`
+	Error string `json:"error"`
+}
+
+// Report is a job's progress and, once it has finished, its outcome.
+type Report struct {
+	State    string    `json:"state"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+	// Total is how many matches have stored replays; Done how many of
+	// them have been parsed again, failures included.
+	Total     int `json:"total"`
+	Done      int `json:"done"`
+	Unchanged int `json:"unchanged"`
+	// Fields counts the differences found by field.
+	Fields   map[string]int `json:"fields"`
+	Changes  []Change       `json:"changes"`
+	Failures []Failure      `json:"failures"`
+	// Error is why a failed job stopped.
+	Error string `json:"error,omitempty"`
+}
+
+// Job parses every stored replay again. Its report can be read while it
+// runs.
+type Job struct {
+	replays *replayfile.Repository
+	db      *matchdb.DB
+	parse   Parser
+
+	mu     sync.Mutex
+	report Report
+}
+
+// NewJob returns a job that parses the replays in replays with parse and
+// compares the results with the records in db.
+func NewJob(replays *replayfile.Repository, db *matchdb.DB, parse Parser) *Job {
+	return &Job{
+		replays: replays,
+		db:      db,
+		parse:   parse,
+		report:  Report{State: StatePending, Fields: map[string]int{}, Changes: []Change{}, Failures: []Failure{}},
+	}
+}
+
+// Report returns a copy of the job's report so far.
+func (j *Job) Report() Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r := j.report
+	r.Fields = make(map[string]int, len(j.report.Fields))
+	for k, v := range j.report.Fields {
+		r.Fields[k] = v
+	}
+	r.Changes = append([]Change{}, j.report.Changes...)
+	r.Failures = append([]Failure{}, j.report.Failures...)
+	return r
+}
+
+func (j *Job) update(fn func(r *Report)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.report)
+}
+
+// Run parses every stored replay and returns the job's report. A replay
+// that fails to parse is reported and skipped; listing the replays or
+// reading the database failing stops the job. Canceling ctx stops it
+// between replays.
+func (j *Job) Run(ctx context.Context) (Report, error) {
+	j.update(func(r *Report) {
+		r.State = StateRunning
+		r.Started = time.Now().UTC()
+	})
+	err := j.run(ctx)
+	j.update(func(r *Report) {
+		r.Finished = time.Now().UTC()
+		switch {
+		case err == nil:
+			r.State = StateDone
+		case errors.Is(err, context.Canceled):
+			r.State = StateCanceled
+		default:
+			r.State = StateFailed
+			r.Error = err.Error()
+		}
+	})
+	return j.Report(), err
+}
+
+func (j *Job) run(ctx context.Context) error {
+	matches, err := j.replays.Matches()
+	if err != nil {
+		return err
+	}
+	j.update(func(r *Report) { r.Total = len(matches) })
+
+	for _, match := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		old, err := j.db.Get(match)
+		if err != nil && !errors.Is(err, matchdb.ErrNotFound) {
+			return err
+		}
+		m, err := j.reparse(match, old)
+		j.update(func(r *Report) {
+			r.Done++
+			if err != nil {
+				r.Failures = append(r.Failures, Failure{Match: match, Error: err.Error()})
+				return
+			}
+			if old == nil {
+				r.Changes = append(r.Changes, Change{Match: match, Added: true})
+				return
+			}
+			diffs := Diff(old, m)
+			if len(diffs) == 0 {
+				r.Unchanged++
+				return
+			}
+			for _, d := range diffs {
+				r.Fields[d.Field]++
+			}
+			r.Changes = append(r.Changes, Change{Match: match, Differences: diffs})
+		})
+	}
+	return nil
+}
+
+// reparse parses the stored replay old, the match's record, was built
+// from, or the match's first stored replay if the record names none. A
+// replay the parser panics on is reported as a failure instead of
+// stopping the job.
+func (j *Job) reparse(match string, old *matchdb.Match) (m *matchdb.Match, err error) {
+	hash := ""
+	if old != nil {
+		hash = old.ReplayHash
+	}
+	data, _, err := j.replays.Load(match, hash)
+	if errors.Is(err, os.ErrNotExist) && hash != "" {
+		data, _, err = j.replays.Load(match, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			m, err = nil, fmt.Errorf("parser panicked: %v", p)
+		}
+	}()
+	return j.parse(match, data)
+}
+
+// Diff returns what changed between two records of a match: its win
+// method, and each slot's result, side, faction and spending. Slots are
+// compared by position.
+func Diff(old, m *matchdb.Match) []Difference {
+	var out []Difference
+	if old.WinMethod != m.WinMethod {
+		out = append(out, Difference{Field: FieldWinMethod, Old: old.WinMethod, New: m.WinMethod})
+	}
+	for i := range max(len(old.Players), len(m.Players)) {
+		var a, b matchdb.Player
+		if i < len(old.Players) {
+			a = old.Players[i]
+		}
+		if i < len(m.Players) {
+			b = m.Players[i]
+		}
+		add := func(field, oldValue, newValue string) {
+			if oldValue != newValue {
+				slot := i
+				out = append(out, Difference{Slot: &slot, Player: b.Name, Field: field, Old: oldValue, New: newValue})
+			}
+		}
+		add(FieldWin, strconv.FormatBool(a.Win), strconv.FormatBool(b.Win))
+		add(FieldSide, a.Side, b.Side)
+		add(FieldFaction, a.Faction, b.Faction)
+		add(FieldSpent, strconv.Itoa(a.Spent), strconv.Itoa(b.Spent))
+	}
+	return out
+}
+
+// Runner runs one job at a time in the background and keeps the latest.
+type Runner struct {
+	mu     sync.Mutex
+	job    *Job
+	cancel context.CancelFunc
+}
+
+// Start runs j in the background, or returns ErrRunning if the previous
+// job hasn't finished.
+func (r *Runner) Start(j *Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.job != nil {
+		if s := r.job.Report().State; s == StatePending || s == StateRunning {
+			return ErrRunning
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.job, r.cancel = j, cancel
+	j.update(func(r *Report) { r.State = StateRunning })
+	go func() {
+		defer cancel()
+		j.Run(ctx)
+	}()
+	return nil
+}
+
+// Cancel stops the running job after the replay being parsed, leaving it
+// canceled, or returns ErrNotRunning if no job is running.
+func (r *Runner) Cancel() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.job == nil {
+		return ErrNotRunning
+	}
+	if s := r.job.Report().State; s != StatePending && s != StateRunning {
+		return ErrNotRunning
+	}
+	r.cancel()
+	return nil
+}
+
+// Latest returns the running or last finished job, or nil before the
+// first.
+func (r *Runner) Latest() *Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.job
+}
@@ -0,0 +1,158 @@
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+	"github.com/bill-rich/cncstats/pkg/replayfile"
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func match(id string, winner int, spent int) *matchdb.Match {
+	m := &matchdb.Match{ID: id, Seed: id, WinMethod: "deathEvents", Players: []matchdb.Player{
+		{Name: "alice", Human: true, Side: "USA", Faction: "USA Airforce", Team: 1},
+		{Name: "bob", Human: true, Side: "China", Faction: "China Nuke", Team: 2, Spent: spent},
+	}}
+	m.Players[winner].Win = true
+	return m
+}
+
+func TestJob(t *testing.T) {
+	db, err := matchdb.Open(filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	replays := replayfile.NewRepository(storage.NewFS(t.TempDir()))
+
+	// "changed" gets a new winner and costs; "added" was never recorded;
+	// "broken" panics the parser.
+	for _, id := range []string{"same", "changed", "added", "broken"} {
+		if _, _, err := replays.Store(id, "alice", id+".rep", []byte(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range []*matchdb.Match{match("same", 0, 500), match("changed", 0, 500)} {
+		if err := db.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parse := func(id string, data []byte) (*matchdb.Match, error) {
+		var m *matchdb.Match
+		switch string(data) {
+		case "same", "added":
+			m = match(id, 0, 500)
+		case "changed":
+			m = match(id, 1, 700)
+		default:
+			panic("bad replay")
+		}
+		return m, db.Put(m)
+	}
+
+	r, err := NewJob(replays, db, parse).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.State != StateDone || r.Total != 4 || r.Done != 4 || r.Unchanged != 1 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if len(r.Failures) != 1 || r.Failures[0].Match != "broken" {
+		t.Errorf("unexpected failures: %+v", r.Failures)
+	}
+	// Matches are walked in key order.
+	if len(r.Changes) != 2 || !r.Changes[0].Added || r.Changes[0].Match != "added" {
+		t.Fatalf("unexpected changes: %+v", r.Changes)
+	}
+	if r.Fields[FieldWin] != 2 || r.Fields[FieldSpent] != 1 || len(r.Changes[1].Differences) != 3 {
+		t.Errorf("unexpected differences: %+v, %+v", r.Fields, r.Changes[1])
+	}
+	if d := r.Changes[1].Differences[2]; *d.Slot != 1 || d.Player != "bob" || d.Old != "500" || d.New != "700" {
+		t.Errorf("unexpected spending difference: %+v", d)
+	}
+	if m, _ := db.Get("added"); m == nil {
+		t.Error("expected the added match to be recorded")
+	}
+
+	// A match is parsed again from the replay its record was built from,
+	// not the first stored.
+	u, _, err := replays.Store("same", "bob", "later.rep", []byte("changed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update("same", func(m *matchdb.Match) { m.ReplayHash = u.Hash }); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := NewJob(replays, db, parse).Run(context.Background()); r.Fields[FieldSpent] != 1 {
+		t.Errorf("expected the recorded replay of \"same\" to be parsed, got %+v", r.Changes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r, err := NewJob(replays, db, parse).Run(ctx); !errors.Is(err, context.Canceled) || r.State != StateCanceled {
+		t.Errorf("expected a canceled job, got %v, %+v", err, r)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	db, err := matchdb.Open(filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	replays := replayfile.NewRepository(storage.NewFS(t.TempDir()))
+	if _, _, err := replays.Store("1", "alice", "1.rep", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	parse := func(id string, data []byte) (*matchdb.Match, error) {
+		<-release
+		return match(id, 0, 0), nil
+	}
+
+	var runner Runner
+	if runner.Latest() != nil {
+		t.Error("expected no job before the first")
+	}
+	job := NewJob(replays, db, parse)
+	if err := runner.Start(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Start(NewJob(replays, db, parse)); !errors.Is(err, ErrRunning) {
+		t.Errorf("expected ErrRunning, got %v", err)
+	}
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); job.Report().State == StateRunning; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("job didn't finish")
+		}
+	}
+	if runner.Latest() != job || job.Report().Done != 1 {
+		t.Errorf("unexpected latest job: %+v", runner.Latest().Report())
+	}
+	if err := runner.Cancel(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected ErrNotRunning, got %v", err)
+	}
+
+	release = make(chan struct{})
+	job = NewJob(replays, db, parse)
+	if err := runner.Start(job); err != nil {
+		t.Errorf("expected a new job to start once the last finished, got %v", err)
+	}
+	if err := runner.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); job.Report().State == StateRunning; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("job didn't stop")
+		}
+	}
+	if s := job.Report().State; s != StateCanceled {
+		t.Errorf("expected the job to be canceled, got %s", s)
+	}
+}
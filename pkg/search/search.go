@@ -0,0 +1,277 @@
+// Package search finds recorded matches by who played, what and where,
+// over the match database, so no replay or stats file is read again.
+//
+// A player filter resolves through the identity directory when one is
+// given, so it finds every nickname linked to the player. Faction, result
+// and used-template filters then apply to that player's seat; without a
+// player they apply to any seat. Observers' seats never match.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/identity"
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+)
+
+// Results a Query can ask for.
+const (
+	ResultWin  = matchdb.ResultWin
+	ResultLoss = matchdb.ResultLoss
+	// ResultUnknown is a match without two teams that finished
+	// differently.
+	ResultUnknown = matchdb.ResultUnknown
+)
+
+// Orders results can be sorted in.
+const (
+	SortTime     = "time"
+	SortDuration = "duration"
+	SortMap      = "map"
+)
+
+// MaxCached is how many queries' results a Cache keeps.
+const MaxCached = 256
+
+// ErrInvalid is returned, wrapped, for a Query that can't be run.
+var ErrInvalid = errors.New("search: invalid query")
+
+// Query is a match search. Zero fields don't filter.
+type Query struct {
+	// Player is a nickname or player ID.
+	Player string
+	// Faction is a faction name, e.g. "USA Airforce", or a side for
+	// matches parsed without stats. Case-insensitive.
+	Faction string
+	// Map is a map CRC, or part of the map's name, case-insensitive.
+	Map string
+	// Version is the game version string from the replay header.
+	Version string
+	// From and To bound when the match was played, To exclusive.
+	From, To time.Time
+	// MinSeconds and MaxSeconds bound the match's duration, inclusive.
+	MinSeconds, MaxSeconds int
+	// TeamSize is how many players the largest team had: 1 for 1v1 and
+	// free-for-all games, 2 for 2v2.
+	TeamSize int
+	// Result is one of the Result constants. Win and loss need a Player.
+	Result string
+	// HasStats and HasLogs, when set, ask whether stats or logs were
+	// uploaded for the match.
+	HasStats, HasLogs *bool
+	// Used is a unit, building, upgrade or power template name.
+	Used string
+
+	// Sort is one of the Sort constants, SortTime when empty; results are
+	// ascending unless Descending is set.
+	Sort       string
+	Descending bool
+}
+
+// Validate reports whether q can be run.
+func (q *Query) Validate() error {
+	switch q.Result {
+	case "", ResultUnknown:
+	case ResultWin, ResultLoss:
+		if q.Player == "" {
+			return fmt.Errorf("%w: a win or loss result needs a player", ErrInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: result must be win, loss or unknown", ErrInvalid)
+	}
+	switch q.Sort {
+	case "", SortTime, SortDuration, SortMap:
+	default:
+		return fmt.Errorf("%w: sort must be time, duration or map", ErrInvalid)
+	}
+	if q.MaxSeconds > 0 && q.MaxSeconds < q.MinSeconds {
+		return fmt.Errorf("%w: maximum duration is below the minimum", ErrInvalid)
+	}
+	return nil
+}
+
+// Run returns the matches in db that q finds, in q's order. dir resolves
+// the player filter; without it nicknames are matched as they are.
+func Run(db *matchdb.DB, dir *identity.Directory, q Query) ([]*matchdb.Match, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	key := q.Player
+	if dir != nil && key != "" {
+		if p, ok := dir.Lookup(key); ok {
+			key = p.ID
+		}
+	}
+
+	var out []*matchdb.Match
+	err := db.Each(func(m *matchdb.Match) error {
+		if q.match(m, dir, key) {
+			out = append(out, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Each lists matches oldest first, so a stable sort leaves ties in
+	// that order, and reversing it lists them newest first.
+	switch q.Sort {
+	case SortDuration:
+		sort.SliceStable(out, func(i, j int) bool { return out[i].DurationSeconds < out[j].DurationSeconds })
+	case SortMap:
+		sort.SliceStable(out, func(i, j int) bool {
+			return strings.ToLower(out[i].MapName()) < strings.ToLower(out[j].MapName())
+		})
+	}
+	if q.Descending {
+		slices.Reverse(out)
+	}
+	return out, nil
+}
+
+// Cache holds the results of the queries run since the database and the
+// identities were last written, so paging through a search reads the
+// database once.
+type Cache struct {
+	db  *matchdb.DB
+	ids *identity.Store
+
+	mu      sync.Mutex
+	rev     uint64
+	idsRev  int
+	results map[cacheKey][]*matchdb.Match
+}
+
+// cacheKey is a Query with its HasStats and HasLogs pointers replaced by
+// their values (0 unset, 1 false, 2 true), so equal queries are equal
+// keys.
+type cacheKey struct {
+	q                 Query
+	hasStats, hasLogs int
+}
+
+func keyOf(q Query) cacheKey {
+	k := cacheKey{q: q, hasStats: tristate(q.HasStats), hasLogs: tristate(q.HasLogs)}
+	k.q.HasStats, k.q.HasLogs = nil, nil
+	return k
+}
+
+func tristate(b *bool) int {
+	switch {
+	case b == nil:
+		return 0
+	case *b:
+		return 2
+	}
+	return 1
+}
+
+// NewCache returns a Cache over db, resolving players by their identity
+// in ids, or by name if ids is nil.
+func NewCache(db *matchdb.DB, ids *identity.Store) *Cache {
+	return &Cache{db: db, ids: ids}
+}
+
+// Run returns what Run finds for q. The result is shared; callers must
+// not modify it.
+func (c *Cache) Run(q Query) ([]*matchdb.Match, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	rev, err := c.db.Revision()
+	if err != nil {
+		return nil, err
+	}
+	var dir *identity.Directory
+	idsRev := 0
+	if c.ids != nil {
+		if dir, err = c.ids.Directory(); err != nil {
+			return nil, err
+		}
+		idsRev = dir.Revision
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil || c.rev != rev || c.idsRev != idsRev || len(c.results) >= MaxCached {
+		c.rev, c.idsRev, c.results = rev, idsRev, map[cacheKey][]*matchdb.Match{}
+	}
+	k := keyOf(q)
+	if found, ok := c.results[k]; ok {
+		return found, nil
+	}
+	found, err := Run(c.db, dir, q)
+	if err != nil {
+		return nil, err
+	}
+	c.results[k] = found
+	return found, nil
+}
+
+// match reports whether q finds m. key is the resolved player filter.
+func (q *Query) match(m *matchdb.Match, dir *identity.Directory, key string) bool {
+	t := m.Time()
+	switch {
+	case q.Version != "" && m.Version != q.Version:
+		return false
+	case !q.From.IsZero() && t.Before(q.From):
+		return false
+	case !q.To.IsZero() && !t.Before(q.To):
+		return false
+	case q.MinSeconds > 0 && m.DurationSeconds < q.MinSeconds:
+		return false
+	case q.MaxSeconds > 0 && m.DurationSeconds > q.MaxSeconds:
+		return false
+	case q.Map != "" && m.MapCRC != q.Map && !strings.Contains(strings.ToLower(m.MapName()), strings.ToLower(q.Map)):
+		return false
+	case q.HasStats != nil && (m.Stats != "") != *q.HasStats:
+		return false
+	case q.HasLogs != nil && (m.Logs != "") != *q.HasLogs:
+		return false
+	case q.Result == ResultUnknown && m.Decided():
+		return false
+	}
+
+	teams := m.Teams()
+	if q.TeamSize > 0 {
+		largest := 0
+		for _, t := range teams {
+			largest = max(largest, len(t.Players))
+		}
+		if largest != q.TeamSize {
+			return false
+		}
+	}
+
+	for _, p := range m.Players {
+		if p.Observer {
+			continue
+		}
+		if key != "" && playerKey(dir, p.Name) != key {
+			continue
+		}
+		if q.Faction != "" && !strings.EqualFold(p.FactionName(), q.Faction) {
+			continue
+		}
+		if q.Used != "" && !p.Used(q.Used) {
+			continue
+		}
+		if (q.Result == ResultWin || q.Result == ResultLoss) && m.Result(p) != q.Result {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func playerKey(dir *identity.Directory, name string) string {
+	if dir == nil {
+		return name
+	}
+	return dir.ID(name)
+}
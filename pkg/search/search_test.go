@@ -0,0 +1,135 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/identity"
+	"github.com/bill-rich/cncstats/pkg/matchdb"
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func player(name, faction string, team int, win bool, units ...string) matchdb.Player {
+	return matchdb.Player{Name: name, Human: true, Side: faction[:3], Faction: faction, Team: team, Win: win, Units: units}
+}
+
+func ids(matches []*matchdb.Match) []string {
+	var out []string
+	for _, m := range matches {
+		out = append(out, m.ID)
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	db, err := matchdb.Open(filepath.Join(t.TempDir(), "matches.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	matches := []*matchdb.Match{
+		{MapCRC: "111", MapPath: "maps/tournament desert", Version: "1.04", DurationSeconds: 900, Stats: "0", Players: []matchdb.Player{
+			player("alice", "USA Airforce", 1, true, "AmericaJetRaptor"), player("bob", "China Nuke", 2, false),
+		}},
+		{MapCRC: "222", MapPath: "maps/winter wolf", Version: "1.04", DurationSeconds: 1500, Logs: "1", Players: []matchdb.Player{
+			player("[CLAN]alice", "USA Airforce", 1, false), player("carol", "GLA Toxin", 1, false),
+			player("bob", "China Nuke", 2, true), player("dave", "GLA Stealth", 2, true),
+			{Name: "eve", Human: true, Observer: true, Side: "Observer", Team: -1},
+		}},
+		{MapCRC: "111", MapPath: "maps/tournament desert", Version: "1.05", DurationSeconds: 600, Players: []matchdb.Player{
+			player("alice", "China Tank", 1, false), player("bob", "China Nuke", 2, false),
+		}},
+	}
+	for i, m := range matches {
+		m.ID = fmt.Sprint(i)
+		m.StartTime = day.AddDate(0, 0, i).Unix()
+		if err := db.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := identity.NewStore(storage.NewFS(t.TempDir()))
+	err = store.Observe(
+		identity.Observation{Alias: "alice", Kind: identity.KindDisplay, Value: "1/0", Match: "1"},
+		identity.Observation{Alias: "[CLAN]alice", Kind: identity.KindDisplay, Value: "1/0", Match: "1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := store.Directory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yes, no := true, false
+	for _, tc := range []struct {
+		name string
+		q    Query
+		want string
+	}{
+		{"everything, oldest first", Query{}, "[0 1 2]"},
+		{"player across nicknames", Query{Player: "alice"}, "[0 1 2]"},
+		{"player's faction", Query{Player: "alice", Faction: "usa airforce"}, "[0 1]"},
+		{"faction of any seat", Query{Faction: "GLA Toxin"}, "[1]"},
+		{"map name", Query{Map: "desert"}, "[0 2]"},
+		{"map CRC", Query{Map: "222"}, "[1]"},
+		{"version", Query{Version: "1.05"}, "[2]"},
+		{"date range", Query{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 2)}, "[1]"},
+		{"duration range", Query{MinSeconds: 600, MaxSeconds: 900}, "[0 2]"},
+		{"team size", Query{TeamSize: 2}, "[1]"},
+		{"player's wins", Query{Player: "alice", Result: ResultWin}, "[0]"},
+		{"player's losses", Query{Player: "bob", Result: ResultLoss}, "[0]"},
+		{"no result", Query{Result: ResultUnknown}, "[2]"},
+		{"with stats", Query{HasStats: &yes}, "[0]"},
+		{"without logs", Query{HasLogs: &no}, "[0 2]"},
+		{"template used", Query{Used: "americajetraptor"}, "[0]"},
+		{"template used by someone else", Query{Player: "bob", Used: "AmericaJetRaptor"}, "[]"},
+		{"observers don't match", Query{Player: "eve"}, "[]"},
+		{"longest first", Query{Sort: SortDuration, Descending: true}, "[1 0 2]"},
+		{"by map, ties oldest first", Query{Sort: SortMap}, "[0 2 1]"},
+		{"newest first", Query{Descending: true}, "[2 1 0]"},
+	} {
+		got, err := Run(db, dir, tc.q)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if s := fmt.Sprint(ids(got)); s != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, s)
+		}
+	}
+
+	// Without identities the nicknames are separate players.
+	if got, _ := Run(db, nil, Query{Player: "alice"}); len(got) != 2 {
+		t.Errorf("expected alice's matches under her own name only, got %v", ids(got))
+	}
+
+	for _, q := range []Query{{Result: ResultWin}, {Result: "draw"}, {Sort: "apm"}, {MinSeconds: 10, MaxSeconds: 5}} {
+		if _, err := Run(db, dir, q); !errors.Is(err, ErrInvalid) {
+			t.Errorf("expected ErrInvalid for %+v, got %v", q, err)
+		}
+	}
+
+	// The cache serves results until the database changes, and tells
+	// HasStats values apart rather than their pointers.
+	cache := NewCache(db, store)
+	if got, err := cache.Run(Query{Player: "p1", HasStats: &yes}); err != nil || fmt.Sprint(ids(got)) != "[0]" {
+		t.Fatalf("unexpected cached results: %v, %v", ids(got), err)
+	}
+	if got, _ := cache.Run(Query{Player: "p1", HasStats: &no}); fmt.Sprint(ids(got)) != "[1 2]" {
+		t.Errorf("expected matches without stats, got %v", ids(got))
+	}
+	m := &matchdb.Match{ID: "3", StartTime: day.AddDate(0, 0, 3).Unix(), Stats: "3", Players: []matchdb.Player{
+		player("alice", "USA Airforce", 1, true), player("bob", "China Nuke", 2, false),
+	}}
+	if err := db.Put(m); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cache.Run(Query{Player: "p1", HasStats: &yes}); fmt.Sprint(ids(got)) != "[0 3]" {
+		t.Errorf("expected the new match in the results, got %v", ids(got))
+	}
+}
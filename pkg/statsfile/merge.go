@@ -0,0 +1,126 @@
+package statsfile
+
+import "sort"
+
+// Merge combines several uploads of the same match into the most complete
+// record. Each player's exporter sees the same deterministic game but may
+// stop early (a crash, a disconnect) or drop events, so:
+//
+//   - game info and final player totals come from the upload that ran the
+//     most frames; players, academy stats and income breakdowns missing
+//     there are filled in from the others
+//   - events are the union of all uploads; an event that appears n times
+//     in one upload and m times in another appears max(n, m) times, so
+//     identical events are de-duplicated without losing genuine repeats
+//   - each player's time series is the longest any upload recorded
+//
+// The uploads are not modified. Merge returns nil for no uploads.
+func Merge(uploads ...*GameStats) *GameStats {
+	var all []*GameStats
+	for _, s := range uploads {
+		if s != nil {
+			all = append(all, s)
+		}
+	}
+	if len(all) == 0 {
+		return nil
+	}
+
+	base := all[0]
+	for _, s := range all[1:] {
+		if s.Game.FrameCount > base.Game.FrameCount {
+			base = s
+		}
+	}
+	out := &GameStats{Game: base.Game}
+	for _, s := range all {
+		out.Version = max(out.Version, s.Version)
+		if s.MigratedFrom > 0 && (out.MigratedFrom == 0 || s.MigratedFrom < out.MigratedFrom) {
+			out.MigratedFrom = s.MigratedFrom
+		}
+		out.Game.PlayerCount = max(out.Game.PlayerCount, s.Game.PlayerCount)
+		out.Game.SnapshotInterval = max(out.Game.SnapshotInterval, s.Game.SnapshotInterval)
+	}
+
+	out.Players = mergePlayers(base, all)
+
+	out.BuildEvents = union(all, func(s *GameStats) []BuildEvent { return s.BuildEvents }, func(e BuildEvent) uint { return e.Frame })
+	out.KillEvents = union(all, func(s *GameStats) []KillEvent { return s.KillEvents }, func(e KillEvent) uint { return e.Frame })
+	out.CaptureEvents = union(all, func(s *GameStats) []CaptureEvent { return s.CaptureEvents }, func(e CaptureEvent) uint { return e.Frame })
+	out.EnergyEvents = union(all, func(s *GameStats) []EnergyEvent { return s.EnergyEvents }, func(e EnergyEvent) uint { return e.Frame })
+	out.RankEvents = union(all, func(s *GameStats) []RankEvent { return s.RankEvents }, func(e RankEvent) uint { return e.Frame })
+	out.SkillPointsEvents = union(all, func(s *GameStats) []SkillPointsEvent { return s.SkillPointsEvents }, func(e SkillPointsEvent) uint { return e.Frame })
+	out.SciencePointsEvents = union(all, func(s *GameStats) []SciencePointsEvent { return s.SciencePointsEvents }, func(e SciencePointsEvent) uint { return e.Frame })
+	out.RadarEvents = union(all, func(s *GameStats) []RadarEvent { return s.RadarEvents }, func(e RadarEvent) uint { return e.Frame })
+	out.DeathEvents = union(all, func(s *GameStats) []DeathEvent { return s.DeathEvents }, func(e DeathEvent) uint { return e.Frame })
+	out.BattlePlanEvents = union(all, func(s *GameStats) []BattlePlanEvent { return s.BattlePlanEvents }, func(e BattlePlanEvent) uint { return e.Frame })
+
+	out.TimeSeries = mergeTimeSeries(all)
+	return out
+}
+
+func mergePlayers(base *GameStats, all []*GameStats) []Player {
+	byIndex := map[int]int{}
+	players := make([]Player, 0, len(base.Players))
+	for _, p := range base.Players {
+		byIndex[p.Index] = len(players)
+		players = append(players, p)
+	}
+	for _, s := range all {
+		for _, p := range s.Players {
+			i, ok := byIndex[p.Index]
+			if !ok {
+				byIndex[p.Index] = len(players)
+				players = append(players, p)
+				continue
+			}
+			if players[i].Academy == nil {
+				players[i].Academy = p.Academy
+			}
+			if players[i].IncomeBySource == nil {
+				players[i].IncomeBySource = p.IncomeBySource
+			}
+		}
+	}
+	sort.SliceStable(players, func(i, j int) bool { return players[i].Index < players[j].Index })
+	return players
+}
+
+func mergeTimeSeries(all []*GameStats) TimeSeries {
+	byIndex := map[int]int{}
+	var ts TimeSeries
+	for _, s := range all {
+		for _, p := range s.TimeSeries.Players {
+			i, ok := byIndex[p.Index]
+			if !ok {
+				byIndex[p.Index] = len(ts.Players)
+				ts.Players = append(ts.Players, p)
+				continue
+			}
+			if len(p.Money) > len(ts.Players[i].Money) {
+				ts.Players[i] = p
+			}
+		}
+	}
+	sort.SliceStable(ts.Players, func(i, j int) bool { return ts.Players[i].Index < ts.Players[j].Index })
+	return ts
+}
+
+// union merges one event list across uploads, keeping each distinct event
+// as many times as the upload that has it most often, ordered by frame.
+func union[T comparable](all []*GameStats, events func(*GameStats) []T, frame func(T) uint) []T {
+	kept := map[T]int{}
+	out := make([]T, 0)
+	for _, s := range all {
+		seen := map[T]int{}
+		for _, e := range events(s) {
+			seen[e]++
+			if seen[e] > kept[e] {
+				kept[e] = seen[e]
+				out = append(out, e)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return frame(out[i]) < frame(out[j]) })
+	return out
+}
@@ -0,0 +1,135 @@
+package statsfile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+func TestMerge(t *testing.T) {
+	// Player 1's exporter crashed at frame 600; player 2's ran to the end
+	// but missed a kill player 1 saw.
+	crashed := &GameStats{
+		Version: 1,
+		Game:    GameInfo{Seed: 7, FrameCount: 600},
+		Players: []Player{{Index: 1, MoneyEarned: 100, Academy: &Academy{PeonsBuilt: 3}}, {Index: 2}},
+		BuildEvents: []BuildEvent{
+			{Frame: 10, Player: 1, Object: "AmericaDozer"},
+			{Frame: 20, Player: 2, Object: "ChinaDozer"},
+		},
+		KillEvents: []KillEvent{{Frame: 500, KillerPlayer: 1, VictimPlayer: 2, Victim: "ChinaDozer"}},
+		TimeSeries: TimeSeries{Players: []TimeSeriesPlayer{
+			{Index: 1, Money: []uint{1, 2}},
+			{Index: 2, Money: []uint{1, 2}},
+		}},
+	}
+	full := &GameStats{
+		Version: 2,
+		Game:    GameInfo{Seed: 7, FrameCount: 9000},
+		Players: []Player{{Index: 1, MoneyEarned: 900}, {Index: 2, MoneyEarned: 800}},
+		BuildEvents: []BuildEvent{
+			{Frame: 10, Player: 1, Object: "AmericaDozer"},
+			// Two identical events in one upload are genuine repeats.
+			{Frame: 15, Player: 2, Object: "ChinaRedguard"},
+			{Frame: 15, Player: 2, Object: "ChinaRedguard"},
+			{Frame: 20, Player: 2, Object: "ChinaDozer"},
+			{Frame: 8000, Player: 2, Object: "ChinaNukeSilo"},
+		},
+		TimeSeries: TimeSeries{Players: []TimeSeriesPlayer{
+			{Index: 2, Money: []uint{1, 2, 3, 4}},
+		}},
+	}
+
+	m := Merge(crashed, full)
+	if m.Game.FrameCount != 9000 || m.Version != 2 {
+		t.Errorf("expected game info from the longest upload, got %+v version %d", m.Game, m.Version)
+	}
+	if m.Players[0].MoneyEarned != 900 || m.Players[0].Academy == nil || m.Players[0].Academy.PeonsBuilt != 3 {
+		t.Errorf("expected final totals from the longest upload with academy filled in, got %+v", m.Players[0])
+	}
+	if len(m.BuildEvents) != 5 {
+		t.Fatalf("expected 5 build events, got %+v", m.BuildEvents)
+	}
+	if m.BuildEvents[1].Object != "ChinaRedguard" || m.BuildEvents[4].Frame != 8000 {
+		t.Errorf("expected events ordered by frame, got %+v", m.BuildEvents)
+	}
+	if len(m.KillEvents) != 1 {
+		t.Errorf("expected the kill only one upload saw, got %+v", m.KillEvents)
+	}
+	ts := m.TimeSeries.Players
+	if len(ts) != 2 || ts[0].Index != 1 || len(ts[0].Money) != 2 || len(ts[1].Money) != 4 {
+		t.Errorf("expected each player's longest series, got %+v", ts)
+	}
+
+	// Merging is idempotent.
+	again := Merge(m, crashed, full)
+	if len(again.BuildEvents) != 5 || len(again.KillEvents) != 1 {
+		t.Errorf("expected re-merge to add nothing, got %d builds, %d kills", len(again.BuildEvents), len(again.KillEvents))
+	}
+	if Merge() != nil {
+		t.Error("expected nil for no uploads")
+	}
+}
+
+func TestStoreUpload(t *testing.T) {
+	r := NewRepository(storage.NewFS(t.TempDir()))
+	encode := func(s *GameStats) []byte {
+		t.Helper()
+		b, err := Encode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	// A merged file from before uploads were kept separately.
+	if err := r.Store("7", encode(&GameStats{Game: GameInfo{FrameCount: 100}, DeathEvents: []DeathEvent{{Frame: 90, Player: 2}}})); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.StoreUpload("7", "alice", []byte("not gzip"), false); !errors.Is(err, ErrBadUpload) {
+		t.Fatalf("expected ErrBadUpload, got %v", err)
+	}
+	var invalid ValidationErrors
+	if _, _, err := r.StoreUpload("7", "alice", encode(&GameStats{}), false); !errors.As(err, &invalid) {
+		t.Fatalf("expected ValidationErrors for an empty payload, got %v", err)
+	}
+
+	players := []Player{{Index: 1}, {Index: 2}}
+	second := encode(&GameStats{Game: GameInfo{FrameCount: 200}, Players: players, DeathEvents: []DeathEvent{{Frame: 150, Player: 1}}})
+	merged, contributors, err := r.StoreUpload("7", "bob", second, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contributors) != 2 || contributors[0].Client != legacyClient || contributors[1].Client != "bob" {
+		t.Errorf("expected the legacy file and bob, got %+v", contributors)
+	}
+	if merged.Game.FrameCount != 200 || len(merged.DeathEvents) != 2 || merged.Version != CurrentVersion {
+		t.Errorf("expected merged stats, got %+v", merged)
+	}
+	loaded, err := r.Load("7")
+	if err != nil || len(loaded.DeathEvents) != 2 {
+		t.Fatalf("expected Load to return the merge, got %+v, %v", loaded, err)
+	}
+
+	// The same bytes again add a contributor but no new events.
+	if merged, contributors, err = r.StoreUpload("7", "carol", second, false); err != nil || len(contributors) != 3 || len(merged.DeathEvents) != 2 {
+		t.Errorf("expected a repeat upload to be recorded only, got %d contributors, %+v, %v", len(contributors), merged, err)
+	}
+
+	// A forced upload replaces the merge but keeps the history.
+	forced := encode(&GameStats{Game: GameInfo{FrameCount: 50}, Players: players})
+	merged, contributors, err = r.StoreUpload("7", "admin", forced, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Game.FrameCount != 50 || len(merged.DeathEvents) != 0 {
+		t.Errorf("expected only the forced upload, got %+v", merged)
+	}
+	if len(contributors) != 4 || !contributors[0].Superseded || contributors[3].Superseded {
+		t.Errorf("expected earlier uploads superseded, got %+v", contributors)
+	}
+	if got, err := r.Contributors("7"); err != nil || len(got) != 4 {
+		t.Errorf("expected 4 recorded uploads, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,61 @@
+package statsfile
+
+import "fmt"
+
+// CurrentVersion is the newest stats JSON version this package reads.
+// Older payloads are migrated up to it by Migrate.
+const CurrentVersion = 2
+
+// IncomeOther is the IncomeBySource key for income with no better source.
+// Migrated version 1 payloads attribute all income to it.
+const IncomeOther = "other"
+
+// migrations[v] upgrades a payload from version v to v+1 in place. Adding a
+// version means bumping CurrentVersion and adding its step here.
+var migrations = map[int]func(*GameStats){
+	1: migrateV1ToV2,
+}
+
+// Migrate upgrades stats to CurrentVersion in place, one version at a time,
+// and records the version it started from in MigratedFrom. A missing
+// version (0) is read as 1, the first exporter format. Stats already at
+// CurrentVersion are left as they are; newer ones are an error.
+func Migrate(s *GameStats) error {
+	if s.Version == 0 {
+		s.Version = 1
+	}
+	if s.Version > CurrentVersion {
+		return fmt.Errorf("statsfile: unsupported stats version %d (newest supported is %d)", s.Version, CurrentVersion)
+	}
+	from := s.Version
+	for s.Version < CurrentVersion {
+		step, ok := migrations[s.Version]
+		if !ok {
+			return fmt.Errorf("statsfile: no migration from stats version %d", s.Version)
+		}
+		step(s)
+		s.Version++
+	}
+	if from != CurrentVersion && s.MigratedFrom == 0 {
+		s.MigratedFrom = from
+	}
+	return nil
+}
+
+// migrateV1ToV2 adds the income breakdown version 2 introduced. Version 1
+// exporters only counted total income, so it all goes to IncomeOther; the
+// totals, and so every chart built on them, are unchanged.
+func migrateV1ToV2(s *GameStats) {
+	for i := range s.Players {
+		p := &s.Players[i]
+		if p.IncomeBySource == nil {
+			p.IncomeBySource = map[string]int{IncomeOther: p.MoneyEarned}
+		}
+	}
+	for i := range s.TimeSeries.Players {
+		p := &s.TimeSeries.Players[i]
+		if p.IncomeBySource == nil {
+			p.IncomeBySource = map[string][]int{IncomeOther: append([]int(nil), p.MoneyEarned...)}
+		}
+	}
+}
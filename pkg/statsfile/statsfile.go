@@ -2,18 +2,21 @@ package statsfile
 
 import (
 	"compress/gzip"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"os"
-	"path/filepath"
+	"sync"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
 )
 
 // GameStats represents the JSON structure from the Generals stats exporter
 type GameStats struct {
-	Version int      `json:"version"`
-	Game    GameInfo `json:"game"`
-	Players []Player `json:"players"`
+	Version int `json:"version"`
+	// MigratedFrom is the version the exporter wrote when the server has
+	// upgraded the payload to Version (see Migrate), and 0 otherwise.
+	MigratedFrom int      `json:"migratedFrom,omitempty"`
+	Game         GameInfo `json:"game"`
+	Players      []Player `json:"players"`
 
 	BuildEvents         []BuildEvent         `json:"buildEvents"`
 	KillEvents          []KillEvent          `json:"killEvents"`
@@ -36,6 +39,11 @@ type GameInfo struct {
 	ReplayFile       string `json:"replayFile"`
 	PlayerCount      int    `json:"playerCount"`
 	SnapshotInterval int    `json:"snapshotInterval"`
+	// MapCRC (decimal) and StartTime (Unix seconds, the replay header's
+	// TimeStampBegin) identify the match together with Seed; see
+	// pkg/matchid. Older exporters leave them out.
+	MapCRC    string `json:"mapCRC,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
 }
 
 type Player struct {
@@ -52,7 +60,8 @@ type Player struct {
 	Score       int      `json:"score"`
 	// IncomeBySource breaks moneyEarned down by source (supply, hacker,
 	// blackMarket, supplyDrop, oilDerrick, bounty, salvage, crate, theft,
-	// other). Present for stats JSON version >= 2; nil for older uploads.
+	// other). Introduced in stats JSON version 2; older uploads are migrated
+	// with all income under "other".
 	IncomeBySource map[string]int `json:"incomeBySource,omitempty"`
 	Academy        *Academy       `json:"academy,omitempty"`
 }
@@ -163,53 +172,66 @@ type TimeSeriesPlayer struct {
 	MoneyEarned []int  `json:"moneyEarned"`
 	MoneySpent  []int  `json:"moneySpent"`
 	// IncomeBySource holds one cumulative-income series per source, keyed by
-	// the same source names as Player.IncomeBySource. Present for stats JSON
-	// version >= 2; nil for older uploads.
+	// the same source names as Player.IncomeBySource. Introduced in stats
+	// JSON version 2; older uploads are migrated with all income under
+	// "other".
 	IncomeBySource map[string][]int `json:"incomeBySource,omitempty"`
 }
 
-// StatsDir is the directory where stats files are stored.
-// Set via STATS_DIR env var or defaults to ./stats/
-var StatsDir = "./stats"
+// Repository stores gzip-compressed stats uploads, one object per match
+// named "<match>.json.gz". A match is the string form of a matchid.ID:
+// "<seed>-<crc>-<start>", or just the seed for stats uploaded without an
+// identity.
+type Repository struct {
+	backend storage.Backend
 
-func init() {
-	if dir := os.Getenv("STATS_DIR"); dir != "" {
-		StatsDir = dir
-	}
+	// mu serializes StoreUpload's read-merge-write.
+	mu sync.Mutex
+}
+
+// NewRepository returns a Repository that keeps stats in b.
+func NewRepository(b storage.Backend) *Repository {
+	return &Repository{backend: b}
 }
 
-// StatsPath returns the file path for a given seed
-func StatsPath(seed string) string {
-	return filepath.Join(StatsDir, seed+".json.gz")
+// Key returns the storage key for a given match
+func Key(match string) string {
+	return match + ".json.gz"
 }
 
-// Store saves gzip-compressed stats data for the given seed
-func Store(seed string, data []byte) error {
-	if err := os.MkdirAll(StatsDir, 0755); err != nil {
-		return fmt.Errorf("create stats dir: %w", err)
+// Store saves gzip-compressed stats data for the given match
+func (r *Repository) Store(match string, data []byte) error {
+	if match == "" {
+		return errors.New("statsfile.Store: empty match")
 	}
-	return os.WriteFile(StatsPath(seed), data, 0644)
+	return storage.PutBytes(r.backend, Key(match), data)
 }
 
-// Exists checks if stats data exists for the given seed
-func Exists(seed string) bool {
-	_, err := os.Stat(StatsPath(seed))
-	return err == nil
+// Exists checks if stats data exists for the given match
+func (r *Repository) Exists(match string) bool {
+	return match != "" && storage.Exists(r.backend, Key(match))
 }
 
-// Size returns the size in bytes of the stored stats file for the given seed.
+// Size returns the size in bytes of the stored stats file for the given match.
 // It returns an error wrapping os.ErrNotExist if no file is stored.
-func Size(seed string) (int64, error) {
-	info, err := os.Stat(StatsPath(seed))
+func (r *Repository) Size(match string) (int64, error) {
+	info, err := r.backend.Stat(Key(match))
 	if err != nil {
 		return 0, err
 	}
-	return info.Size(), nil
+	return info.Size, nil
 }
 
-// Load reads and decompresses stats data for the given seed
-func Load(seed string) (*GameStats, error) {
-	f, err := os.Open(StatsPath(seed))
+// Load reads and decompresses stats data for the given match, migrated to
+// CurrentVersion
+func (r *Repository) Load(match string) (*GameStats, error) {
+	return r.LoadSections(match, SectionAll)
+}
+
+// LoadSections is Load for callers that need only some sections; the rest
+// are skipped while streaming and left nil. See DecodeSections.
+func (r *Repository) LoadSections(match string, want Section) (*GameStats, error) {
+	f, err := r.backend.Get(Key(match))
 	if err != nil {
 		return nil, fmt.Errorf("open stats file: %w", err)
 	}
@@ -221,15 +243,13 @@ func Load(seed string) (*GameStats, error) {
 	}
 	defer gz.Close()
 
-	data, err := io.ReadAll(gz)
+	stats, err := DecodeSections(gz, want)
 	if err != nil {
-		return nil, fmt.Errorf("read stats: %w", err)
+		return nil, err
 	}
-
-	var stats GameStats
-	if err := json.Unmarshal(data, &stats); err != nil {
-		return nil, fmt.Errorf("parse stats JSON: %w", err)
+	if err := Migrate(stats); err != nil {
+		return nil, err
 	}
 
-	return &stats, nil
+	return stats, nil
 }
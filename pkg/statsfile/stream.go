@@ -0,0 +1,249 @@
+package statsfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Section selects parts of a stats file to decode. Version and game info
+// are small and always decoded; everything else can be left out, and is
+// then skipped token by token without being held in memory.
+type Section uint32
+
+const (
+	SectionPlayers Section = 1 << iota
+	SectionBuildEvents
+	SectionKillEvents
+	SectionCaptureEvents
+	SectionEnergyEvents
+	SectionRankEvents
+	SectionSkillPointsEvents
+	SectionSciencePointsEvents
+	SectionRadarEvents
+	SectionDeathEvents
+	SectionBattlePlanEvents
+	SectionTimeSeries
+
+	SectionAll Section = 1<<iota - 1
+)
+
+// MaxDecodedSize caps the decompressed JSON DecodeSections reads, skipped
+// sections included, so a small gzip bomb can't keep the server busy. The
+// longest 8-player games export well under a tenth of it.
+const MaxDecodedSize = 512 << 20
+
+// ErrTooLarge is returned when a stats file decompresses to more than
+// MaxDecodedSize bytes.
+var ErrTooLarge = errors.New("statsfile: decompressed stats exceed size limit")
+
+// sections maps each top-level key to its Section and a decoder that
+// reads its value straight into a GameStats. Keys match the JSON tags.
+var sections = []struct {
+	name    string
+	section Section
+	decode  func(*json.Decoder, *GameStats) error
+}{
+	{"players", SectionPlayers, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.Players) }},
+	{"buildEvents", SectionBuildEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.BuildEvents) }},
+	{"killEvents", SectionKillEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.KillEvents) }},
+	{"captureEvents", SectionCaptureEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.CaptureEvents) }},
+	{"energyEvents", SectionEnergyEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.EnergyEvents) }},
+	{"rankEvents", SectionRankEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.RankEvents) }},
+	{"skillPointsEvents", SectionSkillPointsEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.SkillPointsEvents) }},
+	{"sciencePointsEvents", SectionSciencePointsEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.SciencePointsEvents) }},
+	{"radarEvents", SectionRadarEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.RadarEvents) }},
+	{"deathEvents", SectionDeathEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.DeathEvents) }},
+	{"battlePlanEvents", SectionBattlePlanEvents, func(d *json.Decoder, s *GameStats) error { return decodeArray(d, &s.BattlePlanEvents) }},
+	{"timeSeries", SectionTimeSeries, decodeTimeSeries},
+}
+
+// ParseSections reads a comma-separated list of section names, spelled as
+// the JSON keys ("players,deathEvents"). An empty list or "all" selects
+// every section.
+func ParseSections(list string) (Section, error) {
+	var want Section
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			return SectionAll, nil
+		}
+		found := false
+		for _, sec := range sections {
+			if sec.name == name {
+				want |= sec.section
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("statsfile: unknown section %q", name)
+		}
+	}
+	if want == 0 {
+		return SectionAll, nil
+	}
+	return want, nil
+}
+
+// DecodeSections parses stats JSON from r, keeping only the sections in
+// want. It walks the document token by token and decodes list sections
+// one element at a time, so at most one element is buffered beyond what
+// is kept, and it stops with ErrTooLarge after MaxDecodedSize bytes.
+// Sections left out are nil in the result. Like Decode, it doesn't
+// migrate.
+func DecodeSections(r io.Reader, want Section) (*GameStats, error) {
+	return decodeSections(r, want, MaxDecodedSize)
+}
+
+func decodeSections(r io.Reader, want Section, limit int64) (*GameStats, error) {
+	dec := json.NewDecoder(&capReader{r: r, n: limit})
+	var s GameStats
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "version":
+			return dec.Decode(&s.Version)
+		case "migratedFrom":
+			return dec.Decode(&s.MigratedFrom)
+		case "game":
+			return dec.Decode(&s.Game)
+		}
+		for _, sec := range sections {
+			if sec.name == key && want&sec.section != 0 {
+				return sec.decode(dec, &s)
+			}
+		}
+		return skipValue(dec)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parse stats JSON: %w", err)
+	}
+	return &s, nil
+}
+
+// decodeObject reads one JSON object, calling field for each key with the
+// decoder positioned at its value. field must consume the value.
+func decodeObject(dec *json.Decoder, field func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected object key, got %v", tok)
+		}
+		if err := field(key); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeArray reads a JSON array into dst one element at a time. null
+// leaves dst nil, as json.Unmarshal does.
+func decodeArray[T any](dec *json.Decoder, dst *[]T) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*dst = nil
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("expected array, got %v", tok)
+	}
+	out := make([]T, 0)
+	for dec.More() {
+		var e T
+		if err := dec.Decode(&e); err != nil {
+			return err
+		}
+		out = append(out, e)
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return err
+	}
+	*dst = out
+	return nil
+}
+
+// decodeTimeSeries streams the per-player series, by far the largest part
+// of a long game's stats.
+func decodeTimeSeries(dec *json.Decoder, s *GameStats) error {
+	s.TimeSeries = TimeSeries{}
+	return decodeObject(dec, func(key string) error {
+		if key == "players" {
+			return decodeArray(dec, &s.TimeSeries.Players)
+		}
+		return skipValue(dec)
+	})
+}
+
+// skipValue consumes the next value, however deeply nested, keeping
+// nothing but the decoder's own small buffer.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("expected %v, got %v", want, tok)
+	}
+	return nil
+}
+
+// capReader fails with ErrTooLarge once more than n bytes have been read.
+type capReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.n < 0 {
+		return 0, ErrTooLarge
+	}
+	// Ask for one byte more than allowed, to tell a file of exactly the
+	// limit from a larger one.
+	if int64(len(p)) > c.n+1 {
+		p = p[:c.n+1]
+	}
+	n, err := c.r.Read(p)
+	if int64(n) > c.n {
+		// The byte past the limit is dropped so the caller can't finish
+		// parsing without seeing the error.
+		n = int(c.n)
+		c.n = -1
+		return n, ErrTooLarge
+	}
+	c.n -= int64(n)
+	return n, err
+}
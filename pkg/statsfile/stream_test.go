@@ -0,0 +1,78 @@
+package statsfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeSections(t *testing.T) {
+	stats := validStats()
+	stats.KillEvents = []KillEvent{{Frame: 50, KillerPlayer: 1, VictimPlayer: 2, Victim: "ChinaDozer"}}
+	stats.DeathEvents = []DeathEvent{{Frame: 90, Player: 2}}
+	raw, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys this package doesn't know are skipped however they nest.
+	raw = append([]byte(`{"exporter":{"name":"x","flags":[1,[2,{"a":null}]]},`), raw[1:]...)
+
+	var want GameStats
+	if err := json.Unmarshal(raw, &want); err != nil {
+		t.Fatal(err)
+	}
+	all, err := DecodeSections(bytes.NewReader(raw), SectionAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*all, want) {
+		t.Errorf("expected the same result as json.Unmarshal\ngot  %+v\nwant %+v", *all, want)
+	}
+
+	some, err := DecodeSections(bytes.NewReader(raw), SectionPlayers|SectionDeathEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if some.Game != want.Game || !reflect.DeepEqual(some.Players, want.Players) || !reflect.DeepEqual(some.DeathEvents, want.DeathEvents) {
+		t.Errorf("expected game, players and deaths, got %+v", some)
+	}
+	if some.KillEvents != nil || some.BuildEvents != nil || some.TimeSeries.Players != nil {
+		t.Errorf("expected unselected sections left nil, got %+v", some)
+	}
+
+	if _, err := decodeSections(bytes.NewReader(raw), SectionPlayers, int64(len(raw)-1)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge one byte under the size, got %v", err)
+	}
+	if _, err := decodeSections(bytes.NewReader(raw), SectionPlayers, int64(len(raw))); err != nil {
+		t.Errorf("expected exactly the size to be allowed, got %v", err)
+	}
+
+	for _, bad := range []string{``, `[]`, `{"players":{}}`, `{"players":[1]}`, `{"killEvents":[{"frame":1}`} {
+		if _, err := DecodeSections(strings.NewReader(bad), SectionAll); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Section
+		err  bool
+	}{
+		{"", SectionAll, false},
+		{"all", SectionAll, false},
+		{"players, deathEvents", SectionPlayers | SectionDeathEvents, false},
+		{"timeSeries,", SectionTimeSeries, false},
+		{"players,bogus", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSections(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseSections(%q) = %b, %v; want %b, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package statsfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/storage"
+)
+
+// Raw uploads are kept beside the merged file so the merge can be redone
+// (or audited) later:
+//
+//	<match>.json.gz                      # merged stats, what Load returns
+//	uploads/<match>/<hash>.json.gz       # each distinct upload, as received
+//	uploads/<match>/contributors.json    # who sent what, in arrival order
+const (
+	uploadsDir           = "uploads"
+	contributorsFilename = "contributors.json"
+)
+
+// legacyClient marks a merged file stored before uploads were kept
+// separately; it is adopted as the match's first upload.
+const legacyClient = "(before merging)"
+
+// Contributor records one upload of a match's stats.
+type Contributor struct {
+	// Client is the API key name of the uploader ("anonymous" without one).
+	Client string `json:"client"`
+	// Hash is the SHA-256 of the raw upload; repeated identical uploads
+	// share one stored copy.
+	Hash       string    `json:"hash"`
+	Size       int       `json:"size"`
+	FrameCount uint      `json:"frameCount"`
+	UploadedAt time.Time `json:"uploadedAt"`
+	// Superseded uploads are kept but left out of the merge, because a
+	// later upload was forced to replace them.
+	Superseded bool `json:"superseded,omitempty"`
+}
+
+// ErrBadUpload is returned by StoreUpload when the data isn't
+// gzip-compressed stats JSON. Payloads that decode but fail Validate get
+// ValidationErrors instead.
+var ErrBadUpload = errors.New("statsfile: upload is not gzip-compressed stats JSON")
+
+// Decode decompresses and parses one stats upload.
+func Decode(data []byte) (*GameStats, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gzip reader: %w", err)
+	}
+	defer gz.Close()
+	return DecodeSections(gz, SectionAll)
+}
+
+// DecodeGame reads only the version and game info of an upload, skipping
+// the rest of the payload as it streams past.
+func DecodeGame(data []byte) (*GameStats, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gzip reader: %w", err)
+	}
+	defer gz.Close()
+	return DecodeSections(gz, 0)
+}
+
+// Encode serializes stats the way uploads arrive: gzip-compressed JSON.
+func Encode(stats *GameStats) ([]byte, error) {
+	raw, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func uploadKey(match, hash string) string {
+	return path.Join(uploadsDir, match, hash+".json.gz")
+}
+
+func contributorsKey(match string) string {
+	return path.Join(uploadsDir, match, contributorsFilename)
+}
+
+// Contributors returns the uploads recorded for a match in arrival order,
+// or an empty slice if there are none.
+func (r *Repository) Contributors(match string) ([]Contributor, error) {
+	if match == "" {
+		return nil, errors.New("statsfile.Contributors: empty match")
+	}
+	b, err := storage.ReadAll(r.backend, contributorsKey(match))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Contributor{}, nil
+		}
+		return nil, err
+	}
+	var out []Contributor
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", contributorsFilename, err)
+	}
+	return out, nil
+}
+
+// StoreUpload validates data and keeps it as one raw upload for match from
+// client, then rebuilds the merged stats from every upload not superseded
+// and stores them as the match's stats. With replace, earlier uploads are marked
+// superseded so the merge is this upload alone. It returns the merged
+// stats and the updated contributor list.
+//
+// Merging is serialized within the process only: two replicas taking
+// uploads for the same match at the same moment can each miss the other's
+// contributor record. The raw uploads themselves are never lost.
+func (r *Repository) StoreUpload(match, client string, data []byte, replace bool) (*GameStats, []Contributor, error) {
+	if match == "" {
+		return nil, nil, errors.New("statsfile.StoreUpload: empty match")
+	}
+	upload, err := Decode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrBadUpload, err)
+	}
+	if err := Validate(upload); err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contributors, err := r.Contributors(match)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(contributors) == 0 {
+		if legacy, err := r.adoptLegacy(match); err != nil {
+			return nil, nil, err
+		} else if legacy != nil {
+			contributors = append(contributors, *legacy)
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if !storage.Exists(r.backend, uploadKey(match, hash)) {
+		if err := storage.PutBytes(r.backend, uploadKey(match, hash), data); err != nil {
+			return nil, nil, fmt.Errorf("store raw upload: %w", err)
+		}
+	}
+	if replace {
+		for i := range contributors {
+			contributors[i].Superseded = true
+		}
+	}
+	contributors = append(contributors, Contributor{
+		Client:     client,
+		Hash:       hash,
+		Size:       len(data),
+		FrameCount: upload.Game.FrameCount,
+		UploadedAt: time.Now().UTC(),
+	})
+
+	merged, err := r.merge(match, contributors)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded, err := Encode(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.Store(match, encoded); err != nil {
+		return nil, nil, err
+	}
+	b, err := json.Marshal(contributors)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := storage.PutBytes(r.backend, contributorsKey(match), b); err != nil {
+		return nil, nil, fmt.Errorf("write %s: %w", contributorsFilename, err)
+	}
+	return merged, contributors, nil
+}
+
+// merge loads each distinct, non-superseded upload once and merges them.
+func (r *Repository) merge(match string, contributors []Contributor) (*GameStats, error) {
+	var uploads []*GameStats
+	loaded := map[string]bool{}
+	for _, c := range contributors {
+		if c.Superseded || loaded[c.Hash] {
+			continue
+		}
+		loaded[c.Hash] = true
+		data, err := storage.ReadAll(r.backend, uploadKey(match, c.Hash))
+		if err != nil {
+			return nil, fmt.Errorf("load upload %s: %w", c.Hash, err)
+		}
+		s, err := Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("decode upload %s: %w", c.Hash, err)
+		}
+		if err := Migrate(s); err != nil {
+			return nil, fmt.Errorf("migrate upload %s: %w", c.Hash, err)
+		}
+		uploads = append(uploads, s)
+	}
+	return Merge(uploads...), nil
+}
+
+// adoptLegacy copies a merged file stored before raw uploads were kept
+// into the uploads area so it takes part in the merge. It returns nil if
+// the match has no stats yet.
+func (r *Repository) adoptLegacy(match string) (*Contributor, error) {
+	data, err := storage.ReadAll(r.backend, Key(match))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	stats, err := Decode(data)
+	if err != nil {
+		// Unreadable; the new upload replaces it.
+		return nil, nil
+	}
+	info, _ := r.backend.Stat(Key(match))
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if err := storage.PutBytes(r.backend, uploadKey(match, hash), data); err != nil {
+		return nil, fmt.Errorf("store legacy upload: %w", err)
+	}
+	return &Contributor{
+		Client:     legacyClient,
+		Hash:       hash,
+		Size:       len(data),
+		FrameCount: stats.Game.FrameCount,
+		UploadedAt: info.ModTime.UTC(),
+	}, nil
+}
@@ -0,0 +1,198 @@
+package statsfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxPlayers is the most player slots a game can have.
+const MaxPlayers = 8
+
+// NeutralPlayer is the player index of objects no player owns (civilian
+// buildings, tech structures), which can be killed and captured.
+const NeutralPlayer = 0
+
+// maxValidationErrors bounds how many problems Validate reports, so a
+// badly broken payload doesn't produce a response bigger than itself.
+const maxValidationErrors = 50
+
+// ValidationError is one problem found in a stats payload. Field is a
+// JSON path such as "players[1].index" or "killEvents[12].frame".
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is every problem Validate found. It is returned as an
+// error so callers can pass it along or unwrap it with errors.As.
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	parts := make([]string, len(v))
+	for i, e := range v {
+		parts[i] = e.Field + ": " + e.Message
+	}
+	return "statsfile: invalid stats: " + strings.Join(parts, "; ")
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	if len(v.errs) < maxValidationErrors {
+		v.errs = append(v.errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Validate checks a decoded payload, before migration, for the mistakes a
+// broken or mismatched exporter makes: a version this server can't read,
+// missing game info, player indices out of range or repeated, events that
+// name unknown players (kills and captures may name NeutralPlayer) or run
+// backwards or past the end of the game, and time series whose lengths
+// don't fit the snapshot interval. It returns nil if the payload is usable.
+func Validate(s *GameStats) error {
+	v := &validator{}
+
+	if s.Version < 0 || s.Version > CurrentVersion {
+		v.add("version", "unsupported version %d (newest supported is %d)", s.Version, CurrentVersion)
+	}
+	if s.Game.FrameCount == 0 {
+		v.add("game.frameCount", "required")
+	}
+	if s.Game.SnapshotInterval < 0 {
+		v.add("game.snapshotInterval", "must not be negative")
+	}
+
+	players := map[int]bool{}
+	if len(s.Players) == 0 {
+		v.add("players", "at least one player is required")
+	}
+	if len(s.Players) > MaxPlayers {
+		v.add("players", "%d players, at most %d allowed", len(s.Players), MaxPlayers)
+	}
+	for i, p := range s.Players {
+		field := fmt.Sprintf("players[%d].index", i)
+		switch {
+		case p.Index < 1 || p.Index > MaxPlayers:
+			v.add(field, "%d out of range 1-%d", p.Index, MaxPlayers)
+		case players[p.Index]:
+			v.add(field, "duplicate index %d", p.Index)
+		}
+		players[p.Index] = true
+	}
+
+	frames := func(name string, n int, frame func(int) uint) {
+		var last uint
+		for i := 0; i < n; i++ {
+			f := frame(i)
+			if f < last {
+				v.add(fmt.Sprintf("%s[%d].frame", name, i), "%d is before the previous event's %d", f, last)
+				return
+			}
+			if s.Game.FrameCount > 0 && f > s.Game.FrameCount {
+				v.add(fmt.Sprintf("%s[%d].frame", name, i), "%d is past the end of the game (%d)", f, s.Game.FrameCount)
+				return
+			}
+			last = f
+		}
+	}
+	owner := func(name string, n int, player func(int) int) {
+		for i := 0; i < n; i++ {
+			if p := player(i); !players[p] {
+				v.add(fmt.Sprintf("%s[%d].player", name, i), "unknown player %d", p)
+				return
+			}
+		}
+	}
+	// party is owner for events that can involve neutral objects, naming
+	// the field checked.
+	party := func(name, field string, n int, player func(int) int) {
+		for i := 0; i < n; i++ {
+			if p := player(i); p != NeutralPlayer && !players[p] {
+				v.add(fmt.Sprintf("%s[%d].%s", name, i, field), "unknown player %d", p)
+				return
+			}
+		}
+	}
+
+	frames("buildEvents", len(s.BuildEvents), func(i int) uint { return s.BuildEvents[i].Frame })
+	owner("buildEvents", len(s.BuildEvents), func(i int) int { return s.BuildEvents[i].Player })
+	frames("killEvents", len(s.KillEvents), func(i int) uint { return s.KillEvents[i].Frame })
+	party("killEvents", "killerPlayer", len(s.KillEvents), func(i int) int { return s.KillEvents[i].KillerPlayer })
+	party("killEvents", "victimPlayer", len(s.KillEvents), func(i int) int { return s.KillEvents[i].VictimPlayer })
+	frames("captureEvents", len(s.CaptureEvents), func(i int) uint { return s.CaptureEvents[i].Frame })
+	party("captureEvents", "newOwner", len(s.CaptureEvents), func(i int) int { return s.CaptureEvents[i].NewOwner })
+	party("captureEvents", "oldOwner", len(s.CaptureEvents), func(i int) int { return s.CaptureEvents[i].OldOwner })
+	frames("energyEvents", len(s.EnergyEvents), func(i int) uint { return s.EnergyEvents[i].Frame })
+	owner("energyEvents", len(s.EnergyEvents), func(i int) int { return s.EnergyEvents[i].Player })
+	frames("rankEvents", len(s.RankEvents), func(i int) uint { return s.RankEvents[i].Frame })
+	owner("rankEvents", len(s.RankEvents), func(i int) int { return s.RankEvents[i].Player })
+	frames("skillPointsEvents", len(s.SkillPointsEvents), func(i int) uint { return s.SkillPointsEvents[i].Frame })
+	owner("skillPointsEvents", len(s.SkillPointsEvents), func(i int) int { return s.SkillPointsEvents[i].Player })
+	frames("sciencePointsEvents", len(s.SciencePointsEvents), func(i int) uint { return s.SciencePointsEvents[i].Frame })
+	owner("sciencePointsEvents", len(s.SciencePointsEvents), func(i int) int { return s.SciencePointsEvents[i].Player })
+	frames("radarEvents", len(s.RadarEvents), func(i int) uint { return s.RadarEvents[i].Frame })
+	owner("radarEvents", len(s.RadarEvents), func(i int) int { return s.RadarEvents[i].Player })
+	frames("deathEvents", len(s.DeathEvents), func(i int) uint { return s.DeathEvents[i].Frame })
+	owner("deathEvents", len(s.DeathEvents), func(i int) int { return s.DeathEvents[i].Player })
+	frames("battlePlanEvents", len(s.BattlePlanEvents), func(i int) uint { return s.BattlePlanEvents[i].Frame })
+	owner("battlePlanEvents", len(s.BattlePlanEvents), func(i int) int { return s.BattlePlanEvents[i].Player })
+
+	validateTimeSeries(v, s, players)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// validateTimeSeries checks that every series of a player has the same
+// length and that the length fits the game: one snapshot per
+// SnapshotInterval frames, plus the first and possibly a final one.
+func validateTimeSeries(v *validator, s *GameStats, players map[int]bool) {
+	maxLen := -1
+	if s.Game.SnapshotInterval > 0 && s.Game.FrameCount > 0 {
+		maxLen = int(s.Game.FrameCount)/s.Game.SnapshotInterval + 2
+	}
+	if s.Game.SnapshotInterval == 0 {
+		for _, p := range s.TimeSeries.Players {
+			if len(p.Money) > 0 {
+				v.add("game.snapshotInterval", "required when time series are present")
+				break
+			}
+		}
+	}
+	seen := map[int]bool{}
+	for i, p := range s.TimeSeries.Players {
+		field := fmt.Sprintf("timeSeries.players[%d]", i)
+		if !players[p.Index] {
+			v.add(field+".index", "unknown player %d", p.Index)
+		} else if seen[p.Index] {
+			v.add(field+".index", "duplicate index %d", p.Index)
+		}
+		seen[p.Index] = true
+
+		n := len(p.Money)
+		if len(p.MoneyEarned) != n {
+			v.add(field+".moneyEarned", "%d snapshots, money has %d", len(p.MoneyEarned), n)
+		}
+		if len(p.MoneySpent) != n {
+			v.add(field+".moneySpent", "%d snapshots, money has %d", len(p.MoneySpent), n)
+		}
+		sources := make([]string, 0, len(p.IncomeBySource))
+		for source := range p.IncomeBySource {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			if got := len(p.IncomeBySource[source]); got != n {
+				v.add(field+".incomeBySource."+source, "%d snapshots, money has %d", got, n)
+			}
+		}
+		if maxLen >= 0 && n > maxLen {
+			v.add(field+".money", "%d snapshots, at most %d fit %d frames every %d", n, maxLen, s.Game.FrameCount, s.Game.SnapshotInterval)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package statsfile
+
+import (
+	"errors"
+	"testing"
+)
+
+func validStats() *GameStats {
+	return &GameStats{
+		Version: 2,
+		Game:    GameInfo{FrameCount: 900, SnapshotInterval: 300},
+		Players: []Player{{Index: 1}, {Index: 2}},
+		BuildEvents: []BuildEvent{
+			{Frame: 10, Player: 1},
+			{Frame: 10, Player: 2},
+			{Frame: 400, Player: 1},
+		},
+		KillEvents:    []KillEvent{{Frame: 20, KillerPlayer: 1, VictimPlayer: 2}, {Frame: 30, KillerPlayer: 2, VictimPlayer: NeutralPlayer}},
+		CaptureEvents: []CaptureEvent{{Frame: 40, NewOwner: 1, OldOwner: NeutralPlayer}},
+		TimeSeries: TimeSeries{Players: []TimeSeriesPlayer{{
+			Index:          1,
+			Money:          []uint{1, 2, 3, 4},
+			MoneyEarned:    []int{1, 2, 3, 4},
+			MoneySpent:     []int{0, 0, 0, 0},
+			IncomeBySource: map[string][]int{"supply": {1, 2, 3, 4}},
+		}}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(validStats()); err != nil {
+		t.Fatalf("expected valid stats, got %v", err)
+	}
+
+	fields := func(s *GameStats) []string {
+		t.Helper()
+		var invalid ValidationErrors
+		if !errors.As(Validate(s), &invalid) {
+			t.Fatal("expected ValidationErrors")
+		}
+		var out []string
+		for _, e := range invalid {
+			out = append(out, e.Field)
+		}
+		return out
+	}
+	tests := []struct {
+		name  string
+		edit  func(*GameStats)
+		field string
+	}{
+		{"future version", func(s *GameStats) { s.Version = 3 }, "version"},
+		{"no frame count", func(s *GameStats) { s.Game.FrameCount = 0 }, "game.frameCount"},
+		{"no players", func(s *GameStats) { s.Players = nil; s.BuildEvents = nil; s.TimeSeries.Players = nil }, "players"},
+		{"index out of range", func(s *GameStats) { s.Players[1].Index = 9 }, "players[1].index"},
+		{"duplicate index", func(s *GameStats) { s.Players[1].Index = 1 }, "players[1].index"},
+		{"frames backwards", func(s *GameStats) { s.BuildEvents[2].Frame = 5 }, "buildEvents[2].frame"},
+		{"frame past end", func(s *GameStats) { s.BuildEvents[2].Frame = 901 }, "buildEvents[2].frame"},
+		{"unknown player", func(s *GameStats) { s.BuildEvents[1].Player = 3 }, "buildEvents[1].player"},
+		{"unknown killer", func(s *GameStats) { s.KillEvents[1].KillerPlayer = 5 }, "killEvents[1].killerPlayer"},
+		{"unknown victim", func(s *GameStats) { s.KillEvents[0].VictimPlayer = -1 }, "killEvents[0].victimPlayer"},
+		{"unknown capturer", func(s *GameStats) { s.CaptureEvents[0].NewOwner = 4 }, "captureEvents[0].newOwner"},
+		{"unknown previous owner", func(s *GameStats) { s.CaptureEvents[0].OldOwner = 9 }, "captureEvents[0].oldOwner"},
+		{"ragged series", func(s *GameStats) { s.TimeSeries.Players[0].MoneySpent = []int{0} }, "timeSeries.players[0].moneySpent"},
+		{"ragged income", func(s *GameStats) { s.TimeSeries.Players[0].IncomeBySource["supply"] = nil }, "timeSeries.players[0].incomeBySource.supply"},
+		{"series too long", func(s *GameStats) { s.Game.SnapshotInterval = 600 }, "timeSeries.players[0].money"},
+		{"no interval", func(s *GameStats) { s.Game.SnapshotInterval = 0 }, "game.snapshotInterval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validStats()
+			tt.edit(s)
+			// Later errors may follow from the first (events naming a
+			// player whose index is now wrong), so only the first is checked.
+			if got := fields(s); got[0] != tt.field {
+				t.Errorf("expected an error on %s first, got %v", tt.field, got)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := &GameStats{
+		Game:    GameInfo{FrameCount: 60, SnapshotInterval: 30},
+		Players: []Player{{Index: 1, MoneyEarned: 500}},
+		TimeSeries: TimeSeries{Players: []TimeSeriesPlayer{{
+			Index: 1, Money: []uint{0, 100}, MoneyEarned: []int{0, 500}, MoneySpent: []int{0, 400},
+		}}},
+	}
+	if err := Migrate(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != CurrentVersion || s.MigratedFrom != 1 {
+		t.Errorf("expected version %d migrated from 1, got %d from %d", CurrentVersion, s.Version, s.MigratedFrom)
+	}
+	if s.Players[0].IncomeBySource[IncomeOther] != 500 {
+		t.Errorf("expected all income under %q, got %v", IncomeOther, s.Players[0].IncomeBySource)
+	}
+	series := s.TimeSeries.Players[0].IncomeBySource[IncomeOther]
+	if len(series) != 2 || series[1] != 500 {
+		t.Errorf("expected the earned series copied, got %v", series)
+	}
+	if err := Validate(s); err != nil {
+		t.Errorf("expected migrated stats to validate, got %v", err)
+	}
+
+	// Current payloads are untouched; future ones are refused.
+	current := validStats()
+	if err := Migrate(current); err != nil || current.MigratedFrom != 0 {
+		t.Errorf("expected no migration, got from %d, %v", current.MigratedFrom, err)
+	}
+	if err := Migrate(&GameStats{Version: CurrentVersion + 1}); err == nil {
+		t.Error("expected a future version to be refused")
+	}
+}
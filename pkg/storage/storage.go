@@ -0,0 +1,123 @@
+// Package storage abstracts where stats, logs and maps are persisted, so
+// the server can keep them on local disk or, when several stateless
+// replicas share the data, in an S3-compatible object store.
+//
+// Keys are slash-separated relative paths ("1234/map.map"). Backends
+// report a missing key with an error for which os.IsNotExist (and
+// errors.Is(err, fs.ErrNotExist)) is true.
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Backend stores opaque objects by key.
+type Backend interface {
+	// Put stores the contents of r under key, replacing any existing
+	// object. size is the number of bytes r will yield, or -1 if unknown.
+	// Readers never observe a partially written object.
+	Put(key string, r io.Reader, size int64) error
+	// Get opens the object for reading. The caller closes it.
+	Get(key string) (io.ReadCloser, error)
+	// Stat returns the object's size and modification time.
+	Stat(key string) (Info, error)
+	// List returns every object whose key starts with prefix, sorted by
+	// key. A prefix ending in "/" lists a "directory" recursively.
+	List(prefix string) ([]Info, error)
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// Info describes one stored object.
+type Info struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ReadAll returns the whole object stored under key.
+func ReadAll(b Backend, key string) ([]byte, error) {
+	rc, err := b.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// PutBytes stores data under key.
+func PutBytes(b Backend, key string, data []byte) error {
+	return b.Put(key, bytes.NewReader(data), int64(len(data)))
+}
+
+// Exists reports whether an object is stored under key.
+func Exists(b Backend, key string) bool {
+	_, err := b.Stat(key)
+	return err == nil
+}
+
+// CheckKey rejects keys that could escape the backend's root: empty
+// keys, absolute paths, backslashes and "." or ".." segments.
+func CheckKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("storage: invalid key %q", key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("storage: invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// Open returns the backend the environment configures for one kind of
+// data. With STORAGE_BACKEND=s3, objects live under "<S3_PREFIX>/<name>/"
+// in S3_BUCKET; otherwise they live on disk under dir, which keeps the
+// STATS_DIR / LOGS_DIR / MAPS_DIR layouts of single-server deployments.
+func Open(name, dir string) (Backend, error) {
+	switch backend := strings.ToLower(os.Getenv("STORAGE_BACKEND")); backend {
+	case "", "fs", "file":
+		return NewFS(dir), nil
+	case "s3":
+		cfg := S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    firstEnv("S3_REGION", "AWS_REGION"),
+			AccessKey: firstEnv("S3_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID"),
+			SecretKey: firstEnv("S3_SECRET_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY"),
+			Prefix:    joinPrefix(os.Getenv("S3_PREFIX"), name),
+		}
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("storage: unknown STORAGE_BACKEND %q (want fs or s3)", backend)
+	}
+}
+
+func firstEnv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// joinPrefix joins non-empty prefix parts with "/" and adds a trailing
+// slash, so keys can be appended directly.
+func joinPrefix(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.Trim(p, "/"); p != "" {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "/") + "/"
+}
@@ -0,0 +1,179 @@
+// Package tga decodes Truevision TGA images, the format Generals uses for
+// map previews (preview.tga) and most UI art.
+//
+// Only the true-color variants the game writes are supported: image types 2
+// (uncompressed) and 10 (run-length encoded) at 24 or 32 bits per pixel,
+// with either origin. Color-mapped and grayscale images are rejected.
+package tga
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// headerSize is the fixed TGA file header.
+const headerSize = 18
+
+// Image types from the header's third byte.
+const (
+	typeTrueColor    = 2
+	typeTrueColorRLE = 10
+)
+
+// Image descriptor bits.
+const (
+	descAlphaBits   = 0x0F
+	descRightToLeft = 0x10
+	descTopToBottom = 0x20
+)
+
+// maxDimension rejects headers that would make us allocate an absurd
+// image. Generals previews are 128x128; the largest UI art is 2048 wide.
+const maxDimension = 2048
+
+var errTruncated = errors.New("tga: truncated image data")
+
+type header struct {
+	idLength     uint8
+	colorMapType uint8
+	imageType    uint8
+	colorMapLen  uint16
+	colorMapBits uint8
+	width        int
+	height       int
+	bitsPerPixel uint8
+	descriptor   uint8
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var b [headerSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return header{}, fmt.Errorf("tga: reading header: %w", err)
+	}
+	h := header{
+		idLength:     b[0],
+		colorMapType: b[1],
+		imageType:    b[2],
+		colorMapLen:  uint16(b[5]) | uint16(b[6])<<8,
+		colorMapBits: b[7],
+		width:        int(b[12]) | int(b[13])<<8,
+		height:       int(b[14]) | int(b[15])<<8,
+		bitsPerPixel: b[16],
+		descriptor:   b[17],
+	}
+	if h.imageType != typeTrueColor && h.imageType != typeTrueColorRLE {
+		return h, fmt.Errorf("tga: unsupported image type %d", h.imageType)
+	}
+	if h.bitsPerPixel != 24 && h.bitsPerPixel != 32 {
+		return h, fmt.Errorf("tga: unsupported %d bits per pixel", h.bitsPerPixel)
+	}
+	if h.width == 0 || h.height == 0 || h.width > maxDimension || h.height > maxDimension {
+		return h, fmt.Errorf("tga: bad dimensions %dx%d", h.width, h.height)
+	}
+	return h, nil
+}
+
+// DecodeConfig returns the dimensions and color model of a TGA image
+// without decoding the pixels.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+// Decode reads a TGA image. The result is always an *image.NRGBA; 24-bit
+// images and 32-bit images whose descriptor declares no alpha bits come
+// back fully opaque.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	// Skip the image ID and any color map; a true-color image may still
+	// carry one, which we don't need.
+	skip := int(h.idLength)
+	if h.colorMapType != 0 {
+		skip += int(h.colorMapLen) * ((int(h.colorMapBits) + 7) / 8)
+	}
+	if _, err := br.Discard(skip); err != nil {
+		return nil, errTruncated
+	}
+
+	bpp := int(h.bitsPerPixel) / 8
+	pixels := make([]byte, h.width*h.height*bpp)
+	if h.imageType == typeTrueColorRLE {
+		err = readRLE(br, pixels, bpp)
+	} else {
+		_, err = io.ReadFull(br, pixels)
+	}
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = errTruncated
+		}
+		return nil, err
+	}
+
+	hasAlpha := bpp == 4 && h.descriptor&descAlphaBits != 0
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+	for y := 0; y < h.height; y++ {
+		// Rows are stored bottom-up unless the descriptor says otherwise.
+		row := h.height - 1 - y
+		if h.descriptor&descTopToBottom != 0 {
+			row = y
+		}
+		for x := 0; x < h.width; x++ {
+			col := x
+			if h.descriptor&descRightToLeft != 0 {
+				col = h.width - 1 - x
+			}
+			src := pixels[(row*h.width+col)*bpp:]
+			dst := img.Pix[y*img.Stride+x*4:]
+			// Pixels are stored BGR(A).
+			dst[0], dst[1], dst[2], dst[3] = src[2], src[1], src[0], 0xFF
+			if hasAlpha {
+				dst[3] = src[3]
+			}
+		}
+	}
+	return img, nil
+}
+
+// readRLE fills pixels from run-length packets. Each packet is a header
+// byte whose high bit marks a run (one pixel repeated) and whose low seven
+// bits hold the count minus one, followed by the pixel data.
+func readRLE(r *bufio.Reader, pixels []byte, bpp int) error {
+	for pos := 0; pos < len(pixels); {
+		packet, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		n := (int(packet&0x7F) + 1) * bpp
+		if pos+n > len(pixels) {
+			return errors.New("tga: run-length packet overruns image")
+		}
+		if packet&0x80 == 0 {
+			if _, err := io.ReadFull(r, pixels[pos:pos+n]); err != nil {
+				return err
+			}
+		} else {
+			if _, err := io.ReadFull(r, pixels[pos:pos+bpp]); err != nil {
+				return err
+			}
+			for i := pos + bpp; i < pos+n; i += bpp {
+				copy(pixels[i:i+bpp], pixels[pos:pos+bpp])
+			}
+		}
+		pos += n
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package tga
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func tgaHeader(imageType, bpp, descriptor byte, w, h int) []byte {
+	return []byte{
+		3, 0, imageType, // 3-byte image ID, no color map
+		0, 0, 0, 0, 0,
+		0, 0, 0, 0,
+		byte(w), byte(w >> 8), byte(h), byte(h >> 8),
+		bpp, descriptor,
+		'i', 'd', '!',
+	}
+}
+
+// Pixels of a 2x2 test image, top row first.
+var (
+	red   = color.NRGBA{0xFF, 0, 0, 0xFF}
+	green = color.NRGBA{0, 0xFF, 0, 0x80}
+	blue  = color.NRGBA{0, 0, 0xFF, 0xFF}
+	white = color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+func bgra(c color.NRGBA) []byte { return []byte{c.B, c.G, c.R, c.A} }
+func bgr(c color.NRGBA) []byte  { return []byte{c.B, c.G, c.R} }
+
+func checkPixels(t *testing.T, data []byte, want [4]color.NRGBA) {
+	t.Helper()
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("expected 2x2, got %v", b)
+	}
+	for i, w := range want {
+		if got := img.At(i%2, i/2).(color.NRGBA); got != w {
+			t.Errorf("pixel (%d,%d): expected %v, got %v", i%2, i/2, w, got)
+		}
+	}
+}
+
+func TestDecodeUncompressed(t *testing.T) {
+	// 32-bit with 8 alpha bits, bottom-up: bottom row (blue, white) first.
+	data := tgaHeader(typeTrueColor, 32, 8, 2, 2)
+	for _, c := range []color.NRGBA{blue, white, red, green} {
+		data = append(data, bgra(c)...)
+	}
+	checkPixels(t, data, [4]color.NRGBA{red, green, blue, white})
+
+	// 24-bit, top-down.
+	data = tgaHeader(typeTrueColor, 24, descTopToBottom, 2, 2)
+	for _, c := range []color.NRGBA{red, blue, blue, white} {
+		data = append(data, bgr(c)...)
+	}
+	checkPixels(t, data, [4]color.NRGBA{red, blue, blue, white})
+}
+
+func TestDecodeRLE(t *testing.T) {
+	// A run of three blue pixels then one raw white pixel, top-down.
+	data := tgaHeader(typeTrueColorRLE, 24, descTopToBottom, 2, 2)
+	data = append(data, 0x82)
+	data = append(data, bgr(blue)...)
+	data = append(data, 0x00)
+	data = append(data, bgr(white)...)
+	checkPixels(t, data, [4]color.NRGBA{blue, blue, blue, white})
+
+	// A run longer than the image must not write past it.
+	bad := tgaHeader(typeTrueColorRLE, 24, 0, 2, 2)
+	bad = append(bad, 0x84)
+	bad = append(bad, bgr(blue)...)
+	if _, err := Decode(bytes.NewReader(bad)); err == nil {
+		t.Error("expected error for overlong run")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	short := tgaHeader(typeTrueColor, 24, 0, 2, 2)
+	short = append(short, 1, 2, 3)
+	if _, err := Decode(bytes.NewReader(short)); err == nil {
+		t.Error("expected error for truncated pixels")
+	}
+	if _, err := Decode(bytes.NewReader(tgaHeader(1, 8, 0, 2, 2))); err == nil {
+		t.Error("expected error for color-mapped image")
+	}
+	if _, err := Decode(bytes.NewReader([]byte{0, 0, 2})); err == nil {
+		t.Error("expected error for truncated header")
+	}
+	if _, err := DecodeConfig(bytes.NewReader(tgaHeader(typeTrueColor, 32, 8, 8192, 8192))); err == nil {
+		t.Error("expected error for dimensions past maxDimension")
+	}
+	cfg, err := DecodeConfig(bytes.NewReader(tgaHeader(typeTrueColor, 32, 8, 128, 64)))
+	if err != nil || cfg.Width != 128 || cfg.Height != 64 {
+		t.Errorf("unexpected config %+v, %v", cfg, err)
+	}
+}
@@ -83,6 +83,12 @@ type EnhancedReplayV2 struct {
 	Body          []*body.BodyChunk      `json:"body"`
 	Summary       []*PlayerSummaryV2     `json:"summary"`
 	PlayerIDOffset int                   `json:"offset"`
+	// Language is the language DisplayNames were resolved in; empty when
+	// the replay was not localized.
+	Language string `json:"language,omitempty"`
+	// DisplayNames maps template names used anywhere in the response (e.g.
+	// "Tank_ChinaVehicleDozer") to the localized name shown in game.
+	DisplayNames map[string]string `json:"displayNames,omitempty"`
 }
 
 // GameInfoV2 holds non-duplicate game metadata from the stats file.
@@ -115,6 +121,9 @@ type PlayerSummaryV2 struct {
 	BuildingsBuilt map[string]*object.ObjectSummary `json:"buildingsBuilt"`
 	UpgradesBuilt  map[string]*object.ObjectSummary `json:"upgradesBuilt"`
 	PowersUsed     map[string]int                   `json:"powersUsed"`
+	// PowerTimelines covers superweapons and general's powers; see
+	// BuildPowerTimelines.
+	PowerTimelines []*PowerTimeline `json:"powerTimelines,omitempty"`
 }
 
 // EnrichedBuildEvent embeds a BuildEvent and adds object type classification.
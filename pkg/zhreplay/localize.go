@@ -0,0 +1,77 @@
+package zhreplay
+
+import (
+	"github.com/bill-rich/cncstats/pkg/gametext"
+)
+
+// Localize fills DisplayNames with the player-facing name of every template
+// the replay mentions: the summary maps and, when stats were merged, the
+// build, kill and capture events. label maps a template name to its
+// string-table label (an Object's DisplayName); tables are searched in order,
+// so pass a map.str sidecar before the language table and the english table
+// last as a fallback. Templates with no label or no string are left out and
+// consumers should show the template name.
+func (v2 *EnhancedReplayV2) Localize(language string, label func(name string) string, tables ...*gametext.Table) {
+	if v2 == nil || label == nil {
+		return
+	}
+	names := map[string]string{}
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		if _, done := names[name]; done {
+			return
+		}
+		names[name] = ""
+		key := label(name)
+		if key == "" {
+			return
+		}
+		for _, t := range tables {
+			if text, ok := t.Lookup(key); ok && text != "" {
+				names[name] = text
+				return
+			}
+		}
+	}
+
+	for _, p := range v2.Summary {
+		if p == nil {
+			continue
+		}
+		for name := range p.UnitsCreated {
+			add(name)
+		}
+		for name := range p.BuildingsBuilt {
+			add(name)
+		}
+		for name := range p.UpgradesBuilt {
+			add(name)
+		}
+		for name := range p.PowersUsed {
+			add(name)
+		}
+	}
+	if v2.Stats != nil {
+		for _, e := range v2.Stats.BuildEvents {
+			add(e.Object)
+			add(e.Producer)
+		}
+		for _, e := range v2.Stats.KillEvents {
+			add(e.Killer)
+			add(e.Victim)
+		}
+		for _, e := range v2.Stats.CaptureEvents {
+			add(e.Object)
+		}
+	}
+
+	v2.Language = language
+	v2.DisplayNames = make(map[string]string, len(names))
+	for name, text := range names {
+		if text != "" {
+			v2.DisplayNames[name] = text
+		}
+	}
+}
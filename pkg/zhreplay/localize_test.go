@@ -0,0 +1,50 @@
+package zhreplay
+
+import (
+	"testing"
+
+	"github.com/bill-rich/cncstats/pkg/gametext"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/object"
+)
+
+func TestLocalize(t *testing.T) {
+	v2 := &EnhancedReplayV2{
+		Summary: []*PlayerSummaryV2{{
+			UnitsCreated:   map[string]*object.ObjectSummary{"ChinaTankBattleMaster": {Count: 1}},
+			BuildingsBuilt: map[string]*object.ObjectSummary{"ChinaBarracks": {Count: 1}},
+			UpgradesBuilt:  map[string]*object.ObjectSummary{"Upgrade_Nationalism": {Count: 1}},
+			PowersUsed:     map[string]int{"NoLabelPower": 1},
+		}},
+	}
+	labels := map[string]string{
+		"ChinaTankBattleMaster": "OBJECT:BattleMaster",
+		"ChinaBarracks":         "OBJECT:Barracks",
+		"Upgrade_Nationalism":   "UPGRADE:Nationalism",
+	}
+	mapStr := &gametext.Table{Strings: map[string]string{"object:battlemaster": "Custom Tank"}}
+	german := &gametext.Table{Language: "german", Strings: map[string]string{"object:barracks": "Kaserne"}}
+	english := &gametext.Table{Language: "english", Strings: map[string]string{
+		"object:battlemaster": "Battlemaster",
+		"object:barracks":     "Barracks",
+		"upgrade:nationalism": "Nationalism",
+	}}
+
+	v2.Localize("german", func(name string) string { return labels[name] }, mapStr, german, english)
+
+	want := map[string]string{
+		"ChinaTankBattleMaster": "Custom Tank",
+		"ChinaBarracks":         "Kaserne",
+		"Upgrade_Nationalism":   "Nationalism",
+	}
+	if v2.Language != "german" {
+		t.Errorf("expected language german, got %q", v2.Language)
+	}
+	if len(v2.DisplayNames) != len(want) {
+		t.Errorf("expected %d names, got %v", len(want), v2.DisplayNames)
+	}
+	for name, text := range want {
+		if got := v2.DisplayNames[name]; got != text {
+			t.Errorf("%s: expected %q, got %q", name, text, got)
+		}
+	}
+}
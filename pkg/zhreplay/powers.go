@@ -0,0 +1,225 @@
+package zhreplay
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/iniparse"
+)
+
+const framesPerSecond = 30
+
+// promptFireFrames is how late a shot can be and still count as fired as
+// soon as ready: five seconds covers finding a target and clicking.
+const promptFireFrames = 5 * framesPerSecond
+
+// Power timeline categories. Unit abilities (Burton's charges, Ranger
+// capture) fire far too often to be interesting and are left out.
+const (
+	PowerCategorySuperweapon = "superweapon"
+	PowerCategoryGeneral     = "general"
+)
+
+// PowerFiring is one use of a power. ReadyFrame is when the source that
+// fired was next available, or -1 when that isn't known (the first shot of
+// a source whose build or unlock time isn't in the data).
+type PowerFiring struct {
+	Frame       int `json:"frame"`
+	ReadyFrame  int `json:"readyFrame"`
+	DelayFrames int `json:"delayFrames"`
+	// Source numbers the timer that fired: each superweapon building has
+	// its own, general's powers share one.
+	Source int `json:"source"`
+}
+
+// PowerTimeline is one player's use of one superweapon or general's power.
+type PowerTimeline struct {
+	Power           string        `json:"power"`
+	Category        string        `json:"category"`
+	ReloadFrames    int           `json:"reloadFrames"`
+	RequiredScience string        `json:"requiredScience,omitempty"`
+	GrantedBy       []string      `json:"grantedBy,omitempty"`
+	Firings         []PowerFiring `json:"firings"`
+
+	// Efficiency metrics, over firings with a known ReadyFrame.
+	MeasuredFirings int `json:"measuredFirings"`
+	// PromptFirings counts shots fired within five seconds of being ready.
+	PromptFirings    int     `json:"promptFirings"`
+	MeanDelaySeconds float64 `json:"meanDelaySeconds"`
+	// Efficiency is reload time over reload-plus-delay across measured
+	// firings: 1.0 means every shot went off the moment it was ready.
+	Efficiency float64 `json:"efficiency"`
+	// MissedFirings is how many more shots the sources had time for
+	// between their last ready frame and the end of the game. A building
+	// destroyed while charged still counts, since we can't see that here.
+	MissedFirings int `json:"missedFirings"`
+}
+
+// powerCategory classifies a power for timelines, or "" for powers that
+// don't get one. Anything unlocked by a science or on a shared timer is a
+// general's power; the remaining Superweapon* templates are the buildings.
+func powerCategory(p *iniparse.Power) string {
+	switch {
+	case p.RequiredScience != "" || p.SharedSyncedTimer:
+		return PowerCategoryGeneral
+	case strings.HasPrefix(p.Name, "Superweapon"):
+		return PowerCategorySuperweapon
+	}
+	return ""
+}
+
+func durationToFrames(d time.Duration) int {
+	return int(d * framesPerSecond / time.Second)
+}
+
+// BuildPowerTimelines fills in each player's PowerTimelines from the
+// special power orders in the body. When stats were merged, the build
+// events of each power's granting objects seed the first ready frame of
+// each source; otherwise the first shot of every source is unmeasured.
+func (v2 *EnhancedReplayV2) BuildPowerTimelines(powers *iniparse.PowerStore) {
+	if v2 == nil || powers == nil {
+		return
+	}
+	endFrame := 0
+	if v2.GameInfo != nil {
+		endFrame = int(v2.GameInfo.FrameCount)
+	}
+	for _, chunk := range v2.Body {
+		if chunk.TimeCode > endFrame {
+			endFrame = chunk.TimeCode
+		}
+	}
+
+	for _, p := range v2.Summary {
+		if p == nil || p.Side == "Observer" {
+			continue
+		}
+		fired := map[string][]int{}
+		var order []string
+		for _, chunk := range v2.Body {
+			if chunk.PlayerName != p.Name || chunk.Details == nil {
+				continue
+			}
+			switch chunk.OrderCode {
+			case 1040, 1041, 1042: // SpecialPower variants
+			default:
+				continue
+			}
+			name := chunk.Details.GetName()
+			if _, seen := fired[name]; !seen {
+				order = append(order, name)
+			}
+			fired[name] = append(fired[name], chunk.TimeCode)
+		}
+
+		p.PowerTimelines = nil
+		for _, name := range order {
+			power := powers.GetPowerByName(name)
+			if power == nil {
+				continue
+			}
+			category := powerCategory(power)
+			if category == "" {
+				continue
+			}
+			tl := &PowerTimeline{
+				Power:           name,
+				Category:        category,
+				ReloadFrames:    durationToFrames(power.ReloadTime),
+				RequiredScience: power.RequiredScience,
+				GrantedBy:       powers.GrantedBy(name),
+			}
+			tl.fill(fired[name], v2.sourceReadyFrames(p.Index, tl), power.SharedSyncedTimer, endFrame)
+			p.PowerTimelines = append(p.PowerTimelines, tl)
+		}
+	}
+}
+
+// sourceReadyFrames returns the first ready frame of each source the stats
+// show the player building: build frame plus one reload per granting object.
+func (v2 *EnhancedReplayV2) sourceReadyFrames(playerIndex int, tl *PowerTimeline) []int {
+	if v2.Stats == nil || playerIndex == 0 || len(tl.GrantedBy) == 0 {
+		return nil
+	}
+	var ready []int
+	for _, ev := range v2.Stats.BuildEvents {
+		if ev.Player != playerIndex {
+			continue
+		}
+		for _, obj := range tl.GrantedBy {
+			if ev.Object == obj {
+				ready = append(ready, int(ev.Frame)+tl.ReloadFrames)
+				break
+			}
+		}
+	}
+	sort.Ints(ready)
+	return ready
+}
+
+// fill assigns each firing to a source and computes the metrics. Sources
+// start from seeded (known) ready frames; a shot with no source ready yet
+// means a source we didn't see built, so a new, unmeasured one is opened.
+// A shared timer has exactly one source.
+func (tl *PowerTimeline) fill(frames []int, seeded []int, shared bool, endFrame int) {
+	type source struct{ ready int } // -1: never seen ready
+	var sources []*source
+	for _, r := range seeded {
+		sources = append(sources, &source{ready: r})
+		if shared {
+			break
+		}
+	}
+
+	var delaySum, reloadSum int
+	tl.Firings = make([]PowerFiring, 0, len(frames))
+	for _, f := range frames {
+		// Pick the source that has been ready longest.
+		best := -1
+		for i, s := range sources {
+			if s.ready >= 0 && s.ready <= f && (best < 0 || s.ready < sources[best].ready) {
+				best = i
+			}
+		}
+		if best < 0 {
+			if shared && len(sources) > 0 {
+				// Fired early by our reckoning (a cheaper reload from a
+				// rank upgrade, or clock skew): restart the one timer.
+				best = 0
+			} else {
+				sources = append(sources, &source{ready: -1})
+				best = len(sources) - 1
+			}
+		}
+		s := sources[best]
+		firing := PowerFiring{Frame: f, ReadyFrame: s.ready, Source: best}
+		if s.ready >= 0 && s.ready <= f {
+			firing.DelayFrames = f - s.ready
+			tl.MeasuredFirings++
+			delaySum += firing.DelayFrames
+			reloadSum += tl.ReloadFrames
+			if firing.DelayFrames <= promptFireFrames {
+				tl.PromptFirings++
+			}
+		} else {
+			firing.ReadyFrame = -1
+		}
+		tl.Firings = append(tl.Firings, firing)
+		s.ready = f + tl.ReloadFrames
+	}
+
+	if tl.MeasuredFirings > 0 {
+		tl.MeanDelaySeconds = float64(delaySum) / float64(tl.MeasuredFirings) / framesPerSecond
+		if reloadSum+delaySum > 0 {
+			tl.Efficiency = float64(reloadSum) / float64(reloadSum+delaySum)
+		}
+	}
+	if tl.ReloadFrames > 0 {
+		for _, s := range sources {
+			if s.ready >= 0 && s.ready <= endFrame {
+				tl.MissedFirings += (endFrame-s.ready)/tl.ReloadFrames + 1
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package zhreplay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bill-rich/cncstats/pkg/iniparse"
+	"github.com/bill-rich/cncstats/pkg/statsfile"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/body"
+	"github.com/bill-rich/cncstats/pkg/zhreplay/object"
+)
+
+func powerOrder(frame int, player, power string) *body.BodyChunk {
+	return &body.BodyChunk{
+		TimeCode:   frame,
+		OrderCode:  1041,
+		PlayerName: player,
+		Details:    &object.Power{Name: power},
+	}
+}
+
+func TestBuildPowerTimelines(t *testing.T) {
+	powers := &iniparse.PowerStore{Power: []iniparse.Power{
+		{Name: "SuperweaponNeutronMissile", ReloadTime: 6 * time.Minute},
+		{Name: "SuperweaponDaisyCutter", ReloadTime: time.Minute, RequiredScience: "SCIENCE_DaisyCutter", SharedSyncedTimer: true},
+		{Name: "SpecialAbilityRangerCaptureBuilding"},
+	}}
+
+	const reload = 6 * 60 * framesPerSecond
+	v2 := &EnhancedReplayV2{
+		GameInfo: &GameInfoV2{FrameCount: 3*reload + 1000},
+		Summary:  []*PlayerSummaryV2{{Name: "nuker", Index: 1}},
+		Stats: &EnrichedStats{BuildEvents: []EnrichedBuildEvent{
+			{BuildEvent: statsfile.BuildEvent{Frame: 1000, Player: 1, Object: "ChinaNuclearMissileLauncher"}},
+		}},
+		Body: []*body.BodyChunk{
+			powerOrder(1000+reload+30, "nuker", "SuperweaponNeutronMissile"),    // 1s after ready
+			powerOrder(1000+2*reload+630, "nuker", "SuperweaponNeutronMissile"), // 20s late
+			powerOrder(5000, "nuker", "SuperweaponDaisyCutter"),
+			powerOrder(5000+1800, "nuker", "SuperweaponDaisyCutter"),
+			powerOrder(6000, "nuker", "SpecialAbilityRangerCaptureBuilding"),
+		},
+	}
+	// The store has no grantors linked, so the nuke's first shot can't be
+	// measured.
+	v2.BuildPowerTimelines(powers)
+	tls := v2.Summary[0].PowerTimelines
+	if len(tls) != 2 {
+		t.Fatalf("expected 2 timelines (ability excluded), got %d", len(tls))
+	}
+	nuke := tls[0]
+	if nuke.Category != PowerCategorySuperweapon || nuke.MeasuredFirings != 1 || nuke.Firings[0].ReadyFrame != -1 {
+		t.Errorf("unexpected unseeded nuke timeline: %+v", nuke)
+	}
+	daisy := tls[1]
+	if daisy.Category != PowerCategoryGeneral || daisy.MeasuredFirings != 1 || daisy.PromptFirings != 1 || daisy.Efficiency != 1 {
+		t.Errorf("unexpected daisy cutter timeline: %+v", daisy)
+	}
+
+	// With the launcher as grantor, its build event seeds the first shot.
+	tl := &PowerTimeline{Power: "SuperweaponNeutronMissile", ReloadFrames: reload, GrantedBy: []string{"ChinaNuclearMissileLauncher"}}
+	tl.fill([]int{1000 + reload + 30, 1000 + 2*reload + 630}, v2.sourceReadyFrames(1, tl), false, 3*reload+1000)
+	if tl.MeasuredFirings != 2 || tl.PromptFirings != 1 {
+		t.Fatalf("expected 2 measured, 1 prompt: %+v", tl)
+	}
+	if tl.Firings[1].DelayFrames != 600 {
+		t.Errorf("expected 600 frame delay, got %d", tl.Firings[1].DelayFrames)
+	}
+	if tl.MeanDelaySeconds != 10.5 {
+		t.Errorf("expected mean delay 10.5s, got %v", tl.MeanDelaySeconds)
+	}
+	// Ready again at 1000+3*reload+630, after the game ended.
+	if tl.MissedFirings != 0 {
+		t.Errorf("expected no missed firings, got %d", tl.MissedFirings)
+	}
+}
+
+func TestPowerTimelineSeparateSources(t *testing.T) {
+	// Two cannons fire 10s apart: the second must be a new source, not a
+	// shot fired before the first had reloaded.
+	tl := &PowerTimeline{ReloadFrames: 1000}
+	tl.fill([]int{100, 400, 1100, 1400}, nil, false, 2500)
+	want := []int{0, 1, 0, 1}
+	for i, f := range tl.Firings {
+		if f.Source != want[i] {
+			t.Errorf("firing %d: expected source %d, got %d", i, want[i], f.Source)
+		}
+	}
+	if tl.MeasuredFirings != 2 || tl.Efficiency != 1 {
+		t.Errorf("unexpected metrics: %+v", tl)
+	}
+	// Both sources ready again at 2100 and 2400: one missed shot each.
+	if tl.MissedFirings != 2 {
+		t.Errorf("expected 2 missed firings, got %d", tl.MissedFirings)
+	}
+}